@@ -3,11 +3,17 @@ package agent
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/phinze/sophon/tmux"
@@ -21,6 +27,21 @@ type Config struct {
 	DaemonURL    string
 	ClaudeDir    string
 	NodeName     string
+	Version      string // sophon version reported in heartbeats
+
+	// TranscriptPathTemplate overrides where transcripts are looked up when
+	// the hook did not report a path. Empty means
+	// transcript.DefaultPathTemplate.
+	TranscriptPathTemplate string
+
+	// SendKeysDelay pauses between sent text and its Enter key press, for
+	// terminals where the agent's TUI drops keys; 0 sends them back to back.
+	SendKeysDelay time.Duration
+
+	// ArchiveAfter gzips transcripts under ClaudeDir/projects that haven't
+	// been written for this long and whose sessions the daemon has seen
+	// stop; 0 disables archiving.
+	ArchiveAfter time.Duration
 }
 
 // Agent is the per-node agent HTTP server.
@@ -30,37 +51,58 @@ type Agent struct {
 
 	// Injectable for testing
 	paneFocused    func(pane string) bool
-	sendKeys       func(pane, text string) error
+	paneExists     func(pane string) bool
+	sendKeys       func(pane, text string, enter bool) error
 	listAgentPanes func() (map[string]bool, error)
 	listPaneTitles func() (map[string]string, error)
+	paneInfo       func(pane string) (tmux.Pane, error)
+	liveSessions   func() (map[string]bool, error)
+	httpClient     *http.Client
 }
 
 // New creates a new Agent.
 func New(cfg Config, logger *slog.Logger) *Agent {
-	return &Agent{
+	a := &Agent{
 		cfg:            cfg,
 		logger:         logger,
 		paneFocused:    tmux.PaneFocused,
-		sendKeys:       tmux.SendKeys,
+		paneExists:     tmux.PaneExists,
+		sendKeys:       tmux.Sender{EnterDelay: cfg.SendKeysDelay}.SendKeys,
 		listAgentPanes: tmux.ListAgentPanes,
 		listPaneTitles: tmux.ListPaneTitles,
+		paneInfo:       tmux.PaneInfo,
+		httpClient:     &http.Client{Timeout: 5 * time.Second},
 	}
+	a.liveSessions = a.fetchLiveSessions
+	return a
 }
 
 // Run starts the agent HTTP server and begins heartbeat registration.
 func (a *Agent) Run() error {
 	go a.heartbeat()
+	if a.cfg.ArchiveAfter > 0 {
+		go a.archiveLoop()
+	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /api/transcript/{session_id}", a.handleTranscript)
+	mux.HandleFunc("GET /api/transcript-file", a.handleTranscriptFile)
+	mux.HandleFunc("GET /api/transcript-raw/{session_id}", a.handleRawTranscript)
 	mux.HandleFunc("GET /api/summary/{session_id}", a.handleSummary)
+	mux.HandleFunc("GET /api/tool-call/{session_id}/{tool_use_id}", a.handleToolCall)
 	mux.HandleFunc("POST /api/send-keys", a.handleSendKeys)
 	mux.HandleFunc("GET /api/pane-focused", a.handlePaneFocused)
+	mux.HandleFunc("GET /api/pane-info", a.handlePaneInfo)
 	mux.HandleFunc("GET /api/health", a.handleHealth)
 
 	addr := fmt.Sprintf("%s:%d", a.listenHost(), a.cfg.Port)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
 	a.logger.Info("starting sophon agent", "addr", addr, "node", a.cfg.NodeName)
-	return http.ListenAndServe(addr, mux)
+	go a.selfCheck()
+	return http.Serve(ln, mux)
 }
 
 func (a *Agent) handleTranscript(w http.ResponseWriter, r *http.Request) {
@@ -68,16 +110,123 @@ func (a *Agent) handleTranscript(w http.ResponseWriter, r *http.Request) {
 	cwd := r.URL.Query().Get("cwd")
 
 	path := a.transcriptPath(r.URL.Query().Get("path"), cwd, sessionID)
+
+	// Stat before reading so the ETag never claims newer content than we send.
+	info, statErr := transcript.Stat(path)
+	if statErr == nil {
+		etag := transcriptETag(info)
+		w.Header().Set("ETag", etag)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
 	tr, err := transcript.Read(path)
 	if err != nil {
 		a.logger.Debug("transcript read failed", "path", path, "error", err)
-		tr = &transcript.Transcript{}
+		tr = transcript.Empty()
+	}
+	for _, warning := range tr.Warnings {
+		a.logger.Warn("transcript record skipped", "path", path, "warning", warning)
+	}
+	if r.URL.Query().Get("debug") == "1" {
+		tr.Debug = &transcript.Debug{Path: path, Exists: statErr == nil}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tr)
+}
+
+// handleTranscriptFile reads the transcript at an explicit path, for
+// debugging lookups that the slug or path template gets wrong. The path must
+// resolve to a file inside the Claude directory, so the endpoint can't be
+// used to read arbitrary files.
+func (a *Agent) handleTranscriptFile(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if !filepath.IsAbs(path) {
+		http.Error(w, "path must be absolute", http.StatusBadRequest)
+		return
+	}
+	// Only the resolved path is checked: a link inside the directory mustn't
+	// point the read elsewhere, and a Claude directory reached through a
+	// symlink (like macOS's /var) must still match itself.
+	resolved, ok := transcript.ResolveIn(a.cfg.ClaudeDir, path)
+	if !ok {
+		http.Error(w, "path is outside the Claude directory", http.StatusForbidden)
+		return
 	}
 
+	tr, err := transcript.Read(resolved)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.Error(w, "transcript not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		a.logger.Debug("transcript file read failed", "path", resolved, "error", err)
+		http.Error(w, "reading transcript: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, warning := range tr.Warnings {
+		a.logger.Warn("transcript record skipped", "path", resolved, "warning", warning)
+	}
+	tr.Debug = &transcript.Debug{Path: resolved, Exists: true}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(tr)
 }
 
+// handleRawTranscript streams the session's JSONL as written, for tools that
+// want the original records rather than the display model.
+func (a *Agent) handleRawTranscript(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("session_id")
+	path := a.transcriptPath(r.URL.Query().Get("path"), r.URL.Query().Get("cwd"), sessionID)
+
+	f, err := transcript.OpenRaw(a.cfg.ClaudeDir, path)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.Error(w, "transcript not found", http.StatusNotFound)
+		return
+	} else if errors.Is(err, transcript.ErrNotTranscript) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	} else if errors.Is(err, transcript.ErrTooLarge) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		a.logger.Warn("raw transcript open failed", "path", path, "error", err)
+		http.Error(w, "reading transcript failed", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", rawTranscriptType)
+	if _, err := io.Copy(w, f); err != nil {
+		a.logger.Debug("raw transcript copy failed", "path", path, "error", err)
+		// Break the connection so the daemon sees a failed stream, not a
+		// complete but truncated transcript.
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// rawTranscriptType is the content type of raw JSONL transcripts.
+const rawTranscriptType = "application/x-ndjson"
+
+// transcriptETag identifies a transcript's content by its modification time
+// and size. Transcripts are append-only, so either changes on every write.
+func transcriptETag(info os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
 func (a *Agent) handleSummary(w http.ResponseWriter, r *http.Request) {
 	sessionID := r.PathValue("session_id")
 	cwd := r.URL.Query().Get("cwd")
@@ -86,7 +235,7 @@ func (a *Agent) handleSummary(w http.ResponseWriter, r *http.Request) {
 	tr, err := transcript.Read(path)
 	if err != nil {
 		a.logger.Debug("summary transcript read failed", "path", path, "error", err)
-		tr = &transcript.Transcript{}
+		tr = transcript.Empty()
 	}
 
 	summary := transcript.ExtractSummary(tr)
@@ -94,6 +243,27 @@ func (a *Agent) handleSummary(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(summary)
 }
 
+// handleToolCall returns one tool call's full input and result. A missing
+// transcript is reported the same as a missing call.
+func (a *Agent) handleToolCall(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("session_id")
+	cwd := r.URL.Query().Get("cwd")
+
+	path := a.transcriptPath(r.URL.Query().Get("path"), cwd, sessionID)
+	call, err := transcript.FindToolCall(path, r.PathValue("tool_use_id"))
+	if errors.Is(err, transcript.ErrToolCallNotFound) || errors.Is(err, fs.ErrNotExist) {
+		http.Error(w, "tool call not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		a.logger.Debug("tool call transcript read failed", "path", path, "error", err)
+		http.Error(w, "reading transcript: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(call)
+}
+
 // transcriptPath returns the JSONL path to read. It prefers the path Claude
 // Code reported via its hooks (provided), falling back to recomputing it from
 // the cwd slug for sessions registered before the path was captured.
@@ -101,37 +271,81 @@ func (a *Agent) transcriptPath(provided, cwd, sessionID string) string {
 	if provided != "" {
 		return provided
 	}
-	return transcript.TranscriptPath(a.cfg.ClaudeDir, cwd, sessionID)
+	return transcript.ExpandPathTemplate(a.cfg.TranscriptPathTemplate, a.cfg.ClaudeDir, cwd, sessionID)
 }
 
 func (a *Agent) handleSendKeys(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Pane string `json:"pane"`
-		Text string `json:"text"`
+		Pane  string `json:"pane"`
+		Text  string `json:"text"`
+		Enter *bool  `json:"enter"` // defaults to true
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "bad request", http.StatusBadRequest)
 		return
 	}
+	enter := req.Enter == nil || *req.Enter
+	pane, err := tmux.NormalizePane(req.Pane)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// A closed pane is reported distinctly so the daemon can stop the session
+	// rather than surface a raw tmux error.
+	if !a.paneExists(pane) {
+		a.logger.Warn("send-keys target pane is gone", "pane", pane)
+		http.Error(w, "pane no longer exists", http.StatusGone)
+		return
+	}
 
-	if err := a.sendKeys(req.Pane, req.Text); err != nil {
-		a.logger.Error("send-keys failed", "error", err, "pane", req.Pane)
+	if err := a.sendKeys(pane, req.Text, enter); err != nil {
+		a.logger.Error("send-keys failed", "error", err, "pane", pane)
 		http.Error(w, "send-keys failed: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	a.logger.Info("send-keys success", "pane", req.Pane, "text_len", len(req.Text))
+	a.logger.Info("send-keys success", "pane", pane, "text_len", len(req.Text), "enter", enter)
 	w.WriteHeader(http.StatusOK)
 }
 
 func (a *Agent) handlePaneFocused(w http.ResponseWriter, r *http.Request) {
-	pane := r.URL.Query().Get("pane")
+	pane, err := tmux.NormalizePane(r.URL.Query().Get("pane"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	focused := a.paneFocused(pane)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]bool{"focused": focused})
 }
 
+func (a *Agent) handlePaneInfo(w http.ResponseWriter, r *http.Request) {
+	pane, err := tmux.NormalizePane(r.URL.Query().Get("pane"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !a.paneExists(pane) {
+		http.Error(w, "pane no longer exists", http.StatusGone)
+		return
+	}
+	info, err := a.paneInfo(pane)
+	if err != nil {
+		a.logger.Debug("pane-info failed", "error", err, "pane", pane)
+		http.Error(w, "pane-info failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// nodeHeader identifies which agent answered a health check, so the startup
+// self-check can tell "reachable" apart from "reachable, but someone else".
+const nodeHeader = "X-Sophon-Node"
+
 func (a *Agent) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(nodeHeader, a.cfg.NodeName)
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprintln(w, "ok")
 }
@@ -186,10 +400,38 @@ func resolveAdvertiseURL(raw string, port int, lookup func(string) ([]net.IP, er
 	return raw
 }
 
+// selfCheck requests /api/health at the URL this agent advertises to the
+// daemon and warns if it does not come back to this agent. A misconfigured
+// Tailscale or hostname setup otherwise only shows up later as the daemon
+// treating the node as offline. It reports whether the check passed.
+func (a *Agent) selfCheck() bool {
+	agentURL := resolveAdvertiseURL(a.cfg.AdvertiseURL, a.cfg.Port, net.LookupIP, a.logger)
+	resp, err := a.httpClient.Get(agentURL + "/api/health")
+	if err != nil {
+		a.logger.Warn("advertised URL is unreachable; the daemon may not reach this agent", "url", agentURL, "error", err)
+		return false
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		a.logger.Warn("advertised URL health check failed; the daemon may not reach this agent", "url", agentURL, "status", resp.StatusCode)
+		return false
+	}
+	if node := resp.Header.Get(nodeHeader); node != a.cfg.NodeName {
+		a.logger.Warn("advertised URL is served by a different agent", "url", agentURL, "node", node)
+		return false
+	}
+	a.logger.Debug("advertised URL self-check passed", "url", agentURL)
+	return true
+}
+
+// HeartbeatInterval is how often the agent registers with the daemon. The
+// daemon's agent stale timeout must exceed it.
+const HeartbeatInterval = 30 * time.Second
+
 // heartbeat registers with the daemon periodically.
 func (a *Agent) heartbeat() {
 	a.register()
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(HeartbeatInterval)
 	defer ticker.Stop()
 	for range ticker.C {
 		a.register()
@@ -200,6 +442,7 @@ func (a *Agent) heartbeat() {
 type heartbeatPayload struct {
 	NodeName   string            `json:"node_name"`
 	URL        string            `json:"url"`
+	Version    string            `json:"version,omitempty"`
 	AlivePanes []string          `json:"alive_panes,omitempty"`
 	PaneTitles map[string]string `json:"pane_titles,omitempty"`
 }
@@ -214,6 +457,7 @@ func (a *Agent) register() {
 	payload := heartbeatPayload{
 		NodeName: a.cfg.NodeName,
 		URL:      agentURL,
+		Version:  a.cfg.Version,
 	}
 
 	// Detect supported agent panes; if detection fails, omit alive_panes.
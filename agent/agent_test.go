@@ -1,18 +1,23 @@
 package agent
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/phinze/sophon/tmux"
 	"github.com/phinze/sophon/transcript"
 )
 
@@ -24,6 +29,7 @@ func newTestAgent(t *testing.T) *Agent {
 		NodeName:  "test-node",
 	}
 	a := New(cfg, logger)
+	a.paneExists = func(pane string) bool { return true }
 	return a
 }
 
@@ -41,6 +47,74 @@ func TestHealthEndpoint(t *testing.T) {
 	}
 }
 
+func TestHealthEndpointIdentifiesNode(t *testing.T) {
+	a := newTestAgent(t)
+	w := httptest.NewRecorder()
+	a.handleHealth(w, httptest.NewRequest("GET", "/api/health", nil))
+
+	if got := w.Header().Get(nodeHeader); got != "test-node" {
+		t.Errorf("%s = %q, want %q", nodeHeader, got, "test-node")
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestSelfCheck(t *testing.T) {
+	healthy := func(node string) roundTripFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			h := http.Header{}
+			h.Set(nodeHeader, node)
+			return &http.Response{StatusCode: http.StatusOK, Header: h, Body: io.NopCloser(strings.NewReader("ok\n"))}, nil
+		}
+	}
+	tests := []struct {
+		name     string
+		rt       roundTripFunc
+		wantOK   bool
+		wantWarn string
+	}{
+		{"reachable", healthy("test-node"), true, ""},
+		{"unreachable", func(*http.Request) (*http.Response, error) {
+			return nil, errors.New("connection refused")
+		}, false, "advertised URL is unreachable"},
+		{"unhealthy", func(*http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusBadGateway, Body: io.NopCloser(strings.NewReader(""))}, nil
+		}, false, "health check failed"},
+		{"other agent", healthy("other-node"), false, "served by a different agent"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			var requested string
+			a := New(Config{
+				Port:         2588,
+				AdvertiseURL: "http://100.64.0.1:2588",
+				NodeName:     "test-node",
+			}, slog.New(slog.NewTextHandler(&logs, nil)))
+			a.httpClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				requested = r.URL.String()
+				return tt.rt(r)
+			})}
+
+			if got := a.selfCheck(); got != tt.wantOK {
+				t.Errorf("selfCheck() = %v, want %v", got, tt.wantOK)
+			}
+			if requested != "http://100.64.0.1:2588/api/health" {
+				t.Errorf("requested %q, want advertised health URL", requested)
+			}
+			warned := strings.Contains(logs.String(), "level=WARN")
+			if tt.wantWarn == "" && warned {
+				t.Errorf("unexpected warning: %s", logs.String())
+			}
+			if tt.wantWarn != "" && !strings.Contains(logs.String(), tt.wantWarn) {
+				t.Errorf("logs = %q, want warning containing %q", logs.String(), tt.wantWarn)
+			}
+		})
+	}
+}
+
 func TestPaneFocusedEndpoint(t *testing.T) {
 	a := newTestAgent(t)
 	a.paneFocused = func(pane string) bool { return pane == "%5" }
@@ -62,12 +136,56 @@ func TestPaneFocusedEndpoint(t *testing.T) {
 	}
 }
 
+func TestPaneInfoEndpoint(t *testing.T) {
+	a := newTestAgent(t)
+	var asked string
+	a.paneInfo = func(pane string) (tmux.Pane, error) {
+		asked = pane
+		return tmux.Pane{Title: "✳ Fix the flaky test", WindowName: "sophon"}, nil
+	}
+
+	w := httptest.NewRecorder()
+	a.handlePaneInfo(w, httptest.NewRequest("GET", "/api/pane-info?pane=%253", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d, want 200", w.Code)
+	}
+	if asked != "%3" {
+		t.Errorf("paneInfo called with %q, want %%3", asked)
+	}
+	var got map[string]string
+	json.NewDecoder(w.Body).Decode(&got)
+	if got["pane_title"] != "✳ Fix the flaky test" || got["window_name"] != "sophon" {
+		t.Errorf("body = %v", got)
+	}
+}
+
+func TestPaneInfoEndpointErrors(t *testing.T) {
+	a := newTestAgent(t)
+	a.paneInfo = func(pane string) (tmux.Pane, error) {
+		return tmux.Pane{}, errors.New("no server running")
+	}
+	w := httptest.NewRecorder()
+	a.handlePaneInfo(w, httptest.NewRequest("GET", "/api/pane-info?pane=%253", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("tmux failure: got %d, want 500", w.Code)
+	}
+
+	a.paneExists = func(pane string) bool { return false }
+	w = httptest.NewRecorder()
+	a.handlePaneInfo(w, httptest.NewRequest("GET", "/api/pane-info?pane=%253", nil))
+	if w.Code != http.StatusGone {
+		t.Errorf("missing pane: got %d, want 410", w.Code)
+	}
+}
+
 func TestSendKeysEndpoint(t *testing.T) {
 	a := newTestAgent(t)
 	var sentPane, sentText string
-	a.sendKeys = func(pane, text string) error {
+	var sentEnter bool
+	a.sendKeys = func(pane, text string, enter bool) error {
 		sentPane = pane
 		sentText = text
+		sentEnter = enter
 		return nil
 	}
 
@@ -85,11 +203,56 @@ func TestSendKeysEndpoint(t *testing.T) {
 	if sentText != "hello" {
 		t.Errorf("text = %q, want hello", sentText)
 	}
+	if !sentEnter {
+		t.Error("enter should default to true")
+	}
+}
+
+func TestSendKeysEndpointInvalidPane(t *testing.T) {
+	a := newTestAgent(t)
+	a.sendKeys = func(pane, text string, enter bool) error {
+		t.Errorf("sendKeys called with invalid pane %q", pane)
+		return nil
+	}
+
+	for _, pane := range []string{"5", ""} {
+		body := strings.NewReader(`{"pane":"` + pane + `","text":"hello"}`)
+		req := httptest.NewRequest("POST", "/api/send-keys", body)
+		w := httptest.NewRecorder()
+		a.handleSendKeys(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("pane %q: got %d, want 400", pane, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), "invalid tmux pane id") {
+			t.Errorf("pane %q: body = %q, want a pane id error", pane, w.Body)
+		}
+	}
+}
+
+func TestSendKeysEndpointNoEnter(t *testing.T) {
+	a := newTestAgent(t)
+	sentEnter := true
+	a.sendKeys = func(pane, text string, enter bool) error {
+		sentEnter = enter
+		return nil
+	}
+
+	body := strings.NewReader(`{"pane":"%5","text":"draft","enter":false}`)
+	req := httptest.NewRequest("POST", "/api/send-keys", body)
+	w := httptest.NewRecorder()
+	a.handleSendKeys(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d, want 200", w.Code)
+	}
+	if sentEnter {
+		t.Error("enter = true, want false")
+	}
 }
 
 func TestSendKeysEndpointError(t *testing.T) {
 	a := newTestAgent(t)
-	a.sendKeys = func(pane, text string) error {
+	a.sendKeys = func(pane, text string, enter bool) error {
 		return fmt.Errorf("tmux not running")
 	}
 
@@ -103,6 +266,31 @@ func TestSendKeysEndpointError(t *testing.T) {
 	}
 }
 
+func TestSendKeysEndpointPaneGone(t *testing.T) {
+	a := newTestAgent(t)
+	a.paneExists = func(pane string) bool { return false }
+	called := false
+	a.sendKeys = func(pane, text string, enter bool) error {
+		called = true
+		return nil
+	}
+
+	body := strings.NewReader(`{"pane":"%5","text":"hello"}`)
+	req := httptest.NewRequest("POST", "/api/send-keys", body)
+	w := httptest.NewRecorder()
+	a.handleSendKeys(w, req)
+
+	if w.Code != http.StatusGone {
+		t.Fatalf("got %d, want 410", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "pane no longer exists") {
+		t.Errorf("body = %q", w.Body.String())
+	}
+	if called {
+		t.Error("sendKeys should not run for a missing pane")
+	}
+}
+
 func TestTranscriptEndpoint(t *testing.T) {
 	a := newTestAgent(t)
 
@@ -145,6 +333,78 @@ func TestTranscriptEndpoint(t *testing.T) {
 	}
 }
 
+func TestTranscriptEndpointETag(t *testing.T) {
+	a := newTestAgent(t)
+	path := filepath.Join(t.TempDir(), "sess.jsonl")
+	line := `{"type":"user","timestamp":"2026-01-01T00:00:00.000Z","message":{"role":"user","content":"Hello"}}` + "\n"
+	if err := os.WriteFile(path, []byte(line), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/transcript/sess?path="+url.QueryEscape(path), nil)
+		req.SetPathValue("session_id", "sess")
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		a.handleTranscript(w, req)
+		return w
+	}
+
+	first := get("")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("first fetch: code %d, etag %q", first.Code, etag)
+	}
+
+	// Unchanged file: 304 with no body
+	w := get(etag)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("unchanged: got %d, want 304", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("304 should have no body, got %q", w.Body.String())
+	}
+
+	// Appending changes the size, so the old ETag no longer matches.
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString(`{"type":"assistant","timestamp":"2026-01-01T00:00:01.000Z","message":{"role":"assistant","content":[{"type":"text","text":"Hi"}]}}` + "\n")
+	f.Close()
+
+	w = get(etag)
+	if w.Code != http.StatusOK {
+		t.Fatalf("changed: got %d, want 200", w.Code)
+	}
+	if got := w.Header().Get("ETag"); got == etag || got == "" {
+		t.Errorf("changed file should get a new ETag, got %q (old %q)", got, etag)
+	}
+	var tr transcript.Transcript
+	json.NewDecoder(w.Body).Decode(&tr)
+	if len(tr.Messages) != 2 {
+		t.Errorf("got %d messages, want 2", len(tr.Messages))
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`"a-1"`, true},
+		{`W/"a-1"`, true},
+		{`"b-2", "a-1"`, true},
+		{`*`, true},
+		{`"b-2"`, false},
+		{``, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, `"a-1"`); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
 func TestHeartbeatIncludesAlivePanes(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
 
@@ -160,6 +420,7 @@ func TestHeartbeatIncludesAlivePanes(t *testing.T) {
 			Port:      2588,
 			DaemonURL: daemon.URL,
 			NodeName:  "test-node",
+			Version:   "0.2.0",
 		},
 		logger: logger,
 		listAgentPanes: func() (map[string]bool, error) {
@@ -172,6 +433,9 @@ func TestHeartbeatIncludesAlivePanes(t *testing.T) {
 	if receivedPayload.NodeName != "test-node" {
 		t.Errorf("NodeName = %q", receivedPayload.NodeName)
 	}
+	if receivedPayload.Version != "0.2.0" {
+		t.Errorf("Version = %q, want 0.2.0", receivedPayload.Version)
+	}
 	if len(receivedPayload.AlivePanes) != 2 {
 		t.Fatalf("AlivePanes len = %d, want 2", len(receivedPayload.AlivePanes))
 	}
@@ -256,6 +520,20 @@ func TestTranscriptPathPrefersProvided(t *testing.T) {
 	}
 }
 
+func TestTranscriptPathTemplate(t *testing.T) {
+	a := newTestAgent(t)
+	a.cfg.TranscriptPathTemplate = "{claudeDir}/archive/{sessionID}.jsonl"
+
+	got := a.transcriptPath("", "/home/u/proj", "sid")
+	if want := a.cfg.ClaudeDir + "/archive/sid.jsonl"; got != want {
+		t.Errorf("templated fallback = %q, want %q", got, want)
+	}
+	// A hook-provided path still wins over the template.
+	if got := a.transcriptPath("/explicit/path.jsonl", "/home/u/proj", "sid"); got != "/explicit/path.jsonl" {
+		t.Errorf("got %q, want the provided path", got)
+	}
+}
+
 func TestTranscriptEndpointMissingFile(t *testing.T) {
 	a := newTestAgent(t)
 
@@ -276,3 +554,289 @@ func TestTranscriptEndpointMissingFile(t *testing.T) {
 		t.Errorf("expected null/empty messages, got %v", result.Messages)
 	}
 }
+
+func TestTranscriptFileEndpoint(t *testing.T) {
+	a := newTestAgent(t)
+	inside := filepath.Join(a.cfg.ClaudeDir, "projects", "odd-slug", "sess.jsonl")
+	os.MkdirAll(filepath.Dir(inside), 0o755)
+	line := `{"type":"user","timestamp":"2026-01-01T00:00:00.000Z","message":{"role":"user","content":"Hello"}}` + "\n"
+	os.WriteFile(inside, []byte(line), 0o644)
+	outside := filepath.Join(t.TempDir(), "sess.jsonl")
+	os.WriteFile(outside, []byte(line), 0o644)
+	link := filepath.Join(a.cfg.ClaudeDir, "projects", "link.jsonl")
+	os.Symlink(outside, link)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/transcript-file?path="+url.QueryEscape(path), nil)
+		w := httptest.NewRecorder()
+		a.handleTranscriptFile(w, req)
+		return w
+	}
+
+	w := get(inside)
+	if w.Code != http.StatusOK {
+		t.Fatalf("in-dir path: got %d, want 200: %s", w.Code, w.Body)
+	}
+	var result struct {
+		Messages []any `json:"messages"`
+	}
+	json.NewDecoder(w.Body).Decode(&result)
+	if len(result.Messages) != 1 {
+		t.Errorf("got %d messages, want 1", len(result.Messages))
+	}
+
+	for _, path := range []string{
+		outside,
+		filepath.Join(a.cfg.ClaudeDir, "..", filepath.Base(filepath.Dir(outside)), "sess.jsonl"),
+		link,
+	} {
+		if w := get(path); w.Code != http.StatusForbidden {
+			t.Errorf("%s: got %d, want 403", path, w.Code)
+		}
+	}
+	if w := get("projects/odd-slug/sess.jsonl"); w.Code != http.StatusBadRequest {
+		t.Errorf("relative path: got %d, want 400", w.Code)
+	}
+	if w := get(filepath.Join(a.cfg.ClaudeDir, "missing.jsonl")); w.Code != http.StatusNotFound {
+		t.Errorf("missing file: got %d, want 404", w.Code)
+	}
+}
+
+func TestTranscriptFileEndpointThroughSymlinkedDir(t *testing.T) {
+	a := newTestAgent(t)
+	realDir := a.cfg.ClaudeDir
+	path := filepath.Join(realDir, "projects", "p", "sess.jsonl")
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	os.WriteFile(path, []byte(`{"type":"user","timestamp":"2026-01-01T00:00:00.000Z","message":{"role":"user","content":"Hello"}}`+"\n"), 0o644)
+	// Like macOS's /var -> /private/var: the configured directory and the
+	// paths hooks report may each name it through a link.
+	linked := filepath.Join(t.TempDir(), "claude")
+	if err := os.Symlink(realDir, linked); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	for _, tc := range []struct{ dir, path string }{
+		{linked, path},
+		{realDir, filepath.Join(linked, "projects", "p", "sess.jsonl")},
+		{linked, filepath.Join(linked, "projects", "p", "sess.jsonl")},
+	} {
+		a.cfg.ClaudeDir = tc.dir
+		req := httptest.NewRequest("GET", "/api/transcript-file?path="+url.QueryEscape(tc.path), nil)
+		w := httptest.NewRecorder()
+		a.handleTranscriptFile(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("dir %s, path %s: got %d, want 200", tc.dir, tc.path, w.Code)
+		}
+	}
+}
+
+func TestTranscriptEndpointDebug(t *testing.T) {
+	a := newTestAgent(t)
+
+	debugFor := func(query string) *transcript.Debug {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/transcript/nonexistent?cwd=/tmp/test"+query, nil)
+		req.SetPathValue("session_id", "nonexistent")
+		w := httptest.NewRecorder()
+		a.handleTranscript(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("got %d, want 200", w.Code)
+		}
+		var result transcript.Transcript
+		json.NewDecoder(w.Body).Decode(&result)
+		return result.Debug
+	}
+
+	if d := debugFor(""); d != nil {
+		t.Errorf("debug = %+v without ?debug=1, want none", d)
+	}
+	d := debugFor("&debug=1")
+	if d == nil {
+		t.Fatal("debug missing with ?debug=1")
+	}
+	if want := transcript.TranscriptPath(a.cfg.ClaudeDir, "/tmp/test", "nonexistent"); d.Path != want {
+		t.Errorf("debug path = %q, want %q", d.Path, want)
+	}
+	if d.Exists {
+		t.Error("debug should report the missing file as not existing")
+	}
+}
+
+func TestSummaryEndpointIncludesCounts(t *testing.T) {
+	a := newTestAgent(t)
+
+	path := filepath.Join(t.TempDir(), "s1.jsonl")
+	jsonl := `{"type":"user","timestamp":"2026-01-01T00:00:00.000Z","message":{"role":"user","content":"Run the tests"}}
+{"type":"assistant","timestamp":"2026-01-01T00:00:01.000Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"go test ./..."}}]}}
+{"type":"assistant","timestamp":"2026-01-01T00:00:02.000Z","message":{"role":"assistant","content":[{"type":"text","text":"All green."}]}}
+`
+	os.WriteFile(path, []byte(jsonl), 0o644)
+
+	req := httptest.NewRequest("GET", "/api/summary/s1?path="+url.QueryEscape(path), nil)
+	req.SetPathValue("session_id", "s1")
+	w := httptest.NewRecorder()
+	a.handleSummary(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d, want 200", w.Code)
+	}
+	var summary transcript.SessionSummary
+	if err := json.NewDecoder(w.Body).Decode(&summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.MessageCount != 3 || summary.ToolUseCount != 1 {
+		t.Errorf("counts = %d messages, %d tool uses; want 3, 1", summary.MessageCount, summary.ToolUseCount)
+	}
+	if summary.Topic != "Run the tests" {
+		t.Errorf("Topic = %q", summary.Topic)
+	}
+}
+
+func TestToolCallEndpoint(t *testing.T) {
+	a := newTestAgent(t)
+
+	path := filepath.Join(t.TempDir(), "s1.jsonl")
+	jsonl := `{"type":"assistant","timestamp":"2026-01-01T00:00:01.000Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"go test ./..."}}]}}
+{"type":"user","timestamp":"2026-01-01T00:00:02.000Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"ok"}]}}
+`
+	os.WriteFile(path, []byte(jsonl), 0o644)
+
+	get := func(toolUseID, transcriptPath string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/tool-call/s1/"+toolUseID+"?path="+url.QueryEscape(transcriptPath), nil)
+		req.SetPathValue("session_id", "s1")
+		req.SetPathValue("tool_use_id", toolUseID)
+		w := httptest.NewRecorder()
+		a.handleToolCall(w, req)
+		return w
+	}
+
+	w := get("t1", path)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d, want 200", w.Code)
+	}
+	var call transcript.ToolCall
+	if err := json.NewDecoder(w.Body).Decode(&call); err != nil {
+		t.Fatal(err)
+	}
+	if call.Name != "Bash" || call.Result != "ok" {
+		t.Errorf("call = %+v", call)
+	}
+
+	if w := get("t2", path); w.Code != http.StatusNotFound {
+		t.Errorf("unknown tool call: got %d, want 404", w.Code)
+	}
+	if w := get("t1", filepath.Join(t.TempDir(), "missing.jsonl")); w.Code != http.StatusNotFound {
+		t.Errorf("missing transcript: got %d, want 404", w.Code)
+	}
+}
+
+func TestArchiveTranscripts(t *testing.T) {
+	a := newTestAgent(t)
+	a.cfg.ArchiveAfter = 30 * 24 * time.Hour
+
+	projectDir := filepath.Join(a.cfg.ClaudeDir, "projects", "-home-user-project")
+	os.MkdirAll(projectDir, 0o755)
+	jsonl := `{"type":"user","timestamp":"2026-01-01T00:00:00.000Z","message":{"role":"user","content":"Hello"}}
+`
+	oldPath := filepath.Join(projectDir, "old.jsonl")
+	recentPath := filepath.Join(projectDir, "recent.jsonl")
+	idlePath := filepath.Join(projectDir, "idle.jsonl")
+	os.WriteFile(oldPath, []byte(jsonl), 0o644)
+	os.WriteFile(recentPath, []byte(jsonl), 0o644)
+	os.WriteFile(idlePath, []byte(jsonl), 0o644)
+	now := time.Now()
+	os.Chtimes(oldPath, now.Add(-31*24*time.Hour), now.Add(-31*24*time.Hour))
+	os.Chtimes(idlePath, now.Add(-31*24*time.Hour), now.Add(-31*24*time.Hour))
+
+	// Without the daemon's session list, nothing is archived.
+	a.liveSessions = func() (map[string]bool, error) { return nil, errors.New("daemon down") }
+	a.archiveTranscripts(now)
+	if _, err := os.Stat(oldPath); err != nil {
+		t.Fatalf("archived without knowing which sessions are live: %v", err)
+	}
+
+	a.liveSessions = func() (map[string]bool, error) { return map[string]bool{"idle": true}, nil }
+	a.archiveTranscripts(now)
+
+	if _, err := os.Stat(oldPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("old transcript still present: %v", err)
+	}
+	if _, err := os.Stat(oldPath + transcript.ArchiveExt); err != nil {
+		t.Errorf("old transcript not archived: %v", err)
+	}
+	if data, err := os.ReadFile(recentPath); err != nil || string(data) != jsonl {
+		t.Errorf("recent transcript changed: %q, %v", data, err)
+	}
+	if _, err := os.Stat(recentPath + transcript.ArchiveExt); err == nil {
+		t.Error("recent transcript should not be archived")
+	}
+	if _, err := os.Stat(idlePath + transcript.ArchiveExt); err == nil {
+		t.Error("an idle but live session's transcript should not be archived")
+	}
+
+	// The archived session is still served.
+	req := httptest.NewRequest("GET", "/api/transcript/old?cwd=/home/user/project", nil)
+	req.SetPathValue("session_id", "old")
+	w := httptest.NewRecorder()
+	a.handleTranscript(w, req)
+	var tr transcript.Transcript
+	json.NewDecoder(w.Body).Decode(&tr)
+	if len(tr.Messages) != 1 || w.Header().Get("ETag") == "" {
+		t.Errorf("archived transcript: %d messages, ETag %q; want 1 message and an ETag", len(tr.Messages), w.Header().Get("ETag"))
+	}
+}
+
+func TestRawTranscriptEndpoint(t *testing.T) {
+	a := newTestAgent(t)
+
+	projectDir := filepath.Join(a.cfg.ClaudeDir, "projects", "-home-user-project")
+	os.MkdirAll(projectDir, 0o755)
+	// Records the display model drops must come through untouched.
+	jsonl := `{"type":"summary","summary":"Greeting"}
+{"type":"user","timestamp":"2026-01-01T00:00:00.000Z","message":{"role":"user","content":"Hello"}}
+`
+	os.WriteFile(filepath.Join(projectDir, "s1.jsonl"), []byte(jsonl), 0o644)
+
+	get := func(sessionID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/transcript-raw/"+sessionID+"?cwd=/home/user/project", nil)
+		req.SetPathValue("session_id", sessionID)
+		w := httptest.NewRecorder()
+		a.handleRawTranscript(w, req)
+		return w
+	}
+
+	w := get("s1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d, want 200", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q, want application/x-ndjson", ct)
+	}
+	if w.Body.String() != jsonl {
+		t.Errorf("body = %q, want the file's bytes", w.Body.String())
+	}
+
+	if w := get("missing"); w.Code != http.StatusNotFound {
+		t.Errorf("missing transcript: got %d, want 404", w.Code)
+	}
+
+	// Explicit paths must name a JSONL file inside the Claude directory.
+	outside := filepath.Join(t.TempDir(), "secret.jsonl")
+	os.WriteFile(outside, []byte("{}\n"), 0o644)
+	notJSONL := filepath.Join(projectDir, "notes.txt")
+	os.WriteFile(notJSONL, []byte("hi\n"), 0o644)
+	link := filepath.Join(projectDir, "link.jsonl")
+	os.Symlink(outside, link)
+	for _, path := range []string{outside, notJSONL, link} {
+		req := httptest.NewRequest("GET", "/api/transcript-raw/s1?path="+url.QueryEscape(path), nil)
+		req.SetPathValue("session_id", "s1")
+		w := httptest.NewRecorder()
+		a.handleRawTranscript(w, req)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("path %s: got %d, want 403", path, w.Code)
+		}
+		if strings.Contains(w.Body.String(), "{}") || strings.Contains(w.Body.String(), "hi\n") {
+			t.Errorf("path %s: body leaked file contents: %q", path, w.Body.String())
+		}
+	}
+}
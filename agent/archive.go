@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/phinze/sophon/transcript"
+)
+
+// archiveInterval is how often the agent looks for transcripts to archive.
+const archiveInterval = time.Hour
+
+// archiveLoop compresses old transcripts now and then every archiveInterval.
+func (a *Agent) archiveLoop() {
+	a.archiveTranscripts(time.Now())
+	ticker := time.NewTicker(archiveInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		a.archiveTranscripts(now)
+	}
+}
+
+// archiveTranscripts gzips the JSONL transcripts under the Claude projects
+// directory that haven't been written since ArchiveAfter before now. Reads
+// fall back to the archive, so the sessions stay viewable. Sessions the
+// daemon still lists as active are left alone, however idle; without that
+// list nothing is archived.
+func (a *Agent) archiveTranscripts(now time.Time) {
+	live, err := a.liveSessions()
+	if err != nil {
+		a.logger.Warn("skipping transcript archiving; can't list active sessions", "error", err)
+		return
+	}
+
+	root := filepath.Join(a.cfg.ClaudeDir, "projects")
+	cutoff := now.Add(-a.cfg.ArchiveAfter)
+	archived := 0
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			a.logger.Debug("transcript archive walk error", "path", path, "error", err)
+			return nil
+		}
+		if !d.Type().IsRegular() || !strings.HasSuffix(path, ".jsonl") {
+			return nil
+		}
+		if live[strings.TrimSuffix(d.Name(), ".jsonl")] {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			return nil
+		}
+		if err := transcript.Archive(path); errors.Is(err, transcript.ErrModified) {
+			a.logger.Debug("transcript written while archiving; will retry", "path", path)
+			return nil
+		} else if err != nil {
+			a.logger.Warn("failed to archive transcript", "path", path, "error", err)
+			return nil
+		}
+		archived++
+		return nil
+	})
+	if archived > 0 {
+		a.logger.Info("archived old transcripts", "count", archived, "older_than", a.cfg.ArchiveAfter)
+	}
+}
+
+// fetchLiveSessions asks the daemon for the IDs of sessions that haven't
+// stopped.
+func (a *Agent) fetchLiveSessions() (map[string]bool, error) {
+	if a.cfg.DaemonURL == "" {
+		return nil, errors.New("no daemon URL configured")
+	}
+	resp, err := a.httpClient.Get(a.cfg.DaemonURL + "/api/sessions")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("daemon returned %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Active []struct {
+			SessionID string `json:"session_id"`
+		} `json:"active"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decoding sessions: %w", err)
+	}
+	live := make(map[string]bool, len(body.Active))
+	for _, sess := range body.Active {
+		live[sess.SessionID] = true
+	}
+	return live, nil
+}
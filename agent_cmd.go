@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 
 	"github.com/phinze/sophon/agent"
+	"github.com/phinze/sophon/transcript"
 )
 
 func runAgent(args []string) error {
@@ -16,6 +17,9 @@ func runAgent(args []string) error {
 	daemonURL := fs.String("daemon-url", "", "sophon daemon URL for registration")
 	claudeDir := fs.String("claude-dir", defaultClaudeDir(), "Claude Code config directory")
 	nodeName := fs.String("node-name", defaultNodeName(), "node name for this machine")
+	pathTemplate := fs.String("transcript-path-template", transcript.DefaultPathTemplate, "transcript location when hooks don't report one; placeholders {claudeDir}, {slug}, {sessionID}")
+	sendKeysDelay := fs.Duration("send-keys-delay", 0, "pause between a response's text and its Enter key press, for terminals that drop keys")
+	archiveAfter := fs.Duration("archive-after", 0, "gzip transcripts not written for this long, e.g. 720h; they stay readable (0 disables)")
 	logLevel := fs.String("log-level", "info", "log level (debug, info, warn, error)")
 
 	if err := fs.Parse(args); err != nil {
@@ -27,6 +31,10 @@ func runAgent(args []string) error {
 		*daemonURL = os.Getenv("SOPHON_DAEMON_URL")
 	}
 
+	if err := transcript.ValidatePathTemplate(*pathTemplate); err != nil {
+		return err
+	}
+
 	// Resolve claude dir to absolute path
 	if !filepath.IsAbs(*claudeDir) {
 		abs, err := filepath.Abs(*claudeDir)
@@ -53,6 +61,11 @@ func runAgent(args []string) error {
 		DaemonURL:    *daemonURL,
 		ClaudeDir:    *claudeDir,
 		NodeName:     *nodeName,
+		Version:      version,
+
+		TranscriptPathTemplate: *pathTemplate,
+		SendKeysDelay:          *sendKeysDelay,
+		ArchiveAfter:           *archiveAfter,
 	}
 
 	a := agent.New(cfg, logger)
@@ -0,0 +1,22 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	if !f.Now().Equal(start) {
+		t.Errorf("Now = %v, want %v", f.Now(), start)
+	}
+	f.Advance(time.Hour)
+	if want := start.Add(time.Hour); !f.Now().Equal(want) {
+		t.Errorf("after Advance, Now = %v, want %v", f.Now(), want)
+	}
+	f.Set(start)
+	if !f.Now().Equal(start) {
+		t.Errorf("after Set, Now = %v, want %v", f.Now(), start)
+	}
+}
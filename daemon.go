@@ -5,21 +5,97 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 
+	"github.com/phinze/sophon/agent"
 	"github.com/phinze/sophon/server"
 	"github.com/phinze/sophon/store"
 )
 
-func runDaemon(args []string) error {
+// daemonSettings is the daemon's resolved configuration from flags, the
+// environment, and the config file.
+type daemonSettings struct {
+	cfg         server.Config
+	logLevel    string
+	dataDir     string
+	busyTimeout time.Duration
+	configPath  string
+
+	// reload re-reads the config file and returns the new runtime settings.
+	reload func() (server.Tunables, error)
+}
+
+// loadDaemonSettings parses the daemon's flags. Explicit flags win over
+// environment variables, which win over the config file.
+func loadDaemonSettings(args []string) (*daemonSettings, error) {
 	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
 	port := fs.Int("port", 2587, "listen port")
 	baseURL := fs.String("base-url", "", "public base URL for sophon (e.g. https://host)")
 	minAge := fs.Int("min-session-age", 120, "minimum session age in seconds before stop notifications")
 	logLevel := fs.String("log-level", "info", "log level (debug, info, warn, error)")
+	staleTimeout := fs.Duration("agent-stale-timeout", server.DefaultAgentStaleTimeout, "heartbeat gap after which an agent is considered offline (must exceed the agent heartbeat interval)")
+	transcriptTimeout := fs.Duration("agent-transcript-timeout", server.DefaultAgentTranscriptTimeout, "timeout for fetching transcripts from agents")
+	actionTimeout := fs.Duration("agent-action-timeout", server.DefaultAgentActionTimeout, "timeout for other agent requests (send-keys, summaries, pane checks)")
+	idleTimeout := fs.Duration("idle-timeout", 24*time.Hour, "stop sessions idle this long on nodes without a healthy agent (0 disables)")
+	maxSessions := fs.Int("max-sessions", 0, "keep at most this many stopped sessions, deleting the oldest (0 means no cap)")
+	slowToolAfter := fs.Duration("slow-tool-after", 0, "notify when a single tool call runs longer than this, e.g. 10m (0 disables)")
+	maxBody := fs.Int64("max-body-bytes", 1<<20, "maximum size of JSON request bodies in bytes")
+	busyTimeout := fs.Duration("db-busy-timeout", store.DefaultBusyTimeout, "how long database statements wait on a lock before failing")
+	claudeDir := fs.String("claude-dir", defaultClaudeDir(), "Claude Code config directory for reading --node-name's transcripts locally (empty disables)")
+	nodeName := fs.String("node-name", defaultNodeName(), "node name for this machine; its transcripts are always read locally")
+	withAgent := fs.Bool("with-agent", false, "also act as the agent for --node-name in this process (single-machine setups)")
+	sendKeysDelay := fs.Duration("send-keys-delay", 0, "with --with-agent, pause between a response's text and its Enter key press")
+	quietHours := fs.String("quiet-hours", "", "daily window when notifications don't raise alerts, e.g. 22:00-07:00")
+	quietTZ := fs.String("quiet-hours-tz", "", "IANA time zone for --quiet-hours (default: local time)")
+	reconcileGrace := fs.Duration("reconcile-grace", server.DefaultReconcileGrace, "how long a session's pane may be missing from an agent heartbeat before the session is stopped")
+	tlsCert := fs.String("tls-cert", "", "PEM certificate file; with --tls-key, serve HTTPS directly")
+	tlsKey := fs.String("tls-key", "", "PEM private key file for --tls-cert")
+	var templates stringList
+	fs.Var(&templates, "response-template", "canned reply offered in the web UI; repeat for more")
+	configPath := fs.String("config", defaultDaemonConfigPath(), "TOML config file; keys are flag names with underscores, and SIGHUP re-reads the runtime settings")
 	dataDir := fs.String("data-dir", defaultDataDir(), "directory for persistent data (SQLite database)")
 	if err := fs.Parse(args); err != nil {
-		return err
+		return nil, err
+	}
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	if err := applyDaemonConfig(fs, *configPath, explicit, nil); err != nil {
+		return nil, err
+	}
+
+	if (*tlsCert == "") != (*tlsKey == "") {
+		return nil, fmt.Errorf("--tls-cert and --tls-key must be set together")
+	}
+
+	if *staleTimeout <= agent.HeartbeatInterval {
+		return nil, fmt.Errorf("--agent-stale-timeout (%s) must exceed the agent heartbeat interval (%s)", *staleTimeout, agent.HeartbeatInterval)
+	}
+
+	tunables := func() (server.Tunables, error) {
+		loc := time.Local
+		if *quietTZ != "" {
+			var err error
+			if loc, err = time.LoadLocation(*quietTZ); err != nil {
+				return server.Tunables{}, fmt.Errorf("--quiet-hours-tz: %w", err)
+			}
+		}
+		quiet, err := server.ParseQuietHours(*quietHours, loc)
+		if err != nil {
+			return server.Tunables{}, fmt.Errorf("--quiet-hours: %w", err)
+		}
+		return server.Tunables{
+			QuietHours:     quiet,
+			IdleTimeout:    *idleTimeout,
+			ReconcileGrace: *reconcileGrace,
+		}, nil
+	}
+	tun, err := tunables()
+	if err != nil {
+		return nil, err
 	}
 
 	// Environment variable fallbacks
@@ -27,8 +103,53 @@ func runDaemon(args []string) error {
 		*baseURL = os.Getenv("SOPHON_BASE_URL")
 	}
 
+	return &daemonSettings{
+		cfg: server.Config{
+			Port:          *port,
+			BaseURL:       *baseURL,
+			MinSessionAge: *minAge,
+			MaxBodyBytes:  *maxBody,
+			MaxSessions:   *maxSessions,
+			SlowToolAfter: *slowToolAfter,
+			TLSCert:       *tlsCert,
+			TLSKey:        *tlsKey,
+
+			AgentStaleTimeout:      *staleTimeout,
+			AgentTranscriptTimeout: *transcriptTimeout,
+			AgentActionTimeout:     *actionTimeout,
+			IdleTimeout:            tun.IdleTimeout,
+			QuietHours:             tun.QuietHours,
+			ReconcileGrace:         tun.ReconcileGrace,
+
+			ClaudeDir: *claudeDir,
+			NodeName:  *nodeName,
+
+			InProcessAgent:    *withAgent,
+			SendKeysDelay:     *sendKeysDelay,
+			ResponseTemplates: templates,
+			Version:           version,
+		},
+		logLevel:    *logLevel,
+		dataDir:     *dataDir,
+		busyTimeout: *busyTimeout,
+		configPath:  *configPath,
+		reload: func() (server.Tunables, error) {
+			if err := applyDaemonConfig(fs, *configPath, explicit, reloadableKeys); err != nil {
+				return server.Tunables{}, err
+			}
+			return tunables()
+		},
+	}, nil
+}
+
+func runDaemon(args []string) error {
+	settings, err := loadDaemonSettings(args)
+	if err != nil {
+		return err
+	}
+
 	level := slog.LevelInfo
-	switch *logLevel {
+	switch settings.logLevel {
 	case "debug":
 		level = slog.LevelDebug
 	case "warn":
@@ -40,12 +161,12 @@ func runDaemon(args []string) error {
 	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
 
 	// Create data directory and open store
-	if err := os.MkdirAll(*dataDir, 0o700); err != nil {
+	if err := os.MkdirAll(settings.dataDir, 0o700); err != nil {
 		return fmt.Errorf("creating data directory: %w", err)
 	}
 
-	dbPath := filepath.Join(*dataDir, "sophon.db")
-	st, err := store.Open(dbPath)
+	dbPath := filepath.Join(settings.dataDir, "sophon.db")
+	st, err := store.OpenWithOptions(dbPath, store.Options{BusyTimeout: settings.busyTimeout})
 	if err != nil {
 		return fmt.Errorf("opening database: %w", err)
 	}
@@ -53,16 +174,26 @@ func runDaemon(args []string) error {
 
 	logger.Info("database opened", "path", dbPath)
 
-	cfg := server.Config{
-		Port:          *port,
-		BaseURL:       *baseURL,
-		MinSessionAge: *minAge,
-	}
-
-	srv := server.New(cfg, st, logger)
+	srv := server.New(settings.cfg, st, logger)
+	go reloadOnSIGHUP(srv, settings, logger)
 	return srv.Run()
 }
 
+// reloadOnSIGHUP re-reads the config file on each SIGHUP. A file that fails
+// to load leaves the running settings untouched.
+func reloadOnSIGHUP(srv *server.Server, settings *daemonSettings, logger *slog.Logger) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		t, err := settings.reload()
+		if err != nil {
+			logger.Error("config reload failed; keeping current settings", "path", settings.configPath, "error", err)
+			continue
+		}
+		srv.Reload(t)
+	}
+}
+
 func defaultClaudeDir() string {
 	home, err := os.UserHomeDir()
 	if err != nil {
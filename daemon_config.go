@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// defaultDaemonConfigPath is ~/.config/sophon/daemon.toml, honoring
+// XDG_CONFIG_HOME. The file is optional at its default location.
+func defaultDaemonConfigPath() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "sophon", "daemon.toml")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "sophon", "daemon.toml")
+}
+
+// reloadableKeys are the flags a SIGHUP re-reads from the config file. The
+// rest only take effect at startup.
+var reloadableKeys = map[string]bool{
+	"quiet-hours":     true,
+	"quiet-hours-tz":  true,
+	"idle-timeout":    true,
+	"reconcile-grace": true,
+}
+
+// daemonEnv maps flags to the environment variables that override the
+// config file for them.
+var daemonEnv = map[string]string{
+	"base-url": "SOPHON_BASE_URL",
+}
+
+// applyDaemonConfig sets flags from the config file at path. Keys are flag
+// names with underscores for dashes. Flags named in explicit, or whose
+// environment variable is set, are left alone. When only is non-nil just
+// those flags are applied, after resetting them to their defaults so keys
+// removed from the file stop taking effect. A missing file is an error only
+// when --config was given explicitly.
+func applyDaemonConfig(fset *flag.FlagSet, path string, explicit, only map[string]bool) error {
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) && !explicit["config"] {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("reading config: %w", err)
+	}
+	defer f.Close()
+
+	values, err := parseConfig(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	for name := range only {
+		if fl := fset.Lookup(name); fl != nil && !explicit[name] {
+			fset.Set(name, fl.DefValue)
+		}
+	}
+	for key, value := range values {
+		name := strings.ReplaceAll(key, "_", "-")
+		fl := fset.Lookup(name)
+		if fl == nil || name == "config" {
+			return fmt.Errorf("%s: unknown setting %q", path, key)
+		}
+		if only != nil && !only[name] {
+			continue
+		}
+		if explicit[name] {
+			continue
+		}
+		if env := daemonEnv[name]; env != "" && os.Getenv(env) != "" {
+			continue
+		}
+		if _, isList := fl.Value.(*stringList); value.list && !isList {
+			return fmt.Errorf("%s: %s does not take a list", path, key)
+		}
+		for _, v := range value.items {
+			if err := fset.Set(name, v); err != nil {
+				return fmt.Errorf("%s: %s: %w", path, key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// stringList is a repeatable string flag. In the config file it is set with
+// an array of strings.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ", ") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// configValue is one decoded config value; list is set for arrays.
+type configValue struct {
+	items []string
+	list  bool
+}
+
+// parseConfig reads the flat subset of TOML the daemon config needs:
+// `key = value` lines with string, integer, or boolean values, single-line
+// arrays of strings, and comments. Tables are rejected rather than misread.
+func parseConfig(r io.Reader) (map[string]configValue, error) {
+	values := make(map[string]configValue)
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			return nil, fmt.Errorf("line %d: tables are not supported", n)
+		}
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: want key = value", n)
+		}
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("line %d: missing key", n)
+		}
+		if _, dup := values[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", n, key)
+		}
+		raw = strings.TrimSpace(raw)
+		var value configValue
+		var err error
+		if strings.HasPrefix(raw, "[") {
+			value.list = true
+			value.items, err = parseConfigArray(raw)
+		} else {
+			var v string
+			v, err = parseConfigValue(raw)
+			value.items = []string{v}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", n, key, err)
+		}
+		values[key] = value
+	}
+	return values, sc.Err()
+}
+
+// parseConfigArray decodes a single-line array of strings such as
+// ["yes", "use option 2"].
+func parseConfigArray(raw string) ([]string, error) {
+	rest := strings.TrimSpace(raw[1:])
+	items := []string{}
+	for {
+		if strings.HasPrefix(rest, "]") {
+			return items, checkTrailing(rest[1:])
+		}
+		if !strings.HasPrefix(rest, `"`) {
+			return nil, errors.New("arrays may only hold quoted strings")
+		}
+		quoted, after, err := cutQuoted(rest)
+		if err != nil {
+			return nil, err
+		}
+		item, err := strconv.Unquote(quoted)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", quoted)
+		}
+		items = append(items, item)
+		rest = strings.TrimSpace(after)
+		if strings.HasPrefix(rest, ",") {
+			rest = strings.TrimSpace(rest[1:])
+		} else if !strings.HasPrefix(rest, "]") {
+			return nil, errors.New("unterminated array")
+		}
+	}
+}
+
+// parseConfigValue decodes one TOML value, dropping any trailing comment.
+func parseConfigValue(raw string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		quoted, rest, err := cutQuoted(raw)
+		if err != nil {
+			return "", err
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return "", fmt.Errorf("invalid string %s", quoted)
+		}
+		return value, checkTrailing(rest)
+	case strings.HasPrefix(raw, "'"):
+		end := strings.IndexByte(raw[1:], '\'')
+		if end < 0 {
+			return "", errors.New("unterminated string")
+		}
+		return raw[1 : end+1], checkTrailing(raw[end+2:])
+	}
+
+	value, _, _ := strings.Cut(raw, "#")
+	value = strings.TrimSpace(value)
+	if value == "true" || value == "false" {
+		return value, nil
+	}
+	if _, err := strconv.ParseInt(strings.ReplaceAll(value, "_", ""), 10, 64); err == nil {
+		return strings.ReplaceAll(value, "_", ""), nil
+	}
+	return "", fmt.Errorf("unsupported value %q (quote strings)", value)
+}
+
+// cutQuoted splits a basic string literal from whatever follows it.
+func cutQuoted(raw string) (quoted, rest string, err error) {
+	for i := 1; i < len(raw); i++ {
+		switch raw[i] {
+		case '\\':
+			i++
+		case '"':
+			return raw[:i+1], raw[i+1:], nil
+		}
+	}
+	return "", "", errors.New("unterminated string")
+}
+
+func checkTrailing(rest string) error {
+	rest = strings.TrimSpace(rest)
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return fmt.Errorf("unexpected %q after value", rest)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeDaemonConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "daemon.toml")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDaemonSettingsFromConfigFile(t *testing.T) {
+	t.Setenv("SOPHON_BASE_URL", "")
+	path := writeDaemonConfig(t, `
+# sophon daemon settings
+port = 9000
+base_url = "https://sophon.example.com"
+min_session_age = 45   # seconds
+data_dir = '/srv/sophon'
+claude_dir = "/home/me/.claude"
+idle_timeout = "2h"
+with_agent = true
+response_template = ["yes", "approve", "use option \"2\""]  # canned replies
+`)
+
+	s, err := loadDaemonSettings([]string{"--config", path})
+	if err != nil {
+		t.Fatalf("loadDaemonSettings: %v", err)
+	}
+	if s.cfg.Port != 9000 || s.cfg.BaseURL != "https://sophon.example.com" || s.cfg.MinSessionAge != 45 {
+		t.Errorf("cfg = %+v, want values from the file", s.cfg)
+	}
+	if s.cfg.ClaudeDir != "/home/me/.claude" || s.cfg.IdleTimeout != 2*time.Hour || !s.cfg.InProcessAgent {
+		t.Errorf("cfg = %+v, want values from the file", s.cfg)
+	}
+	if got := strings.Join(s.cfg.ResponseTemplates, "|"); got != `yes|approve|use option "2"` {
+		t.Errorf("ResponseTemplates = %q, want the file's list", s.cfg.ResponseTemplates)
+	}
+	if s.dataDir != "/srv/sophon" {
+		t.Errorf("dataDir = %q, want /srv/sophon", s.dataDir)
+	}
+}
+
+func TestDaemonConfigPrecedence(t *testing.T) {
+	path := writeDaemonConfig(t, `
+port = 9000
+base_url = "https://from-file.example.com"
+min_session_age = 45
+`)
+	t.Setenv("SOPHON_BASE_URL", "https://from-env.example.com")
+
+	s, err := loadDaemonSettings([]string{"--config", path, "--port", "7000"})
+	if err != nil {
+		t.Fatalf("loadDaemonSettings: %v", err)
+	}
+	if s.cfg.Port != 7000 {
+		t.Errorf("Port = %d, want the flag value", s.cfg.Port)
+	}
+	if s.cfg.BaseURL != "https://from-env.example.com" {
+		t.Errorf("BaseURL = %q, want the env value", s.cfg.BaseURL)
+	}
+	if s.cfg.MinSessionAge != 45 {
+		t.Errorf("MinSessionAge = %d, want the file value", s.cfg.MinSessionAge)
+	}
+}
+
+func TestDaemonConfigMissingFile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if _, err := loadDaemonSettings(nil); err != nil {
+		t.Errorf("missing default config should be ignored: %v", err)
+	}
+	missing := filepath.Join(t.TempDir(), "nope.toml")
+	if _, err := loadDaemonSettings([]string{"--config", missing}); err == nil {
+		t.Error("missing explicit --config should fail")
+	}
+}
+
+func TestDaemonConfigErrors(t *testing.T) {
+	for _, body := range []string{
+		`ntfy_url = "https://ntfy.sh/x"`,
+		`port = "not a number"`,
+		`base_url = https://unquoted`,
+		`[daemon]`,
+		"port = 1\nport = 2",
+		`base_url = "unterminated`,
+		`config = "/etc/other.toml"`,
+		`port = ["9000"]`,
+		`response_template = ["yes", no]`,
+		`response_template = ["yes"`,
+	} {
+		if _, err := loadDaemonSettings([]string{"--config", writeDaemonConfig(t, body)}); err == nil {
+			t.Errorf("config %q should fail", body)
+		}
+	}
+}
+
+func TestDaemonConfigReload(t *testing.T) {
+	path := writeDaemonConfig(t, "idle_timeout = \"1h\"\nport = 9000\n")
+	s, err := loadDaemonSettings([]string{"--config", path, "--reconcile-grace", "3s"})
+	if err != nil {
+		t.Fatalf("loadDaemonSettings: %v", err)
+	}
+
+	os.WriteFile(path, []byte(`
+port = 9100
+quiet_hours = "22:00-07:00"
+quiet_hours_tz = "UTC"
+reconcile_grace = "30s"
+`), 0o600)
+	tun, err := s.reload()
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if tun.IdleTimeout != 24*time.Hour {
+		t.Errorf("IdleTimeout = %v, want the default once removed from the file", tun.IdleTimeout)
+	}
+	if tun.QuietHours == nil || tun.QuietHours.Location != time.UTC {
+		t.Errorf("QuietHours = %+v, want 22:00-07:00 UTC", tun.QuietHours)
+	}
+	if tun.ReconcileGrace != 3*time.Second {
+		t.Errorf("ReconcileGrace = %v, want the explicit flag value", tun.ReconcileGrace)
+	}
+
+	os.WriteFile(path, []byte(`quiet_hours = "late"`), 0o600)
+	if _, err := s.reload(); err == nil || !strings.Contains(err.Error(), "quiet-hours") {
+		t.Errorf("reload error = %v, want a quiet-hours error", err)
+	}
+}
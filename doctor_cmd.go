@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/phinze/sophon/tmux"
+)
+
+func runDoctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	daemonURL := fs.String("daemon-url", "", "sophon daemon URL")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *daemonURL == "" {
+		*daemonURL = os.Getenv("SOPHON_DAEMON_URL")
+	}
+	if *daemonURL == "" {
+		*daemonURL = "http://127.0.0.1:2587"
+	}
+
+	d := newDoctor()
+	if !d.run(os.Stdout, *daemonURL) {
+		return fmt.Errorf("some checks failed")
+	}
+	return nil
+}
+
+// checkResult is the outcome of a single doctor check. Hint tells the user
+// what to do about a failure.
+type checkResult struct {
+	Name   string
+	OK     bool
+	Detail string
+	Hint   string
+}
+
+// doctor runs setup checks. Its dependencies are injectable for testing.
+type doctor struct {
+	httpGet        func(url string) (*http.Response, error)
+	lookPath       func(file string) (string, error)
+	listAgentPanes func() (map[string]bool, error)
+}
+
+func newDoctor() *doctor {
+	client := &http.Client{Timeout: 5 * time.Second}
+	return &doctor{
+		httpGet:        client.Get,
+		lookPath:       exec.LookPath,
+		listAgentPanes: tmux.ListAgentPanes,
+	}
+}
+
+// run performs every check, printing one line per result, and reports whether
+// all of them passed.
+func (d *doctor) run(w io.Writer, daemonURL string) bool {
+	results := []checkResult{
+		d.checkDaemon(daemonURL),
+		d.checkTmux(),
+	}
+	// Agent detection shells out to tmux, so it only means something once
+	// tmux itself is present.
+	if results[1].OK {
+		results = append(results, d.checkAgentDetection())
+	}
+
+	allOK := true
+	for _, r := range results {
+		mark := "ok  "
+		if !r.OK {
+			mark = "FAIL"
+			allOK = false
+		}
+		fmt.Fprintf(w, "[%s] %s: %s\n", mark, r.Name, r.Detail)
+		if !r.OK && r.Hint != "" {
+			fmt.Fprintf(w, "       %s\n", r.Hint)
+		}
+	}
+	return allOK
+}
+
+func (d *doctor) checkDaemon(daemonURL string) checkResult {
+	r := checkResult{Name: "daemon"}
+	resp, err := d.httpGet(strings.TrimRight(daemonURL, "/") + "/health")
+	if err != nil {
+		r.Detail = fmt.Sprintf("cannot reach %s: %v", daemonURL, err)
+		r.Hint = "start it with `sophon daemon`, or point --daemon-url / SOPHON_DAEMON_URL at the right host"
+		return r
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		r.Detail = fmt.Sprintf("%s/health returned %d", daemonURL, resp.StatusCode)
+		r.Hint = "check that the URL points at a sophon daemon and not another service"
+		return r
+	}
+	r.OK = true
+	r.Detail = "reachable at " + daemonURL
+	return r
+}
+
+func (d *doctor) checkTmux() checkResult {
+	r := checkResult{Name: "tmux"}
+	path, err := d.lookPath("tmux")
+	if err != nil {
+		r.Detail = "tmux not found on PATH"
+		r.Hint = "install tmux; sophon responds to sessions by sending keys to their pane"
+		return r
+	}
+	r.OK = true
+	r.Detail = path
+	return r
+}
+
+func (d *doctor) checkAgentDetection() checkResult {
+	r := checkResult{Name: "agent detection"}
+	panes, err := d.listAgentPanes()
+	if err != nil {
+		r.Detail = err.Error()
+		r.Hint = "make sure a tmux server is running and `ps` is available"
+		return r
+	}
+	r.OK = true
+	r.Detail = fmt.Sprintf("%d pane(s) running a coding agent", len(panes))
+	return r
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func stubDoctor() *doctor {
+	return &doctor{
+		httpGet: func(url string) (*http.Response, error) {
+			return nil, errors.New("unexpected request")
+		},
+		lookPath: func(file string) (string, error) {
+			return "/usr/bin/" + file, nil
+		},
+		listAgentPanes: func() (map[string]bool, error) {
+			return map[string]bool{"%1": true}, nil
+		},
+	}
+}
+
+func TestCheckDaemon(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, "ok\n")
+	}))
+	defer srv.Close()
+
+	d := stubDoctor()
+	d.httpGet = http.Get
+
+	if r := d.checkDaemon(srv.URL + "/"); !r.OK {
+		t.Errorf("expected daemon check to pass, got %+v", r)
+	}
+
+	d.httpGet = func(url string) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}
+	r := d.checkDaemon("http://127.0.0.1:2587")
+	if r.OK {
+		t.Fatal("expected daemon check to fail")
+	}
+	if !strings.Contains(r.Detail, "connection refused") || r.Hint == "" {
+		t.Errorf("failure should explain and hint: %+v", r)
+	}
+}
+
+func TestCheckDaemonBadStatus(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	d := stubDoctor()
+	d.httpGet = http.Get
+	if r := d.checkDaemon(srv.URL); r.OK || !strings.Contains(r.Detail, "404") {
+		t.Errorf("expected 404 failure, got %+v", r)
+	}
+}
+
+func TestCheckTmux(t *testing.T) {
+	d := stubDoctor()
+	if r := d.checkTmux(); !r.OK || r.Detail != "/usr/bin/tmux" {
+		t.Errorf("expected tmux found, got %+v", r)
+	}
+
+	d.lookPath = func(string) (string, error) { return "", errors.New("not found") }
+	if r := d.checkTmux(); r.OK || r.Hint == "" {
+		t.Errorf("expected tmux failure with hint, got %+v", r)
+	}
+}
+
+func TestDoctorRunSkipsDetectionWithoutTmux(t *testing.T) {
+	d := stubDoctor()
+	d.httpGet = func(url string) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok"))}, nil
+	}
+	d.lookPath = func(string) (string, error) { return "", errors.New("not found") }
+	called := false
+	d.listAgentPanes = func() (map[string]bool, error) {
+		called = true
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	if d.run(&buf, "http://daemon") {
+		t.Error("run should report failure when tmux is missing")
+	}
+	if called {
+		t.Error("agent detection should be skipped when tmux is missing")
+	}
+	if !strings.Contains(buf.String(), "[FAIL] tmux") || !strings.Contains(buf.String(), "[ok  ] daemon") {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
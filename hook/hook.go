@@ -9,6 +9,8 @@ import (
 	"os"
 	"strings"
 	"time"
+
+	"github.com/phinze/sophon/tmux"
 )
 
 // HookEvent represents the JSON input from Claude Code hooks.
@@ -20,6 +22,7 @@ type HookEvent struct {
 	Message          string          `json:"message"`
 	ToolName         string          `json:"tool_name"`
 	ToolInput        json.RawMessage `json:"tool_input"`
+	ToolUseID        string          `json:"tool_use_id"`
 	TranscriptPath   string          `json:"transcript_path"`
 
 	// Antigravity uses a separate, camelCase hook contract. These fields are
@@ -44,6 +47,19 @@ type Config struct {
 	MinSessionAge int
 	Provider      string
 	EventName     string
+
+	// BasePath is prepended to every API path, for daemons served behind a
+	// reverse proxy under a prefix such as "/sophon".
+	BasePath string
+}
+
+// endpoint returns the daemon URL for an API path such as "/api/sessions".
+func (c Config) endpoint(path string) string {
+	base := strings.TrimRight(c.DaemonURL, "/")
+	if prefix := strings.Trim(c.BasePath, "/"); prefix != "" {
+		base += "/" + prefix
+	}
+	return base + path
 }
 
 // Run reads a hook event from stdin and forwards it to the daemon.
@@ -59,8 +75,9 @@ func Run(cfg Config) error {
 	}
 	event = normalizeEvent(cfg, event)
 
-	// Try to get the tmux pane from the environment
-	tmuxPane := os.Getenv("TMUX_PANE")
+	// Try to get the tmux pane from the environment. A malformed value is
+	// dropped, as if outside tmux, rather than reported for send-keys to trip on.
+	tmuxPane, _ := tmux.NormalizePane(os.Getenv("TMUX_PANE"))
 
 	switch event.HookEventName {
 	case "SessionStart":
@@ -71,11 +88,11 @@ func Run(cfg Config) error {
 		}
 		return handleSessionStart(cfg, event, tmuxPane)
 	case "Notification":
-		return handleNotification(cfg, event)
+		return handleNotification(cfg, event, tmuxPane)
 	case "PermissionRequest":
-		return handlePermissionRequest(cfg, event)
+		return handlePermissionRequest(cfg, event, tmuxPane)
 	case "Stop":
-		return handleTurnEnd(cfg, event)
+		return handleTurnEnd(cfg, event, tmuxPane)
 	case "SessionEnd":
 		return handleSessionEnd(cfg, event)
 	case "PreToolUse":
@@ -137,7 +154,7 @@ func handlePlan(cfg Config, event HookEvent) error {
 		"plan":      input.Plan,
 		"node_name": cfg.NodeName,
 	}
-	return postJSON(cfg.DaemonURL+"/api/sessions/"+event.SessionID+"/plan", body)
+	return postJSON(cfg.endpoint("/api/sessions/"+event.SessionID+"/plan"), body)
 }
 
 func handleSessionStart(cfg Config, event HookEvent, tmuxPane string) error {
@@ -148,10 +165,10 @@ func handleSessionStart(cfg Config, event HookEvent, tmuxPane string) error {
 		"node_name":       cfg.NodeName,
 		"transcript_path": event.TranscriptPath,
 	}
-	return postJSON(cfg.DaemonURL+"/api/sessions", body)
+	return postJSON(cfg.endpoint("/api/sessions"), body)
 }
 
-func handleNotification(cfg Config, event HookEvent) error {
+func handleNotification(cfg Config, event HookEvent, tmuxPane string) error {
 	repo := repoFromCwd(event.Cwd)
 
 	var title, message string
@@ -169,13 +186,15 @@ func handleNotification(cfg Config, event HookEvent) error {
 		"title":             title,
 		"message":           message,
 		"cwd":               event.Cwd,
+		"transcript_path":   event.TranscriptPath,
 		"node_name":         cfg.NodeName,
+		"tmux_pane":         tmuxPane,
 	}
 
-	return postJSON(cfg.DaemonURL+"/api/sessions/"+event.SessionID+"/notify", body)
+	return postJSON(cfg.endpoint("/api/sessions/"+event.SessionID+"/notify"), body)
 }
 
-func handlePermissionRequest(cfg Config, event HookEvent) error {
+func handlePermissionRequest(cfg Config, event HookEvent, tmuxPane string) error {
 	repo := repoFromCwd(event.Cwd)
 	message := event.ToolName
 	if message == "" {
@@ -186,16 +205,21 @@ func handlePermissionRequest(cfg Config, event HookEvent) error {
 		"title":             repo + " · Needs approval",
 		"message":           message,
 		"cwd":               event.Cwd,
+		"transcript_path":   event.TranscriptPath,
 		"node_name":         cfg.NodeName,
+		"tmux_pane":         tmuxPane,
 	}
-	return postJSON(cfg.DaemonURL+"/api/sessions/"+event.SessionID+"/notify", body)
+	return postJSON(cfg.endpoint("/api/sessions/"+event.SessionID+"/notify"), body)
 }
 
-func handleTurnEnd(cfg Config, event HookEvent) error {
+func handleTurnEnd(cfg Config, event HookEvent, tmuxPane string) error {
 	body := map[string]interface{}{
-		"node_name": cfg.NodeName,
+		"node_name":       cfg.NodeName,
+		"tmux_pane":       tmuxPane,
+		"cwd":             event.Cwd,
+		"transcript_path": event.TranscriptPath,
 	}
-	err := postJSON(cfg.DaemonURL+"/api/sessions/"+event.SessionID+"/activity", body)
+	err := postJSON(cfg.endpoint("/api/sessions/"+event.SessionID+"/activity"), body)
 	if err != nil {
 		// Daemon down, nothing to do for turn end
 		return nil
@@ -205,7 +229,7 @@ func handleTurnEnd(cfg Config, event HookEvent) error {
 
 func handleSessionEnd(cfg Config, event HookEvent) error {
 	client := &http.Client{Timeout: 5 * time.Second}
-	url := cfg.DaemonURL + "/api/sessions/" + event.SessionID
+	url := cfg.endpoint("/api/sessions/" + event.SessionID)
 	if cfg.NodeName != "" {
 		url += "?node_name=" + cfg.NodeName
 	}
@@ -226,9 +250,10 @@ func handleToolActivity(cfg Config, event HookEvent) error {
 	body := map[string]interface{}{
 		"hook_event_name": event.HookEventName,
 		"tool_name":       event.ToolName,
+		"tool_use_id":     event.ToolUseID,
 		"node_name":       cfg.NodeName,
 	}
-	err := postJSON(cfg.DaemonURL+"/api/sessions/"+event.SessionID+"/tool-activity", body)
+	err := postJSON(cfg.endpoint("/api/sessions/"+event.SessionID+"/tool-activity"), body)
 	if err != nil {
 		// Daemon down, nothing to do for tool activity
 		return nil
@@ -63,7 +63,7 @@ func TestPermissionRequestPostsNotification(t *testing.T) {
 		SessionID: "session-1",
 		Cwd:       "/workspace/project",
 		ToolName:  "functions.exec",
-	})
+	}, "%3")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -73,4 +73,80 @@ func TestPermissionRequestPostsNotification(t *testing.T) {
 	if body["notification_type"] != "permission_prompt" || body["message"] != "functions.exec" {
 		t.Errorf("body = %#v", body)
 	}
+	if body["tmux_pane"] != "%3" {
+		t.Errorf("tmux_pane = %v, want %%3", body["tmux_pane"])
+	}
+}
+
+func TestNotificationAndTurnEndIncludePane(t *testing.T) {
+	bodies := make(map[string]map[string]any)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		var body map[string]any
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &body)
+		bodies[r.URL.Path] = body
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := Config{DaemonURL: server.URL, NodeName: "node-1"}
+	event := HookEvent{SessionID: "session-1", Cwd: "/workspace/project", NotificationType: "idle_prompt"}
+	if err := handleNotification(cfg, event, "%7"); err != nil {
+		t.Fatal(err)
+	}
+	if err := handleTurnEnd(cfg, event, "%7"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/api/sessions/session-1/notify", "/api/sessions/session-1/activity"} {
+		body, ok := bodies[path]
+		if !ok {
+			t.Errorf("no request to %s", path)
+			continue
+		}
+		if body["tmux_pane"] != "%7" {
+			t.Errorf("%s: tmux_pane = %v, want %%7", path, body["tmux_pane"])
+		}
+	}
+}
+
+func TestEndpointBasePath(t *testing.T) {
+	tests := []struct {
+		daemonURL, basePath, want string
+	}{
+		{"http://127.0.0.1:2587", "", "http://127.0.0.1:2587/api/sessions"},
+		{"https://host", "/sophon", "https://host/sophon/api/sessions"},
+		{"https://host/", "sophon/", "https://host/sophon/api/sessions"},
+		{"https://host", "/a/b", "https://host/a/b/api/sessions"},
+	}
+	for _, tt := range tests {
+		cfg := Config{DaemonURL: tt.daemonURL, BasePath: tt.basePath}
+		if got := cfg.endpoint("/api/sessions"); got != tt.want {
+			t.Errorf("endpoint(%q, %q) = %q, want %q", tt.daemonURL, tt.basePath, got, tt.want)
+		}
+	}
+}
+
+func TestHookRequestsUseBasePath(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := Config{DaemonURL: server.URL, NodeName: "node-1", BasePath: "/sophon"}
+	event := HookEvent{SessionID: "session-1", Cwd: "/workspace/project"}
+	if err := handleNotification(cfg, event, "%1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := handleSessionEnd(cfg, event); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"POST /sophon/api/sessions/session-1/notify", "DELETE /sophon/api/sessions/session-1"}
+	if len(paths) != len(want) || paths[0] != want[0] || paths[1] != want[1] {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
 }
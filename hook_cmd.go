@@ -15,6 +15,7 @@ func runHook(args []string) error {
 	nodeName := fs.String("node-name", defaultNodeName(), "node name for this machine")
 	provider := fs.String("provider", "auto", "hook provider (auto, claude, codex, antigravity)")
 	eventName := fs.String("event", "", "provider event name (required for Antigravity hooks)")
+	basePath := fs.String("base-path", "", "path prefix when the daemon is served behind a reverse proxy (e.g. /sophon)")
 
 	if err := fs.Parse(args); err != nil {
 		return err
@@ -34,12 +35,16 @@ func runHook(args []string) error {
 	if *daemonURL == "" {
 		*daemonURL = "http://127.0.0.1:2587"
 	}
+	if *basePath == "" {
+		*basePath = os.Getenv("SOPHON_BASE_PATH")
+	}
 
 	cfg := hook.Config{
 		DaemonURL: *daemonURL,
 		NodeName:  *nodeName,
 		Provider:  *provider,
 		EventName: *eventName,
+		BasePath:  *basePath,
 	}
 
 	err := hook.Run(cfg)
@@ -60,7 +65,13 @@ func runHook(args []string) error {
 	return err
 }
 
+// defaultNodeName prefers SOPHON_NODE_NAME over the hostname. The hook and
+// agent must agree on the name for reconciliation to match sessions to panes,
+// so both take their default from here.
 func defaultNodeName() string {
+	if name := os.Getenv("SOPHON_NODE_NAME"); name != "" {
+		return name
+	}
 	name, err := os.Hostname()
 	if err != nil {
 		return "unknown"
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDefaultNodeNameEnvOverride(t *testing.T) {
+	t.Setenv("SOPHON_NODE_NAME", "container-1")
+	if got := defaultNodeName(); got != "container-1" {
+		t.Errorf("defaultNodeName() = %q, want container-1", got)
+	}
+}
+
+func TestDefaultNodeNameHostname(t *testing.T) {
+	t.Setenv("SOPHON_NODE_NAME", "")
+	host, err := os.Hostname()
+	if err != nil {
+		t.Skip("no hostname available")
+	}
+	if got := defaultNodeName(); got != host {
+		t.Errorf("defaultNodeName() = %q, want %q", got, host)
+	}
+}
@@ -5,9 +5,14 @@ import (
 	"os"
 )
 
+// version is the sophon release, set at build time with
+// -ldflags "-X main.version=...". Agents report it so the daemon can spot
+// nodes running a different build.
+var version = "dev"
+
 func main() {
 	if len(os.Args) < 2 {
-		fmt.Fprintf(os.Stderr, "Usage: sophon <command>\n\nCommands:\n  daemon  Run the coordinator HTTP server\n  agent   Run the per-node agent (transcript, tmux)\n  hook    Process Claude Code, Codex, or Antigravity hook events from stdin\n")
+		fmt.Fprintf(os.Stderr, "Usage: sophon <command>\n\nCommands:\n  daemon    Run the coordinator HTTP server\n  agent     Run the per-node agent (transcript, tmux)\n  hook      Process Claude Code, Codex, or Antigravity hook events from stdin\n  sessions  List sessions from the daemon's database\n  doctor    Check that sophon is set up correctly on this machine\n")
 		os.Exit(1)
 	}
 
@@ -27,6 +32,16 @@ func main() {
 			fmt.Fprintf(os.Stderr, "error: %v\n", err)
 			os.Exit(1)
 		}
+	case "sessions":
+		if err := runSessions(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	case "doctor":
+		if err := runDoctor(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
 	default:
 		fmt.Fprintf(os.Stderr, "unknown command: %s\n", os.Args[1])
 		os.Exit(1)
@@ -28,7 +28,7 @@ let
   };
 in
 
-buildGoModule {
+buildGoModule rec {
   pname = "sophon";
   version = "0.1.0-${builtins.substring 0 12 lastModifiedDate}";
 
@@ -38,6 +38,8 @@ buildGoModule {
 
   env.CGO_ENABLED = "0";
 
+  ldflags = [ "-X main.version=${version}" ];
+
   preBuild = ''
     cp ${frontend}/app.js server/static/
     cp ${frontend}/style.css server/static/
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// accessLog logs one line per request with its method, path, status, and
+// duration. Heartbeats, health checks, tool-activity hooks, and static
+// assets arrive constantly and are logged at debug so they don't drown out
+// real traffic.
+func accessLog(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+
+		level := slog.LevelInfo
+		if isNoisyRoute(r) {
+			level = slog.LevelDebug
+		}
+		logger.LogAttrs(context.Background(), level, "http request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", sw.code()),
+			slog.Duration("duration", time.Since(start)),
+		)
+	})
+}
+
+func isNoisyRoute(r *http.Request) bool {
+	return r.URL.Path == "/health" ||
+		r.URL.Path == "/api/agents/register" ||
+		(r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/api/sessions/") && strings.HasSuffix(r.URL.Path, "/tool-activity")) ||
+		strings.HasPrefix(r.URL.Path, "/static/")
+}
+
+// statusWriter records the status code written through it.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusWriter) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusWriter) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// Flush keeps SSE handlers, which assert http.Flusher directly, working.
+func (s *statusWriter) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (s *statusWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// code returns the recorded status; a handler that never wrote anything
+// produced an implicit 200.
+func (s *statusWriter) code() int {
+	if s.status == 0 {
+		return http.StatusOK
+	}
+	return s.status
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	h := accessLog(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+
+	tests := []struct {
+		method, path string
+		status       int
+		level        string
+	}{
+		{"GET", "/api/sessions", http.StatusOK, "INFO"},
+		{"POST", "/missing", http.StatusNotFound, "INFO"},
+		{"GET", "/health", http.StatusOK, "DEBUG"},
+		{"POST", "/api/sessions/s1/tool-activity", http.StatusOK, "DEBUG"},
+		{"POST", "/api/sessions/s1/activity", http.StatusOK, "INFO"},
+	}
+	for _, tt := range tests {
+		logs.Reset()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+
+		var entry struct {
+			Level    string  `json:"level"`
+			Msg      string  `json:"msg"`
+			Method   string  `json:"method"`
+			Path     string  `json:"path"`
+			Status   int     `json:"status"`
+			Duration float64 `json:"duration"`
+		}
+		if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+			t.Fatalf("%s %s: decoding log entry %q: %v", tt.method, tt.path, logs.String(), err)
+		}
+		if entry.Msg != "http request" || entry.Method != tt.method || entry.Path != tt.path || entry.Status != tt.status {
+			t.Errorf("%s %s: got %+v, want status %d", tt.method, tt.path, entry, tt.status)
+		}
+		if entry.Level != tt.level {
+			t.Errorf("%s %s: level = %s, want %s", tt.method, tt.path, entry.Level, tt.level)
+		}
+	}
+}
+
+func TestAccessLogKeepsSSEFlushable(t *testing.T) {
+	h := newTestHarness(t)
+	srv := httptest.NewServer(h.server.routes())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got %d, want 200", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+}
@@ -2,54 +2,130 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"net"
 	"net/http"
 	"net/url"
 	"time"
 
+	"github.com/phinze/sophon/tmux"
 	"github.com/phinze/sophon/transcript"
 )
 
+// ErrPaneGone is returned when an agent reports that the target pane no
+// longer exists.
+var ErrPaneGone = errors.New("pane no longer exists")
+
+// ErrAgentOffline is returned when no healthy agent serves a session's node.
+var ErrAgentOffline = errors.New("no healthy agent")
+
+// ErrNotModified is returned when a conditional transcript fetch finds the
+// transcript unchanged since the given ETag.
+var ErrNotModified = errors.New("transcript not modified")
+
+// ErrAgentUnreachable is returned when a request fails before the agent
+// answers, such as on a refused or reset connection. Unlike an error status
+// from the agent, these are often transient.
+var ErrAgentUnreachable = errors.New("agent unreachable")
+
+// connectionError marks err from an HTTP round trip as ErrAgentUnreachable.
+// Timeouts are left unmarked: the agent may be busy rather than gone, and
+// trying again would only double the wait.
+func connectionError(err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrAgentUnreachable, err)
+}
+
 // agentClient wraps HTTP calls to agent API endpoints.
 type agentClient struct {
 	transcriptTimeout time.Duration
 	actionTimeout     time.Duration
+
+	// stream carries responses that are copied straight through to the
+	// daemon's client, so it has no overall timeout: only connecting and
+	// waiting for headers are bounded, and the request's context bounds
+	// the rest.
+	stream *http.Client
 }
 
-func newAgentClient() *agentClient {
+// Default agent request timeouts. Transcripts get longer since they can be
+// large and may cross slow links.
+const (
+	DefaultAgentTranscriptTimeout = 10 * time.Second
+	DefaultAgentActionTimeout     = 5 * time.Second
+)
+
+// newAgentClient creates a client with the given timeouts; zero values take
+// the defaults.
+func newAgentClient(transcriptTimeout, actionTimeout time.Duration) *agentClient {
+	if transcriptTimeout <= 0 {
+		transcriptTimeout = DefaultAgentTranscriptTimeout
+	}
+	if actionTimeout <= 0 {
+		actionTimeout = DefaultAgentActionTimeout
+	}
 	return &agentClient{
-		transcriptTimeout: 10 * time.Second,
-		actionTimeout:     5 * time.Second,
+		transcriptTimeout: transcriptTimeout,
+		actionTimeout:     actionTimeout,
+		stream: &http.Client{Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           (&net.Dialer{Timeout: transcriptTimeout}).DialContext,
+			ResponseHeaderTimeout: transcriptTimeout,
+		}},
 	}
 }
 
-// GetTranscript fetches the transcript from an agent.
-func (c *agentClient) GetTranscript(agentURL, sessionID, cwd, path string) (*transcript.Transcript, error) {
-	u := fmt.Sprintf("%s/api/transcript/%s?cwd=%s&path=%s", agentURL, sessionID, url.QueryEscape(cwd), url.QueryEscape(path))
+// GetTranscript fetches the transcript from an agent, along with its ETag.
+// When etag is non-empty the request is conditional, and ErrNotModified is
+// returned if the transcript hasn't changed. Debug info is always requested;
+// the daemon drops it unless its own client asked.
+func (c *agentClient) GetTranscript(agentURL, sessionID, cwd, path, etag string) (*transcript.Transcript, string, error) {
+	u := fmt.Sprintf("%s/api/transcript/%s?cwd=%s&path=%s&debug=1", agentURL, sessionID, url.QueryEscape(cwd), url.QueryEscape(path))
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
 	client := &http.Client{Timeout: c.transcriptTimeout}
-	resp, err := client.Get(u)
+	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("agent transcript request: %w", err)
+		return nil, "", fmt.Errorf("agent transcript request: %w", connectionError(err))
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, etag, ErrNotModified
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("agent transcript returned %d", resp.StatusCode)
+		return nil, "", fmt.Errorf("agent transcript returned %d", resp.StatusCode)
 	}
 
 	var tr transcript.Transcript
 	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
-		return nil, fmt.Errorf("decoding agent transcript: %w", err)
+		return nil, "", fmt.Errorf("decoding agent transcript: %w", err)
 	}
-	return &tr, nil
+	return &tr, resp.Header.Get("ETag"), nil
 }
 
 // GetSummary fetches the session summary from an agent.
-func (c *agentClient) GetSummary(agentURL, sessionID, cwd, path string) (*transcript.SessionSummary, error) {
+func (c *agentClient) GetSummary(ctx context.Context, agentURL, sessionID, cwd, path string) (*transcript.SessionSummary, error) {
 	u := fmt.Sprintf("%s/api/summary/%s?cwd=%s&path=%s", agentURL, sessionID, url.QueryEscape(cwd), url.QueryEscape(path))
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("agent summary request: %w", err)
+	}
 	client := &http.Client{Timeout: c.actionTimeout}
-	resp, err := client.Get(u)
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("agent summary request: %w", err)
 	}
@@ -66,9 +142,64 @@ func (c *agentClient) GetSummary(agentURL, sessionID, cwd, path string) (*transc
 	return &summary, nil
 }
 
+// GetToolCall fetches one tool call's full input and result from an agent.
+// It returns transcript.ErrToolCallNotFound when the agent has no such call.
+func (c *agentClient) GetToolCall(agentURL, sessionID, cwd, path, toolUseID string) (*transcript.ToolCall, error) {
+	u := fmt.Sprintf("%s/api/tool-call/%s/%s?cwd=%s&path=%s", agentURL, sessionID, url.PathEscape(toolUseID), url.QueryEscape(cwd), url.QueryEscape(path))
+	client := &http.Client{Timeout: c.transcriptTimeout}
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, fmt.Errorf("agent tool-call request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, transcript.ErrToolCallNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("agent tool-call returned %d", resp.StatusCode)
+	}
+
+	var call transcript.ToolCall
+	if err := json.NewDecoder(resp.Body).Decode(&call); err != nil {
+		return nil, fmt.Errorf("decoding agent tool-call: %w", err)
+	}
+	return &call, nil
+}
+
+// GetRawTranscript opens a session's unparsed JSONL on an agent. The caller
+// closes the returned body.
+func (c *agentClient) GetRawTranscript(ctx context.Context, agentURL, sessionID, cwd, path string) (io.ReadCloser, error) {
+	u := fmt.Sprintf("%s/api/transcript-raw/%s?cwd=%s&path=%s", agentURL, sessionID, url.QueryEscape(cwd), url.QueryEscape(path))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.stream.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("agent raw transcript request: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("agent raw transcript: %w", fs.ErrNotExist)
+	case http.StatusForbidden:
+		resp.Body.Close()
+		return nil, transcript.ErrNotTranscript
+	case http.StatusRequestEntityTooLarge:
+		resp.Body.Close()
+		return nil, transcript.ErrTooLarge
+	}
+	resp.Body.Close()
+	return nil, fmt.Errorf("agent raw transcript returned %d", resp.StatusCode)
+}
+
 // SendKeys sends a send-keys request to an agent.
-func (c *agentClient) SendKeys(agentURL, pane, text string) error {
-	body, _ := json.Marshal(map[string]string{"pane": pane, "text": text})
+func (c *agentClient) SendKeys(agentURL, pane, text string, enter bool) error {
+	body, _ := json.Marshal(map[string]any{"pane": pane, "text": text, "enter": enter})
 	client := &http.Client{Timeout: c.actionTimeout}
 	resp, err := client.Post(agentURL+"/api/send-keys", "application/json", bytes.NewReader(body))
 	if err != nil {
@@ -76,12 +207,39 @@ func (c *agentClient) SendKeys(agentURL, pane, text string) error {
 	}
 	resp.Body.Close()
 
+	if resp.StatusCode == http.StatusGone {
+		return ErrPaneGone
+	}
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("agent send-keys returned %d", resp.StatusCode)
 	}
 	return nil
 }
 
+// PaneInfo fetches a pane's title and window name from an agent.
+func (c *agentClient) PaneInfo(agentURL, pane string) (tmux.Pane, error) {
+	u := fmt.Sprintf("%s/api/pane-info?pane=%s", agentURL, url.QueryEscape(pane))
+	client := &http.Client{Timeout: c.actionTimeout}
+	resp, err := client.Get(u)
+	if err != nil {
+		return tmux.Pane{}, fmt.Errorf("agent pane-info request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusGone {
+		return tmux.Pane{}, ErrPaneGone
+	}
+	if resp.StatusCode != http.StatusOK {
+		return tmux.Pane{}, fmt.Errorf("agent pane-info returned %d", resp.StatusCode)
+	}
+
+	var info tmux.Pane
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return tmux.Pane{}, fmt.Errorf("decoding agent pane-info: %w", err)
+	}
+	return info, nil
+}
+
 // PaneFocused checks if a pane is focused via an agent.
 func (c *agentClient) PaneFocused(agentURL, pane string) (bool, error) {
 	u := fmt.Sprintf("%s/api/pane-focused?pane=%s", agentURL, url.QueryEscape(pane))
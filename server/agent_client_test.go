@@ -0,0 +1,182 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/phinze/sophon/clock"
+	"github.com/phinze/sophon/store"
+)
+
+func TestNewAgentClientTimeouts(t *testing.T) {
+	c := newAgentClient(45*time.Second, 7*time.Second)
+	if c.transcriptTimeout != 45*time.Second || c.actionTimeout != 7*time.Second {
+		t.Errorf("timeouts = %v/%v, want 45s/7s", c.transcriptTimeout, c.actionTimeout)
+	}
+
+	c = newAgentClient(0, 0)
+	if c.transcriptTimeout != DefaultAgentTranscriptTimeout || c.actionTimeout != DefaultAgentActionTimeout {
+		t.Errorf("zero timeouts = %v/%v, want defaults", c.transcriptTimeout, c.actionTimeout)
+	}
+}
+
+func TestServerAppliesAgentTimeouts(t *testing.T) {
+	st, err := store.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	cfg := Config{AgentTranscriptTimeout: 30 * time.Second, AgentActionTimeout: 3 * time.Second}
+	s := New(cfg, st, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	client := s.nodeOps.(*agentProxyOps).client
+	if client.transcriptTimeout != 30*time.Second || client.actionTimeout != 3*time.Second {
+		t.Errorf("timeouts = %v/%v, want 30s/3s", client.transcriptTimeout, client.actionTimeout)
+	}
+}
+
+func TestSendKeysCountsPerNode(t *testing.T) {
+	var fail bool
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer agent.Close()
+
+	agents := NewAgentRegistry(0)
+	agents.Register("good", agent.URL, "")
+	o := &agentProxyOps{
+		agents: agents,
+		client: newAgentClient(0, 0),
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		sends:  newSendKeysCounters(),
+	}
+
+	if err := o.SendKeys("good", "%1", "yes", true); err != nil {
+		t.Fatalf("SendKeys: %v", err)
+	}
+	fail = true
+	if err := o.SendKeys("good", "%1", "yes", true); err == nil {
+		t.Fatal("expected agent error")
+	}
+	if err := o.SendKeys("offline", "%1", "yes", true); !errors.Is(err, ErrAgentOffline) {
+		t.Fatalf("err = %v, want ErrAgentOffline", err)
+	}
+
+	got := o.sends.snapshot()
+	if want := (SendKeysStats{Success: 1, Failure: 1}); got["good"] != want {
+		t.Errorf("good = %+v, want %+v", got["good"], want)
+	}
+	if want := (SendKeysStats{Failure: 1}); got["offline"] != want {
+		t.Errorf("offline = %+v, want %+v", got["offline"], want)
+	}
+}
+
+func TestPaneFocusedCachesAgentAnswers(t *testing.T) {
+	var calls atomic.Int32
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		io.WriteString(w, `{"focused":true}`)
+	}))
+	defer agent.Close()
+
+	fake := clock.NewFake(time.Now())
+	agents := NewAgentRegistry(time.Hour)
+	agents.clock = fake
+	agents.Register("node", agent.URL, "")
+	o := &agentProxyOps{
+		agents: agents,
+		client: newAgentClient(0, 0),
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		sends:  newSendKeysCounters(),
+	}
+
+	for range 3 {
+		if !o.PaneFocused("node", "%1") {
+			t.Fatal("PaneFocused = false, want the agent's true")
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("agent called %d times within the TTL, want 1", n)
+	}
+
+	o.PaneFocused("node", "%2")
+	if n := calls.Load(); n != 2 {
+		t.Errorf("agent called %d times, want another call for a different pane", n)
+	}
+
+	fake.Advance(focusCacheTTL)
+	o.PaneFocused("node", "%1")
+	if n := calls.Load(); n != 3 {
+		t.Errorf("agent called %d times, want a fresh call once the TTL passed", n)
+	}
+}
+
+func TestReadTranscriptRetriesDroppedConnection(t *testing.T) {
+	var calls atomic.Int32
+	var dropFirst, empty atomic.Bool
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 && dropFirst.Load() {
+			// Drop the connection without answering, as a restarting agent would.
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		if empty.Load() {
+			io.WriteString(w, `{"messages":[]}`)
+			return
+		}
+		io.WriteString(w, `{"messages":[{"role":"user","blocks":[{"type":"text","text":"hello"}]}]}`)
+	}))
+	defer agent.Close()
+
+	agents := NewAgentRegistry(0)
+	agents.Register("node", agent.URL, "")
+	o := &agentProxyOps{
+		agents: agents,
+		client: newAgentClient(0, 0),
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		sends:  newSendKeysCounters(),
+	}
+
+	dropFirst.Store(true)
+	tr, _, err := o.ReadTranscript("node", "s1", "/tmp", "", "")
+	if err != nil {
+		t.Fatalf("ReadTranscript: %v", err)
+	}
+	if len(tr.Messages) != 1 || calls.Load() != 2 {
+		t.Errorf("got %d messages after %d calls, want the transcript after one retry", len(tr.Messages), calls.Load())
+	}
+
+	// A valid empty transcript is not retried.
+	dropFirst.Store(false)
+	empty.Store(true)
+	calls.Store(0)
+	if tr, _, _ := o.ReadTranscript("node", "s1", "/tmp", "", ""); len(tr.Messages) != 0 || calls.Load() != 1 {
+		t.Errorf("empty transcript: %d messages after %d calls, want 0 after 1", len(tr.Messages), calls.Load())
+	}
+}
+
+func TestGetTranscriptMarksConnectionErrors(t *testing.T) {
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	c := newAgentClient(0, 0)
+
+	if _, _, err := c.GetTranscript(agent.URL, "s1", "/tmp", "", ""); err == nil || errors.Is(err, ErrAgentUnreachable) {
+		t.Errorf("500 from agent: err = %v, want a non-connection error", err)
+	}
+	agent.Close()
+	if _, _, err := c.GetTranscript(agent.URL, "s1", "/tmp", "", ""); !errors.Is(err, ErrAgentUnreachable) {
+		t.Errorf("closed agent: err = %v, want ErrAgentUnreachable", err)
+	}
+}
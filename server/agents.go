@@ -1,41 +1,83 @@
 package server
 
 import (
+	"sort"
 	"sync"
 	"time"
+
+	"github.com/phinze/sophon/clock"
 )
 
 // AgentInfo represents a registered agent.
 type AgentInfo struct {
 	NodeName string
 	URL      string
+	Version  string // sophon version the agent reported; empty for older agents
 	LastSeen time.Time
+
+	online bool // health last reported to event subscribers
 }
 
-const agentStaleTimeout = 90 * time.Second
+// DefaultAgentStaleTimeout is how long an agent may go without a heartbeat
+// before it is considered unhealthy. Three missed 30s heartbeats.
+const DefaultAgentStaleTimeout = 90 * time.Second
 
 // AgentRegistry tracks registered agents by node name.
 type AgentRegistry struct {
-	mu     sync.RWMutex
-	agents map[string]*AgentInfo
+	mu           sync.RWMutex
+	agents       map[string]*AgentInfo
+	staleTimeout time.Duration
+	clock        clock.Clock
 }
 
-// NewAgentRegistry creates a new AgentRegistry.
-func NewAgentRegistry() *AgentRegistry {
+// NewAgentRegistry creates a new AgentRegistry. A zero staleTimeout uses
+// DefaultAgentStaleTimeout; it must exceed the agents' heartbeat interval or
+// healthy agents will flap offline between heartbeats.
+func NewAgentRegistry(staleTimeout time.Duration) *AgentRegistry {
+	if staleTimeout <= 0 {
+		staleTimeout = DefaultAgentStaleTimeout
+	}
 	return &AgentRegistry{
-		agents: make(map[string]*AgentInfo),
+		agents:       make(map[string]*AgentInfo),
+		staleTimeout: staleTimeout,
+		clock:        clock.Real{},
 	}
 }
 
-// Register adds or updates an agent registration.
-func (r *AgentRegistry) Register(nodeName, url string) {
+// StaleTimeout returns the heartbeat gap after which an agent is unhealthy.
+func (r *AgentRegistry) StaleTimeout() time.Duration {
+	return r.staleTimeout
+}
+
+// Register adds or updates an agent registration. It reports whether the
+// agent transitioned to online, i.e. it is new or had previously gone stale.
+func (r *AgentRegistry) Register(nodeName, url, version string) bool {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	prev, ok := r.agents[nodeName]
 	r.agents[nodeName] = &AgentInfo{
 		NodeName: nodeName,
 		URL:      url,
-		LastSeen: time.Now(),
+		Version:  version,
+		LastSeen: r.clock.Now(),
+		online:   true,
 	}
+	return !ok || !prev.online
+}
+
+// ExpireStale marks agents whose heartbeats have lapsed as offline and returns
+// the node names that transitioned since the last call.
+func (r *AgentRegistry) ExpireStale() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var expired []string
+	for name, info := range r.agents {
+		if info.online && r.clock.Now().Sub(info.LastSeen) >= r.staleTimeout {
+			info.online = false
+			expired = append(expired, name)
+		}
+	}
+	return expired
 }
 
 // Get returns the agent info for a node, if registered.
@@ -46,6 +88,18 @@ func (r *AgentRegistry) Get(nodeName string) (*AgentInfo, bool) {
 	return info, ok
 }
 
+// List returns a copy of every registered agent, sorted by node name.
+func (r *AgentRegistry) List() []AgentInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	list := make([]AgentInfo, 0, len(r.agents))
+	for _, info := range r.agents {
+		list = append(list, *info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].NodeName < list[j].NodeName })
+	return list
+}
+
 // IsHealthy returns true if the agent is registered and was seen recently.
 func (r *AgentRegistry) IsHealthy(nodeName string) bool {
 	r.mu.RLock()
@@ -54,5 +108,5 @@ func (r *AgentRegistry) IsHealthy(nodeName string) bool {
 	if !ok {
 		return false
 	}
-	return time.Since(info.LastSeen) < agentStaleTimeout
+	return r.clock.Now().Sub(info.LastSeen) < r.staleTimeout
 }
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/phinze/sophon/clock"
+)
+
+func TestAgentRegistryStaleTimeout(t *testing.T) {
+	r := NewAgentRegistry(50 * time.Millisecond)
+	fake := clock.NewFake(time.Now())
+	r.clock = fake
+
+	if r.IsHealthy("node1") {
+		t.Fatal("unregistered agent should not be healthy")
+	}
+
+	r.Register("node1", "http://127.0.0.1:2588", "")
+	if !r.IsHealthy("node1") {
+		t.Fatal("freshly registered agent should be healthy")
+	}
+
+	fake.Advance(80 * time.Millisecond)
+	if r.IsHealthy("node1") {
+		t.Error("agent should be stale after the timeout")
+	}
+	if expired := r.ExpireStale(); len(expired) != 1 || expired[0] != "node1" {
+		t.Errorf("ExpireStale = %v, want [node1]", expired)
+	}
+
+	if !r.Register("node1", "http://127.0.0.1:2588", "") {
+		t.Error("re-registering a stale agent should report an online transition")
+	}
+	if !r.IsHealthy("node1") {
+		t.Error("agent should be healthy again after re-registering")
+	}
+}
+
+func TestAgentRegistryDefaultStaleTimeout(t *testing.T) {
+	r := NewAgentRegistry(0)
+	if r.StaleTimeout() != DefaultAgentStaleTimeout {
+		t.Errorf("StaleTimeout = %v, want %v", r.StaleTimeout(), DefaultAgentStaleTimeout)
+	}
+}
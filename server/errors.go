@@ -0,0 +1,40 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Error codes carried in structured error responses, so clients can tell
+// failure kinds apart without parsing messages.
+const (
+	errCodeBadRequest   = "bad_request"
+	errCodeNotFound     = "not_found"
+	errCodeTooLarge     = "payload_too_large"
+	errCodeAgentOffline = "agent_offline"
+	errCodePaneGone     = "pane_gone"
+	errCodeNoPane       = "no_pane"
+	errCodeSendFailed   = "send_failed"
+	errCodeNoQuestion   = "no_pending_question"
+	errCodeInternal     = "internal"
+)
+
+// apiError is the body of every error response:
+// {"error":{"code":"not_found","message":"session not found"}}.
+type apiError struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// writeJSONError writes a structured error response.
+func writeJSONError(w http.ResponseWriter, status int, code, message string) {
+	var body apiError
+	body.Error.Code = code
+	body.Error.Message = message
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
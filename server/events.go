@@ -2,19 +2,24 @@ package server
 
 import (
 	"encoding/json"
+	"slices"
 	"sync"
+	"sync/atomic"
 )
 
 // EventType identifies the kind of SSE event.
 type EventType string
 
 const (
-	EventNotification  EventType = "notification"
-	EventActivity      EventType = "activity"
-	EventToolActivity  EventType = "tool_activity"
-	EventSessionEnd    EventType = "session_end"
-	EventSessionStart  EventType = "session_start"
-	EventResponse      EventType = "response"
+	EventNotification EventType = "notification"
+	EventActivity     EventType = "activity"
+	EventToolActivity EventType = "tool_activity"
+	EventSessionEnd   EventType = "session_end"
+	EventSessionStart EventType = "session_start"
+	EventResponse     EventType = "response"
+	EventAgentStatus  EventType = "agent_status"
+	EventSnapshot     EventType = "snapshot"
+	EventBusy         EventType = "busy"
 )
 
 // globalKey is the sentinel subscription key for global (all-session) subscribers.
@@ -27,16 +32,52 @@ type Event struct {
 	Data    json.RawMessage `json:"data,omitempty"`
 }
 
-// EventHub is a fan-out pub/sub hub keyed by session ID.
+// AgentStatus is the payload of an EventAgentStatus event, published to
+// global subscribers when an agent's health changes.
+type AgentStatus struct {
+	Node   string `json:"node"`
+	Online bool   `json:"online"`
+}
+
+// Activity is the payload of an EventActivity event, published when a turn
+// ends. ElapsedSeconds is the time since the session's previous activity.
+type Activity struct {
+	ElapsedSeconds int64 `json:"elapsed_seconds"`
+}
+
+// Busy is the payload of an EventBusy event, published when a session
+// starts its first tool of a turn and again when the turn ends.
+type Busy struct {
+	Busy bool `json:"busy"`
+}
+
+// Snapshot is the payload of the EventSnapshot event that opens each global
+// stream, so dashboards get their initial state from the stream itself
+// rather than racing a separate /api/sessions fetch.
+type Snapshot struct {
+	Active []sessionResponse `json:"active"`
+}
+
+// historySize is how many recent events are kept per session for replay.
+const historySize = 50
+
+// EventHub is a fan-out pub/sub hub keyed by session ID. It also keeps a
+// short per-session history so a client that just connected can catch up.
 type EventHub struct {
-	mu   sync.Mutex
-	subs map[string]map[chan Event]struct{}
+	mu sync.Mutex
+	// subs holds each key's subscribers. The slices are copy-on-write:
+	// subscribe and unsubscribe replace them rather than edit in place, so
+	// Publish can send to a slice after releasing the lock without copying.
+	subs    map[string][]chan Event
+	history map[string][]Event
+	dropped atomic.Uint64
 }
 
 // NewEventHub creates a new EventHub.
 func NewEventHub() *EventHub {
 	return &EventHub{
-		subs: make(map[string]map[chan Event]struct{}),
+		subs:    make(map[string][]chan Event),
+		history: make(map[string][]Event),
 	}
 }
 
@@ -50,52 +91,103 @@ func (h *EventHub) SubscribeGlobal() (<-chan Event, func()) {
 // an unsubscribe function. The caller must call the returned function when done.
 func (h *EventHub) Subscribe(sessionID string) (<-chan Event, func()) {
 	ch := make(chan Event, 16)
+	return ch, h.subscribe(sessionID, ch)
+}
 
+// subscribe registers ch under key and returns its unsubscribe function.
+func (h *EventHub) subscribe(sessionID string, ch chan Event) func() {
 	h.mu.Lock()
-	if h.subs[sessionID] == nil {
-		h.subs[sessionID] = make(map[chan Event]struct{})
+	if subs := h.subs[sessionID]; !slices.Contains(subs, ch) {
+		h.subs[sessionID] = append(slices.Clip(subs), ch)
 	}
-	h.subs[sessionID][ch] = struct{}{}
 	h.mu.Unlock()
 
 	unsub := func() {
 		h.mu.Lock()
-		delete(h.subs[sessionID], ch)
-		if len(h.subs[sessionID]) == 0 {
+		subs := slices.DeleteFunc(slices.Clone(h.subs[sessionID]), func(c chan Event) bool { return c == ch })
+		if len(subs) == 0 {
 			delete(h.subs, sessionID)
+		} else {
+			h.subs[sessionID] = subs
 		}
 		h.mu.Unlock()
 	}
 
-	return ch, unsub
+	return unsub
 }
 
 // Publish sends an event to all subscribers for the given session and to
-// all global subscribers. If a subscriber's buffer is full the event is
-// dropped (non-blocking).
+// all global subscribers. A channel registered under both receives the event
+// once. If a subscriber's buffer is full the event is dropped (non-blocking).
 func (h *EventHub) Publish(sessionID string, evt Event) {
 	h.mu.Lock()
-	// Collect session-specific and global subscribers under lock.
-	sessionSubs := h.subs[sessionID]
-	globalSubs := h.subs[globalKey]
-	chs := make([]chan Event, 0, len(sessionSubs)+len(globalSubs))
-	for ch := range sessionSubs {
-		chs = append(chs, ch)
+	hist := h.history[sessionID]
+	if len(hist) == historySize {
+		// Shift in place so a full history stops reallocating.
+		copy(hist, hist[1:])
+		hist[len(hist)-1] = evt
+	} else {
+		hist = append(hist, evt)
 	}
-	for ch := range globalSubs {
-		chs = append(chs, ch)
+	h.history[sessionID] = hist
+	sessionSubs := h.subs[sessionID]
+	var globalSubs []chan Event
+	if sessionID != globalKey {
+		globalSubs = h.subs[globalKey]
 	}
 	h.mu.Unlock()
 
-	for _, ch := range chs {
-		select {
-		case ch <- evt:
-		default:
-			// Buffer full — drop event; client can refetch via transcript API.
+	for _, ch := range sessionSubs {
+		h.send(ch, evt)
+	}
+	for _, ch := range globalSubs {
+		if !slices.Contains(sessionSubs, ch) {
+			h.send(ch, evt)
 		}
 	}
 }
 
+// send delivers evt to ch without blocking. If the buffer is full the event
+// is dropped; the client can refetch via the transcript API.
+func (h *EventHub) send(ch chan Event, evt Event) {
+	select {
+	case ch <- evt:
+	default:
+		h.dropped.Add(1)
+	}
+}
+
+// PublishGlobal sends an event that isn't tied to any session to global
+// subscribers only.
+func (h *EventHub) PublishGlobal(evt Event) {
+	h.mu.Lock()
+	subs := h.subs[globalKey]
+	h.mu.Unlock()
+
+	for _, ch := range subs {
+		h.send(ch, evt)
+	}
+}
+
+// History returns up to limit of the session's most recent events, oldest
+// first. A limit of 0 or less returns everything retained.
+func (h *EventHub) History(sessionID string, limit int) []Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hist := h.history[sessionID]
+	if limit > 0 && len(hist) > limit {
+		hist = hist[len(hist)-limit:]
+	}
+	return append([]Event{}, hist...)
+}
+
+// Forget discards a session's retained history.
+func (h *EventHub) Forget(sessionID string) {
+	h.mu.Lock()
+	delete(h.history, sessionID)
+	h.mu.Unlock()
+}
+
 // SubscriberCount returns the number of active subscribers for a session.
 func (h *EventHub) SubscriberCount(sessionID string) int {
 	h.mu.Lock()
@@ -103,6 +195,12 @@ func (h *EventHub) SubscriberCount(sessionID string) int {
 	return len(h.subs[sessionID])
 }
 
+// Dropped returns the number of events discarded because a subscriber's
+// buffer was full.
+func (h *EventHub) Dropped() uint64 {
+	return h.dropped.Load()
+}
+
 // mustJSON marshals v to json.RawMessage, panicking on error.
 func mustJSON(v interface{}) json.RawMessage {
 	data, err := json.Marshal(v)
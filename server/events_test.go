@@ -3,8 +3,10 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -98,6 +100,114 @@ func TestEventHubIsolation(t *testing.T) {
 	}
 }
 
+func TestEventHubDeliversOnceToDoubleSubscriber(t *testing.T) {
+	hub := NewEventHub()
+	ch := make(chan Event, 16)
+	unsubSession := hub.subscribe("s1", ch)
+	defer unsubSession()
+	unsubGlobal := hub.subscribe(globalKey, ch)
+	defer unsubGlobal()
+
+	hub.Publish("s1", Event{Type: EventActivity, Session: "s1"})
+
+	if n := len(ch); n != 1 {
+		t.Errorf("received %d copies, want 1", n)
+	}
+}
+
+func TestEventHubGlobalKeyPublishDeliversOnce(t *testing.T) {
+	hub := NewEventHub()
+	ch, unsub := hub.SubscribeGlobal()
+	defer unsub()
+
+	// Publishing under the global key itself must not double up.
+	hub.Publish(globalKey, Event{Type: EventActivity})
+
+	if n := len(ch); n != 1 {
+		t.Errorf("received %d copies, want 1", n)
+	}
+}
+
+func TestEventHubHistoryCapped(t *testing.T) {
+	hub := NewEventHub()
+	for i := range historySize + 5 {
+		hub.Publish("s1", Event{Type: EventToolActivity, Session: "s1", Data: mustJSON(map[string]int{"n": i})})
+	}
+	hist := hub.History("s1", 0)
+	if len(hist) != historySize {
+		t.Fatalf("history length = %d, want %d", len(hist), historySize)
+	}
+	if string(hist[0].Data) != `{"n":5}` {
+		t.Errorf("oldest retained event = %s, want n=5", hist[0].Data)
+	}
+
+	hub.Forget("s1")
+	if got := hub.History("s1", 0); len(got) != 0 {
+		t.Errorf("history after Forget = %v", got)
+	}
+}
+
+func BenchmarkPublish(b *testing.B) {
+	hub := NewEventHub()
+	for range 4 {
+		_, unsub := hub.Subscribe("s1")
+		defer unsub()
+		_, unsubGlobal := hub.SubscribeGlobal()
+		defer unsubGlobal()
+	}
+	evt := Event{Type: EventToolActivity, Session: "s1"}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		// Subscribers never drain, so this measures the drop path too.
+		hub.Publish("s1", evt)
+	}
+}
+
+func TestEventHistoryEndpoint(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+	h.notify(t, "s1", "permission_prompt", "Allow Bash?")
+	h.toolActivity(t, "s1", "PreToolUse", "Bash")
+	h.turnEnd(t, "s1")
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/sessions/s1/events/history"+query, nil)
+		req.SetPathValue("id", "s1")
+		w := httptest.NewRecorder()
+		h.server.handleEventHistory(w, req)
+		return w
+	}
+
+	w := get("")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d, want 200", w.Code)
+	}
+	var events []Event
+	if err := json.NewDecoder(w.Body).Decode(&events); err != nil {
+		t.Fatal(err)
+	}
+	want := []EventType{EventSessionStart, EventNotification, EventToolActivity, EventBusy, EventBusy, EventActivity}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for i, evt := range events {
+		if evt.Type != want[i] || evt.Session != "s1" {
+			t.Errorf("event %d = %s/%s, want %s/s1", i, evt.Type, evt.Session, want[i])
+		}
+	}
+
+	events = nil
+	json.NewDecoder(get("?limit=2").Body).Decode(&events)
+	if len(events) != 2 || events[0].Type != EventBusy || events[1].Type != EventActivity {
+		t.Errorf("limit=2 returned %+v", events)
+	}
+
+	if w := get("?limit=x"); w.Code != http.StatusBadRequest {
+		t.Errorf("bad limit: got %d, want 400", w.Code)
+	}
+}
+
 func TestSSEEndpoint(t *testing.T) {
 	h := newTestHarness(t)
 	h.createSession(t, "s1", "%5", "/home/user/project")
@@ -246,3 +356,354 @@ func TestRespondPublishesSSEEvent(t *testing.T) {
 		t.Fatal("timed out waiting for SSE event")
 	}
 }
+
+func TestAgentStatusTransitionsPublishGlobally(t *testing.T) {
+	h := newTestHarness(t)
+	ch, unsub := h.server.events.SubscribeGlobal()
+	defer unsub()
+
+	register := func() {
+		body := strings.NewReader(`{"node_name":"node1","url":"http://127.0.0.1:2588"}`)
+		req := httptest.NewRequest("POST", "/api/agents/register", body)
+		w := httptest.NewRecorder()
+		h.server.handleAgentRegister(w, req)
+	}
+	expectStatus := func(wantOnline bool) {
+		t.Helper()
+		select {
+		case evt := <-ch:
+			if evt.Type != EventAgentStatus {
+				t.Fatalf("type = %q, want %q", evt.Type, EventAgentStatus)
+			}
+			var st AgentStatus
+			if err := json.Unmarshal(evt.Data, &st); err != nil {
+				t.Fatal(err)
+			}
+			if st.Node != "node1" || st.Online != wantOnline {
+				t.Errorf("status = %+v, want node1 online=%v", st, wantOnline)
+			}
+		default:
+			t.Fatalf("expected agent_status online=%v event", wantOnline)
+		}
+	}
+	expectNone := func() {
+		t.Helper()
+		select {
+		case evt := <-ch:
+			t.Fatalf("unexpected event %q", evt.Type)
+		default:
+		}
+	}
+
+	register()
+	expectStatus(true)
+
+	// Heartbeats from a healthy agent are not transitions.
+	register()
+	h.server.checkAgentHealth()
+	expectNone()
+
+	// Simulate a heartbeat gap.
+	info, _ := h.server.agents.Get("node1")
+	info.LastSeen = time.Now().Add(-2 * DefaultAgentStaleTimeout)
+	h.server.checkAgentHealth()
+	expectStatus(false)
+
+	// Already reported offline; no repeat.
+	h.server.checkAgentHealth()
+	expectNone()
+
+	register()
+	expectStatus(true)
+}
+
+// captureSSE runs handler against req until an event is published and
+// flushed, returning the data line of the first non-"connected" event.
+func captureSSE(t *testing.T, h *testHarness, handler http.HandlerFunc, req *http.Request, subscribed func() bool, evt Event) string {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req = req.WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		handler(w, req)
+		close(done)
+	}()
+	for i := 0; i < 50 && !subscribed(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	h.server.events.Publish(evt.Session, evt)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	for _, block := range strings.Split(w.Body.String(), "\n\n") {
+		if strings.HasPrefix(block, "event: "+string(evt.Type)+"\n") {
+			return strings.TrimPrefix(strings.SplitN(block, "\n", 2)[1], "data: ")
+		}
+	}
+	t.Fatalf("no %s event in SSE output: %q", evt.Type, w.Body.String())
+	return ""
+}
+
+func TestSSEWireFormatMatchesAcrossEndpoints(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+
+	evt := Event{
+		Type:    EventNotification,
+		Session: "s1",
+		Data:    mustJSON(map[string]string{"type": "permission_prompt", "message": "Allow Bash?"}),
+	}
+
+	sessionReq := httptest.NewRequest("GET", "/api/sessions/s1/events", nil)
+	sessionReq.SetPathValue("id", "s1")
+	perSession := captureSSE(t, h, h.server.handleSSE, sessionReq,
+		func() bool { return h.server.events.SubscriberCount("s1") > 0 }, evt)
+
+	globalReq := httptest.NewRequest("GET", "/api/events", nil)
+	global := captureSSE(t, h, h.server.handleGlobalSSE, globalReq,
+		func() bool { return h.server.events.SubscriberCount(globalKey) > 0 }, evt)
+
+	if perSession != global {
+		t.Errorf("wire formats differ:\n  per-session: %s\n  global:      %s", perSession, global)
+	}
+
+	var got Event
+	if err := json.Unmarshal([]byte(perSession), &got); err != nil {
+		t.Fatalf("decoding event envelope: %v", err)
+	}
+	if got.Type != EventNotification || got.Session != "s1" {
+		t.Errorf("envelope = %+v", got)
+	}
+}
+
+func TestGlobalSSEFiltersByProject(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "mine", "%1", "/home/user/project")
+	h.createSession(t, "theirs", "%2", "/home/user/other")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req := httptest.NewRequest("GET", "/api/events?project=user/project", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		h.server.handleGlobalSSE(w, req)
+		close(done)
+	}()
+	for i := 0; i < 50 && h.server.events.SubscriberCount(globalKey) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	h.server.events.Publish("theirs", Event{Type: EventActivity, Session: "theirs"})
+	h.server.events.Publish("mine", Event{Type: EventNotification, Session: "mine"})
+	h.server.events.Publish("theirs", Event{Type: EventToolActivity, Session: "theirs"})
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	body := w.Body.String()
+	if !strings.Contains(body, `"session_id":"mine"`) {
+		t.Errorf("missing event for matching project: %q", body)
+	}
+	if strings.Contains(body, `"session_id":"theirs"`) {
+		t.Errorf("event for other project leaked through filter: %q", body)
+	}
+}
+
+func TestProjectSSEIsolatesProjects(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "mine", "%1", "/home/user/project")
+	h.createSession(t, "theirs", "%2", "/home/user/other")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req := httptest.NewRequest("GET", "/api/projects/user%2Fproject/events", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		h.server.routes().ServeHTTP(w, req)
+		close(done)
+	}()
+	for i := 0; i < 50 && h.server.events.SubscriberCount(globalKey) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	h.server.events.Publish("theirs", Event{Type: EventActivity, Session: "theirs"})
+	h.server.events.Publish("mine", Event{Type: EventNotification, Session: "mine"})
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	body := w.Body.String()
+	if !strings.Contains(body, "event: notification") {
+		t.Errorf("missing event for the stream's project: %q", body)
+	}
+	if strings.Contains(body, "theirs") {
+		t.Errorf("other project's session leaked into the stream: %q", body)
+	}
+	if !strings.Contains(body, `"active":[{"session_id":"mine"`) {
+		t.Errorf("snapshot missing the project's active session: %q", body)
+	}
+}
+
+func TestStreamFilterFollowsProjectChanges(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/alpha")
+	sess, _ := h.store.GetSession("s1")
+	filter := &streamFilter{project: "beta", store: h.store, seen: make(map[string]bool)}
+
+	if filter.match(Event{Type: EventActivity, Session: "s1"}) {
+		t.Fatal("alpha session matched a beta filter")
+	}
+	sess.Project = "beta"
+	h.store.UpdateSession(sess)
+	if !filter.match(Event{Type: EventActivity, Session: "s1"}) {
+		t.Error("activity after a project change should re-check the session")
+	}
+	if !filter.match(Event{Type: EventToolActivity, Session: "s1"}) {
+		t.Error("the fresh verdict should be cached")
+	}
+}
+
+func TestGlobalSSESendsSnapshotFirst(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%1", "/home/user/project")
+	h.createSession(t, "s2", "%2", "/home/user/other")
+	h.createSession(t, "done", "%3", "/home/user/project")
+	h.endSession(t, "done")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req := httptest.NewRequest("GET", "/api/events", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		h.server.handleGlobalSSE(w, req)
+		close(done)
+	}()
+	for i := 0; i < 50 && h.server.events.SubscriberCount(globalKey) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	h.server.events.Publish("s1", Event{Type: EventActivity, Session: "s1"})
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	var types []string
+	var snapshot Event
+	for _, block := range strings.Split(strings.TrimSpace(w.Body.String()), "\n\n") {
+		name, data, _ := strings.Cut(block, "\n")
+		types = append(types, strings.TrimPrefix(name, "event: "))
+		if name == "event: snapshot" {
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(data, "data: ")), &snapshot); err != nil {
+				t.Fatalf("decoding snapshot: %v", err)
+			}
+		}
+	}
+	if got := strings.Join(types, ","); got != "connected,snapshot,activity" {
+		t.Fatalf("events = %s, want connected,snapshot,activity", got)
+	}
+
+	var payload struct {
+		Active []struct {
+			ID          string `json:"session_id"`
+			AgentOnline *bool  `json:"agent_online"`
+		} `json:"active"`
+	}
+	if err := json.Unmarshal(snapshot.Data, &payload); err != nil {
+		t.Fatalf("decoding snapshot payload: %v", err)
+	}
+	var ids []string
+	for _, sess := range payload.Active {
+		ids = append(ids, sess.ID)
+		if sess.AgentOnline == nil {
+			t.Errorf("%s: agent_online missing from snapshot", sess.ID)
+		}
+	}
+	slices.Sort(ids)
+	if !slices.Equal(ids, []string{"s1", "s2"}) {
+		t.Errorf("snapshot sessions = %v, want the active [s1 s2]", ids)
+	}
+}
+
+func TestStreamFilterNode(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%1", "/home/user/project")
+	f := &streamFilter{node: "other-node", store: h.store, seen: make(map[string]bool)}
+
+	if f.match(Event{Type: EventActivity, Session: "s1"}) {
+		t.Error("session on test-node should not match node=other-node")
+	}
+	if !f.match(Event{Type: EventAgentStatus, Data: mustJSON(AgentStatus{Node: "other-node", Online: true})}) {
+		t.Error("agent_status for the filtered node should match")
+	}
+	if f.match(Event{Type: EventAgentStatus, Data: mustJSON(AgentStatus{Node: "test-node"})}) {
+		t.Error("agent_status for another node should not match")
+	}
+}
+
+// failingWriter is a streaming ResponseWriter whose writes start failing
+// after okWrites successful ones, simulating a client that vanished without
+// cancelling the request context.
+type failingWriter struct {
+	header   http.Header
+	okWrites int
+}
+
+func (f *failingWriter) Header() http.Header { return f.header }
+func (f *failingWriter) WriteHeader(int)     {}
+func (f *failingWriter) Flush()              {}
+func (f *failingWriter) Write(p []byte) (int, error) {
+	if f.okWrites <= 0 {
+		return 0, errors.New("broken pipe")
+	}
+	f.okWrites--
+	return len(p), nil
+}
+
+func TestSSEHandlersReturnOnWriteError(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+
+	for _, tc := range []struct {
+		name    string
+		key     string
+		handler http.HandlerFunc
+	}{
+		{"session", "s1", h.server.handleSSE},
+		{"global", globalKey, h.server.handleGlobalSSE},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil) // context never cancelled
+			req.SetPathValue("id", "s1")
+			w := &failingWriter{header: make(http.Header), okWrites: 1} // "connected" succeeds
+
+			done := make(chan struct{})
+			go func() {
+				tc.handler(w, req)
+				close(done)
+			}()
+			for i := 0; i < 50 && h.server.events.SubscriberCount(tc.key) == 0; i++ {
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			h.server.events.Publish("s1", Event{Type: EventActivity, Session: "s1"})
+
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("handler did not return after write error")
+			}
+			if n := h.server.events.SubscriberCount(tc.key); n != 0 {
+				t.Errorf("subscriber count = %d after write error, want 0", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/phinze/sophon/transcript"
+)
+
+// transcriptFlight coalesces concurrent transcript reads with the same key so
+// several clients opening one session share a single agent request.
+type transcriptFlight struct {
+	mu    sync.Mutex
+	calls map[string]*transcriptCall
+}
+
+type transcriptCall struct {
+	done chan struct{}
+	dups int // callers that joined an in-flight read
+
+	tr   *transcript.Transcript
+	etag string
+	err  error
+}
+
+// do runs fn for key unless a read for key is already in flight, in which case
+// it waits for and returns that read's result. Results are shared, so callers
+// must not mutate the returned transcript.
+func (f *transcriptFlight) do(key string, fn func() (*transcript.Transcript, string, error)) (*transcript.Transcript, string, error) {
+	f.mu.Lock()
+	if f.calls == nil {
+		f.calls = make(map[string]*transcriptCall)
+	}
+	if c, ok := f.calls[key]; ok {
+		c.dups++
+		f.mu.Unlock()
+		<-c.done
+		return c.tr, c.etag, c.err
+	}
+	c := &transcriptCall{done: make(chan struct{})}
+	f.calls[key] = c
+	f.mu.Unlock()
+
+	c.tr, c.etag, c.err = fn()
+
+	f.mu.Lock()
+	delete(f.calls, key)
+	f.mu.Unlock()
+	close(c.done)
+	return c.tr, c.etag, c.err
+}
+
+// waiters returns how many callers have joined the in-flight read for key.
+func (f *transcriptFlight) waiters(key string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if c, ok := f.calls[key]; ok {
+		return c.dups
+	}
+	return 0
+}
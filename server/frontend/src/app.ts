@@ -59,6 +59,7 @@ function showWebNotification(sessionId: string, data: NotificationEventData): vo
 sse.on("notification", (e: MessageEvent) => {
   const evt: GlobalEvent = JSON.parse(e.data);
   const data = (evt.data || {}) as NotificationEventData;
+  if (data.muted === "true" || data.quiet === "true") return;
   showWebNotification(evt.session_id, data);
 });
 
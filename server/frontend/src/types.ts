@@ -9,12 +9,19 @@ export interface Session {
   notify_message?: string;
   notified_at?: string;
   tmux_pane?: string;
+  extra_panes?: string[];
   cwd?: string;
   agent_online?: boolean;
+  focused?: boolean;
   topic?: string;
   plan_summary?: string;
   plan_text?: string;
   pane_title?: string;
+  busy?: boolean; // a tool ran since the last turn end
+  window_name?: string;
+  pending_question?: AskQuestion[]; // unanswered AskUserQuestion
+  error_count?: number; // tool calls that returned <tool_use_error>
+  stop_reason?: string; // user_end, superseded, reconciled, pane_gone, or idle_timeout
 }
 
 export interface SessionsResponse {
@@ -32,6 +39,24 @@ export interface NotificationEventData {
   type?: string;
   message?: string;
   title?: string;
+  muted?: string; // "true" when the session is muted
+  quiet?: string; // "true" during the daemon's quiet hours
+}
+
+export interface ActivityEventData {
+  elapsed_seconds: number;
+}
+
+// Sent when a session's first tool of a turn starts, and when the turn ends.
+export interface BusyEventData {
+  busy: boolean;
+}
+
+// First event on /api/events (and /api/projects/{project}/events) after
+// "connected": the active sessions at subscribe time, matching /api/sessions'
+// active list.
+export interface SnapshotEventData {
+  active: Session[];
 }
 
 export interface AskQuestionOption {
@@ -43,6 +68,7 @@ export interface AskQuestion {
   header?: string;
   question: string;
   options?: AskQuestionOption[];
+  multi_select?: boolean; // set on the block's normalized questions
 }
 
 export interface AskQuestionInput {
@@ -58,12 +84,30 @@ export interface PlanInput {
   plan?: string;
 }
 
+export interface BashInput {
+  command?: string;
+  description?: string;
+}
+
 export interface TranscriptBlock {
   type: string;
   text: string;
   summary?: string;
+  has_image_result?: boolean;
+  is_error?: boolean;
+  questions?: AskQuestion[]; // validated AskUserQuestion input
+  tool_use_id?: string; // key for GET /api/sessions/{id}/tools/{tool_use_id}
   // eslint-disable-next-line @typescript-eslint/no-explicit-any
-  input?: AskQuestionInput & WriteInput & PlanInput & Record<string, any>;
+  input?: AskQuestionInput & WriteInput & PlanInput & BashInput & Record<string, any>;
+}
+
+export interface ToolCall {
+  id: string;
+  name: string;
+  input?: Record<string, unknown>;
+  result: string;
+  is_error?: boolean;
+  has_image_result?: boolean;
 }
 
 export interface TranscriptMessage {
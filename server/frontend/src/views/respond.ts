@@ -35,7 +35,11 @@ function send(text: string): void {
         // Clear notification UI since we've responded
         document.querySelector(".context")?.remove();
         document.querySelector(".quick-buttons")?.remove();
-      } else r.text().then((t) => showStatus("Error: " + t, false));
+      } else
+        r.json().then(
+          (body) => showStatus("Error: " + (body.error?.message || r.statusText), false),
+          () => showStatus("Error: " + r.statusText, false),
+        );
     })
     .catch((e) => showStatus("Network error: " + e, false));
 }
@@ -88,7 +92,8 @@ function renderMessageContent(msg: TranscriptMessage): string {
       }
     } else if (b.type === "tool_use") {
       const label = b.summary || b.text;
-      content += '<div class="tool-use">' + escapeHtml(label) + "</div>";
+      const image = b.has_image_result ? ' <span class="tool-image">[image]</span>' : "";
+      content += '<div class="tool-use">' + escapeHtml(label) + image + "</div>";
     } else {
       content += renderMarkdown(b.text);
     }
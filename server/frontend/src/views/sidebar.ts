@@ -14,8 +14,12 @@ function parsePaneTitle(title: string): string {
 const lastToolActivity: Map<string, number> = new Map();
 const WORKING_THRESHOLD_MS = 60_000;
 
-function isWorking(sessionId: string): boolean {
-  const last = lastToolActivity.get(sessionId);
+// A session is working while the daemon reports it busy (a tool ran since
+// its last turn end), unless it has stopped to ask the user something.
+// Recent tool activity covers the moments before the next refresh.
+function isWorking(s: Session): boolean {
+  if (s.busy && !s.notification_type) return true;
+  const last = lastToolActivity.get(s.session_id);
   if (!last) return false;
   return Date.now() - last < WORKING_THRESHOLD_MS;
 }
@@ -27,7 +31,7 @@ function renderSidebarCard(s: Session, isActive: boolean): string {
     ? "dot-stopped"
     : isOffline
       ? "dot-offline"
-      : isWorking(s.session_id)
+      : isWorking(s)
         ? "dot-active"
         : hasNotification
           ? "dot-waiting"
@@ -150,6 +154,7 @@ export function mount(sse: SSEManager): void {
   sse.on("session_end", () => refreshSessions());
   sse.on("activity", () => refreshSessions());
   sse.on("response", () => refreshSessions());
+  sse.on("busy", () => refreshSessions());
   let idleTimer: ReturnType<typeof setTimeout> | null = null;
   sse.on("tool_activity", (e: MessageEvent) => {
     try {
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+)
+
+// gzipHandler compresses responses for clients that accept gzip. SSE streams
+// are passed through untouched: they rely on flushing each event as it is
+// written, which a compressor would hold back.
+func gzipHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isEventStream(r) || !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// isEventStream reports whether r targets one of the SSE endpoints.
+func isEventStream(r *http.Request) bool {
+	return strings.HasSuffix(r.URL.Path, "/events")
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(enc), ";")
+		if strings.TrimSpace(name) == "gzip" && strings.ReplaceAll(params, " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipResponseWriter compresses the body. Headers are held back until the
+// first Write so that bodiless responses (304s, bare 200s) go out without a
+// Content-Encoding and an empty gzip stream.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz         *gzip.Writer
+	status     int
+	headerSent bool
+}
+
+func (g *gzipResponseWriter) WriteHeader(code int) {
+	if g.status == 0 {
+		g.status = code
+	}
+}
+
+func (g *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !g.headerSent {
+		if g.status == 0 {
+			g.status = http.StatusOK
+		}
+		// Partial content refers to byte ranges of the uncompressed file.
+		if len(b) > 0 && g.status != http.StatusPartialContent {
+			g.Header().Set("Content-Encoding", "gzip")
+			g.Header().Del("Content-Length")
+			g.gz = gzip.NewWriter(g.ResponseWriter)
+		}
+		g.sendHeader()
+	}
+	if g.gz == nil {
+		return g.ResponseWriter.Write(b)
+	}
+	return g.gz.Write(b)
+}
+
+func (g *gzipResponseWriter) sendHeader() {
+	g.headerSent = true
+	if g.status != 0 {
+		g.ResponseWriter.WriteHeader(g.status)
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (g *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
+
+func (g *gzipResponseWriter) close() {
+	if !g.headerSent {
+		g.sendHeader()
+	}
+	if g.gz != nil {
+		g.gz.Close()
+	}
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/phinze/sophon/transcript"
+)
+
+func TestGzipTranscriptResponse(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+	h.mockOps.transcripts["s1"] = &transcript.Transcript{Messages: []transcript.Message{
+		{Role: "user", Blocks: []transcript.Block{{Type: "text", Text: strings.Repeat("hello ", 100)}}},
+	}}
+
+	req := httptest.NewRequest("GET", "/api/sessions/s1/transcript", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	w := httptest.NewRecorder()
+	h.server.routes().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d, want 200", w.Code)
+	}
+	if enc := w.Header().Get("Content-Encoding"); enc != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", enc)
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	var tr transcript.Transcript
+	if err := json.NewDecoder(zr).Decode(&tr); err != nil {
+		t.Fatalf("decoding gunzipped body: %v", err)
+	}
+	if len(tr.Messages) != 1 {
+		t.Errorf("got %d messages, want 1", len(tr.Messages))
+	}
+}
+
+func TestGzipSkippedWithoutAcceptEncoding(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+
+	req := httptest.NewRequest("GET", "/api/sessions/s1", nil)
+	w := httptest.NewRecorder()
+	h.server.routes().ServeHTTP(w, req)
+
+	if enc := w.Header().Get("Content-Encoding"); enc != "" {
+		t.Errorf("Content-Encoding = %q, want none", enc)
+	}
+}
+
+func TestGzipBodilessResponse(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+
+	req := httptest.NewRequest("DELETE", "/api/sessions/s1", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h.server.routes().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d, want 200", w.Code)
+	}
+	if enc := w.Header().Get("Content-Encoding"); enc != "" || w.Body.Len() != 0 {
+		t.Errorf("bodiless response should not be encoded: encoding %q, %d bytes", enc, w.Body.Len())
+	}
+}
+
+func TestGzipSkipsSSE(t *testing.T) {
+	h := newTestHarness(t)
+	srv := httptest.NewServer(h.server.routes())
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/api/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if enc := resp.Header.Get("Content-Encoding"); enc != "" {
+		t.Fatalf("SSE Content-Encoding = %q, want none", enc)
+	}
+	// The connected event must arrive immediately, uncompressed.
+	line, err := bufio.NewReader(io.LimitReader(resp.Body, 1024)).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "event: connected\n" {
+		t.Errorf("first line = %q", line)
+	}
+}
@@ -0,0 +1,257 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"time"
+
+	"github.com/phinze/sophon/tmux"
+	"github.com/phinze/sophon/transcript"
+)
+
+// localOps reads transcripts from the daemon's own filesystem, for
+// single-machine setups where no agent runs alongside Claude Code. Unless the
+// tmux functions are set (see withTmux), it can't drive tmux and pane
+// operations report the agent as offline.
+type localOps struct {
+	claudeDir string
+	logger    *slog.Logger
+
+	// Injectable for testing; nil unless the daemon runs an in-process agent.
+	paneFocused    func(pane string) bool
+	paneExists     func(pane string) bool
+	sendKeys       func(pane, text string, enter bool) error
+	listAgentPanes func() (map[string]bool, error)
+	listPaneTitles func() (map[string]string, error)
+	paneInfo       func(pane string) (tmux.Pane, error)
+}
+
+// withTmux wires o to the local tmux server, letting it stand in for an agent.
+// enterDelay is the pause between sent text and its Enter key press.
+func (o *localOps) withTmux(enterDelay time.Duration) *localOps {
+	o.paneFocused = tmux.PaneFocused
+	o.paneExists = tmux.PaneExists
+	o.sendKeys = tmux.Sender{EnterDelay: enterDelay}.SendKeys
+	o.listAgentPanes = tmux.ListAgentPanes
+	o.listPaneTitles = tmux.ListPaneTitles
+	o.paneInfo = tmux.PaneInfo
+	return o
+}
+
+func (o *localOps) drivesTmux() bool {
+	return o.sendKeys != nil
+}
+
+func (o *localOps) PaneFocused(nodeName, pane string) bool {
+	if !o.drivesTmux() {
+		return false
+	}
+	return o.paneFocused(pane)
+}
+
+func (o *localOps) PaneInfo(nodeName, pane string) (tmux.Pane, error) {
+	if !o.drivesTmux() {
+		return tmux.Pane{}, fmt.Errorf("%w for node %q", ErrAgentOffline, nodeName)
+	}
+	if !o.paneExists(pane) {
+		return tmux.Pane{}, ErrPaneGone
+	}
+	return o.paneInfo(pane)
+}
+
+func (o *localOps) SendKeys(nodeName, pane, text string, enter bool) error {
+	if !o.drivesTmux() {
+		return fmt.Errorf("%w for node %q", ErrAgentOffline, nodeName)
+	}
+	if !o.paneExists(pane) {
+		return ErrPaneGone
+	}
+	return o.sendKeys(pane, text, enter)
+}
+
+func (o *localOps) ReadTranscript(nodeName, sessionID, cwd, transcriptPath, etag string) (*transcript.Transcript, string, error) {
+	path := o.path(transcriptPath, cwd, sessionID)
+
+	// Stat before reading so the ETag never claims newer content than we return.
+	newETag := ""
+	info, statErr := transcript.Stat(path)
+	if statErr == nil {
+		newETag = fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+		if etag != "" && etag == newETag {
+			return nil, newETag, ErrNotModified
+		}
+	}
+
+	debug := &transcript.Debug{Path: path, Exists: statErr == nil}
+	tr, err := transcript.Read(path)
+	if err != nil {
+		o.logger.Debug("local transcript read failed", "path", path, "error", err)
+		return &transcript.Transcript{Version: transcript.FormatVersion, Debug: debug}, "", nil
+	}
+	for _, warning := range tr.Warnings {
+		o.logger.Warn("transcript record skipped", "path", path, "warning", warning)
+	}
+	tr.Debug = debug
+	return tr, newETag, nil
+}
+
+func (o *localOps) ReadSummary(ctx context.Context, nodeName, sessionID, cwd, transcriptPath string) (*transcript.SessionSummary, error) {
+	path := o.path(transcriptPath, cwd, sessionID)
+	tr, err := transcript.Read(path)
+	if err != nil {
+		o.logger.Debug("local summary transcript read failed", "path", path, "error", err)
+		return nil, nil
+	}
+	summary := transcript.ExtractSummary(tr)
+	return &summary, nil
+}
+
+func (o *localOps) ReadRawTranscript(ctx context.Context, nodeName, sessionID, cwd, transcriptPath string) (io.ReadCloser, error) {
+	return transcript.OpenRaw(o.claudeDir, o.path(transcriptPath, cwd, sessionID))
+}
+
+func (o *localOps) ReadToolCall(nodeName, sessionID, cwd, transcriptPath, toolUseID string) (*transcript.ToolCall, error) {
+	call, err := transcript.FindToolCall(o.path(transcriptPath, cwd, sessionID), toolUseID)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, transcript.ErrToolCallNotFound
+	}
+	return call, err
+}
+
+// path prefers the transcript path reported by the hooks, falling back to
+// recomputing it from the cwd slug, the same way the agent does. Hooks are
+// unauthenticated, so a reported path is only used when it resolves inside
+// the Claude directory.
+func (o *localOps) path(provided, cwd, sessionID string) string {
+	if provided != "" {
+		if resolved, ok := transcript.ResolveIn(o.claudeDir, provided); ok {
+			return resolved
+		}
+		o.logger.Warn("ignoring transcript path outside the Claude directory", "session_id", sessionID, "path", provided)
+	}
+	return transcript.TranscriptPath(o.claudeDir, cwd, sessionID)
+}
+
+// localFallbackOps serves transcripts and summaries from the local filesystem
+// for the daemon's own node, and proxies everything else to agents. Other
+// nodes' paths mean nothing on this machine, even when their agent is down.
+type localFallbackOps struct {
+	remote   *agentProxyOps
+	local    *localOps
+	nodeName string
+}
+
+func (o *localFallbackOps) useLocal(nodeName string) bool {
+	return nodeName == o.nodeName
+}
+
+// usesLocalTmux reports whether pane operations for nodeName go to the
+// in-process agent rather than a remote one.
+func (o *localFallbackOps) usesLocalTmux(nodeName string) bool {
+	return nodeName == o.nodeName && o.local.drivesTmux()
+}
+
+func (o *localFallbackOps) PaneFocused(nodeName, pane string) bool {
+	if o.usesLocalTmux(nodeName) {
+		return o.local.PaneFocused(nodeName, pane)
+	}
+	return o.remote.PaneFocused(nodeName, pane)
+}
+
+func (o *localFallbackOps) PaneInfo(nodeName, pane string) (tmux.Pane, error) {
+	if o.usesLocalTmux(nodeName) {
+		return o.local.PaneInfo(nodeName, pane)
+	}
+	return o.remote.PaneInfo(nodeName, pane)
+}
+
+func (o *localFallbackOps) SendKeys(nodeName, pane, text string, enter bool) error {
+	if o.usesLocalTmux(nodeName) {
+		return o.local.SendKeys(nodeName, pane, text, enter)
+	}
+	return o.remote.SendKeys(nodeName, pane, text, enter)
+}
+
+func (o *localFallbackOps) ReadTranscript(nodeName, sessionID, cwd, transcriptPath, etag string) (*transcript.Transcript, string, error) {
+	if o.useLocal(nodeName) {
+		return o.local.ReadTranscript(nodeName, sessionID, cwd, transcriptPath, etag)
+	}
+	return o.remote.ReadTranscript(nodeName, sessionID, cwd, transcriptPath, etag)
+}
+
+func (o *localFallbackOps) ReadSummary(ctx context.Context, nodeName, sessionID, cwd, transcriptPath string) (*transcript.SessionSummary, error) {
+	if o.useLocal(nodeName) {
+		return o.local.ReadSummary(ctx, nodeName, sessionID, cwd, transcriptPath)
+	}
+	return o.remote.ReadSummary(ctx, nodeName, sessionID, cwd, transcriptPath)
+}
+
+func (o *localFallbackOps) ReadToolCall(nodeName, sessionID, cwd, transcriptPath, toolUseID string) (*transcript.ToolCall, error) {
+	if o.useLocal(nodeName) {
+		return o.local.ReadToolCall(nodeName, sessionID, cwd, transcriptPath, toolUseID)
+	}
+	return o.remote.ReadToolCall(nodeName, sessionID, cwd, transcriptPath, toolUseID)
+}
+
+func (o *localFallbackOps) ReadRawTranscript(ctx context.Context, nodeName, sessionID, cwd, transcriptPath string) (io.ReadCloser, error) {
+	if o.useLocal(nodeName) {
+		return o.local.ReadRawTranscript(ctx, nodeName, sessionID, cwd, transcriptPath)
+	}
+	return o.remote.ReadRawTranscript(ctx, nodeName, sessionID, cwd, transcriptPath)
+}
+
+// localHeartbeatInterval matches the agent's heartbeat interval.
+const localHeartbeatInterval = 30 * time.Second
+
+// localHeartbeat stands in for an agent's heartbeat when the daemon runs one
+// in-process: it keeps the daemon's node registered and reconciles its panes
+// without a round trip through the registration endpoint.
+func (s *Server) localHeartbeat() {
+	s.localRegister()
+	ticker := time.NewTicker(localHeartbeatInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.localRegister()
+	}
+}
+
+func (s *Server) localRegister() {
+	var alivePanes *[]string
+	var titles map[string]string
+
+	panes, err := s.local.listAgentPanes()
+	if err != nil {
+		s.logger.Debug("failed to list agent panes", "error", err)
+	} else {
+		alive := make([]string, 0, len(panes))
+		for paneID := range panes {
+			alive = append(alive, paneID)
+		}
+		alivePanes = &alive
+
+		if len(panes) > 0 {
+			allTitles, err := s.local.listPaneTitles()
+			if err != nil {
+				s.logger.Debug("failed to list pane titles", "error", err)
+			} else {
+				titles = make(map[string]string, len(panes))
+				for paneID := range panes {
+					if title, ok := allTitles[paneID]; ok {
+						titles[paneID] = title
+					}
+				}
+			}
+		}
+	}
+
+	s.recordHeartbeat(s.cfg.NodeName, inProcessAgentURL, s.cfg.Version, alivePanes, titles)
+}
+
+// inProcessAgentURL is registered for the daemon's own node when it runs an
+// in-process agent. Nothing dials it; pane operations for the node never
+// reach the HTTP proxy.
+const inProcessAgentURL = "in-process"
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/phinze/sophon/transcript"
+)
+
+func TestTranscriptEndpointReadsLocalWithoutAgent(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+
+	claudeDir := t.TempDir()
+	path := transcript.TranscriptPath(claudeDir, "/home/user/project", "s1")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	jsonl := `{"type":"user","timestamp":"2026-01-01T00:00:00.000Z","message":{"role":"user","content":"Hello"}}
+`
+	if err := os.WriteFile(path, []byte(jsonl), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	srv := New(Config{ClaudeDir: claudeDir, NodeName: "test-node"}, h.store, logger)
+
+	req := httptest.NewRequest("GET", "/api/sessions/s1/transcript", nil)
+	req.SetPathValue("id", "s1")
+	w := httptest.NewRecorder()
+	srv.handleTranscript(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d, want 200", w.Code)
+	}
+	if w.Header().Get("ETag") == "" {
+		t.Error("missing ETag on local transcript")
+	}
+	var result transcript.Transcript
+	json.NewDecoder(w.Body).Decode(&result)
+	if len(result.Messages) != 1 || result.Messages[0].Blocks[0].Text != "Hello" {
+		t.Errorf("messages = %+v", result.Messages)
+	}
+
+	// The same ETag revalidates.
+	req = httptest.NewRequest("GET", "/api/sessions/s1/transcript", nil)
+	req.SetPathValue("id", "s1")
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	srv.handleTranscript(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("conditional read: got %d, want 304", w.Code)
+	}
+}
+
+func TestLocalFallbackSelection(t *testing.T) {
+	agents := NewAgentRegistry(0)
+	agents.Register("remote", "http://remote:2588", "")
+	ops := &localFallbackOps{
+		remote:   &agentProxyOps{agents: agents},
+		nodeName: "daemon-node",
+	}
+
+	if !ops.useLocal("daemon-node") {
+		t.Error("daemon's own node should read locally")
+	}
+	if ops.useLocal("no-agent") {
+		t.Error("another node's transcripts should never be read locally, even without an agent")
+	}
+	if ops.useLocal("remote") {
+		t.Error("node with a healthy agent should be proxied")
+	}
+}
+
+// newInProcessServer returns a server running an in-process agent for
+// test-node, with tmux replaced by stubs recording sent keys.
+func newInProcessServer(t *testing.T, h *testHarness) (*Server, *[]string) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	srv := New(Config{NodeName: "test-node", InProcessAgent: true}, h.store, logger)
+
+	var sent []string
+	srv.local.paneFocused = func(string) bool { return false }
+	srv.local.paneExists = func(pane string) bool { return pane == "%5" }
+	srv.local.sendKeys = func(pane, text string, enter bool) error {
+		sent = append(sent, pane+":"+text)
+		return nil
+	}
+	srv.local.listAgentPanes = func() (map[string]bool, error) {
+		return map[string]bool{"%5": true}, nil
+	}
+	srv.local.listPaneTitles = func() (map[string]string, error) {
+		return map[string]string{}, nil
+	}
+	return srv, &sent
+}
+
+func TestInProcessAgentRespond(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+	srv, sent := newInProcessServer(t, h)
+
+	body, _ := json.Marshal(map[string]string{"text": "yes"})
+	req := httptest.NewRequest("POST", "/api/respond/s1", bytes.NewReader(body))
+	req.SetPathValue("id", "s1")
+	w := httptest.NewRecorder()
+	srv.handleRespond(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d, want 200: %s", w.Code, w.Body.String())
+	}
+	if len(*sent) != 1 || (*sent)[0] != "%5:yes" {
+		t.Errorf("sent = %v, want [%%5:yes]", *sent)
+	}
+}
+
+func TestInProcessAgentRegistersAndReconciles(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "alive", "%5", "/home/user/project")
+	h.createSession(t, "gone", "%6", "/home/user/project")
+	srv, _ := newInProcessServer(t, h)
+
+	srv.localRegister()
+
+	if !srv.agents.IsHealthy("test-node") {
+		t.Error("in-process agent should register the daemon's node")
+	}
+	if sess, _ := h.store.GetSession("alive"); !sess.StoppedAt.IsZero() {
+		t.Error("session in a live pane should stay active")
+	}
+	if sess, _ := h.store.GetSession("gone"); sess.StoppedAt.IsZero() {
+		t.Error("session whose pane is gone should be stopped")
+	}
+}
+
+func TestTranscriptEndpointDebugOnRequest(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	claudeDir := t.TempDir()
+	srv := New(Config{ClaudeDir: claudeDir, NodeName: "test-node"}, h.store, logger)
+
+	debugFor := func(query string) *transcript.Debug {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/sessions/s1/transcript"+query, nil)
+		req.SetPathValue("id", "s1")
+		w := httptest.NewRecorder()
+		srv.handleTranscript(w, req)
+		var result transcript.Transcript
+		json.NewDecoder(w.Body).Decode(&result)
+		return result.Debug
+	}
+
+	if d := debugFor(""); d != nil {
+		t.Errorf("debug = %+v without ?debug=1, want none", d)
+	}
+	d := debugFor("?debug=1")
+	want := transcript.TranscriptPath(claudeDir, "/home/user/project", "s1")
+	if d == nil || d.Path != want || d.Exists {
+		t.Errorf("debug = %+v, want path %q not existing", d, want)
+	}
+}
+
+func TestLocalOpsIgnoresPathsOutsideClaudeDir(t *testing.T) {
+	claudeDir := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "secret.jsonl")
+	os.WriteFile(outside, []byte(`{"type":"user","message":{"role":"user","content":"secret"}}`+"\n"), 0o644)
+	inside := filepath.Join(claudeDir, "projects", "-other", "s1.jsonl")
+	os.MkdirAll(filepath.Dir(inside), 0o755)
+	link := filepath.Join(claudeDir, "projects", "-other", "link.jsonl")
+	os.Symlink(outside, link)
+
+	o := &localOps{claudeDir: claudeDir, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	slug := transcript.TranscriptPath(claudeDir, "/p", "s1")
+	want, _ := transcript.ResolveIn(claudeDir, inside)
+	if got := o.path(inside, "/p", "s1"); got != want {
+		t.Errorf("path inside the Claude dir = %q, want %q", got, want)
+	}
+	for _, provided := range []string{outside, link, filepath.Join(claudeDir, "..", filepath.Base(filepath.Dir(outside)), "secret.jsonl"), "relative.jsonl"} {
+		if got := o.path(provided, "/p", "s1"); got != slug {
+			t.Errorf("path(%q) = %q, want the slug path %q", provided, got, slug)
+		}
+	}
+}
+
+func TestLocalOpsRawTranscriptRequiresJSONL(t *testing.T) {
+	claudeDir := t.TempDir()
+	notes := filepath.Join(claudeDir, "notes.txt")
+	os.WriteFile(notes, []byte("private\n"), 0o644)
+
+	o := &localOps{claudeDir: claudeDir, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	if _, err := o.ReadRawTranscript(context.Background(), "", "s1", "/p", notes); !errors.Is(err, transcript.ErrNotTranscript) {
+		t.Errorf("err = %v, want ErrNotTranscript", err)
+	}
+}
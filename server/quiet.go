@@ -0,0 +1,64 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// QuietHours is a daily window during which notifications don't raise alerts.
+// Events and session state still flow; notification events are only flagged
+// quiet so clients skip the alert. A window whose end precedes its start
+// wraps past midnight.
+type QuietHours struct {
+	Start, End time.Duration // offsets from local midnight
+	Location   *time.Location
+}
+
+// ParseQuietHours parses a window such as "22:00-07:00" interpreted in loc.
+// An empty string returns nil, meaning no quiet hours.
+func ParseQuietHours(s string, loc *time.Location) (*QuietHours, error) {
+	if s == "" {
+		return nil, nil
+	}
+	startStr, endStr, ok := strings.Cut(s, "-")
+	if !ok {
+		return nil, fmt.Errorf("quiet hours %q: want HH:MM-HH:MM", s)
+	}
+	start, err := parseClockTime(startStr)
+	if err != nil {
+		return nil, fmt.Errorf("quiet hours %q: %w", s, err)
+	}
+	end, err := parseClockTime(endStr)
+	if err != nil {
+		return nil, fmt.Errorf("quiet hours %q: %w", s, err)
+	}
+	if start == end {
+		return nil, fmt.Errorf("quiet hours %q: start and end are equal", s)
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	return &QuietHours{Start: start, End: end, Location: loc}, nil
+}
+
+func parseClockTime(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains reports whether t falls inside the window.
+func (q *QuietHours) Contains(t time.Time) bool {
+	if q == nil {
+		return false
+	}
+	t = t.In(q.Location)
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if q.Start < q.End {
+		return offset >= q.Start && offset < q.End
+	}
+	return offset >= q.Start || offset < q.End
+}
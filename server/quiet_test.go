@@ -0,0 +1,97 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseQuietHours(t *testing.T) {
+	q, err := ParseQuietHours("22:00-07:30", time.UTC)
+	if err != nil {
+		t.Fatalf("ParseQuietHours: %v", err)
+	}
+	if q.Start != 22*time.Hour || q.End != 7*time.Hour+30*time.Minute {
+		t.Errorf("window = %s-%s", q.Start, q.End)
+	}
+
+	if q, err := ParseQuietHours("", time.UTC); q != nil || err != nil {
+		t.Errorf("empty = %v, %v; want nil, nil", q, err)
+	}
+	for _, bad := range []string{"22:00", "25:00-07:00", "22:00-22:00", "late-early"} {
+		if _, err := ParseQuietHours(bad, time.UTC); err == nil {
+			t.Errorf("ParseQuietHours(%q) should fail", bad)
+		}
+	}
+}
+
+func TestQuietHoursContains(t *testing.T) {
+	at := func(hour, min int) time.Time {
+		return time.Date(2026, 3, 1, hour, min, 0, 0, time.UTC)
+	}
+	wrapping, _ := ParseQuietHours("22:00-07:00", time.UTC)
+	daytime, _ := ParseQuietHours("12:00-13:00", time.UTC)
+
+	tests := []struct {
+		q    *QuietHours
+		t    time.Time
+		want bool
+	}{
+		{wrapping, at(23, 0), true},
+		{wrapping, at(0, 30), true},
+		{wrapping, at(6, 59), true},
+		{wrapping, at(7, 0), false},
+		{wrapping, at(12, 0), false},
+		{wrapping, at(21, 59), false},
+		{daytime, at(12, 30), true},
+		{daytime, at(13, 0), false},
+		{daytime, at(23, 0), false},
+		{nil, at(23, 0), false},
+	}
+	for _, tt := range tests {
+		if got := tt.q.Contains(tt.t); got != tt.want {
+			t.Errorf("%v.Contains(%s) = %v, want %v", tt.q, tt.t.Format("15:04"), got, tt.want)
+		}
+	}
+}
+
+func TestQuietHoursTimeZone(t *testing.T) {
+	loc := time.FixedZone("UTC-5", -5*3600)
+	q, _ := ParseQuietHours("22:00-07:00", loc)
+	// 03:00 UTC is 22:00 in UTC-5.
+	if !q.Contains(time.Date(2026, 3, 1, 3, 0, 0, 0, time.UTC)) {
+		t.Error("window should be evaluated in its own time zone")
+	}
+}
+
+func TestNotificationDuringQuietHours(t *testing.T) {
+	h := newTestHarness(t)
+	h.server.tun.QuietHours, _ = ParseQuietHours("22:00-07:00", time.UTC)
+	h.createSession(t, "s1", "%1", "/home/user/project")
+
+	lastNotification := func() map[string]string {
+		t.Helper()
+		hist := h.server.events.History("s1", 0)
+		if len(hist) == 0 || hist[len(hist)-1].Type != EventNotification {
+			t.Fatalf("no notification event published: %+v", hist)
+		}
+		var data map[string]string
+		json.Unmarshal(hist[len(hist)-1].Data, &data)
+		return data
+	}
+
+	h.clock.Set(time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC))
+	h.notify(t, "s1", "permission_prompt", "Allow Bash?")
+	if data := lastNotification(); data["quiet"] != "true" {
+		t.Errorf("in-window event data = %v, want quiet flag", data)
+	}
+	if sess, _ := h.store.GetSession("s1"); sess.NotificationType != "permission_prompt" {
+		t.Error("quiet hours should not keep state from being stored")
+	}
+
+	h.clock.Set(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	h.notify(t, "s1", "permission_prompt", "Allow Bash?")
+	if data := lastNotification(); data["quiet"] != "" {
+		t.Errorf("out-of-window event data = %v, should not be quiet", data)
+	}
+}
@@ -0,0 +1,29 @@
+package server
+
+import "time"
+
+// Tunables are the settings that can change while the daemon runs. They
+// start from the matching Config fields and are replaced wholesale by Reload,
+// so SSE connections, the store, and agent state survive a reload.
+type Tunables struct {
+	QuietHours     *QuietHours
+	IdleTimeout    time.Duration
+	ReconcileGrace time.Duration
+}
+
+// tunables returns the current reloadable settings.
+func (s *Server) tunables() Tunables {
+	s.tunMu.RLock()
+	defer s.tunMu.RUnlock()
+	return s.tun
+}
+
+// Reload swaps in new reloadable settings. Requests already in flight may
+// still see the old values.
+func (s *Server) Reload(t Tunables) {
+	s.tunMu.Lock()
+	s.tun = t
+	s.tunMu.Unlock()
+	s.logger.Info("config reloaded", "quiet_hours", t.QuietHours != nil,
+		"idle_timeout", t.IdleTimeout, "reconcile_grace", t.ReconcileGrace)
+}
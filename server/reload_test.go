@@ -0,0 +1,31 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReloadAppliesToNextRequest(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%1", "/home/user/project")
+	h.clock.Set(time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC))
+
+	sub, unsub := h.server.events.Subscribe("s1")
+	defer unsub()
+
+	tun := h.server.tunables()
+	tun.QuietHours, _ = ParseQuietHours("22:00-07:00", time.UTC)
+	h.server.Reload(tun)
+
+	// The subscriber opened before the reload sees the new quiet hours.
+	h.notify(t, "s1", "permission_prompt", "Allow Bash?")
+	select {
+	case ev := <-sub:
+		if ev.Type != EventNotification || !strings.Contains(string(ev.Data), `"quiet":"true"`) {
+			t.Errorf("event = %s %s, want quiet notification", ev.Type, ev.Data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event delivered after reload")
+	}
+}
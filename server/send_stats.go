@@ -0,0 +1,47 @@
+package server
+
+import "sync"
+
+// SendKeysStats counts send-keys outcomes for one node.
+type SendKeysStats struct {
+	Success uint64 `json:"success"`
+	Failure uint64 `json:"failure"`
+}
+
+// sendKeysCounters tracks send-keys outcomes per node so flaky responds can
+// be traced to a specific machine.
+type sendKeysCounters struct {
+	mu     sync.Mutex
+	byNode map[string]*SendKeysStats
+}
+
+func newSendKeysCounters() *sendKeysCounters {
+	return &sendKeysCounters{byNode: make(map[string]*SendKeysStats)}
+}
+
+// record counts one send-keys attempt against nodeName.
+func (c *sendKeysCounters) record(nodeName string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, ok := c.byNode[nodeName]
+	if !ok {
+		st = &SendKeysStats{}
+		c.byNode[nodeName] = st
+	}
+	if err != nil {
+		st.Failure++
+	} else {
+		st.Success++
+	}
+}
+
+// snapshot returns a copy of the current counts keyed by node.
+func (c *sendKeysCounters) snapshot() map[string]SendKeysStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]SendKeysStats, len(c.byNode))
+	for node, st := range c.byNode {
+		out[node] = *st
+	}
+	return out
+}
@@ -1,17 +1,27 @@
 package server
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/phinze/sophon/clock"
 	"github.com/phinze/sophon/sessiontitle"
 	"github.com/phinze/sophon/store"
+	"github.com/phinze/sophon/tmux"
 	"github.com/phinze/sophon/transcript"
 )
 
@@ -35,15 +45,92 @@ func init() {
 type Config struct {
 	Port          int
 	BaseURL       string
-	MinSessionAge int // seconds since last activity before turn-end sends notification
+	MinSessionAge int   // seconds since last activity before turn-end sends notification
+	MaxBodyBytes  int64 // cap on JSON request bodies; 0 means defaultMaxBodyBytes
+
+	// TLSCert and TLSKey are PEM file paths. When both are set the daemon
+	// serves HTTPS directly; otherwise it serves plain HTTP.
+	TLSCert string
+	TLSKey  string
+
+	// AgentStaleTimeout is the heartbeat gap after which an agent is treated
+	// as offline; 0 means DefaultAgentStaleTimeout.
+	AgentStaleTimeout time.Duration
+
+	// AgentTranscriptTimeout and AgentActionTimeout bound requests to agents
+	// for transcripts and for everything else; 0 means the defaults.
+	AgentTranscriptTimeout time.Duration
+	AgentActionTimeout     time.Duration
+
+	// ClaudeDir, when set, lets the daemon read transcripts from its own
+	// filesystem for sessions on NodeName, so single-machine setups work
+	// without running an agent.
+	ClaudeDir string
+	NodeName  string
+
+	// InProcessAgent makes the daemon act as the agent for NodeName itself,
+	// driving local tmux and reconciling panes without an agent process.
+	InProcessAgent bool
+
+	// SendKeysDelay pauses between a response's text and its Enter key press
+	// when InProcessAgent drives tmux; 0 sends them back to back.
+	SendKeysDelay time.Duration
+
+	// ResponseTemplates are canned replies offered to the web UI, such as
+	// "yes" or "use option 2", in display order.
+	ResponseTemplates []string
+
+	// QuietHours, when set, flags notification events raised inside the
+	// window so clients skip the alert.
+	QuietHours *QuietHours
+
+	// Version is the daemon's sophon version. Agents reporting a different
+	// one are logged at registration.
+	Version string
+
+	// ReconcileGrace is how long a session's pane may be missing from an
+	// agent's alive set before reconciliation stops it, absorbing races where
+	// a pane briefly lacks its process; 0 stops it on the first miss.
+	ReconcileGrace time.Duration
+
+	// Clock supplies the current time to handlers and the agent registry;
+	// nil means the wall clock.
+	Clock clock.Clock
+
+	// IdleTimeout stops sessions with no activity for this long when no
+	// healthy agent covers their node; 0 disables the sweep.
+	IdleTimeout time.Duration
+
+	// MaxSessions caps how many stopped sessions are kept, deleting the
+	// oldest beyond it ahead of the TTL; 0 means no cap.
+	MaxSessions int
+
+	// SlowToolAfter publishes a "tool_running" notification when a tool call
+	// runs this long without its PostToolUse; 0 disables it.
+	SlowToolAfter time.Duration
 }
 
+// defaultMaxBodyBytes leaves room for large plan markdown while keeping a
+// stray or hostile POST from exhausting memory.
+const defaultMaxBodyBytes = 1 << 20
+
 // NodeOps abstracts per-node operations that may be proxied to a remote agent.
 type NodeOps interface {
 	PaneFocused(nodeName, pane string) bool
-	SendKeys(nodeName, pane, text string) error
-	ReadTranscript(nodeName, sessionID, cwd, transcriptPath string) (*transcript.Transcript, error)
-	ReadSummary(nodeName, sessionID, cwd, transcriptPath string) (*transcript.SessionSummary, error)
+	PaneInfo(nodeName, pane string) (tmux.Pane, error)
+	SendKeys(nodeName, pane, text string, enter bool) error
+	// ReadTranscript returns the transcript and its ETag. A non-empty etag
+	// makes the read conditional: ErrNotModified means it still matches.
+	ReadTranscript(nodeName, sessionID, cwd, transcriptPath, etag string) (*transcript.Transcript, string, error)
+	ReadSummary(ctx context.Context, nodeName, sessionID, cwd, transcriptPath string) (*transcript.SessionSummary, error)
+	// ReadToolCall returns one tool call's full input and result, or
+	// transcript.ErrToolCallNotFound.
+	ReadToolCall(nodeName, sessionID, cwd, transcriptPath, toolUseID string) (*transcript.ToolCall, error)
+	// ReadRawTranscript opens the unparsed JSONL for streaming. A missing
+	// transcript is fs.ErrNotExist, a path outside the Claude directory is
+	// transcript.ErrNotTranscript, and one over the size cap is
+	// transcript.ErrTooLarge.
+	ReadRawTranscript(ctx context.Context, nodeName, sessionID, cwd, transcriptPath string) (io.ReadCloser, error)
 }
 
 // Server is the sophon HTTP server.
@@ -54,6 +141,42 @@ type Server struct {
 	agents  *AgentRegistry
 	nodeOps NodeOps
 	events  *EventHub
+
+	// local is the in-process agent, nil unless cfg.InProcessAgent is set.
+	local *localOps
+
+	// sends counts proxied send-keys outcomes per node for /api/stats.
+	sends *sendKeysCounters
+
+	clock clock.Clock
+
+	// bg tracks background work started by handlers, so tests can wait for
+	// it instead of sleeping.
+	bg sync.WaitGroup
+
+	// summarySem caps concurrent summary fetches after turn ends.
+	summarySem chan struct{}
+
+	// summaryMu guards summaryDirty, which holds the sessions with a
+	// summary worker running, true when another refresh was requested
+	// while it fetched.
+	summaryMu    sync.Mutex
+	summaryDirty map[string]bool
+
+	// transcripts coalesces concurrent transcript reads per session.
+	transcripts transcriptFlight
+
+	// missingMu guards missingSince, which records per node when each
+	// session's pane was first missing from a heartbeat's alive set.
+	missingMu    sync.Mutex
+	missingSince map[string]map[string]time.Time
+
+	// toolTimers tracks running tool calls for SlowToolAfter.
+	toolTimers toolTimers
+
+	// tunMu guards tun, the settings Reload can change at runtime.
+	tunMu sync.RWMutex
+	tun   Tunables
 }
 
 // New creates a new Server.
@@ -62,13 +185,41 @@ func New(cfg Config, st *store.Store, logger *slog.Logger) *Server {
 		cfg:    cfg,
 		store:  st,
 		logger: logger,
-		agents: NewAgentRegistry(),
+		agents: NewAgentRegistry(cfg.AgentStaleTimeout),
 		events: NewEventHub(),
+		clock:  cfg.Clock,
+
+		summarySem:   make(chan struct{}, maxSummaryFetches),
+		summaryDirty: make(map[string]bool),
+
+		tun: Tunables{
+			QuietHours:     cfg.QuietHours,
+			IdleTimeout:    cfg.IdleTimeout,
+			ReconcileGrace: cfg.ReconcileGrace,
+		},
+	}
+	if s.clock == nil {
+		s.clock = clock.Real{}
 	}
-	s.nodeOps = &agentProxyOps{
+	s.agents.clock = s.clock
+	proxy := &agentProxyOps{
 		agents: s.agents,
-		client: newAgentClient(),
+		client: newAgentClient(cfg.AgentTranscriptTimeout, cfg.AgentActionTimeout),
 		logger: logger,
+		sends:  newSendKeysCounters(),
+	}
+	s.sends = proxy.sends
+	s.nodeOps = proxy
+	if cfg.ClaudeDir != "" || cfg.InProcessAgent {
+		local := &localOps{claudeDir: cfg.ClaudeDir, logger: logger}
+		if cfg.InProcessAgent {
+			s.local = local.withTmux(cfg.SendKeysDelay)
+		}
+		s.nodeOps = &localFallbackOps{
+			remote:   proxy,
+			local:    local,
+			nodeName: cfg.NodeName,
+		}
 	}
 	return s
 }
@@ -78,6 +229,20 @@ type agentProxyOps struct {
 	agents *AgentRegistry
 	client *agentClient
 	logger *slog.Logger
+	sends  *sendKeysCounters
+
+	// focusMu guards focusCache, recent pane focus checks keyed by node and
+	// pane, so session lists polled by the UI don't call agents every time.
+	focusMu    sync.Mutex
+	focusCache map[string]focusEntry
+}
+
+// focusCacheTTL is how long a pane focus check is reused.
+const focusCacheTTL = 2 * time.Second
+
+type focusEntry struct {
+	focused bool
+	checked time.Time
 }
 
 func (o *agentProxyOps) PaneFocused(nodeName, pane string) bool {
@@ -86,41 +251,97 @@ func (o *agentProxyOps) PaneFocused(nodeName, pane string) bool {
 		o.logger.Debug("no healthy agent for pane focus check", "node", nodeName)
 		return false
 	}
+
+	key := nodeName + "\x00" + pane
+	now := o.agents.clock.Now()
+	o.focusMu.Lock()
+	entry, ok := o.focusCache[key]
+	o.focusMu.Unlock()
+	if ok && now.Sub(entry.checked) < focusCacheTTL {
+		return entry.focused
+	}
+
 	focused, err := o.client.PaneFocused(info.URL, pane)
 	if err != nil {
+		// Errors aren't cached, so the next check asks again.
 		o.logger.Debug("agent pane-focused error", "node", nodeName, "error", err)
 		return false
 	}
+	o.focusMu.Lock()
+	if o.focusCache == nil {
+		o.focusCache = make(map[string]focusEntry)
+	}
+	for k, e := range o.focusCache {
+		if now.Sub(e.checked) >= focusCacheTTL {
+			delete(o.focusCache, k)
+		}
+	}
+	o.focusCache[key] = focusEntry{focused: focused, checked: now}
+	o.focusMu.Unlock()
 	return focused
 }
 
-func (o *agentProxyOps) SendKeys(nodeName, pane, text string) error {
+func (o *agentProxyOps) PaneInfo(nodeName, pane string) (tmux.Pane, error) {
+	info, ok := o.agents.Get(nodeName)
+	if !ok || !o.agents.IsHealthy(nodeName) {
+		return tmux.Pane{}, fmt.Errorf("%w for node %q", ErrAgentOffline, nodeName)
+	}
+	return o.client.PaneInfo(info.URL, pane)
+}
+
+func (o *agentProxyOps) SendKeys(nodeName, pane, text string, enter bool) error {
+	err := o.sendKeys(nodeName, pane, text, enter)
+	if o.sends != nil {
+		o.sends.record(nodeName, err)
+	}
+	return err
+}
+
+func (o *agentProxyOps) sendKeys(nodeName, pane, text string, enter bool) error {
 	info, ok := o.agents.Get(nodeName)
 	if !ok || !o.agents.IsHealthy(nodeName) {
-		return fmt.Errorf("no healthy agent for node %q", nodeName)
+		return fmt.Errorf("%w for node %q", ErrAgentOffline, nodeName)
 	}
-	return o.client.SendKeys(info.URL, pane, text)
+	return o.client.SendKeys(info.URL, pane, text, enter)
 }
 
-func (o *agentProxyOps) ReadTranscript(nodeName, sessionID, cwd, transcriptPath string) (*transcript.Transcript, error) {
+func (o *agentProxyOps) ReadTranscript(nodeName, sessionID, cwd, transcriptPath, etag string) (*transcript.Transcript, string, error) {
 	info, ok := o.agents.Get(nodeName)
 	if !ok || !o.agents.IsHealthy(nodeName) {
-		return &transcript.Transcript{}, nil
+		return transcript.Empty(), "", nil
+	}
+	tr, newETag, err := o.client.GetTranscript(info.URL, sessionID, cwd, transcriptPath, etag)
+	if errors.Is(err, ErrAgentUnreachable) {
+		// One quick retry rides out blips such as a restarting agent or a
+		// dropped keep-alive connection.
+		o.logger.Debug("agent transcript request failed, retrying", "node", nodeName, "error", err)
+		time.Sleep(transcriptRetryDelay)
+		tr, newETag, err = o.client.GetTranscript(info.URL, sessionID, cwd, transcriptPath, etag)
+	}
+	if errors.Is(err, ErrNotModified) {
+		return nil, newETag, err
+	}
+	if errors.Is(err, ErrAgentUnreachable) {
+		o.logger.Warn("agent unreachable, serving empty transcript", "node", nodeName, "error", err)
+		return transcript.Empty(), "", nil
 	}
-	tr, err := o.client.GetTranscript(info.URL, sessionID, cwd, transcriptPath)
 	if err != nil {
 		o.logger.Debug("agent transcript error", "node", nodeName, "error", err)
-		return &transcript.Transcript{}, nil
+		return transcript.Empty(), "", nil
 	}
-	return tr, nil
+	return tr, newETag, nil
 }
 
-func (o *agentProxyOps) ReadSummary(nodeName, sessionID, cwd, transcriptPath string) (*transcript.SessionSummary, error) {
+// transcriptRetryDelay is the pause before retrying a transcript fetch that
+// failed to reach the agent.
+const transcriptRetryDelay = 200 * time.Millisecond
+
+func (o *agentProxyOps) ReadSummary(ctx context.Context, nodeName, sessionID, cwd, transcriptPath string) (*transcript.SessionSummary, error) {
 	info, ok := o.agents.Get(nodeName)
 	if !ok || !o.agents.IsHealthy(nodeName) {
 		return nil, nil
 	}
-	summary, err := o.client.GetSummary(info.URL, sessionID, cwd, transcriptPath)
+	summary, err := o.client.GetSummary(ctx, info.URL, sessionID, cwd, transcriptPath)
 	if err != nil {
 		o.logger.Debug("agent summary error", "node", nodeName, "error", err)
 		return nil, nil
@@ -128,12 +349,69 @@ func (o *agentProxyOps) ReadSummary(nodeName, sessionID, cwd, transcriptPath str
 	return summary, nil
 }
 
+func (o *agentProxyOps) ReadToolCall(nodeName, sessionID, cwd, transcriptPath, toolUseID string) (*transcript.ToolCall, error) {
+	info, ok := o.agents.Get(nodeName)
+	if !ok || !o.agents.IsHealthy(nodeName) {
+		return nil, fmt.Errorf("%w for node %q", ErrAgentOffline, nodeName)
+	}
+	return o.client.GetToolCall(info.URL, sessionID, cwd, transcriptPath, toolUseID)
+}
+
+func (o *agentProxyOps) ReadRawTranscript(ctx context.Context, nodeName, sessionID, cwd, transcriptPath string) (io.ReadCloser, error) {
+	info, ok := o.agents.Get(nodeName)
+	if !ok || !o.agents.IsHealthy(nodeName) {
+		return nil, fmt.Errorf("%w for node %q", ErrAgentOffline, nodeName)
+	}
+	return o.client.GetRawTranscript(ctx, info.URL, sessionID, cwd, transcriptPath)
+}
+
 const stoppedSessionTTL = 24 * time.Hour
 
+// DefaultReconcileGrace is the daemon's default ReconcileGrace. It is shorter
+// than the agent heartbeat interval, so a missing pane survives exactly one
+// heartbeat.
+const DefaultReconcileGrace = 10 * time.Second
+
+// maxSummaryFetches caps concurrent summary fetches, and summaryFetchTimeout
+// bounds each one.
+const (
+	maxSummaryFetches   = 4
+	summaryFetchTimeout = 10 * time.Second
+)
+
 // Run starts the HTTP server.
 func (s *Server) Run() error {
 	go s.reapSessions()
+	go s.maintainStore()
+	go s.watchAgents()
+	if s.local != nil {
+		go s.localHeartbeat()
+	}
+
+	addr := fmt.Sprintf("0.0.0.0:%d", s.cfg.Port)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.logger.Info("starting sophon daemon", "addr", addr, "tls", s.tlsEnabled())
+	return s.serve(ln)
+}
+
+func (s *Server) tlsEnabled() bool {
+	return s.cfg.TLSCert != "" && s.cfg.TLSKey != ""
+}
 
+// serve serves the daemon's routes on ln, over TLS when configured.
+func (s *Server) serve(ln net.Listener) error {
+	srv := &http.Server{Handler: s.routes()}
+	if s.tlsEnabled() {
+		return srv.ServeTLS(ln, s.cfg.TLSCert, s.cfg.TLSKey)
+	}
+	return srv.Serve(ln)
+}
+
+// routes builds the daemon's HTTP handler.
+func (s *Server) routes() http.Handler {
 	mux := http.NewServeMux()
 
 	// API routes
@@ -142,13 +420,24 @@ func (s *Server) Run() error {
 	mux.HandleFunc("POST /api/sessions/{id}/plan", s.handlePlan)
 	mux.HandleFunc("POST /api/sessions/{id}/activity", s.handleActivity)
 	mux.HandleFunc("POST /api/sessions/{id}/tool-activity", s.handleToolActivity)
+	mux.HandleFunc("POST /api/sessions/{id}/refresh", s.handleRefreshSession)
+	mux.HandleFunc("PATCH /api/sessions/{id}", s.handlePatchSession)
 	mux.HandleFunc("DELETE /api/sessions/{id}", s.handleDeleteSession)
 	mux.HandleFunc("POST /api/respond/{id}", s.handleRespond)
 	mux.HandleFunc("GET /api/sessions/{id}/transcript", s.handleTranscript)
+	mux.HandleFunc("GET /api/sessions/{id}/export", s.handleExport)
+	mux.HandleFunc("GET /api/sessions/{id}/raw", s.handleRawTranscript)
+	mux.HandleFunc("GET /api/sessions/{id}/tools/{toolUseId}", s.handleToolCall)
+	mux.HandleFunc("GET /api/sessions/{id}/last-reply", s.handleLastReply)
 	mux.HandleFunc("GET /api/sessions/{id}/events", s.handleSSE)
+	mux.HandleFunc("GET /api/sessions/{id}/events/history", s.handleEventHistory)
 	mux.HandleFunc("GET /api/events", s.handleGlobalSSE)
+	mux.HandleFunc("GET /api/projects/{project}/events", s.handleProjectSSE)
 	mux.HandleFunc("GET /api/sessions/{id}", s.handleGetSession)
 	mux.HandleFunc("GET /api/sessions", s.handleSessionsAPI)
+	mux.HandleFunc("GET /api/stats", s.handleStats)
+	mux.HandleFunc("GET /api/responses/templates", s.handleResponseTemplates)
+	mux.HandleFunc("GET /api/agents", s.handleListAgents)
 	mux.HandleFunc("POST /api/agents/register", s.handleAgentRegister)
 
 	// Static assets
@@ -165,9 +454,27 @@ func (s *Server) Run() error {
 		fmt.Fprintln(w, "ok")
 	})
 
-	addr := fmt.Sprintf("0.0.0.0:%d", s.cfg.Port)
-	s.logger.Info("starting sophon daemon", "addr", addr)
-	return http.ListenAndServe(addr, mux)
+	return accessLog(s.logger, gzipHandler(mux))
+}
+
+// decodeJSON decodes a size-capped JSON request body into v. On failure it
+// writes a 400 (or 413 when the body exceeds the cap) and returns false.
+func (s *Server) decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	limit := s.cfg.MaxBodyBytes
+	if limit <= 0 {
+		limit = defaultMaxBodyBytes
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, errCodeTooLarge, "request body too large")
+			return false
+		}
+		writeJSONError(w, http.StatusBadRequest, errCodeBadRequest, "bad request")
+		return false
+	}
+	return true
 }
 
 func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
@@ -177,21 +484,25 @@ func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
 		Cwd            string `json:"cwd"`
 		NodeName       string `json:"node_name"`
 		TranscriptPath string `json:"transcript_path"`
+
+		// ExtraPanes replaces the session's additional panes when present.
+		// Hooks don't send it, so re-registration leaves them alone.
+		ExtraPanes []string `json:"extra_panes"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+	if !s.decodeJSON(w, r, &req) {
 		return
 	}
+	req.TmuxPane = s.requestPane(req.SessionID, req.TmuxPane)
 
 	project := store.ProjectFromCwd(req.Cwd)
 
-	now := time.Now()
+	now := s.clock.Now()
 	sess, err := s.store.GetSession(req.SessionID)
 	if errors.Is(err, store.ErrNotFound) {
 		sess = &store.Session{ID: req.SessionID, StartedAt: now}
 	} else if err != nil {
 		s.logger.Error("failed to look up session", "error", err)
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
 		return
 	}
 	// Session registration is intentionally idempotent. Antigravity's closest
@@ -203,11 +514,15 @@ func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
 	sess.NodeName = req.NodeName
 	sess.TranscriptPath = req.TranscriptPath
 	sess.StoppedAt = time.Time{}
+	sess.StopReason = ""
 	sess.LastActivityAt = now
+	if req.ExtraPanes != nil {
+		sess.ExtraPanes = s.extraPanes(req.SessionID, req.TmuxPane, req.ExtraPanes)
+	}
 
 	if err := s.store.CreateSession(sess); err != nil {
 		s.logger.Error("failed to create session", "error", err)
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
 		return
 	}
 
@@ -225,10 +540,46 @@ func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
 
 	s.events.Publish(req.SessionID, Event{Type: EventSessionStart, Session: req.SessionID})
 
+	if req.TmuxPane != "" {
+		s.refreshPaneInfo(sess)
+	}
+
 	s.logger.Info("session registered", "session_id", req.SessionID, "project", project, "pane", req.TmuxPane)
 	w.WriteHeader(http.StatusCreated)
 }
 
+// requestPane normalizes a pane id reported by a hook. Malformed ids are
+// dropped, as if the session weren't in tmux, so they never reach send-keys.
+// So are session:window.pane targets: sessions are tracked by "%N" id, which
+// is what agents report alive in heartbeats.
+func (s *Server) requestPane(sessionID, pane string) string {
+	if pane == "" {
+		return ""
+	}
+	normalized, err := tmux.NormalizePane(pane)
+	if err == nil && !tmux.IsPaneID(normalized) {
+		err = fmt.Errorf("%w %q: sessions are tracked by %%N pane id", tmux.ErrInvalidPane, normalized)
+	}
+	if err != nil {
+		s.logger.Warn("ignoring malformed tmux pane", "session_id", sessionID, "error", err)
+		return ""
+	}
+	return normalized
+}
+
+// extraPanes normalizes a session's additional panes like requestPane,
+// dropping malformed ones, repeats, and the primary pane.
+func (s *Server) extraPanes(sessionID, primary string, panes []string) []string {
+	var out []string
+	for _, pane := range panes {
+		pane = s.requestPane(sessionID, pane)
+		if pane != "" && pane != primary && !slices.Contains(out, pane) {
+			out = append(out, pane)
+		}
+	}
+	return out
+}
+
 func (s *Server) handleNotify(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
@@ -237,41 +588,50 @@ func (s *Server) handleNotify(w http.ResponseWriter, r *http.Request) {
 		Title            string `json:"title"`
 		Message          string `json:"message"`
 		Cwd              string `json:"cwd"`
+		TranscriptPath   string `json:"transcript_path"`
 		NodeName         string `json:"node_name"`
+		TmuxPane         string `json:"tmux_pane"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+	if !s.decodeJSON(w, r, &req) {
 		return
 	}
+	req.TmuxPane = s.requestPane(id, req.TmuxPane)
 
 	sess, err := s.store.GetSession(id)
 	if errors.Is(err, store.ErrNotFound) {
 		// Create a temporary session for notifications without prior SessionStart
 		sess = &store.Session{
-			ID:        id,
-			Cwd:       req.Cwd,
-			Project:   store.ProjectFromCwd(req.Cwd),
-			NodeName:  req.NodeName,
-			StartedAt: time.Now(),
+			ID:             id,
+			TmuxPane:       req.TmuxPane,
+			Cwd:            req.Cwd,
+			Project:        store.ProjectFromCwd(req.Cwd),
+			NodeName:       req.NodeName,
+			TranscriptPath: req.TranscriptPath,
+			StartedAt:      s.clock.Now(),
 		}
 	} else if err != nil {
 		s.logger.Error("failed to get session", "error", err)
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
 		return
 	} else {
-		// Backfill project/cwd/node_name if missing
-		if sess.Project == "" && req.Cwd != "" {
-			sess.Cwd = req.Cwd
-			sess.Project = store.ProjectFromCwd(req.Cwd)
-		}
+		// Follow cwd changes; backfill node_name/pane if missing
+		s.followCwd(sess, req.Cwd, req.TranscriptPath)
 		if sess.NodeName == "" && req.NodeName != "" {
 			sess.NodeName = req.NodeName
 		}
+		if sess.TmuxPane == "" && req.TmuxPane != "" {
+			sess.TmuxPane = req.TmuxPane
+		}
+	}
+
+	notifType := normalizeNotificationType(req.NotificationType)
+	if notifType != req.NotificationType {
+		s.logger.Debug("normalized notification type", "session_id", id, "from", req.NotificationType, "to", notifType)
 	}
 
-	now := time.Now()
-	title := alertTitle(sess, req.NotificationType, req.Title)
-	sess.NotificationType = req.NotificationType
+	now := s.clock.Now()
+	title := alertTitle(sess, notifType, req.Title)
+	sess.NotificationType = notifType
 	sess.NotifyTitle = title
 	sess.NotifyMessage = req.Message
 	sess.NotifiedAt = now
@@ -279,20 +639,41 @@ func (s *Server) handleNotify(w http.ResponseWriter, r *http.Request) {
 
 	if err := s.store.CreateSession(sess); err != nil {
 		s.logger.Error("failed to save session", "error", err)
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
 		return
 	}
 
 	s.events.Publish(id, Event{
 		Type:    EventNotification,
 		Session: id,
-		Data:    mustJSON(map[string]string{"type": req.NotificationType, "message": req.Message, "title": title}),
+		Data:    s.notificationData(sess, map[string]string{"type": notifType, "message": req.Message, "title": title}),
 	})
 
-	s.logger.Info("notification stored", "session_id", id, "type", req.NotificationType)
+	s.logger.Info("notification stored", "session_id", id, "type", notifType)
 	w.WriteHeader(http.StatusOK)
 }
 
+// followCwd updates a session's cwd and project when a hook reports that the
+// agent has changed directory. Claude Code keeps appending to the transcript
+// where the session started, and without a recorded transcript path that
+// file is found by the original cwd's slug, so in that case only the project
+// moves.
+func (s *Server) followCwd(sess *store.Session, cwd, transcriptPath string) {
+	if sess.TranscriptPath == "" && transcriptPath != "" {
+		sess.TranscriptPath = transcriptPath
+	}
+	if cwd == "" || cwd == sess.Cwd {
+		return
+	}
+	if sess.Cwd != "" {
+		s.logger.Info("session changed directory", "session_id", sess.ID, "from", sess.Cwd, "to", cwd)
+	}
+	if sess.TranscriptPath != "" || sess.Cwd == "" {
+		sess.Cwd = cwd
+	}
+	sess.Project = store.ProjectFromCwd(cwd)
+}
+
 // handlePlan stores the plan markdown captured from the ExitPlanMode PreToolUse
 // hook. This is the push path: the plan arrives directly from the hook instead
 // of being reconstructed from a transcript the daemon would have to pull.
@@ -303,8 +684,7 @@ func (s *Server) handlePlan(w http.ResponseWriter, r *http.Request) {
 		Plan     string `json:"plan"`
 		NodeName string `json:"node_name"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+	if !s.decodeJSON(w, r, &req) {
 		return
 	}
 
@@ -313,26 +693,26 @@ func (s *Server) handlePlan(w http.ResponseWriter, r *http.Request) {
 		sess = &store.Session{
 			ID:        id,
 			NodeName:  req.NodeName,
-			StartedAt: time.Now(),
+			StartedAt: s.clock.Now(),
 		}
 	} else if err != nil {
 		s.logger.Error("failed to get session", "error", err)
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
 		return
 	}
 
 	sess.PlanText = req.Plan
-	sess.LastActivityAt = time.Now()
+	sess.LastActivityAt = s.clock.Now()
 	if err := s.store.CreateSession(sess); err != nil {
 		s.logger.Error("failed to save plan", "error", err)
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
 		return
 	}
 
 	s.events.Publish(id, Event{
 		Type:    EventNotification,
 		Session: id,
-		Data: mustJSON(map[string]string{
+		Data: s.notificationData(sess, map[string]string{
 			"type":  "plan_approval",
 			"title": alertTitle(sess, "plan_approval", "Plan ready for approval"),
 		}),
@@ -348,17 +728,28 @@ func (s *Server) handlePlan(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleActivity(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
+	var req struct {
+		NodeName       string `json:"node_name"`
+		TmuxPane       string `json:"tmux_pane"`
+		Cwd            string `json:"cwd"`
+		TranscriptPath string `json:"transcript_path"`
+	}
+	if !s.decodeJSON(w, r, &req) {
+		return
+	}
+	req.TmuxPane = s.requestPane(id, req.TmuxPane)
+
 	sess, err := s.store.GetSession(id)
 	if errors.Is(err, store.ErrNotFound) {
 		w.WriteHeader(http.StatusOK)
 		return
 	} else if err != nil {
 		s.logger.Error("failed to get session", "error", err)
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
 		return
 	}
 
-	now := time.Now()
+	now := s.clock.Now()
 
 	// Duration = time since last meaningful activity (not total session age)
 	activityRef := sess.LastActivityAt
@@ -367,36 +758,218 @@ func (s *Server) handleActivity(w http.ResponseWriter, r *http.Request) {
 	}
 	elapsed := now.Sub(activityRef)
 
+	if sess.TmuxPane == "" && req.TmuxPane != "" {
+		sess.TmuxPane = req.TmuxPane
+	}
+	s.followCwd(sess, req.Cwd, req.TranscriptPath)
 	sess.LastActivityAt = now
+	wasBusy := sess.Busy
+	sess.Busy = false
+	s.toolTimers.stopAll(id)
 	if err := s.store.UpdateSession(sess); err != nil {
 		s.logger.Error("failed to update session", "error", err)
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
 		return
 	}
 
-	s.events.Publish(id, Event{Type: EventActivity, Session: id})
+	if wasBusy {
+		s.events.Publish(id, Event{Type: EventBusy, Session: id, Data: mustJSON(Busy{Busy: false})})
+	}
+
+	s.events.Publish(id, Event{
+		Type:    EventActivity,
+		Session: id,
+		Data:    mustJSON(Activity{ElapsedSeconds: int64(elapsed / time.Second)}),
+	})
+
+	s.refreshSummary(sess)
+
+	s.logger.Info("turn ended", "session_id", id, "elapsed_since_last_activity", elapsed.Round(time.Second))
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// answerQuestion translates an option selection into the text that answers
+// the session's pending AskUserQuestion. On failure it returns the HTTP
+// status, error code, and message to report.
+func (s *Server) answerQuestion(sess *store.Session, question int, options []int) (text string, status int, code, msg string) {
+	tr, _, err := s.nodeOps.ReadTranscript(sess.NodeName, sess.ID, sess.Cwd, sess.TranscriptPath, "")
+	if err != nil {
+		s.logger.Error("failed to read transcript for question", "error", err, "session_id", sess.ID)
+		return "", http.StatusInternalServerError, errCodeInternal, "internal error"
+	}
+	if len(tr.Messages) == 0 {
+		// An unreachable agent reads as an empty transcript; that says
+		// nothing about whether a question is pending.
+		return "", http.StatusServiceUnavailable, errCodeAgentOffline, "transcript unavailable"
+	}
+	pending := tr.PendingQuestions()
+	if len(pending) == 0 {
+		return "", http.StatusConflict, errCodeNoQuestion, "session has no pending question"
+	}
+	if question < 0 || question >= len(pending) {
+		return "", http.StatusBadRequest, errCodeBadRequest,
+			fmt.Sprintf("question %d out of range (%d pending)", question, len(pending))
+	}
+	text, err = pending[question].Answer(options)
+	if err != nil {
+		return "", http.StatusBadRequest, errCodeBadRequest, err.Error()
+	}
+	return text, 0, "", ""
+}
 
-	// Asynchronously fetch and store session summary
+// refreshPaneInfo asynchronously records the tmux window name for a newly
+// registered session, and its pane title until the next heartbeat reports one.
+func (s *Server) refreshPaneInfo(sess *store.Session) {
+	s.bg.Add(1)
 	go func() {
-		summary, err := s.nodeOps.ReadSummary(sess.NodeName, id, sess.Cwd, sess.TranscriptPath)
-		if err != nil || summary == nil {
+		defer s.bg.Done()
+
+		info, err := s.nodeOps.PaneInfo(sess.NodeName, sess.TmuxPane)
+		if err != nil {
+			s.logger.Debug("pane info unavailable", "session_id", sess.ID, "error", err)
 			return
 		}
 		// Re-fetch session to avoid overwriting concurrent changes
-		current, err := s.store.GetSession(id)
-		if err != nil {
+		current, err := s.store.GetSession(sess.ID)
+		if err != nil || current.TmuxPane != sess.TmuxPane {
 			return
 		}
-		current.Topic = summary.Topic
-		current.PlanSummary = summary.PlanSummary
+		current.WindowName = info.WindowName
+		if current.PaneTitle == "" {
+			current.PaneTitle = sessiontitle.Parse(info.Title)
+		}
 		if err := s.store.UpdateSession(current); err != nil {
-			s.logger.Debug("failed to update session summary", "error", err)
+			s.logger.Debug("failed to update session pane info", "error", err)
 		}
 	}()
+}
 
-	s.logger.Info("turn ended", "session_id", id, "elapsed_since_last_activity", elapsed.Round(time.Second))
+// refreshSummary asynchronously fetches and stores a session's summary.
+// Each session has at most one fetch running: a refresh requested meanwhile
+// marks the session dirty, and the fetch runs once more when it finishes, so
+// no turn end is lost however many pile up. Fetches across sessions are
+// capped so a slow or offline agent can't tie up more than a few at once.
+func (s *Server) refreshSummary(sess *store.Session) {
+	s.summaryMu.Lock()
+	if _, running := s.summaryDirty[sess.ID]; running {
+		s.summaryDirty[sess.ID] = true
+		s.summaryMu.Unlock()
+		return
+	}
+	s.summaryDirty[sess.ID] = false
+	s.summaryMu.Unlock()
 
-	w.WriteHeader(http.StatusOK)
+	s.bg.Add(1)
+	go func() {
+		defer s.bg.Done()
+		for {
+			s.fetchSummary(sess)
+
+			s.summaryMu.Lock()
+			if !s.summaryDirty[sess.ID] {
+				delete(s.summaryDirty, sess.ID)
+				s.summaryMu.Unlock()
+				return
+			}
+			s.summaryDirty[sess.ID] = false
+			s.summaryMu.Unlock()
+
+			// The session may have moved since; fetch with its latest paths.
+			current, err := s.store.GetSession(sess.ID)
+			if err != nil {
+				s.summaryMu.Lock()
+				delete(s.summaryDirty, sess.ID)
+				s.summaryMu.Unlock()
+				return
+			}
+			sess = current
+		}
+	}()
+}
+
+// fetchSummary reads sess's summary from its node and applies it, waiting
+// for a slot under maxSummaryFetches first.
+func (s *Server) fetchSummary(sess *store.Session) {
+	s.summarySem <- struct{}{}
+	defer func() { <-s.summarySem }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), summaryFetchTimeout)
+	defer cancel()
+	summary, err := s.nodeOps.ReadSummary(ctx, sess.NodeName, sess.ID, sess.Cwd, sess.TranscriptPath)
+	if err != nil || summary == nil {
+		return
+	}
+	// Re-fetch session to avoid overwriting concurrent changes
+	current, err := s.store.GetSession(sess.ID)
+	if err != nil {
+		return
+	}
+	applySummary(current, summary)
+	if err := s.store.UpdateSession(current); err != nil {
+		s.logger.Debug("failed to update session summary", "error", err)
+	}
+}
+
+// applySummary copies the transcript-derived fields of summary onto sess.
+func applySummary(sess *store.Session, summary *transcript.SessionSummary) {
+	if !sess.TopicLocked {
+		sess.Topic = summary.Topic
+	}
+	sess.PlanSummary = summary.PlanSummary
+	sess.LastReply = replyPreview(summary.LastReply)
+	sess.ErrorCount = summary.ErrorCount
+	sess.PendingQuestion = nil
+	if len(summary.PendingQuestions) > 0 && sess.StoppedAt.IsZero() {
+		sess.PendingQuestion = mustJSON(summary.PendingQuestions)
+	}
+}
+
+// handleRefreshSession re-reads a session's transcript and updates its
+// summary fields right away, rather than waiting for the next turn end.
+func (s *Server) handleRefreshSession(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	sess, err := s.store.GetSession(id)
+	if errors.Is(err, store.ErrNotFound) {
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "session not found")
+		return
+	} else if err != nil {
+		s.logger.Error("failed to get session", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), summaryFetchTimeout)
+	defer cancel()
+	summary, err := s.nodeOps.ReadSummary(ctx, sess.NodeName, sess.ID, sess.Cwd, sess.TranscriptPath)
+	if errors.Is(err, ErrAgentOffline) {
+		writeJSONError(w, http.StatusServiceUnavailable, errCodeAgentOffline, err.Error())
+		return
+	} else if err != nil {
+		s.logger.Error("summary read failed", "error", err, "session_id", id)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+
+	if summary != nil {
+		// Re-fetch session to avoid overwriting changes made during the read
+		if sess, err = s.store.GetSession(id); err == nil {
+			applySummary(sess, summary)
+			err = s.store.UpdateSession(sess)
+		}
+		if errors.Is(err, store.ErrNotFound) {
+			writeJSONError(w, http.StatusNotFound, errCodeNotFound, "session not found")
+			return
+		} else if err != nil {
+			s.logger.Error("failed to update session summary", "error", err, "session_id", id)
+			writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sess)
 }
 
 func (s *Server) handleToolActivity(w http.ResponseWriter, r *http.Request) {
@@ -405,34 +978,106 @@ func (s *Server) handleToolActivity(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		HookEventName string `json:"hook_event_name"`
 		ToolName      string `json:"tool_name"`
+		ToolUseID     string `json:"tool_use_id"`
 		NodeName      string `json:"node_name"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+	if !s.decodeJSON(w, r, &req) {
 		return
 	}
 
-	_, err := s.store.GetSession(id)
+	sess, err := s.store.GetSession(id)
 	if errors.Is(err, store.ErrNotFound) {
 		w.WriteHeader(http.StatusOK)
 		return
 	} else if err != nil {
 		s.logger.Error("failed to get session", "error", err)
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
 		return
 	}
 
+	// Mark the session busy on its first tool of a turn. Later tools find it
+	// already busy and skip the write; the Stop hook clears it.
+	becameBusy := req.HookEventName == "PreToolUse" && !sess.Busy && sess.StoppedAt.IsZero()
+	if becameBusy {
+		sess.Busy = true
+		if err := s.store.UpdateSession(sess); err != nil {
+			s.logger.Error("failed to update session", "error", err)
+			writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+			return
+		}
+	}
+
+	// The agent blocks on AskUserQuestion without ending its turn, so pick the
+	// question up from the transcript now rather than waiting for Stop.
+	if req.HookEventName == "PreToolUse" && req.ToolName == "AskUserQuestion" && sess.StoppedAt.IsZero() {
+		s.refreshSummary(sess)
+	}
+
+	if sess.StoppedAt.IsZero() {
+		s.trackSlowTool(id, req.HookEventName, req.ToolName, req.ToolUseID)
+	}
+
 	// Do NOT update LastActivityAt — avoid frequent store writes; Stop hook handles that.
 	s.events.Publish(id, Event{
 		Type:    EventToolActivity,
 		Session: id,
 		Data:    mustJSON(map[string]string{"hook_event_name": req.HookEventName, "tool_name": req.ToolName}),
 	})
+	if becameBusy {
+		s.events.Publish(id, Event{Type: EventBusy, Session: id, Data: mustJSON(Busy{Busy: true})})
+	}
 
 	s.logger.Debug("tool activity", "session_id", id, "event", req.HookEventName, "tool", req.ToolName)
 	w.WriteHeader(http.StatusOK)
 }
 
+// handlePatchSession applies user-editable settings to a session. Only fields
+// present in the request body are changed.
+func (s *Server) handlePatchSession(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var req struct {
+		Pinned *bool   `json:"pinned"`
+		Muted  *bool   `json:"muted"`
+		Topic  *string `json:"topic"`
+	}
+	if !s.decodeJSON(w, r, &req) {
+		return
+	}
+
+	sess, err := s.store.GetSession(id)
+	if errors.Is(err, store.ErrNotFound) {
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "session not found")
+		return
+	} else if err != nil {
+		s.logger.Error("failed to get session", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+
+	if req.Pinned != nil {
+		sess.Pinned = *req.Pinned
+	}
+	if req.Muted != nil {
+		sess.Muted = *req.Muted
+	}
+	if req.Topic != nil {
+		// An empty topic hands control back to summary extraction.
+		sess.Topic = strings.TrimSpace(*req.Topic)
+		sess.TopicLocked = sess.Topic != ""
+	}
+
+	if err := s.store.UpdateSession(sess); err != nil {
+		s.logger.Error("failed to update session", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+
+	s.logger.Info("session updated", "session_id", id, "pinned", sess.Pinned, "muted", sess.Muted, "topic_locked", sess.TopicLocked)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sess)
+}
+
 func (s *Server) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
@@ -442,17 +1087,21 @@ func (s *Server) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
 		return
 	} else if err != nil {
 		s.logger.Error("failed to get session", "error", err)
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
 		return
 	}
 
-	sess.StoppedAt = time.Now()
+	sess.StoppedAt = s.clock.Now()
+	sess.StopReason = store.StopReasonUserEnd
+	sess.Busy = false
+	sess.PendingQuestion = nil
 	if err := s.store.UpdateSession(sess); err != nil {
 		s.logger.Error("failed to update session", "error", err)
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
 		return
 	}
 
+	s.toolTimers.stopAll(id)
 	s.events.Publish(id, Event{Type: EventSessionEnd, Session: id})
 
 	s.logger.Info("session ended", "session_id", id)
@@ -468,26 +1117,97 @@ func (s *Server) handleRespond(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
 	var req struct {
-		Text string `json:"text"`
+		Text   string `json:"text"`
+		Submit *bool  `json:"submit"` // defaults to true; false stages the text without Enter
+
+		// Options answers a pending AskUserQuestion by 0-based option index
+		// instead of Text; Question picks which of its questions.
+		Options  []int `json:"options"`
+		Question int   `json:"question"`
+
+		// Pane picks which of the session's panes receives the text;
+		// empty means the primary pane, where the agent runs.
+		Pane string `json:"pane"`
+	}
+	if !s.decodeJSON(w, r, &req) {
+		return
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+	submit := req.Submit == nil || *req.Submit
+	if req.Options != nil && req.Text != "" {
+		writeJSONError(w, http.StatusBadRequest, errCodeBadRequest, "send either text or options, not both")
 		return
 	}
+	if req.Pane != "" {
+		pane, err := tmux.NormalizePane(req.Pane)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, errCodeBadRequest, err.Error())
+			return
+		}
+		req.Pane = pane
+	}
 
 	sess, err := s.store.GetSession(id)
 	if errors.Is(err, store.ErrNotFound) {
-		http.Error(w, "session not found", http.StatusNotFound)
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "session not found")
 		return
 	} else if err != nil {
 		s.logger.Error("failed to get session", "error", err)
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+	if req.Pane != "" && req.Pane != sess.TmuxPane {
+		if !slices.Contains(sess.ExtraPanes, req.Pane) {
+			writeJSONError(w, http.StatusBadRequest, errCodeBadRequest, "pane "+req.Pane+" is not one of the session's panes")
+			return
+		}
+		if req.Options != nil {
+			writeJSONError(w, http.StatusBadRequest, errCodeBadRequest, "options answer the agent's question, so they go to the primary pane")
+			return
+		}
+		s.respondInExtraPane(w, sess, req.Pane, req.Text, submit)
+		return
+	}
+	if _, err := tmux.NormalizePane(sess.TmuxPane); err != nil {
+		writeJSONError(w, http.StatusConflict, errCodeNoPane, "session has no tmux pane to respond in: "+err.Error())
 		return
 	}
 
-	if err := s.nodeOps.SendKeys(sess.NodeName, sess.TmuxPane, req.Text); err != nil {
+	if req.Options != nil {
+		text, status, code, msg := s.answerQuestion(sess, req.Question, req.Options)
+		if status != 0 {
+			writeJSONError(w, status, code, msg)
+			return
+		}
+		req.Text = text
+	}
+
+	err = s.nodeOps.SendKeys(sess.NodeName, sess.TmuxPane, req.Text, submit)
+	if errors.Is(err, ErrPaneGone) {
+		// The pane was closed without a SessionEnd hook firing; nothing can
+		// answer this session anymore, so stop it.
+		s.logger.Warn("pane gone, stopping session", "session_id", id, "pane", sess.TmuxPane, "node", sess.NodeName)
+		if err := s.store.StopSessions([]string{id}, store.StopReasonPaneGone); err != nil {
+			s.logger.Error("failed to stop session", "error", err)
+		} else {
+			s.events.Publish(id, Event{Type: EventSessionEnd, Session: id})
+		}
+		writeJSONError(w, http.StatusGone, errCodePaneGone, "pane no longer exists")
+		return
+	} else if errors.Is(err, ErrAgentOffline) {
+		s.logger.Warn("cannot respond, agent offline", "session_id", id, "node", sess.NodeName)
+		writeJSONError(w, http.StatusServiceUnavailable, errCodeAgentOffline, err.Error())
+		return
+	} else if err != nil {
 		s.logger.Error("tmux send-keys failed", "error", err, "pane", sess.TmuxPane, "node", sess.NodeName)
-		http.Error(w, "failed to send response: "+err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeSendFailed, "failed to send response: "+err.Error())
+		return
+	}
+
+	// Staged text hasn't answered anything yet, so leave the notification up.
+	if !submit {
+		s.logger.Info("response staged", "session_id", id, "pane", sess.TmuxPane, "text_len", len(req.Text))
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"status": "staged"})
 		return
 	}
 
@@ -495,7 +1215,8 @@ func (s *Server) handleRespond(w http.ResponseWriter, r *http.Request) {
 	sess.NotifyMessage = ""
 	sess.NotificationType = ""
 	sess.NotifiedAt = time.Time{}
-	sess.LastActivityAt = time.Now()
+	sess.PendingQuestion = nil
+	sess.LastActivityAt = s.clock.Now()
 	if err := s.store.UpdateSession(sess); err != nil {
 		s.logger.Error("failed to update last activity", "error", err)
 	}
@@ -507,30 +1228,295 @@ func (s *Server) handleRespond(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "sent"})
 }
 
-func (s *Server) handleTranscript(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-
-	sess, err := s.store.GetSession(id)
-	if errors.Is(err, store.ErrNotFound) {
-		http.Error(w, "session not found", http.StatusNotFound)
+// respondInExtraPane sends text to one of the session's additional panes.
+// The agent isn't running there, so the session's notification state is
+// left alone, and a closed pane doesn't stop the session.
+func (s *Server) respondInExtraPane(w http.ResponseWriter, sess *store.Session, pane, text string, submit bool) {
+	err := s.nodeOps.SendKeys(sess.NodeName, pane, text, submit)
+	if errors.Is(err, ErrPaneGone) {
+		writeJSONError(w, http.StatusGone, errCodePaneGone, "pane no longer exists")
+		return
+	} else if errors.Is(err, ErrAgentOffline) {
+		writeJSONError(w, http.StatusServiceUnavailable, errCodeAgentOffline, err.Error())
 		return
 	} else if err != nil {
-		s.logger.Error("failed to get session", "error", err)
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		s.logger.Error("tmux send-keys failed", "error", err, "pane", pane, "node", sess.NodeName)
+		writeJSONError(w, http.StatusInternalServerError, errCodeSendFailed, "failed to send response: "+err.Error())
 		return
 	}
 
-	tr, err := s.nodeOps.ReadTranscript(sess.NodeName, id, sess.Cwd, sess.TranscriptPath)
-	if err != nil {
+	status := "sent"
+	if !submit {
+		status = "staged"
+	}
+	s.logger.Info("response "+status, "session_id", sess.ID, "pane", pane, "text_len", len(text))
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": status})
+}
+
+func (s *Server) handleTranscript(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	sess, err := s.store.GetSession(id)
+	if errors.Is(err, store.ErrNotFound) {
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "session not found")
+		return
+	} else if err != nil {
+		s.logger.Error("failed to get session", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+
+	compact := r.URL.Query().Get("compact") == "1"
+	maxText := defaultCompactTextLen
+	if v := r.URL.Query().Get("max_text"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeJSONError(w, http.StatusBadRequest, errCodeBadRequest, "invalid max_text")
+			return
+		}
+		maxText = n
+	}
+
+	// The agent's ETag covers the raw transcript; a variant suffix keeps
+	// compact and full representations from validating each other.
+	variant := ""
+	if compact {
+		variant = fmt.Sprintf(";compact=%d", maxText)
+	}
+	agentETag := ""
+	if inm := strings.Trim(r.Header.Get("If-None-Match"), `"`); inm != "" && strings.HasSuffix(inm, variant) {
+		agentETag = `"` + strings.TrimSuffix(inm, variant) + `"`
+	}
+
+	// Clients opening the same session at once share one agent request. The
+	// key includes the validator because a conditional read can come back
+	// not-modified.
+	tr, etag, err := s.transcripts.do(id+"\x00"+agentETag, func() (*transcript.Transcript, string, error) {
+		return s.nodeOps.ReadTranscript(sess.NodeName, id, sess.Cwd, sess.TranscriptPath, agentETag)
+	})
+	if errors.Is(err, ErrNotModified) {
+		w.Header().Set("ETag", r.Header.Get("If-None-Match"))
+		w.WriteHeader(http.StatusNotModified)
+		return
+	} else if err != nil {
 		s.logger.Debug("transcript read failed", "error", err)
-		tr = &transcript.Transcript{}
+		tr = transcript.Empty()
+	}
+	if compact {
+		tr = tr.Compact(maxText)
 	}
+	tr = clientTranscript(tr, r.URL.Query().Get("debug") == "1")
 
+	if etag != "" {
+		w.Header().Set("ETag", `"`+strings.Trim(etag, `"`)+variant+`"`)
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(tr)
+	json.NewEncoder(w).Encode(transcriptResponse{
+		Transcript:  tr,
+		AgentOnline: s.agents.IsHealthy(sess.NodeName),
+	})
 }
 
-// reapSessions periodically removes sessions that have been stopped longer than the TTL.
+// handleToolCall returns the full input and result of one tool call, which
+// transcripts omit, so clients can expand a tool_use block on demand.
+func (s *Server) handleToolCall(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	sess, err := s.store.GetSession(id)
+	if errors.Is(err, store.ErrNotFound) {
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "session not found")
+		return
+	} else if err != nil {
+		s.logger.Error("failed to get session", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+
+	call, err := s.nodeOps.ReadToolCall(sess.NodeName, id, sess.Cwd, sess.TranscriptPath, r.PathValue("toolUseId"))
+	if errors.Is(err, transcript.ErrToolCallNotFound) {
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "tool call not found")
+		return
+	} else if errors.Is(err, ErrAgentOffline) {
+		writeJSONError(w, http.StatusServiceUnavailable, errCodeAgentOffline, err.Error())
+		return
+	} else if err != nil {
+		s.logger.Error("tool call read failed", "error", err, "session_id", id)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(call)
+}
+
+// handleLastReply returns just the session's latest assistant text, a cheap
+// preview for session lists. It comes from the agent's summary, falling back
+// to the full transcript and then to the stored one-line preview; the text
+// is empty when none of those has it.
+func (s *Server) handleLastReply(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	sess, err := s.store.GetSession(id)
+	if errors.Is(err, store.ErrNotFound) {
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "session not found")
+		return
+	} else if err != nil {
+		s.logger.Error("failed to get session", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+
+	text := ""
+	summary, err := s.nodeOps.ReadSummary(r.Context(), sess.NodeName, id, sess.Cwd, sess.TranscriptPath)
+	if err != nil {
+		s.logger.Debug("last reply summary read failed", "error", err, "session_id", id)
+	}
+	if summary != nil {
+		text = summary.LastReply
+	} else {
+		// Shares in-flight unconditional reads with handleTranscript.
+		tr, _, err := s.transcripts.do(id+"\x00", func() (*transcript.Transcript, string, error) {
+			return s.nodeOps.ReadTranscript(sess.NodeName, id, sess.Cwd, sess.TranscriptPath, "")
+		})
+		if err != nil {
+			s.logger.Debug("last reply transcript read failed", "error", err, "session_id", id)
+		} else {
+			text = transcript.LastAssistantText(tr)
+		}
+	}
+	if text == "" {
+		text = sess.LastReply
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"text": text})
+}
+
+// transcriptResponse lets clients tell an empty transcript from one that
+// couldn't be loaded because the session's agent is offline.
+type transcriptResponse struct {
+	*transcript.Transcript
+	AgentOnline bool `json:"agent_online"`
+}
+
+// clientTranscript returns a copy of tr ready to send to clients: stamped with
+// the current format version, and without debug info unless asked for. tr may
+// be shared with concurrent requests, so it is left untouched.
+func clientTranscript(tr *transcript.Transcript, debug bool) *transcript.Transcript {
+	out := *tr
+	out.Version = transcript.FormatVersion
+	if !debug {
+		out.Debug = nil
+	}
+	return &out
+}
+
+// defaultCompactTextLen is the text block cap, in runes, for ?compact=1
+// transcripts when no max_text is given.
+const defaultCompactTextLen = 2000
+
+// handleExport serves a session's transcript as a downloadable document,
+// either Markdown (format=md, the default) or the parsed JSON (format=json).
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "md"
+	}
+	if format != "md" && format != "json" {
+		writeJSONError(w, http.StatusBadRequest, errCodeBadRequest, "unknown format "+format)
+		return
+	}
+
+	sess, err := s.store.GetSession(id)
+	if errors.Is(err, store.ErrNotFound) {
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "session not found")
+		return
+	} else if err != nil {
+		s.logger.Error("failed to get session", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+
+	tr, _, err := s.nodeOps.ReadTranscript(sess.NodeName, id, sess.Cwd, sess.TranscriptPath, "")
+	if err != nil {
+		s.logger.Debug("transcript read failed", "error", err)
+		tr = transcript.Empty()
+	}
+
+	tr = clientTranscript(tr, false)
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "sophon-"+id+"."+format))
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(tr)
+		return
+	}
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	io.WriteString(w, transcript.RenderMarkdown(tr))
+}
+
+// handleRawTranscript streams the session's original JSONL from its node,
+// unparsed, for tooling that wants the full records.
+func (s *Server) handleRawTranscript(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	sess, err := s.store.GetSession(id)
+	if errors.Is(err, store.ErrNotFound) {
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "session not found")
+		return
+	} else if err != nil {
+		s.logger.Error("failed to get session", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+
+	raw, err := s.nodeOps.ReadRawTranscript(r.Context(), sess.NodeName, id, sess.Cwd, sess.TranscriptPath)
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, transcript.ErrNotTranscript) {
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "transcript not found")
+		return
+	} else if errors.Is(err, transcript.ErrTooLarge) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, errCodeTooLarge, err.Error())
+		return
+	} else if errors.Is(err, ErrAgentOffline) {
+		writeJSONError(w, http.StatusServiceUnavailable, errCodeAgentOffline, err.Error())
+		return
+	} else if err != nil {
+		s.logger.Error("raw transcript read failed", "error", err, "session_id", id)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+	defer raw.Close()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "sophon-"+id+".jsonl"))
+	if _, err := io.Copy(w, raw); err != nil {
+		s.logger.Debug("raw transcript stream failed", "error", err, "session_id", id)
+		// Headers are already out; breaking the connection is the only way
+		// left to tell the client the download is incomplete.
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// maxLastReplyLen caps the stored reply preview, in runes.
+const maxLastReplyLen = 200
+
+// replyPreview collapses text onto a single line and truncates it for storage
+// as a session's LastReply.
+func replyPreview(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > maxLastReplyLen {
+		return string(runes[:maxLastReplyLen]) + "…"
+	}
+	return text
+}
+
+// reapSessions periodically removes sessions that have been stopped longer
+// than the TTL, and the oldest stopped sessions beyond MaxSessions.
 func (s *Server) reapSessions() {
 	ticker := time.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
@@ -541,44 +1527,250 @@ func (s *Server) reapSessions() {
 			continue
 		}
 		for _, id := range reaped {
+			s.events.Forget(id)
 			s.logger.Info("session reaped", "session_id", id)
 		}
+		s.trimSessions()
+		s.stopIdleSessions()
+	}
+}
+
+// trimSessions deletes the oldest stopped sessions beyond MaxSessions.
+func (s *Server) trimSessions() {
+	if s.cfg.MaxSessions <= 0 {
+		return
+	}
+	trimmed, err := s.store.TrimStoppedSessions(s.cfg.MaxSessions)
+	if err != nil {
+		s.logger.Error("failed to trim sessions", "error", err)
+		return
+	}
+	for _, id := range trimmed {
+		s.events.Forget(id)
+		s.logger.Info("session trimmed", "session_id", id, "max_sessions", s.cfg.MaxSessions)
+	}
+}
+
+// stopIdleSessions stops sessions that have gone quiet past the idle timeout.
+// Sessions on nodes with a healthy agent are left to reconcileSessions, which
+// knows whether claude is actually still running in the pane.
+func (s *Server) stopIdleSessions() {
+	idleTimeout := s.tunables().IdleTimeout
+	if idleTimeout <= 0 {
+		return
+	}
+	sessions, err := s.store.ListIdleSessions(idleTimeout)
+	if err != nil {
+		s.logger.Error("failed to list idle sessions", "error", err)
+		return
+	}
+
+	var toStop []string
+	for _, sess := range sessions {
+		if s.agents.IsHealthy(sess.NodeName) {
+			continue
+		}
+		toStop = append(toStop, sess.ID)
+	}
+	if len(toStop) == 0 {
+		return
+	}
+
+	if err := s.store.StopSessions(toStop, store.StopReasonIdleTimeout); err != nil {
+		s.logger.Error("failed to stop idle sessions", "error", err)
+		return
+	}
+	for _, id := range toStop {
+		s.events.Publish(id, Event{Type: EventSessionEnd, Session: id})
+		s.logger.Info("idle session stopped", "session_id", id)
 	}
 }
 
+// maintainStore periodically compacts the database so the WAL and freed pages
+// from reaped sessions don't grow the file without bound.
+func (s *Server) maintainStore() {
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := s.store.Maintain(); err != nil {
+			s.logger.Error("store maintenance failed", "error", err)
+			continue
+		}
+		s.logger.Info("store maintenance complete")
+	}
+}
+
+// watchAgents periodically publishes offline transitions for agents whose
+// heartbeats have lapsed. Online transitions are published on registration.
+func (s *Server) watchAgents() {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.checkAgentHealth()
+	}
+}
+
+func (s *Server) checkAgentHealth() {
+	for _, node := range s.agents.ExpireStale() {
+		s.logger.Warn("agent went offline", "node", node)
+		s.publishAgentStatus(node, false)
+	}
+}
+
+func (s *Server) publishAgentStatus(node string, online bool) {
+	s.events.PublishGlobal(Event{
+		Type: EventAgentStatus,
+		Data: mustJSON(AgentStatus{Node: node, Online: online}),
+	})
+}
+
 func (s *Server) handleAgentRegister(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		NodeName   string            `json:"node_name"`
 		URL        string            `json:"url"`
+		Version    string            `json:"version,omitempty"`
 		AlivePanes *[]string         `json:"alive_panes,omitempty"` // nil = agent couldn't check
 		PaneTitles map[string]string `json:"pane_titles,omitempty"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+	if !s.decodeJSON(w, r, &req) {
+		return
+	}
+	if err := validateAgentURL(req.URL); err != nil {
+		s.logger.Warn("rejecting agent registration", "node", req.NodeName, "url", req.URL, "error", err)
+		writeJSONError(w, http.StatusBadRequest, errCodeBadRequest, err.Error())
 		return
 	}
 
-	s.agents.Register(req.NodeName, req.URL)
+	s.recordHeartbeat(req.NodeName, req.URL, req.Version, req.AlivePanes, req.PaneTitles)
+	w.WriteHeader(http.StatusOK)
+}
+
+// agentJSON is one agent in the /api/agents response.
+type agentJSON struct {
+	NodeName string    `json:"node_name"`
+	URL      string    `json:"url"`
+	Version  string    `json:"version,omitempty"`
+	LastSeen time.Time `json:"last_seen"`
+	Online   bool      `json:"online"`
+}
+
+// handleListAgents reports registered agents alongside the daemon's own
+// version, so clients can flag nodes running a different build.
+func (s *Server) handleListAgents(w http.ResponseWriter, r *http.Request) {
+	agents := []agentJSON{}
+	for _, info := range s.agents.List() {
+		agents = append(agents, agentJSON{
+			NodeName: info.NodeName,
+			URL:      info.URL,
+			Version:  info.Version,
+			LastSeen: info.LastSeen,
+			Online:   s.agents.IsHealthy(info.NodeName),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Version string      `json:"version,omitempty"`
+		Agents  []agentJSON `json:"agents"`
+	}{s.cfg.Version, agents})
+}
+
+// validateAgentURL checks that an agent's registered URL is something the
+// proxy can actually dial. A bad URL would otherwise only surface later as
+// opaque proxy failures.
+func validateAgentURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid agent url: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid agent url %q: scheme must be http or https", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid agent url %q: missing host", raw)
+	}
+	return nil
+}
+
+// recordHeartbeat registers an agent and applies the pane state it reported.
+// alivePanes is nil when the agent couldn't check its panes.
+func (s *Server) recordHeartbeat(nodeName, agentURL, version string, alivePanes *[]string, paneTitles map[string]string) {
+	// A gap longer than the stale timeout between consecutive heartbeats means
+	// the agent's interval is too long for this daemon's timeout, and the
+	// agent will flap offline between registrations.
+	prev, seen := s.agents.Get(nodeName)
+	if seen {
+		if gap := s.clock.Now().Sub(prev.LastSeen); gap >= s.agents.StaleTimeout() {
+			s.logger.Warn("agent heartbeat gap exceeds stale timeout", "node", nodeName,
+				"gap", gap.Round(time.Second), "stale_timeout", s.agents.StaleTimeout())
+		}
+	}
+
+	// Warn once per reported version rather than on every heartbeat.
+	if version != "" && s.cfg.Version != "" && version != s.cfg.Version && (!seen || prev.Version != version) {
+		s.logger.Warn("agent version differs from daemon", "node", nodeName,
+			"agent_version", version, "daemon_version", s.cfg.Version)
+	}
+
+	if s.agents.Register(nodeName, agentURL, version) {
+		s.publishAgentStatus(nodeName, true)
+	}
 
 	// Reconcile sessions if agent reported alive panes
-	if req.AlivePanes != nil {
-		s.reconcileSessions(req.NodeName, *req.AlivePanes)
+	if alivePanes != nil {
+		s.reconcileSessions(nodeName, *alivePanes)
 	}
 
 	// Store semantic task titles rather than terminal animation state. Besides
 	// keeping the sidebar quiet, this makes the same concise label available to
 	// alerts emitted between heartbeats.
-	if len(req.PaneTitles) > 0 {
-		for pane, title := range req.PaneTitles {
-			req.PaneTitles[pane] = sessiontitle.Parse(title)
+	if len(paneTitles) > 0 {
+		for pane, title := range paneTitles {
+			paneTitles[pane] = sessiontitle.Parse(title)
 		}
-		if err := s.store.UpdatePaneTitles(req.NodeName, req.PaneTitles); err != nil {
-			s.logger.Error("failed to update pane titles", "error", err, "node", req.NodeName)
+		if err := s.store.UpdatePaneTitles(nodeName, paneTitles); err != nil {
+			s.logger.Error("failed to update pane titles", "error", err, "node", nodeName)
 		}
 	}
 
-	s.logger.Debug("agent registered", "node", req.NodeName, "url", req.URL)
-	w.WriteHeader(http.StatusOK)
+	s.logger.Debug("agent registered", "node", nodeName, "url", agentURL)
+}
+
+// knownNotificationTypes is the set of notification types the UI understands.
+// Claude Code emits the first four; plan_approval is synthesized by handlePlan.
+var knownNotificationTypes = map[string]bool{
+	"permission_prompt":  true,
+	"idle_prompt":        true,
+	"auth_success":       true,
+	"elicitation_dialog": true,
+	"plan_approval":      true,
+}
+
+// defaultNotificationType is stored for empty or unrecognized types, matching
+// the generic "Waiting for input" treatment they already get in alerts.
+const defaultNotificationType = "idle_prompt"
+
+// normalizeNotificationType maps a hook-provided type onto the known set so
+// typos and new upstream types don't leak into stored session state.
+func normalizeNotificationType(t string) string {
+	t = strings.ToLower(strings.TrimSpace(t))
+	if knownNotificationTypes[t] {
+		return t
+	}
+	return defaultNotificationType
+}
+
+// notificationData encodes a notification event payload. Muted sessions and
+// notifications during quiet hours are flagged so clients keep the dashboard
+// current without raising an alert.
+func (s *Server) notificationData(sess *store.Session, data map[string]string) json.RawMessage {
+	if sess.Muted {
+		data["muted"] = "true"
+	}
+	if s.tunables().QuietHours.Contains(s.clock.Now()) {
+		data["quiet"] = "true"
+	}
+	return mustJSON(data)
 }
 
 func alertTitle(sess *store.Session, notificationType, fallback string) string {
@@ -596,13 +1788,16 @@ func alertTitle(sess *store.Session, notificationType, fallback string) string {
 		state = "Needs approval"
 	case "plan_approval":
 		state = "Plan ready"
+	case "tool_running":
+		state = "Tool still running"
 	default:
 		state = "Waiting for input"
 	}
 	return title + " · " + state
 }
 
-// reconcileSessions stops active sessions whose tmux pane is not in the alive set.
+// reconcileSessions stops active sessions whose tmux pane is not in the alive
+// set and has been missing for at least the configured grace period.
 func (s *Server) reconcileSessions(nodeName string, alivePanes []string) {
 	aliveSet := make(map[string]bool, len(alivePanes))
 	for _, p := range alivePanes {
@@ -615,21 +1810,40 @@ func (s *Server) reconcileSessions(nodeName string, alivePanes []string) {
 		return
 	}
 
+	now := s.clock.Now()
+	grace := s.tunables().ReconcileGrace
+	s.missingMu.Lock()
+	prevMissing := s.missingSince[nodeName]
+	missing := make(map[string]time.Time)
 	var toStop []string
 	for _, sess := range sessions {
 		if sess.TmuxPane == "" {
 			continue // can't reconcile sessions without pane info
 		}
-		if !aliveSet[sess.TmuxPane] {
+		if aliveSet[sess.TmuxPane] {
+			continue
+		}
+		since, seen := prevMissing[sess.ID]
+		if !seen {
+			since = now
+		}
+		if now.Sub(since) >= grace {
 			toStop = append(toStop, sess.ID)
+		} else {
+			missing[sess.ID] = since
 		}
 	}
+	if s.missingSince == nil {
+		s.missingSince = make(map[string]map[string]time.Time)
+	}
+	s.missingSince[nodeName] = missing
+	s.missingMu.Unlock()
 
 	if len(toStop) == 0 {
 		return
 	}
 
-	if err := s.store.StopSessions(toStop); err != nil {
+	if err := s.store.StopSessions(toStop, store.StopReasonReconciled); err != nil {
 		s.logger.Error("failed to stop reconciled sessions", "error", err)
 		return
 	}
@@ -640,12 +1854,31 @@ func (s *Server) reconcileSessions(nodeName string, alivePanes []string) {
 	}
 }
 
+// writeSSE writes one event in the wire format shared by the per-session and
+// global streams: the SSE event name is the type, and the data is the full
+// Event envelope so consumers of either stream parse the same shape.
+func writeSSE(w io.Writer, evt Event) error {
+	data, _ := json.Marshal(evt)
+	_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", evt.Type, data)
+	return err
+}
+
+// sendSSE writes and flushes one event. An error means the client is gone and
+// the stream handler should return so its subscription is released even if
+// the request context was never cancelled.
+func sendSSE(w http.ResponseWriter, evt Event) error {
+	if err := writeSSE(w, evt); err != nil {
+		return err
+	}
+	return http.NewResponseController(w).Flush()
+}
+
 func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "streaming not supported")
 		return
 	}
 
@@ -658,7 +1891,9 @@ func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
 	defer unsub()
 
 	// Send initial connection event
-	fmt.Fprintf(w, "event: connected\ndata: {}\n\n")
+	if _, err := fmt.Fprintf(w, "event: connected\ndata: {}\n\n"); err != nil {
+		return
+	}
 	flusher.Flush()
 
 	ctx := r.Context()
@@ -670,17 +1905,59 @@ func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
 			if !ok {
 				return
 			}
-			data, _ := json.Marshal(evt.Data)
-			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", evt.Type, data)
-			flusher.Flush()
+			if err := sendSSE(w, evt); err != nil {
+				s.logger.Debug("sse write failed; dropping subscriber", "error", err)
+				return
+			}
 		}
 	}
 }
 
+// handleEventHistory returns a session's recently published events, oldest
+// first, so a freshly opened client can show what it missed. ?limit= caps the
+// count.
+func (s *Server) handleEventHistory(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeJSONError(w, http.StatusBadRequest, errCodeBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	if _, err := s.store.GetSession(id); errors.Is(err, store.ErrNotFound) {
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "session not found")
+		return
+	} else if err != nil {
+		s.logger.Error("failed to get session", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.events.History(id, limit))
+}
+
 func (s *Server) handleGlobalSSE(w http.ResponseWriter, r *http.Request) {
+	s.streamGlobal(w, r, r.URL.Query().Get("project"))
+}
+
+// handleProjectSSE streams events for one project's sessions. Project names
+// contain a slash, so clients escape it: /api/projects/user%2Frepo/events.
+func (s *Server) handleProjectSSE(w http.ResponseWriter, r *http.Request) {
+	s.streamGlobal(w, r, r.PathValue("project"))
+}
+
+// streamGlobal serves the global event stream, limited to project's sessions
+// when it is set and to the ?node= query parameter's node.
+func (s *Server) streamGlobal(w http.ResponseWriter, r *http.Request, project string) {
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "streaming not supported")
 		return
 	}
 
@@ -689,12 +1966,29 @@ func (s *Server) handleGlobalSSE(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("X-Accel-Buffering", "no")
 
+	filter := &streamFilter{
+		project: project,
+		node:    r.URL.Query().Get("node"),
+		store:   s.store,
+		seen:    make(map[string]bool),
+	}
+
 	ch, unsub := s.events.SubscribeGlobal()
 	defer unsub()
 
-	fmt.Fprintf(w, "event: connected\ndata: {}\n\n")
+	if _, err := fmt.Fprintf(w, "event: connected\ndata: {}\n\n"); err != nil {
+		return
+	}
 	flusher.Flush()
 
+	// The snapshot is read after subscribing, so no event is lost between
+	// the two; one racing the read may repeat state the snapshot already has.
+	if snapshot, err := s.activeSnapshot(filter); err != nil {
+		s.logger.Error("failed to list active sessions for snapshot", "error", err)
+	} else if err := sendSSE(w, snapshot); err != nil {
+		return
+	}
+
 	ctx := r.Context()
 	for {
 		select {
@@ -704,58 +1998,214 @@ func (s *Server) handleGlobalSSE(w http.ResponseWriter, r *http.Request) {
 			if !ok {
 				return
 			}
-			data, _ := json.Marshal(evt)
-			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", evt.Type, data)
-			flusher.Flush()
+			if !filter.match(evt) {
+				continue
+			}
+			if err := sendSSE(w, evt); err != nil {
+				s.logger.Debug("sse write failed; dropping subscriber", "error", err)
+				return
+			}
+		}
+	}
+}
+
+// activeSnapshot builds the EventSnapshot for a new global stream from the
+// active sessions the stream's filter admits.
+func (s *Server) activeSnapshot(filter *streamFilter) (Event, error) {
+	active, err := s.store.ListActiveSessionsOrdered(store.OrderStarted)
+	if err != nil {
+		return Event{}, err
+	}
+	active = slices.DeleteFunc(active, func(sess *store.Session) bool { return !filter.matchSession(sess) })
+	return Event{Type: EventSnapshot, Data: mustJSON(Snapshot{Active: s.withAgentStatus(active)})}, nil
+}
+
+// streamFilter restricts a global SSE stream to sessions in one project
+// and/or on one node. Each session's verdict is cached, and looked up again
+// on the events that can move a session to another project.
+type streamFilter struct {
+	project string
+	node    string
+	store   *store.Store
+	seen    map[string]bool
+}
+
+func (f *streamFilter) match(evt Event) bool {
+	if f.project == "" && f.node == "" {
+		return true
+	}
+	if evt.Session == "" {
+		// Session-less events (agent status) are scoped only by node.
+		if f.node == "" || evt.Type != EventAgentStatus {
+			return true
 		}
+		var st AgentStatus
+		return json.Unmarshal(evt.Data, &st) == nil && st.Node == f.node
 	}
+	switch evt.Type {
+	case EventSessionStart, EventNotification, EventActivity:
+		// Hooks behind these report the session's cwd, which followCwd
+		// may have just moved.
+		delete(f.seen, evt.Session)
+	}
+	if ok, cached := f.seen[evt.Session]; cached {
+		return ok
+	}
+	sess, err := f.store.GetSession(evt.Session)
+	if err != nil {
+		return false // unknown sessions can't be attributed; retry next event
+	}
+	return f.matchSession(sess)
+}
+
+// matchSession reports whether sess is in the filter's project and node,
+// caching the verdict for the session's later events.
+func (f *streamFilter) matchSession(sess *store.Session) bool {
+	ok := (f.project == "" || sess.Project == f.project) && (f.node == "" || sess.NodeName == f.node)
+	f.seen[sess.ID] = ok
+	return ok
 }
 
 // sessionResponse extends Session with agent health info for the API.
 type sessionResponse struct {
 	*store.Session
 	AgentOnline *bool `json:"agent_online,omitempty"` // only set for active sessions
+	// Focused is whether the session's pane is the one the user is looking
+	// at; only set for active sessions whose agent is online.
+	Focused *bool `json:"focused,omitempty"`
 }
 
 func (s *Server) handleSessionsAPI(w http.ResponseWriter, r *http.Request) {
-	active, err := s.store.ListActiveSessions()
+	if r.URL.Query().Get("attention") == "1" {
+		s.handleAttentionSessions(w)
+		return
+	}
+
+	order := store.OrderStarted
+	switch sort := r.URL.Query().Get("sort"); sort {
+	case "", "started":
+	case "activity":
+		order = store.OrderActivity
+	default:
+		writeJSONError(w, http.StatusBadRequest, errCodeBadRequest, "unknown sort "+sort)
+		return
+	}
+
+	active, err := s.store.ListActiveSessionsOrdered(order)
 	if err != nil {
 		s.logger.Error("failed to list active sessions", "error", err)
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
 		return
 	}
 
 	recent, err := s.store.ListRecentSessions(20)
 	if err != nil {
 		s.logger.Error("failed to list recent sessions", "error", err)
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
 		return
 	}
 
-	// Enrich active sessions with agent_online status
-	activeResp := make([]sessionResponse, len(active))
-	for i, sess := range active {
-		online := s.agents.IsHealthy(sess.NodeName)
-		activeResp[i] = sessionResponse{Session: sess, AgentOnline: &online}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"active": s.withAgentStatus(active),
+		"recent": recent,
+	})
+}
+
+// handleAttentionSessions serves ?attention=1: only active sessions waiting on
+// the user, longest-waiting first. Stopped sessions are omitted.
+func (s *Server) handleAttentionSessions(w http.ResponseWriter) {
+	active, err := s.store.ListAttentionSessions()
+	if err != nil {
+		s.logger.Error("failed to list attention sessions", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]any{
-		"active": activeResp,
-		"recent": recent,
+		"active": s.withAgentStatus(active),
 	})
 }
 
+// focusCheckTimeout bounds how long a session listing waits on agents for
+// pane focus. Sessions whose check hasn't answered by then omit focused.
+const focusCheckTimeout = 500 * time.Millisecond
+
+// withAgentStatus enriches active sessions with agent_online status and,
+// where the agent can tell, whether the session's pane is focused. Focus
+// checks run concurrently, so one slow agent can't stall the listing.
+func (s *Server) withAgentStatus(active []*store.Session) []sessionResponse {
+	type focusResult struct {
+		i       int
+		focused bool
+	}
+	results := make(chan focusResult, len(active))
+	pending := 0
+
+	resp := make([]sessionResponse, len(active))
+	for i, sess := range active {
+		online := s.agents.IsHealthy(sess.NodeName)
+		resp[i] = sessionResponse{Session: sess, AgentOnline: &online}
+		if online && sess.TmuxPane != "" {
+			pending++
+			s.bg.Add(1)
+			go func(i int, nodeName, pane string) {
+				defer s.bg.Done()
+				results <- focusResult{i: i, focused: s.nodeOps.PaneFocused(nodeName, pane)}
+			}(i, sess.NodeName, sess.TmuxPane)
+		}
+	}
+
+	timeout := time.NewTimer(focusCheckTimeout)
+	defer timeout.Stop()
+	for ; pending > 0; pending-- {
+		select {
+		case r := <-results:
+			resp[r.i].Focused = &r.focused
+		case <-timeout.C:
+			s.logger.Debug("pane focus checks timed out", "pending", pending)
+			return resp
+		}
+	}
+	return resp
+}
+
+func (s *Server) handleResponseTemplates(w http.ResponseWriter, r *http.Request) {
+	templates := s.cfg.ResponseTemplates
+	if templates == nil {
+		templates = []string{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"templates": templates})
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	counts, err := s.store.Counts()
+	if err != nil {
+		s.logger.Error("failed to count sessions", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		*store.SessionCounts
+		EventsDropped uint64                   `json:"events_dropped"`
+		SendKeys      map[string]SendKeysStats `json:"send_keys"`
+	}{counts, s.events.Dropped(), s.sends.snapshot()})
+}
+
 func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
 	sess, err := s.store.GetSession(id)
 	if errors.Is(err, store.ErrNotFound) {
-		http.Error(w, "session not found", http.StatusNotFound)
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "session not found")
 		return
 	} else if err != nil {
 		s.logger.Error("failed to get session", "error", err)
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
 		return
 	}
 
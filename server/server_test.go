@@ -2,15 +2,23 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/phinze/sophon/clock"
 	"github.com/phinze/sophon/store"
+	"github.com/phinze/sophon/tmux"
 	"github.com/phinze/sophon/transcript"
 )
 
@@ -18,29 +26,86 @@ import (
 type mockNodeOps struct {
 	focused     bool
 	sentKeys    []string
+	sentPanes   []string
+	sentEnter   []bool
+	sendErr     error
 	transcripts map[string]*transcript.Transcript     // keyed by sessionID
 	summaries   map[string]*transcript.SessionSummary // keyed by sessionID
+	etags       map[string]string                     // transcript ETags, keyed by sessionID
+	panes       map[string]tmux.Pane                  // keyed by pane
+	toolCalls   map[string]*transcript.ToolCall       // keyed by tool_use ID
+
+	// focusGates, keyed by pane, block focus checks until closed.
+	focusGates map[string]chan struct{}
+
+	// summaryGate and transcriptGate, when set, block reads until closed;
+	// the counters record how many reads ran.
+	mu                 sync.Mutex
+	summaryGate        chan struct{}
+	summaryInFlight    int
+	summaryMaxInFlight int
+	summaryCalls       int
+	transcriptGate     chan struct{}
+	transcriptCalls    int
 }
 
 func (m *mockNodeOps) PaneFocused(nodeName, pane string) bool {
+	if gate := m.focusGates[pane]; gate != nil {
+		<-gate
+	}
 	return m.focused
 }
 
-func (m *mockNodeOps) SendKeys(nodeName, pane, text string) error {
+func (m *mockNodeOps) PaneInfo(nodeName, pane string) (tmux.Pane, error) {
+	info, ok := m.panes[pane]
+	if !ok {
+		return tmux.Pane{}, ErrPaneGone
+	}
+	return info, nil
+}
+
+func (m *mockNodeOps) SendKeys(nodeName, pane, text string, enter bool) error {
 	m.sentKeys = append(m.sentKeys, text)
-	return nil
+	m.sentPanes = append(m.sentPanes, pane)
+	m.sentEnter = append(m.sentEnter, enter)
+	return m.sendErr
 }
 
-func (m *mockNodeOps) ReadTranscript(nodeName, sessionID, cwd, transcriptPath string) (*transcript.Transcript, error) {
+func (m *mockNodeOps) ReadTranscript(nodeName, sessionID, cwd, transcriptPath, etag string) (*transcript.Transcript, string, error) {
+	if m.transcriptGate != nil {
+		m.mu.Lock()
+		m.transcriptCalls++
+		m.mu.Unlock()
+		<-m.transcriptGate
+	}
+	current := m.etags[sessionID]
+	if etag != "" && etag == current {
+		return nil, current, ErrNotModified
+	}
 	if m.transcripts != nil {
 		if tr, ok := m.transcripts[sessionID]; ok {
-			return tr, nil
+			return tr, current, nil
 		}
 	}
-	return &transcript.Transcript{}, nil
+	return &transcript.Transcript{}, current, nil
+}
+
+func (m *mockNodeOps) ReadRawTranscript(ctx context.Context, nodeName, sessionID, cwd, transcriptPath string) (io.ReadCloser, error) {
+	return nil, fs.ErrNotExist
 }
 
-func (m *mockNodeOps) ReadSummary(nodeName, sessionID, cwd, transcriptPath string) (*transcript.SessionSummary, error) {
+func (m *mockNodeOps) ReadSummary(ctx context.Context, nodeName, sessionID, cwd, transcriptPath string) (*transcript.SessionSummary, error) {
+	if m.summaryGate != nil {
+		m.mu.Lock()
+		m.summaryCalls++
+		m.summaryInFlight++
+		m.summaryMaxInFlight = max(m.summaryMaxInFlight, m.summaryInFlight)
+		m.mu.Unlock()
+		<-m.summaryGate
+		m.mu.Lock()
+		m.summaryInFlight--
+		m.mu.Unlock()
+	}
 	if m.summaries != nil {
 		if s, ok := m.summaries[sessionID]; ok {
 			return s, nil
@@ -49,27 +114,38 @@ func (m *mockNodeOps) ReadSummary(nodeName, sessionID, cwd, transcriptPath strin
 	return nil, nil
 }
 
+func (m *mockNodeOps) ReadToolCall(nodeName, sessionID, cwd, transcriptPath, toolUseID string) (*transcript.ToolCall, error) {
+	call, ok := m.toolCalls[toolUseID]
+	if !ok {
+		return nil, transcript.ErrToolCallNotFound
+	}
+	return call, nil
+}
+
 // testHarness sets up a Server with an in-memory store and a mockNodeOps.
 type testHarness struct {
 	server  *Server
 	store   *store.Store
 	mockOps mockNodeOps
+	clock   *clock.Fake
 }
 
 func newTestHarness(t *testing.T) *testHarness {
 	t.Helper()
 
-	st, err := store.Open(":memory:")
+	fake := clock.NewFake(time.Now())
+	st, err := store.OpenWithOptions(":memory:", store.Options{Clock: fake})
 	if err != nil {
 		t.Fatalf("store.Open: %v", err)
 	}
 	t.Cleanup(func() { st.Close() })
 
-	h := &testHarness{store: st}
+	h := &testHarness{store: st, clock: fake}
 
 	cfg := Config{
 		BaseURL:       "https://example.com",
 		MinSessionAge: 120,
+		Clock:         fake,
 	}
 
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
@@ -135,10 +211,17 @@ func (h *testHarness) endSession(t *testing.T, id string) int {
 }
 
 func (h *testHarness) toolActivity(t *testing.T, id, hookEventName, toolName string) int {
+	t.Helper()
+	return h.toolCall(t, id, hookEventName, toolName, "")
+}
+
+// toolCall is toolActivity for a hook that reports the call's tool_use_id.
+func (h *testHarness) toolCall(t *testing.T, id, hookEventName, toolName, toolUseID string) int {
 	t.Helper()
 	body, _ := json.Marshal(map[string]string{
 		"hook_event_name": hookEventName,
 		"tool_name":       toolName,
+		"tool_use_id":     toolUseID,
 		"node_name":       "test-node",
 	})
 	req := httptest.NewRequest("POST", "/api/sessions/"+id+"/tool-activity", bytes.NewReader(body))
@@ -148,6 +231,31 @@ func (h *testHarness) toolActivity(t *testing.T, id, hookEventName, toolName str
 	return w.Code
 }
 
+func TestCreateSessionRecordsPaneInfo(t *testing.T) {
+	h := newTestHarness(t)
+	h.mockOps.panes = map[string]tmux.Pane{
+		"%5": {Title: "✳ Fix the flaky test", WindowName: "sophon"},
+	}
+
+	h.createSession(t, "s1", "%5", "/home/user/project")
+	h.server.bg.Wait()
+
+	sess, _ := h.store.GetSession("s1")
+	if sess.WindowName != "sophon" {
+		t.Errorf("WindowName = %q, want sophon", sess.WindowName)
+	}
+	if sess.PaneTitle != "Fix the flaky test" {
+		t.Errorf("PaneTitle = %q, want the parsed pane title", sess.PaneTitle)
+	}
+
+	// A pane the agent can't see leaves the session as registered.
+	h.createSession(t, "s2", "%9", "/home/user/project")
+	h.server.bg.Wait()
+	if sess, _ := h.store.GetSession("s2"); sess.WindowName != "" || sess.PaneTitle != "" {
+		t.Errorf("unknown pane: got window %q title %q", sess.WindowName, sess.PaneTitle)
+	}
+}
+
 func TestNotifyStoresSessionState(t *testing.T) {
 	h := newTestHarness(t)
 	h.createSession(t, "s1", "%5", "/home/user/project")
@@ -243,6 +351,35 @@ func TestTurnEndUpdatesLastActivity(t *testing.T) {
 	}
 }
 
+func TestTurnEndPublishesElapsed(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+
+	sess, _ := h.store.GetSession("s1")
+	sess.LastActivityAt = h.clock.Now().Add(-3 * time.Minute)
+	h.store.UpdateSession(sess)
+
+	h.turnEnd(t, "s1")
+	h.server.bg.Wait()
+
+	var activity *Event
+	for _, ev := range h.server.events.History("s1", 0) {
+		if ev.Type == EventActivity {
+			activity = &ev
+		}
+	}
+	if activity == nil {
+		t.Fatal("no activity event published")
+	}
+	var data Activity
+	if err := json.Unmarshal(activity.Data, &data); err != nil {
+		t.Fatalf("decoding activity data %s: %v", activity.Data, err)
+	}
+	if data.ElapsedSeconds != 180 {
+		t.Errorf("elapsed_seconds = %d, want 180", data.ElapsedSeconds)
+	}
+}
+
 func TestTurnEndSuppressedWhenTooYoung(t *testing.T) {
 	h := newTestHarness(t)
 	h.createSession(t, "s1", "%5", "/home/user/project")
@@ -271,6 +408,15 @@ func TestSessionEndSetsStoppedAt(t *testing.T) {
 	if sess.StoppedAt.IsZero() {
 		t.Error("StoppedAt should be set after session end")
 	}
+	if sess.StopReason != store.StopReasonUserEnd {
+		t.Errorf("StopReason = %q, want %q", sess.StopReason, store.StopReasonUserEnd)
+	}
+
+	// Resuming the session clears the reason along with StoppedAt.
+	h.createSession(t, "s1", "%5", "/home/user/project")
+	if sess, _ := h.store.GetSession("s1"); sess.StopReason != "" {
+		t.Errorf("StopReason = %q after resuming, want empty", sess.StopReason)
+	}
 }
 
 func TestLastActivitySetOnCreate(t *testing.T) {
@@ -358,6 +504,201 @@ func TestLastActivityUpdatedOnRespond(t *testing.T) {
 	}
 }
 
+func TestRespondSubmitFlag(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+	h.notify(t, "s1", "permission_prompt", "Allow Bash?")
+
+	respond := func(body map[string]any) string {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/api/respond/s1", bytes.NewReader(b))
+		req.SetPathValue("id", "s1")
+		w := httptest.NewRecorder()
+		h.server.handleRespond(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("respond: got %d", w.Code)
+		}
+		var result map[string]string
+		json.NewDecoder(w.Body).Decode(&result)
+		return result["status"]
+	}
+
+	if status := respond(map[string]any{"text": "draft", "submit": false}); status != "staged" {
+		t.Errorf("status = %q, want staged", status)
+	}
+	sess, _ := h.store.GetSession("s1")
+	if sess.NotificationType == "" {
+		t.Error("staging text should not clear the notification")
+	}
+
+	if status := respond(map[string]any{"text": "yes"}); status != "sent" {
+		t.Errorf("status = %q, want sent", status)
+	}
+	sess, _ = h.store.GetSession("s1")
+	if sess.NotificationType != "" {
+		t.Errorf("submitting should clear the notification, got %q", sess.NotificationType)
+	}
+
+	want := []bool{false, true}
+	if len(h.mockOps.sentEnter) != 2 || h.mockOps.sentEnter[0] != want[0] || h.mockOps.sentEnter[1] != want[1] {
+		t.Errorf("enter flags = %v, want %v", h.mockOps.sentEnter, want)
+	}
+}
+
+func TestRespondToExtraPane(t *testing.T) {
+	h := newTestHarness(t)
+	body, _ := json.Marshal(map[string]any{
+		"session_id":  "s1",
+		"tmux_pane":   "%5",
+		"cwd":         "/home/user/project",
+		"node_name":   "test-node",
+		"extra_panes": []string{"%7", "bogus", "%5", "%7", "%8"},
+	})
+	w := httptest.NewRecorder()
+	h.server.handleCreateSession(w, httptest.NewRequest("POST", "/api/sessions", bytes.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: got %d", w.Code)
+	}
+	sess, _ := h.store.GetSession("s1")
+	if !slices.Equal(sess.ExtraPanes, []string{"%7", "%8"}) {
+		t.Fatalf("ExtraPanes = %q, want [%%7 %%8]", sess.ExtraPanes)
+	}
+
+	// Hook re-registration doesn't carry extra panes and keeps them.
+	h.createSession(t, "s1", "%5", "/home/user/project")
+	if sess, _ := h.store.GetSession("s1"); len(sess.ExtraPanes) != 2 {
+		t.Fatalf("ExtraPanes = %q after re-registration, want them kept", sess.ExtraPanes)
+	}
+	h.notify(t, "s1", "permission_prompt", "Allow Bash?")
+
+	respond := func(body map[string]any) int {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/api/respond/s1", bytes.NewReader(b))
+		req.SetPathValue("id", "s1")
+		w := httptest.NewRecorder()
+		h.server.handleRespond(w, req)
+		return w.Code
+	}
+
+	if code := respond(map[string]any{"text": "go test ./...", "pane": "%8"}); code != http.StatusOK {
+		t.Fatalf("respond to extra pane: got %d", code)
+	}
+	if sess, _ := h.store.GetSession("s1"); sess.NotificationType == "" {
+		t.Error("text for an extra pane should not clear the agent's notification")
+	}
+	if code := respond(map[string]any{"text": "yes"}); code != http.StatusOK {
+		t.Fatalf("respond to primary pane: got %d", code)
+	}
+	if want := []string{"%8", "%5"}; !slices.Equal(h.mockOps.sentPanes, want) {
+		t.Errorf("sent to panes %q, want %q", h.mockOps.sentPanes, want)
+	}
+
+	if code := respond(map[string]any{"text": "hi", "pane": "%9"}); code != http.StatusBadRequest {
+		t.Errorf("pane outside the session: got %d, want 400", code)
+	}
+	if code := respond(map[string]any{"options": []int{0}, "pane": "%7"}); code != http.StatusBadRequest {
+		t.Errorf("options to an extra pane: got %d, want 400", code)
+	}
+
+	h.mockOps.sendErr = ErrPaneGone
+	if code := respond(map[string]any{"text": "hi", "pane": "%7"}); code != http.StatusGone {
+		t.Errorf("closed extra pane: got %d, want 410", code)
+	}
+	if sess, _ := h.store.GetSession("s1"); !sess.StoppedAt.IsZero() {
+		t.Error("a closed extra pane should not stop the session")
+	}
+}
+
+func TestRespondByOptionIndex(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+
+	respond := func(body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/api/respond/s1", strings.NewReader(body))
+		req.SetPathValue("id", "s1")
+		w := httptest.NewRecorder()
+		h.server.handleRespond(w, req)
+		return w
+	}
+
+	if w := respond(`{"options":[0]}`); w.Code != http.StatusServiceUnavailable || decodeAPIError(t, w).Error.Code != errCodeAgentOffline {
+		t.Fatalf("without a transcript: got %d %s", w.Code, w.Body)
+	}
+
+	h.mockOps.transcripts["s1"] = &transcript.Transcript{Messages: []transcript.Message{{
+		Role:   "assistant",
+		Blocks: []transcript.Block{{Type: "text", Text: "All done."}},
+	}}}
+	if w := respond(`{"options":[0]}`); w.Code != http.StatusConflict || decodeAPIError(t, w).Error.Code != errCodeNoQuestion {
+		t.Fatalf("without a pending question: got %d %s", w.Code, w.Body)
+	}
+
+	h.mockOps.transcripts["s1"] = &transcript.Transcript{Messages: []transcript.Message{{
+		Role: "assistant",
+		Blocks: []transcript.Block{
+			{Type: "text", Text: "A couple of questions."},
+			{Type: "tool_use", Text: "AskUserQuestion", Questions: []transcript.Question{
+				{Question: "Which approach?", Options: []transcript.QuestionOption{{Label: "A"}, {Label: "B"}, {Label: "C"}}},
+				{Question: "Which files?", MultiSelect: true, Options: []transcript.QuestionOption{{Label: "a.go"}, {Label: "b.go"}, {Label: "c.go"}}},
+			}},
+		},
+	}}}
+
+	if w := respond(`{"options":[1]}`); w.Code != http.StatusOK {
+		t.Fatalf("single select: got %d %s", w.Code, w.Body)
+	}
+	if w := respond(`{"question":1,"options":[2,0]}`); w.Code != http.StatusOK {
+		t.Fatalf("multi select: got %d %s", w.Code, w.Body)
+	}
+	if want := []string{"2", "13"}; strings.Join(h.mockOps.sentKeys, ",") != strings.Join(want, ",") {
+		t.Errorf("sent keys = %q, want %q", h.mockOps.sentKeys, want)
+	}
+
+	for _, body := range []string{
+		`{"options":[0,1]}`,            // two picks on a single-select question
+		`{"options":[3]}`,              // out of range
+		`{"options":[]}`,               // nothing picked
+		`{"question":2,"options":[0]}`, // no such question
+		`{"text":"yes","options":[0]}`, // ambiguous
+	} {
+		if w := respond(body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want 400", body, w.Code)
+		}
+	}
+	if len(h.mockOps.sentKeys) != 2 {
+		t.Errorf("rejected selections should send nothing, sent %q", h.mockOps.sentKeys)
+	}
+}
+
+func TestRespondPaneGone(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+	h.mockOps.sendErr = ErrPaneGone
+
+	body, _ := json.Marshal(map[string]string{"text": "yes"})
+	req := httptest.NewRequest("POST", "/api/respond/s1", bytes.NewReader(body))
+	req.SetPathValue("id", "s1")
+	w := httptest.NewRecorder()
+	h.server.handleRespond(w, req)
+
+	if w.Code != http.StatusGone {
+		t.Fatalf("got %d, want 410", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "pane no longer exists") {
+		t.Errorf("body = %q", w.Body.String())
+	}
+	sess, _ := h.store.GetSession("s1")
+	if sess.StoppedAt.IsZero() {
+		t.Error("session should be stopped when its pane is gone")
+	}
+	if sess.StopReason != store.StopReasonPaneGone {
+		t.Errorf("StopReason = %q, want %q", sess.StopReason, store.StopReasonPaneGone)
+	}
+}
+
 func TestTranscriptEndpointReturnsEmptyForNoAgent(t *testing.T) {
 	h := newTestHarness(t)
 	h.createSession(t, "s1", "%5", "/home/user/project")
@@ -429,6 +770,153 @@ func TestTranscriptEndpointWithData(t *testing.T) {
 	}
 }
 
+func TestLastReplyEndpoint(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+	h.createSession(t, "s2", "%6", "/home/user/project")
+	h.mockOps.transcripts["s1"] = &transcript.Transcript{
+		Messages: []transcript.Message{
+			{Role: "assistant", Blocks: []transcript.Block{{Type: "text", Text: "First answer"}}},
+			{Role: "user", Blocks: []transcript.Block{{Type: "text", Text: "And then?"}}},
+			{Role: "assistant", Blocks: []transcript.Block{{Type: "text", Text: "Latest answer"}}},
+		},
+	}
+
+	lastReply := func(id string) (int, string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.server.routes().ServeHTTP(w, httptest.NewRequest("GET", "/api/sessions/"+id+"/last-reply", nil))
+		var result struct {
+			Text *string `json:"text"`
+		}
+		json.NewDecoder(w.Body).Decode(&result)
+		if w.Code == http.StatusOK && result.Text == nil {
+			t.Fatalf("%s: response missing text", id)
+		}
+		if result.Text == nil {
+			return w.Code, ""
+		}
+		return w.Code, *result.Text
+	}
+
+	if code, text := lastReply("s1"); code != http.StatusOK || text != "Latest answer" {
+		t.Errorf("s1: got %d %q, want 200 %q", code, text, "Latest answer")
+	}
+	if code, text := lastReply("s2"); code != http.StatusOK || text != "" {
+		t.Errorf("s2 without a transcript: got %d %q, want 200 with empty text", code, text)
+	}
+
+	// The agent's summary wins over reading the whole transcript.
+	h.mockOps.summaries = map[string]*transcript.SessionSummary{"s1": {LastReply: "Summarized answer"}}
+	if code, text := lastReply("s1"); code != http.StatusOK || text != "Summarized answer" {
+		t.Errorf("s1 with a summary: got %d %q, want 200 %q", code, text, "Summarized answer")
+	}
+
+	// With nothing readable, the stored preview is served.
+	sess, _ := h.store.GetSession("s2")
+	sess.LastReply = "Stored preview"
+	h.store.UpdateSession(sess)
+	if code, text := lastReply("s2"); code != http.StatusOK || text != "Stored preview" {
+		t.Errorf("s2 with a stored preview: got %d %q, want 200 %q", code, text, "Stored preview")
+	}
+	if code, _ := lastReply("nope"); code != http.StatusNotFound {
+		t.Errorf("unknown session: got %d, want 404", code)
+	}
+}
+
+func TestRawTranscriptProxiesAgent(t *testing.T) {
+	raw := `{"type":"user","message":{"role":"user","content":"Hello"}}` + "\n"
+	var gotPath string
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		if !strings.HasSuffix(r.URL.Path, "/s1") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		io.WriteString(w, raw)
+	}))
+	defer agent.Close()
+
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+	h.createSession(t, "s2", "%6", "/home/user/project")
+	h.server.bg.Wait()
+	h.server.agents.Register("test-node", agent.URL, "")
+	h.server.nodeOps = &agentProxyOps{
+		agents: h.server.agents,
+		client: newAgentClient(0, 0),
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		sends:  newSendKeysCounters(),
+	}
+
+	w := httptest.NewRecorder()
+	h.server.routes().ServeHTTP(w, httptest.NewRequest("GET", "/api/sessions/s1/raw", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d, want 200", w.Code)
+	}
+	if gotPath != "/api/transcript-raw/s1" {
+		t.Errorf("agent path = %q, want /api/transcript-raw/s1", gotPath)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q, want application/x-ndjson", ct)
+	}
+	if w.Body.String() != raw {
+		t.Errorf("body = %q, want the agent's bytes unchanged", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.server.routes().ServeHTTP(w, httptest.NewRequest("GET", "/api/sessions/s2/raw", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing transcript: got %d, want 404", w.Code)
+	}
+}
+
+func TestNotifyFollowsCwdChange(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+	h.createSession(t, "s2", "%6", "/home/user/project")
+
+	post := func(path string, body map[string]string) {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		h.server.routes().ServeHTTP(w, httptest.NewRequest("POST", path, bytes.NewReader(b)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("POST %s: got %d", path, w.Code)
+		}
+	}
+
+	// With the transcript path reported, cwd can move freely.
+	post("/api/sessions/s1/notify", map[string]string{
+		"notification_type": "permission_prompt",
+		"cwd":               "/home/user/other",
+		"transcript_path":   "/home/user/.claude/projects/-home-user-project/s1.jsonl",
+		"node_name":         "test-node",
+	})
+	sess, _ := h.store.GetSession("s1")
+	if sess.Project != "user/other" || sess.Cwd != "/home/user/other" {
+		t.Errorf("project/cwd = %q/%q, want user/other and the new cwd", sess.Project, sess.Cwd)
+	}
+	if sess.TranscriptPath != "/home/user/.claude/projects/-home-user-project/s1.jsonl" {
+		t.Errorf("TranscriptPath = %q, want the reported path", sess.TranscriptPath)
+	}
+
+	// Without one, the original cwd still locates the transcript.
+	post("/api/sessions/s2/activity", map[string]string{
+		"cwd":       "/home/user/other",
+		"node_name": "test-node",
+	})
+	h.server.bg.Wait()
+	sess, _ = h.store.GetSession("s2")
+	if sess.Project != "user/other" {
+		t.Errorf("project = %q, want user/other", sess.Project)
+	}
+	if sess.Cwd != "/home/user/project" {
+		t.Errorf("cwd = %q, want the original kept for transcript lookup", sess.Cwd)
+	}
+}
+
 func TestNodeNameStoredOnCreate(t *testing.T) {
 	h := newTestHarness(t)
 	h.createSession(t, "s1", "%5", "/home/user/project")
@@ -476,6 +964,27 @@ func TestGetSession(t *testing.T) {
 	}
 }
 
+func TestMalformedPaneIsDropped(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "5", "/home/user/project")
+
+	sess, _ := h.store.GetSession("s1")
+	if sess.TmuxPane != "" {
+		t.Errorf("TmuxPane = %q, want a malformed id dropped", sess.TmuxPane)
+	}
+
+	req := httptest.NewRequest("POST", "/api/respond/s1", strings.NewReader(`{"text":"yes"}`))
+	req.SetPathValue("id", "s1")
+	w := httptest.NewRecorder()
+	h.server.handleRespond(w, req)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), errCodeNoPane) {
+		t.Errorf("respond: got %d %s, want 409 no_pane", w.Code, w.Body)
+	}
+	if sess, _ := h.store.GetSession("s1"); !sess.StoppedAt.IsZero() {
+		t.Error("a session without a pane should not be stopped by a response")
+	}
+}
+
 func TestSamePaneDedup(t *testing.T) {
 	h := newTestHarness(t)
 
@@ -488,6 +997,9 @@ func TestSamePaneDedup(t *testing.T) {
 	if s1.StoppedAt.IsZero() {
 		t.Error("s1 should be auto-stopped when s2 starts on same pane")
 	}
+	if s1.StopReason != store.StopReasonSuperseded {
+		t.Errorf("s1 StopReason = %q, want %q", s1.StopReason, store.StopReasonSuperseded)
+	}
 
 	// s2 should still be active
 	s2, _ := h.store.GetSession("s2")
@@ -523,6 +1035,9 @@ func TestReconcileSessionsStopsDeadPanes(t *testing.T) {
 	if dead1.StoppedAt.IsZero() {
 		t.Error("dead1 should be stopped")
 	}
+	if dead1.StopReason != store.StopReasonReconciled {
+		t.Errorf("dead1 StopReason = %q, want %q", dead1.StopReason, store.StopReasonReconciled)
+	}
 
 	dead2, _ := h.store.GetSession("dead2")
 	if dead2.StoppedAt.IsZero() {
@@ -550,6 +1065,23 @@ func TestReconcileSessionsEmptyAlivePanesStopsAll(t *testing.T) {
 	}
 }
 
+func TestReconcileClearsNotification(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%0", "/home/user/proj")
+	h.notify(t, "s1", "permission_prompt", "Allow Bash?")
+
+	h.server.reconcileSessions("test-node", []string{})
+
+	sess, _ := h.store.GetSession("s1")
+	if sess.StoppedAt.IsZero() {
+		t.Error("session should be stopped")
+	}
+	if sess.NotificationType != "" || sess.NotifyMessage != "" || sess.NotifyTitle != "" || !sess.NotifiedAt.IsZero() {
+		t.Errorf("notification not cleared: type=%q title=%q message=%q at=%v",
+			sess.NotificationType, sess.NotifyTitle, sess.NotifyMessage, sess.NotifiedAt)
+	}
+}
+
 func TestReconcileOnlyAffectsTargetNode(t *testing.T) {
 	h := newTestHarness(t)
 
@@ -578,6 +1110,46 @@ func TestReconcileOnlyAffectsTargetNode(t *testing.T) {
 	}
 }
 
+func TestReconcileGracePeriod(t *testing.T) {
+	h := newTestHarness(t)
+	h.server.tun.ReconcileGrace = 10 * time.Second
+	h.createSession(t, "flaky", "%0", "/home/user/proj")
+	h.createSession(t, "gone", "%1", "/home/user/proj")
+
+	stopped := func(id string) bool {
+		sess, _ := h.store.GetSession(id)
+		return !sess.StoppedAt.IsZero()
+	}
+
+	// Both panes go missing; neither session is stopped on the first miss.
+	h.server.reconcileSessions("test-node", []string{})
+	if stopped("flaky") || stopped("gone") {
+		t.Fatal("sessions should survive a reconcile within the grace window")
+	}
+
+	// flaky's pane comes back, which resets its grace window.
+	h.clock.Advance(5 * time.Second)
+	h.server.reconcileSessions("test-node", []string{"%0"})
+	if stopped("gone") {
+		t.Fatal("gone should still be within its grace window")
+	}
+
+	h.clock.Advance(5 * time.Second)
+	h.server.reconcileSessions("test-node", []string{})
+	if !stopped("gone") {
+		t.Error("gone should be stopped once the grace window elapses")
+	}
+	if stopped("flaky") {
+		t.Error("flaky went missing again only just now and should survive")
+	}
+
+	h.clock.Advance(10 * time.Second)
+	h.server.reconcileSessions("test-node", []string{})
+	if !stopped("flaky") {
+		t.Error("flaky should be stopped after missing for the full grace window")
+	}
+}
+
 func TestAgentRegisterWithAlivePanes(t *testing.T) {
 	h := newTestHarness(t)
 
@@ -687,15 +1259,282 @@ func TestAgentRegister(t *testing.T) {
 	}
 }
 
-func TestToolActivityUnknownSessionReturns200(t *testing.T) {
+func TestListAgentsReportsVersion(t *testing.T) {
 	h := newTestHarness(t)
-	code := h.toolActivity(t, "nonexistent", "PreToolUse", "Bash")
-	if code != http.StatusOK {
-		t.Fatalf("got %d, want 200", code)
+	h.server.cfg.Version = "0.2.0"
+
+	body, _ := json.Marshal(map[string]string{
+		"node_name": "foxtrotbase",
+		"url":       "http://127.0.0.1:2588",
+		"version":   "0.1.0",
+	})
+	req := httptest.NewRequest("POST", "/api/agents/register", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	h.server.handleAgentRegister(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("register: got %d, want 200", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.server.handleListAgents(w, httptest.NewRequest("GET", "/api/agents", nil))
+	var resp struct {
+		Version string      `json:"version"`
+		Agents  []agentJSON `json:"agents"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Version != "0.2.0" {
+		t.Errorf("daemon version = %q, want 0.2.0", resp.Version)
+	}
+	if len(resp.Agents) != 1 {
+		t.Fatalf("agents = %+v, want one", resp.Agents)
+	}
+	if a := resp.Agents[0]; a.NodeName != "foxtrotbase" || a.Version != "0.1.0" || !a.Online {
+		t.Errorf("agent = %+v, want foxtrotbase online at 0.1.0", a)
 	}
 }
 
-func TestToolActivityPublishesSSE(t *testing.T) {
+func TestAgentRegisterValidatesURL(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want int
+	}{
+		{"valid", "http://100.64.0.1:2588", http.StatusOK},
+		{"https", "https://agent.example.com", http.StatusOK},
+		{"malformed", "http://[::1", http.StatusBadRequest},
+		{"no scheme", "127.0.0.1:2588", http.StatusBadRequest},
+		{"no host", "http://", http.StatusBadRequest},
+		{"empty", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHarness(t)
+			body, _ := json.Marshal(map[string]string{"node_name": "foxtrotbase", "url": tt.url})
+			req := httptest.NewRequest("POST", "/api/agents/register", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+			h.server.handleAgentRegister(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("got %d, want %d", w.Code, tt.want)
+			}
+			_, registered := h.server.agents.Get("foxtrotbase")
+			if registered != (tt.want == http.StatusOK) {
+				t.Errorf("registered = %v, want %v", registered, tt.want == http.StatusOK)
+			}
+			if tt.want == http.StatusBadRequest {
+				if body := decodeAPIError(t, w); body.Error.Code != errCodeBadRequest {
+					t.Errorf("code = %q, want %q", body.Error.Code, errCodeBadRequest)
+				}
+			}
+		})
+	}
+}
+
+func TestSessionsAPIReportsFocus(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+
+	focusedInAPI := func() any {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.server.handleSessionsAPI(w, httptest.NewRequest("GET", "/api/sessions", nil))
+		var resp struct {
+			Active []map[string]any `json:"active"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || len(resp.Active) != 1 {
+			t.Fatalf("decoding sessions: %v (%d active)", err, len(resp.Active))
+		}
+		return resp.Active[0]["focused"]
+	}
+
+	h.mockOps.focused = true
+	if got := focusedInAPI(); got != nil {
+		t.Errorf("focused = %v with the agent offline, want it omitted", got)
+	}
+
+	h.server.agents.Register("test-node", "http://test-node:2588", "")
+	if got := focusedInAPI(); got != true {
+		t.Errorf("focused = %v, want true", got)
+	}
+
+	h.mockOps.focused = false
+	if got := focusedInAPI(); got != false {
+		t.Errorf("focused = %v, want false", got)
+	}
+}
+
+func TestSessionsAPIOmitsSlowFocusChecks(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "fast", "%5", "/home/user/project")
+	h.createSession(t, "slow", "%6", "/home/user/project")
+	h.server.agents.Register("test-node", "http://test-node:2588", "")
+	h.server.bg.Wait()
+	h.mockOps.focused = true
+	gate := make(chan struct{})
+	h.mockOps.focusGates = map[string]chan struct{}{"%6": gate}
+	defer h.server.bg.Wait()
+	defer close(gate)
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	h.server.handleSessionsAPI(w, httptest.NewRequest("GET", "/api/sessions", nil))
+	if elapsed := time.Since(start); elapsed > 2*focusCheckTimeout {
+		t.Errorf("listing took %s; a stuck focus check should be cut off", elapsed)
+	}
+
+	var resp struct {
+		Active []map[string]any `json:"active"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	focused := map[string]any{}
+	for _, sess := range resp.Active {
+		focused[sess["session_id"].(string)] = sess["focused"]
+	}
+	if focused["fast"] != true {
+		t.Errorf("fast session focused = %v, want true", focused["fast"])
+	}
+	if got, ok := focused["slow"]; !ok || got != nil {
+		t.Errorf("slow session focused = %v, want it omitted", got)
+	}
+}
+
+func TestSlowToolNotification(t *testing.T) {
+	h := newTestHarness(t)
+	h.server.cfg.SlowToolAfter = 30 * time.Millisecond
+	h.createSession(t, "fast", "%5", "/home/user/project")
+	h.createSession(t, "slow", "%6", "/home/user/project")
+
+	toolRunning := func(id string) bool {
+		for _, ev := range h.server.events.History(id, 0) {
+			if ev.Type == EventNotification && strings.Contains(string(ev.Data), `"type":"tool_running"`) {
+				return true
+			}
+		}
+		return false
+	}
+
+	h.toolActivity(t, "fast", "PreToolUse", "Bash")
+	h.toolActivity(t, "fast", "PostToolUse", "Bash")
+	h.toolActivity(t, "slow", "PreToolUse", "Bash")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !toolRunning("slow") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !toolRunning("slow") {
+		t.Fatal("slow tool call did not trigger a tool_running notification")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if toolRunning("fast") {
+		t.Error("a tool call that finished in time should not notify")
+	}
+	if sess, _ := h.store.GetSession("slow"); sess.NotificationType != "" {
+		t.Errorf("NotificationType = %q, want the heads-up left out of session state", sess.NotificationType)
+	}
+}
+
+func TestSlowToolNotificationWithParallelCalls(t *testing.T) {
+	h := newTestHarness(t)
+	h.server.cfg.SlowToolAfter = 30 * time.Millisecond
+	h.createSession(t, "s1", "%5", "/home/user/project")
+
+	slowTools := func() []string {
+		var tools []string
+		for _, ev := range h.server.events.History("s1", 0) {
+			var data map[string]string
+			json.Unmarshal(ev.Data, &data)
+			if ev.Type == EventNotification && data["type"] == "tool_running" {
+				tools = append(tools, strings.Fields(data["message"])[0])
+			}
+		}
+		return tools
+	}
+
+	// The agent runs a long Bash and a quick Read side by side; the Read
+	// finishing must not cancel the Bash's timer.
+	h.toolCall(t, "s1", "PreToolUse", "Bash", "toolu_bash")
+	h.toolCall(t, "s1", "PreToolUse", "Read", "toolu_read")
+	h.toolCall(t, "s1", "PostToolUse", "Read", "toolu_read")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(slowTools()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if got := slowTools(); !slices.Equal(got, []string{"Bash"}) {
+		t.Errorf("slow tool notifications for %q, want just the Bash call", got)
+	}
+}
+
+func TestToolActivityTogglesBusy(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+
+	busyInAPI := func() bool {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.server.handleSessionsAPI(w, httptest.NewRequest("GET", "/api/sessions", nil))
+		var resp struct {
+			Active []map[string]any `json:"active"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || len(resp.Active) != 1 {
+			t.Fatalf("decoding sessions: %v (%d active)", err, len(resp.Active))
+		}
+		return resp.Active[0]["busy"] == true
+	}
+
+	if busyInAPI() {
+		t.Fatal("new session should not be busy")
+	}
+
+	h.toolActivity(t, "s1", "PreToolUse", "Bash")
+	if !busyInAPI() {
+		t.Error("PreToolUse should mark the session busy")
+	}
+	h.toolActivity(t, "s1", "PreToolUse", "Grep")
+	h.toolActivity(t, "s1", "PostToolUse", "Bash")
+	if !busyInAPI() {
+		t.Error("PostToolUse should leave the session busy until the turn ends")
+	}
+
+	h.turnEnd(t, "s1")
+	h.server.bg.Wait()
+	if busyInAPI() {
+		t.Error("turn end should clear busy")
+	}
+
+	// Each transition is published once: the second PreToolUse of the turn
+	// and the PostToolUse change nothing.
+	var transitions []bool
+	for _, ev := range h.server.events.History("s1", 0) {
+		if ev.Type == EventBusy {
+			var b Busy
+			json.Unmarshal(ev.Data, &b)
+			transitions = append(transitions, b.Busy)
+		}
+	}
+	if want := []bool{true, false}; !slices.Equal(transitions, want) {
+		t.Errorf("busy events = %v, want %v", transitions, want)
+	}
+
+	h.toolActivity(t, "s1", "PreToolUse", "Read")
+	h.endSession(t, "s1")
+	if sess, _ := h.store.GetSession("s1"); sess.Busy {
+		t.Error("session end should clear busy")
+	}
+}
+
+func TestToolActivityUnknownSessionReturns200(t *testing.T) {
+	h := newTestHarness(t)
+	code := h.toolActivity(t, "nonexistent", "PreToolUse", "Bash")
+	if code != http.StatusOK {
+		t.Fatalf("got %d, want 200", code)
+	}
+}
+
+func TestToolActivityPublishesSSE(t *testing.T) {
 	h := newTestHarness(t)
 	h.createSession(t, "s1", "%5", "/home/user/project")
 
@@ -726,6 +1565,43 @@ func TestToolActivityPublishesSSE(t *testing.T) {
 	}
 }
 
+func TestGetToolCall(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+	h.mockOps.toolCalls = map[string]*transcript.ToolCall{
+		"toolu_01": {ID: "toolu_01", Name: "Bash", Input: json.RawMessage(`{"command":"go test ./..."}`), Result: "ok"},
+	}
+
+	get := func(session, toolUseID string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/sessions/"+session+"/tools/"+toolUseID, nil)
+		req.SetPathValue("id", session)
+		req.SetPathValue("toolUseId", toolUseID)
+		w := httptest.NewRecorder()
+		h.server.handleToolCall(w, req)
+		return w
+	}
+
+	w := get("s1", "toolu_01")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s, want 200", w.Code, w.Body)
+	}
+	var call transcript.ToolCall
+	if err := json.NewDecoder(w.Body).Decode(&call); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if call.Name != "Bash" || string(call.Input) != `{"command":"go test ./..."}` || call.Result != "ok" {
+		t.Errorf("call = %+v", call)
+	}
+
+	if w := get("s1", "toolu_missing"); w.Code != http.StatusNotFound || decodeAPIError(t, w).Error.Message != "tool call not found" {
+		t.Errorf("unknown tool call: got %d %s, want 404", w.Code, w.Body)
+	}
+	if w := get("nope", "toolu_01"); w.Code != http.StatusNotFound || decodeAPIError(t, w).Error.Message != "session not found" {
+		t.Errorf("unknown session: got %d %s, want 404", w.Code, w.Body)
+	}
+}
+
 func TestActivityUpdatesSummary(t *testing.T) {
 	h := newTestHarness(t)
 	h.createSession(t, "s1", "%5", "/home/user/project")
@@ -734,12 +1610,13 @@ func TestActivityUpdatesSummary(t *testing.T) {
 	h.mockOps.summaries["s1"] = &transcript.SessionSummary{
 		Topic:       "Fix the bug",
 		PlanSummary: "Refactor error handling",
+		ErrorCount:  3,
 	}
 
 	h.turnEnd(t, "s1")
 
-	// The summary is fetched asynchronously — give the goroutine time to complete
-	time.Sleep(50 * time.Millisecond)
+	// The summary is fetched asynchronously
+	h.server.bg.Wait()
 
 	sess, err := h.store.GetSession("s1")
 	if err != nil {
@@ -751,6 +1628,90 @@ func TestActivityUpdatesSummary(t *testing.T) {
 	if sess.PlanSummary != "Refactor error handling" {
 		t.Errorf("PlanSummary = %q, want %q", sess.PlanSummary, "Refactor error handling")
 	}
+	if sess.ErrorCount != 3 {
+		t.Errorf("ErrorCount = %d, want 3", sess.ErrorCount)
+	}
+}
+
+func TestRefreshSessionUpdatesSummary(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+	h.mockOps.summaries["s1"] = &transcript.SessionSummary{
+		Topic:       "Fix the bug",
+		PlanSummary: "Refactor error handling",
+		LastReply:   "Done.",
+	}
+
+	req := httptest.NewRequest("POST", "/api/sessions/s1/refresh", nil)
+	req.SetPathValue("id", "s1")
+	w := httptest.NewRecorder()
+	h.server.handleRefreshSession(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s, want 200", w.Code, w.Body)
+	}
+
+	var got store.Session
+	json.NewDecoder(w.Body).Decode(&got)
+	if got.Topic != "Fix the bug" || got.PlanSummary != "Refactor error handling" || got.LastReply != "Done." {
+		t.Errorf("response = %+v, want the refreshed summary", got)
+	}
+	sess, _ := h.store.GetSession("s1")
+	if sess.Topic != "Fix the bug" || sess.PlanSummary != "Refactor error handling" {
+		t.Errorf("stored session = %+v, want the refreshed summary persisted", sess)
+	}
+
+	req = httptest.NewRequest("POST", "/api/sessions/nope/refresh", nil)
+	req.SetPathValue("id", "nope")
+	w = httptest.NewRecorder()
+	h.server.handleRefreshSession(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown session: got %d, want 404", w.Code)
+	}
+}
+
+func TestPendingQuestionFromSummary(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+
+	h.mockOps.summaries["s1"] = &transcript.SessionSummary{
+		PendingQuestions: []transcript.Question{
+			{Question: "Which approach?", Options: []transcript.QuestionOption{{Label: "A"}, {Label: "B"}}},
+		},
+	}
+
+	// AskUserQuestion blocks mid-turn, so the tool hook fetches it.
+	h.toolActivity(t, "s1", "PreToolUse", "AskUserQuestion")
+	h.server.bg.Wait()
+
+	sess, _ := h.store.GetSession("s1")
+	var questions []transcript.Question
+	if err := json.Unmarshal(sess.PendingQuestion, &questions); err != nil {
+		t.Fatalf("PendingQuestion = %s: %v", sess.PendingQuestion, err)
+	}
+	if len(questions) != 1 || questions[0].Question != "Which approach?" || len(questions[0].Options) != 2 {
+		t.Errorf("PendingQuestion = %s, want the summary's question", sess.PendingQuestion)
+	}
+
+	// Answering clears it.
+	req := httptest.NewRequest("POST", "/api/respond/s1", strings.NewReader(`{"text":"1"}`))
+	req.SetPathValue("id", "s1")
+	w := httptest.NewRecorder()
+	h.server.handleRespond(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("respond: got %d %s", w.Code, w.Body)
+	}
+	if sess, _ := h.store.GetSession("s1"); sess.PendingQuestion != nil {
+		t.Errorf("PendingQuestion = %s, want cleared after a response", sess.PendingQuestion)
+	}
+
+	// A turn that ends without a question clears a stale one.
+	h.store.UpdateSession(sess) // sess still holds the question
+	h.mockOps.summaries["s1"] = &transcript.SessionSummary{Topic: "Done"}
+	h.turnEnd(t, "s1")
+	h.server.bg.Wait()
+	if sess, _ := h.store.GetSession("s1"); sess.PendingQuestion != nil {
+		t.Errorf("PendingQuestion = %s, want nil once the turn ends without a question", sess.PendingQuestion)
+	}
 }
 
 func TestActivityNoSummaryDoesNotOverwrite(t *testing.T) {
@@ -765,7 +1726,7 @@ func TestActivityNoSummaryDoesNotOverwrite(t *testing.T) {
 	// No summary configured in mock — ReadSummary returns nil
 
 	h.turnEnd(t, "s1")
-	time.Sleep(50 * time.Millisecond)
+	h.server.bg.Wait()
 
 	sess, _ = h.store.GetSession("s1")
 	if sess.Topic != "Existing topic" {
@@ -860,3 +1821,785 @@ func TestCreateSessionStoresTranscriptPath(t *testing.T) {
 		t.Errorf("TranscriptPath = %q, want %q", sess.TranscriptPath, want)
 	}
 }
+
+func (h *testHarness) patchSession(t *testing.T, id string, fields map[string]any) *httptest.ResponseRecorder {
+	t.Helper()
+	body, _ := json.Marshal(fields)
+	req := httptest.NewRequest("PATCH", "/api/sessions/"+id, bytes.NewReader(body))
+	req.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	h.server.handlePatchSession(w, req)
+	return w
+}
+
+func TestPatchSessionTogglesPinned(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%1", "/home/user/project")
+	h.createSession(t, "s2", "%2", "/home/user/project")
+
+	w := h.patchSession(t, "s1", map[string]any{"pinned": true})
+	if w.Code != http.StatusOK {
+		t.Fatalf("patch: got %d, want 200", w.Code)
+	}
+	var got store.Session
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !got.Pinned {
+		t.Error("response should report pinned=true")
+	}
+
+	active, _ := h.store.ListActiveSessions()
+	if len(active) != 2 || active[0].ID != "s1" {
+		t.Fatalf("pinned session should sort first, got %v", active)
+	}
+
+	// A patch without the field leaves it untouched.
+	h.patchSession(t, "s1", map[string]any{})
+	sess, _ := h.store.GetSession("s1")
+	if !sess.Pinned {
+		t.Error("empty patch should not clear pinned")
+	}
+
+	h.patchSession(t, "s1", map[string]any{"pinned": false})
+	sess, _ = h.store.GetSession("s1")
+	if sess.Pinned {
+		t.Error("pinned should be cleared")
+	}
+}
+
+func TestPatchSessionNotFound(t *testing.T) {
+	h := newTestHarness(t)
+	w := h.patchSession(t, "missing", map[string]any{"pinned": true})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("got %d, want 404", w.Code)
+	}
+}
+
+func TestPatchSessionTopicSurvivesSummary(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%1", "/home/user/project")
+	h.mockOps.summaries["s1"] = &transcript.SessionSummary{
+		Topic:       "auto-derived topic",
+		PlanSummary: "Refactor error handling",
+	}
+
+	w := h.patchSession(t, "s1", map[string]any{"topic": "My custom topic"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("patch: got %d, want 200", w.Code)
+	}
+
+	h.turnEnd(t, "s1")
+	h.server.bg.Wait()
+
+	sess, _ := h.store.GetSession("s1")
+	if sess.Topic != "My custom topic" {
+		t.Errorf("Topic = %q, want custom topic preserved", sess.Topic)
+	}
+	if !sess.TopicLocked {
+		t.Error("TopicLocked should be set")
+	}
+	if sess.PlanSummary != "Refactor error handling" {
+		t.Errorf("PlanSummary = %q, summary should still apply to other fields", sess.PlanSummary)
+	}
+
+	// Clearing the topic unlocks it so the next summary wins again.
+	h.patchSession(t, "s1", map[string]any{"topic": ""})
+	h.turnEnd(t, "s1")
+	h.server.bg.Wait()
+
+	sess, _ = h.store.GetSession("s1")
+	if sess.TopicLocked {
+		t.Error("TopicLocked should be cleared")
+	}
+	if sess.Topic != "auto-derived topic" {
+		t.Errorf("Topic = %q, want auto-derived topic after unlock", sess.Topic)
+	}
+}
+
+func TestOversizedBodyRejected(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%1", "/home/user/project")
+	h.server.cfg.MaxBodyBytes = 64
+
+	body, _ := json.Marshal(map[string]string{"text": strings.Repeat("x", 128)})
+	req := httptest.NewRequest("POST", "/api/respond/s1", bytes.NewReader(body))
+	req.SetPathValue("id", "s1")
+	w := httptest.NewRecorder()
+	h.server.handleRespond(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("got %d, want 413", w.Code)
+	}
+	if len(h.mockOps.sentKeys) != 0 {
+		t.Errorf("oversized response should not be sent, got %v", h.mockOps.sentKeys)
+	}
+
+	// Malformed bodies under the cap are still a plain 400.
+	req = httptest.NewRequest("POST", "/api/respond/s1", strings.NewReader("{"))
+	req.SetPathValue("id", "s1")
+	w = httptest.NewRecorder()
+	h.server.handleRespond(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("malformed body: got %d, want 400", w.Code)
+	}
+}
+
+func TestNotifyNormalizesNotificationType(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"permission_prompt", "permission_prompt"},
+		{" Permission_Prompt ", "permission_prompt"},
+		{"permision_prompt", defaultNotificationType},
+		{"", defaultNotificationType},
+	}
+	for _, tc := range tests {
+		h := newTestHarness(t)
+		h.createSession(t, "s1", "%5", "/home/user/project")
+		if code := h.notify(t, "s1", tc.in, "msg"); code != http.StatusOK {
+			t.Fatalf("notify(%q): got %d", tc.in, code)
+		}
+		sess, _ := h.store.GetSession("s1")
+		if sess.NotificationType != tc.want {
+			t.Errorf("notify(%q): NotificationType = %q, want %q", tc.in, sess.NotificationType, tc.want)
+		}
+	}
+}
+
+func TestResponseTemplatesEndpoint(t *testing.T) {
+	h := newTestHarness(t)
+
+	get := func() []string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.server.routes().ServeHTTP(w, httptest.NewRequest("GET", "/api/responses/templates", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("got %d, want 200", w.Code)
+		}
+		var resp struct {
+			Templates []string `json:"templates"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Templates == nil {
+			t.Fatal("templates should be a list, not null")
+		}
+		return resp.Templates
+	}
+
+	if got := get(); len(got) != 0 {
+		t.Errorf("unconfigured templates = %q, want empty", got)
+	}
+
+	h.server.cfg.ResponseTemplates = []string{"yes", "approve", "use option 2"}
+	if got := get(); strings.Join(got, "|") != "yes|approve|use option 2" {
+		t.Errorf("templates = %q, want configured list in order", got)
+	}
+}
+
+func TestStatsEndpoint(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%1", "/home/user/project")
+	h.createSession(t, "s2", "%2", "/home/user/project")
+	h.endSession(t, "s2")
+
+	// Overflow one subscriber buffer to register a drop.
+	_, unsub := h.server.events.Subscribe("s1")
+	defer unsub()
+	for i := 0; i < 17; i++ {
+		h.server.events.Publish("s1", Event{Type: EventActivity, Session: "s1"})
+	}
+
+	req := httptest.NewRequest("GET", "/api/stats", nil)
+	w := httptest.NewRecorder()
+	h.server.handleStats(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d, want 200", w.Code)
+	}
+
+	var got map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got["send_keys"].(map[string]any); !ok {
+		t.Errorf("send_keys = %v, want an object", got["send_keys"])
+	}
+	for key, want := range map[string]float64{"active": 1, "stopped": 1, "total": 2, "events_dropped": 1} {
+		if got[key] != want {
+			t.Errorf("%s = %v, want %v", key, got[key], want)
+		}
+	}
+	byNode, ok := got["by_node"].(map[string]any)
+	if !ok || byNode["test-node"] != float64(1) {
+		t.Errorf("by_node = %v, want {test-node: 1}", got["by_node"])
+	}
+}
+
+func TestSessionsAPISort(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "older", "%1", "/home/user/project")
+	h.createSession(t, "newer", "%2", "/home/user/project")
+
+	// Backdate starts so "older" started first, then give it the latest activity.
+	now := time.Now()
+	older, _ := h.store.GetSession("older")
+	older.StartedAt = now.Add(-2 * time.Hour)
+	older.LastActivityAt = now
+	h.store.UpdateSession(older)
+	newer, _ := h.store.GetSession("newer")
+	newer.StartedAt = now.Add(-1 * time.Hour)
+	newer.LastActivityAt = now.Add(-30 * time.Minute)
+	h.store.UpdateSession(newer)
+
+	firstActive := func(query string) string {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/sessions"+query, nil)
+		w := httptest.NewRecorder()
+		h.server.handleSessionsAPI(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: got %d", query, w.Code)
+		}
+		var resp struct {
+			Active []store.Session `json:"active"`
+		}
+		json.NewDecoder(w.Body).Decode(&resp)
+		if len(resp.Active) == 0 {
+			t.Fatalf("%s: no active sessions", query)
+		}
+		return resp.Active[0].ID
+	}
+
+	if got := firstActive(""); got != "newer" {
+		t.Errorf("default sort: first = %q, want newer", got)
+	}
+	if got := firstActive("?sort=started"); got != "newer" {
+		t.Errorf("sort=started: first = %q, want newer", got)
+	}
+	if got := firstActive("?sort=activity"); got != "older" {
+		t.Errorf("sort=activity: first = %q, want older", got)
+	}
+
+	req := httptest.NewRequest("GET", "/api/sessions?sort=bogus", nil)
+	w := httptest.NewRecorder()
+	h.server.handleSessionsAPI(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("sort=bogus: got %d, want 400", w.Code)
+	}
+}
+
+func TestActivityStoresLastReplyPreview(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+
+	h.mockOps.summaries["s1"] = &transcript.SessionSummary{
+		LastReply: "All done.\n\nThe tests   pass now.",
+	}
+	h.turnEnd(t, "s1")
+	h.server.bg.Wait()
+
+	sess, _ := h.store.GetSession("s1")
+	if sess.LastReply != "All done. The tests pass now." {
+		t.Errorf("LastReply = %q", sess.LastReply)
+	}
+}
+
+func TestReplyPreviewTruncates(t *testing.T) {
+	long := strings.Repeat("é", maxLastReplyLen+50)
+	got := replyPreview(long)
+	if n := len([]rune(got)); n != maxLastReplyLen+1 {
+		t.Errorf("preview length = %d runes, want %d", n, maxLastReplyLen+1)
+	}
+	if !strings.HasSuffix(got, "…") {
+		t.Errorf("truncated preview should end with an ellipsis: %q", got[len(got)-10:])
+	}
+	if got := replyPreview("short"); got != "short" {
+		t.Errorf("short preview = %q", got)
+	}
+}
+
+func TestExportEndpoint(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+	h.mockOps.transcripts["s1"] = &transcript.Transcript{Messages: []transcript.Message{
+		{Role: "user", Blocks: []transcript.Block{{Type: "text", Text: "Fix the bug"}}},
+		{Role: "assistant", Blocks: []transcript.Block{{Type: "tool_use", Text: "Read", Summary: "Read main.go"}}},
+	}}
+
+	export := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/sessions/s1/export"+query, nil)
+		req.SetPathValue("id", "s1")
+		w := httptest.NewRecorder()
+		h.server.handleExport(w, req)
+		return w
+	}
+
+	w := export("?format=md")
+	if w.Code != http.StatusOK {
+		t.Fatalf("md: got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/markdown") {
+		t.Errorf("md Content-Type = %q", ct)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "## User") || !strings.Contains(body, "- `Read main.go`") {
+		t.Errorf("md body = %q", body)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, "sophon-s1.md") {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+
+	w = export("?format=json")
+	var tr transcript.Transcript
+	if err := json.NewDecoder(w.Body).Decode(&tr); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if len(tr.Messages) != 2 {
+		t.Errorf("json messages = %d, want 2", len(tr.Messages))
+	}
+
+	if w := export("?format=pdf"); w.Code != http.StatusBadRequest {
+		t.Errorf("pdf: got %d, want 400", w.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/api/sessions/missing/export", nil)
+	req.SetPathValue("id", "missing")
+	w = httptest.NewRecorder()
+	h.server.handleExport(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing: got %d, want 404", w.Code)
+	}
+}
+
+func TestStopIdleSessions(t *testing.T) {
+	h := newTestHarness(t)
+	h.server.tun.IdleTimeout = time.Hour
+
+	h.createSession(t, "orphan", "%1", "/home/user/a")
+	h.createSession(t, "covered", "%2", "/home/user/b")
+	h.createSession(t, "recent", "%3", "/home/user/c")
+
+	old := time.Now().Add(-2 * time.Hour)
+	for _, id := range []string{"orphan", "covered"} {
+		sess, _ := h.store.GetSession(id)
+		sess.LastActivityAt = old
+		if id == "covered" {
+			sess.NodeName = "agent-node"
+		}
+		h.store.UpdateSession(sess)
+	}
+	h.server.agents.Register("agent-node", "http://agent-node:2588", "")
+
+	h.server.stopIdleSessions()
+
+	for id, wantStopped := range map[string]bool{"orphan": true, "covered": false, "recent": false} {
+		sess, _ := h.store.GetSession(id)
+		if stopped := !sess.StoppedAt.IsZero(); stopped != wantStopped {
+			t.Errorf("%s: stopped = %v, want %v", id, stopped, wantStopped)
+		}
+	}
+	if sess, _ := h.store.GetSession("orphan"); sess.StopReason != store.StopReasonIdleTimeout {
+		t.Errorf("orphan StopReason = %q, want %q", sess.StopReason, store.StopReasonIdleTimeout)
+	}
+}
+
+func TestNotifyBackfillsPane(t *testing.T) {
+	h := newTestHarness(t)
+
+	// First seen via a notification that carried no pane
+	h.notify(t, "s1", "idle_prompt", "")
+	sess, _ := h.store.GetSession("s1")
+	if sess.TmuxPane != "" {
+		t.Fatalf("TmuxPane = %q, want empty", sess.TmuxPane)
+	}
+
+	body, _ := json.Marshal(map[string]string{
+		"notification_type": "permission_prompt",
+		"message":           "Allow Bash?",
+		"node_name":         "test-node",
+		"tmux_pane":         "%9",
+	})
+	req := httptest.NewRequest("POST", "/api/sessions/s1/notify", bytes.NewReader(body))
+	req.SetPathValue("id", "s1")
+	w := httptest.NewRecorder()
+	h.server.handleNotify(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("notify: got %d", w.Code)
+	}
+
+	sess, _ = h.store.GetSession("s1")
+	if sess.TmuxPane != "%9" {
+		t.Errorf("TmuxPane = %q, want %%9", sess.TmuxPane)
+	}
+
+	// A known pane is not overwritten
+	body, _ = json.Marshal(map[string]string{"node_name": "test-node", "tmux_pane": "%1"})
+	req = httptest.NewRequest("POST", "/api/sessions/s1/activity", bytes.NewReader(body))
+	req.SetPathValue("id", "s1")
+	w = httptest.NewRecorder()
+	h.server.handleActivity(w, req)
+	sess, _ = h.store.GetSession("s1")
+	if sess.TmuxPane != "%9" {
+		t.Errorf("TmuxPane = %q after activity, want %%9", sess.TmuxPane)
+	}
+}
+
+func TestTranscriptEndpointCompact(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+	h.mockOps.transcripts["s1"] = &transcript.Transcript{Messages: []transcript.Message{
+		{Role: "assistant", Blocks: []transcript.Block{
+			{Type: "text", Text: strings.Repeat("a", 50)},
+			{Type: "tool_use", Text: "ExitPlanMode", Input: json.RawMessage(`{"plan":"x"}`)},
+		}},
+	}}
+
+	req := httptest.NewRequest("GET", "/api/sessions/s1/transcript?compact=1&max_text=10", nil)
+	req.SetPathValue("id", "s1")
+	w := httptest.NewRecorder()
+	h.server.handleTranscript(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d, want 200", w.Code)
+	}
+
+	var tr transcript.Transcript
+	json.NewDecoder(w.Body).Decode(&tr)
+	blocks := tr.Messages[0].Blocks
+	if blocks[0].Text != strings.Repeat("a", 10) || !blocks[0].Truncated {
+		t.Errorf("text block = %+v", blocks[0])
+	}
+	if blocks[1].Input != nil {
+		t.Errorf("tool input should be stripped, got %s", blocks[1].Input)
+	}
+}
+
+func TestTranscriptEndpointConditional(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+	h.mockOps.etags = map[string]string{"s1": `"abc-10"`}
+
+	get := func(query, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/sessions/s1/transcript"+query, nil)
+		req.SetPathValue("id", "s1")
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		h.server.handleTranscript(w, req)
+		return w
+	}
+
+	full := get("", "")
+	compact := get("?compact=1", "")
+	if full.Header().Get("ETag") != `"abc-10"` {
+		t.Errorf("full ETag = %q", full.Header().Get("ETag"))
+	}
+	if compact.Header().Get("ETag") != `"abc-10;compact=2000"` {
+		t.Errorf("compact ETag = %q", compact.Header().Get("ETag"))
+	}
+
+	if w := get("", full.Header().Get("ETag")); w.Code != http.StatusNotModified {
+		t.Errorf("full revalidation: got %d, want 304", w.Code)
+	}
+	if w := get("?compact=1", compact.Header().Get("ETag")); w.Code != http.StatusNotModified {
+		t.Errorf("compact revalidation: got %d, want 304", w.Code)
+	}
+	// A compact ETag must not validate the full representation.
+	if w := get("", compact.Header().Get("ETag")); w.Code != http.StatusOK {
+		t.Errorf("cross-variant revalidation: got %d, want 200", w.Code)
+	}
+}
+
+func decodeAPIError(t *testing.T, w *httptest.ResponseRecorder) apiError {
+	t.Helper()
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var body apiError
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("decode error body: %v", err)
+	}
+	return body
+}
+
+func TestErrorPayloadNotFound(t *testing.T) {
+	h := newTestHarness(t)
+	w := h.patchSession(t, "missing", map[string]any{"pinned": true})
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("got %d, want 404", w.Code)
+	}
+	body := decodeAPIError(t, w)
+	if body.Error.Code != errCodeNotFound || body.Error.Message != "session not found" {
+		t.Errorf("error = %+v", body.Error)
+	}
+}
+
+func TestErrorPayloadBadRequest(t *testing.T) {
+	h := newTestHarness(t)
+	req := httptest.NewRequest("GET", "/api/sessions?sort=bogus", nil)
+	w := httptest.NewRecorder()
+	h.server.handleSessionsAPI(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("got %d, want 400", w.Code)
+	}
+	body := decodeAPIError(t, w)
+	if body.Error.Code != errCodeBadRequest || body.Error.Message != "unknown sort bogus" {
+		t.Errorf("error = %+v", body.Error)
+	}
+}
+
+func TestRespondAgentOffline(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%1", "/home/user/project")
+	h.mockOps.sendErr = fmt.Errorf("%w for node %q", ErrAgentOffline, "test-node")
+
+	body, _ := json.Marshal(map[string]string{"text": "yes"})
+	req := httptest.NewRequest("POST", "/api/respond/s1", bytes.NewReader(body))
+	req.SetPathValue("id", "s1")
+	w := httptest.NewRecorder()
+	h.server.handleRespond(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("got %d, want 503", w.Code)
+	}
+	if got := decodeAPIError(t, w).Error.Code; got != errCodeAgentOffline {
+		t.Errorf("code = %q, want %q", got, errCodeAgentOffline)
+	}
+}
+
+func TestTranscriptEndpointAgentOnline(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+
+	agentOnline := func() bool {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/sessions/s1/transcript", nil)
+		req.SetPathValue("id", "s1")
+		w := httptest.NewRecorder()
+		h.server.handleTranscript(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("got %d, want 200", w.Code)
+		}
+		var result struct {
+			AgentOnline *bool `json:"agent_online"`
+		}
+		json.NewDecoder(w.Body).Decode(&result)
+		if result.AgentOnline == nil {
+			t.Fatal("agent_online missing from response")
+		}
+		return *result.AgentOnline
+	}
+
+	if agentOnline() {
+		t.Error("agent_online = true with no agent registered")
+	}
+	h.server.agents.Register("test-node", "http://test-node:2588", "")
+	if !agentOnline() {
+		t.Error("agent_online = false with a healthy agent")
+	}
+}
+
+func TestSessionsAPIAttention(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "quiet", "%1", "/home/user/project")
+	h.createSession(t, "waiting", "%2", "/home/user/project")
+	h.createSession(t, "ended", "%3", "/home/user/project")
+	h.notify(t, "waiting", "permission_prompt", "Allow Bash?")
+	h.notify(t, "ended", "idle_prompt", "Waiting")
+	h.endSession(t, "ended")
+
+	req := httptest.NewRequest("GET", "/api/sessions?attention=1", nil)
+	w := httptest.NewRecorder()
+	h.server.handleSessionsAPI(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d, want 200", w.Code)
+	}
+
+	var resp struct {
+		Active []store.Session  `json:"active"`
+		Recent *[]store.Session `json:"recent"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Active) != 1 || resp.Active[0].ID != "waiting" {
+		t.Errorf("active = %+v, want only waiting", resp.Active)
+	}
+	if resp.Recent != nil {
+		t.Error("attention response should omit recent sessions")
+	}
+}
+
+func TestMutedSessionNotificationsFlagged(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%1", "/home/user/project")
+
+	if w := h.patchSession(t, "s1", map[string]any{"muted": true}); w.Code != http.StatusOK {
+		t.Fatalf("patch: got %d, want 200", w.Code)
+	}
+	if sess, _ := h.store.GetSession("s1"); !sess.Muted {
+		t.Fatal("session should be muted")
+	}
+
+	// The event is still published so dashboards update, but flagged muted
+	// so clients skip the alert.
+	h.notify(t, "s1", "permission_prompt", "Allow Bash?")
+	hist := h.server.events.History("s1", 0)
+	if len(hist) == 0 || hist[len(hist)-1].Type != EventNotification {
+		t.Fatalf("no notification event published: %+v", hist)
+	}
+	var data map[string]string
+	json.Unmarshal(hist[len(hist)-1].Data, &data)
+	if data["muted"] != "true" {
+		t.Errorf("event data = %v, want muted flag", data)
+	}
+
+	h.patchSession(t, "s1", map[string]any{"muted": false})
+	h.notify(t, "s1", "permission_prompt", "Allow Bash?")
+	hist = h.server.events.History("s1", 0)
+	data = nil
+	json.Unmarshal(hist[len(hist)-1].Data, &data)
+	if _, ok := data["muted"]; ok {
+		t.Errorf("unmuted event data = %v, should not carry muted", data)
+	}
+}
+
+func TestActivityUsesClock(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+
+	h.clock.Advance(10 * time.Minute)
+	h.turnEnd(t, "s1")
+	h.server.bg.Wait()
+
+	sess, _ := h.store.GetSession("s1")
+	// Stored timestamps have second precision.
+	if want := h.clock.Now().Truncate(time.Second); !sess.LastActivityAt.Equal(want) {
+		t.Errorf("LastActivityAt = %v, want %v", sess.LastActivityAt, want)
+	}
+}
+
+func TestActivitySummaryFetchesAreCapped(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+	h.server.bg.Wait()
+	h.mockOps.summaryGate = make(chan struct{})
+
+	for range 20 {
+		h.turnEnd(t, "s1")
+	}
+	// The burst's last turn end arrives while the first fetch is blocked;
+	// its summary must still be applied.
+	h.mockOps.mu.Lock()
+	h.mockOps.summaries["s1"] = &transcript.SessionSummary{Topic: "after the burst"}
+	h.mockOps.mu.Unlock()
+	close(h.mockOps.summaryGate)
+	h.server.bg.Wait()
+
+	if h.mockOps.summaryCalls != 2 {
+		t.Errorf("summary fetches = %d, want 2: one running and one coalesced re-run", h.mockOps.summaryCalls)
+	}
+	if sess, _ := h.store.GetSession("s1"); sess.Topic != "after the burst" {
+		t.Errorf("Topic = %q, want summary applied after the burst", sess.Topic)
+	}
+}
+
+func TestSummaryFetchesAcrossSessionsAreCappedNotDropped(t *testing.T) {
+	h := newTestHarness(t)
+	const n = maxSummaryFetches + 3
+	for i := range n {
+		h.createSession(t, fmt.Sprintf("s%d", i), fmt.Sprintf("%%%d", i), "/home/user/project")
+	}
+	h.server.bg.Wait()
+	h.mockOps.summaryGate = make(chan struct{})
+	for i := range n {
+		h.mockOps.summaries[fmt.Sprintf("s%d", i)] = &transcript.SessionSummary{Topic: "summarized"}
+	}
+
+	for i := range n {
+		h.turnEnd(t, fmt.Sprintf("s%d", i))
+	}
+	close(h.mockOps.summaryGate)
+	h.server.bg.Wait()
+
+	if h.mockOps.summaryMaxInFlight > maxSummaryFetches {
+		t.Errorf("max concurrent summary fetches = %d, want <= %d", h.mockOps.summaryMaxInFlight, maxSummaryFetches)
+	}
+	for i := range n {
+		sess, err := h.store.GetSession(fmt.Sprintf("s%d", i))
+		if err != nil {
+			t.Errorf("GetSession(s%d): %v", i, err)
+		} else if sess.Topic != "summarized" {
+			t.Errorf("s%d Topic = %q; a refresh over the cap was dropped", i, sess.Topic)
+		}
+	}
+}
+
+func TestTranscriptEndpointCoalescesConcurrentReads(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+	h.mockOps.transcripts["s1"] = &transcript.Transcript{Messages: []transcript.Message{
+		{Role: "user", Blocks: []transcript.Block{{Type: "text", Text: "Hello"}}},
+	}}
+	h.mockOps.transcriptGate = make(chan struct{})
+
+	const clients = 5
+	codes := make(chan int, clients)
+	bodies := make(chan string, clients)
+	for range clients {
+		go func() {
+			req := httptest.NewRequest("GET", "/api/sessions/s1/transcript", nil)
+			req.SetPathValue("id", "s1")
+			w := httptest.NewRecorder()
+			h.server.handleTranscript(w, req)
+			codes <- w.Code
+			bodies <- w.Body.String()
+		}()
+	}
+
+	// Release the agent read only once every other client has joined it.
+	deadline := time.Now().Add(5 * time.Second)
+	for h.server.transcripts.waiters("s1\x00") < clients-1 {
+		if time.Now().After(deadline) {
+			t.Fatal("clients never joined the in-flight read")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(h.mockOps.transcriptGate)
+
+	for range clients {
+		if code := <-codes; code != http.StatusOK {
+			t.Errorf("got %d, want 200", code)
+		}
+		if body := <-bodies; !strings.Contains(body, "Hello") {
+			t.Errorf("body = %q, want shared transcript", body)
+		}
+	}
+	if h.mockOps.transcriptCalls != 1 {
+		t.Errorf("agent transcript reads = %d, want 1", h.mockOps.transcriptCalls)
+	}
+}
+
+func TestTranscriptEndpointVersion(t *testing.T) {
+	h := newTestHarness(t)
+	h.createSession(t, "s1", "%5", "/home/user/project")
+	// A transcript from an agent that predates versioning.
+	h.mockOps.transcripts["s1"] = &transcript.Transcript{Messages: []transcript.Message{
+		{Role: "user", Blocks: []transcript.Block{{Type: "text", Text: "Hello"}}},
+	}}
+
+	req := httptest.NewRequest("GET", "/api/sessions/s1/transcript", nil)
+	req.SetPathValue("id", "s1")
+	w := httptest.NewRecorder()
+	h.server.handleTranscript(w, req)
+
+	var result struct {
+		Version  int   `json:"version"`
+		Messages []any `json:"messages"`
+	}
+	json.NewDecoder(w.Body).Decode(&result)
+	if result.Version != transcript.FormatVersion {
+		t.Errorf("version = %d, want %d", result.Version, transcript.FormatVersion)
+	}
+	if len(result.Messages) != 1 {
+		t.Errorf("messages = %v, want unchanged", result.Messages)
+	}
+}
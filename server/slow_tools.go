@@ -0,0 +1,122 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// toolTimers holds a timer per running tool call, keyed by session and then
+// tool_use_id, firing a heads-up if the call outlasts Config.SlowToolAfter.
+// Calls the agent runs in parallel each get their own timer. Hooks that
+// don't report a tool_use_id share the session's "" slot, where each call
+// replaces the last. It is safe for concurrent use.
+type toolTimers struct {
+	mu     sync.Mutex
+	timers map[string]map[string]*time.Timer
+}
+
+// start (re)arms the timer for one tool call to run fn after d.
+func (t *toolTimers) start(sessionID, toolUseID string, d time.Duration, fn func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timers == nil {
+		t.timers = make(map[string]map[string]*time.Timer)
+	}
+	calls := t.timers[sessionID]
+	if calls == nil {
+		calls = make(map[string]*time.Timer)
+		t.timers[sessionID] = calls
+	}
+	if old, ok := calls[toolUseID]; ok {
+		old.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(d, func() {
+		// A stop or restart that raced with the timer firing wins.
+		t.mu.Lock()
+		current := t.timers[sessionID][toolUseID] == timer
+		if current {
+			t.remove(sessionID, toolUseID)
+		}
+		t.mu.Unlock()
+		if current {
+			fn()
+		}
+	})
+	calls[toolUseID] = timer
+}
+
+// stop disarms the timer for one tool call, if any.
+func (t *toolTimers) stop(sessionID, toolUseID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if timer, ok := t.timers[sessionID][toolUseID]; ok {
+		timer.Stop()
+		t.remove(sessionID, toolUseID)
+	}
+}
+
+// stopAll disarms every timer for the session.
+func (t *toolTimers) stopAll(sessionID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, timer := range t.timers[sessionID] {
+		timer.Stop()
+	}
+	delete(t.timers, sessionID)
+}
+
+// remove drops a call's entry, and the session's once it has none. t.mu
+// must be held.
+func (t *toolTimers) remove(sessionID, toolUseID string) {
+	delete(t.timers[sessionID], toolUseID)
+	if len(t.timers[sessionID]) == 0 {
+		delete(t.timers, sessionID)
+	}
+}
+
+// waitsOnUser reports tools that block on the user rather than work, which
+// never warrant a slow-tool notification.
+func waitsOnUser(tool string) bool {
+	return tool == "AskUserQuestion" || tool == "ExitPlanMode"
+}
+
+// trackSlowTool arms or disarms a tool call's slow-tool timer for a
+// tool-activity hook.
+func (s *Server) trackSlowTool(sessionID, hookEvent, tool, toolUseID string) {
+	after := s.cfg.SlowToolAfter
+	if after <= 0 {
+		return
+	}
+	switch hookEvent {
+	case "PreToolUse":
+		if waitsOnUser(tool) {
+			s.toolTimers.stop(sessionID, toolUseID)
+			return
+		}
+		s.toolTimers.start(sessionID, toolUseID, after, func() { s.notifySlowTool(sessionID, tool, after) })
+	case "PostToolUse":
+		s.toolTimers.stop(sessionID, toolUseID)
+	}
+}
+
+// notifySlowTool publishes a "tool_running" notification for a tool call
+// still running after the threshold. It isn't stored as the session's
+// notification, since nothing is waiting on the user.
+func (s *Server) notifySlowTool(sessionID, tool string, after time.Duration) {
+	sess, err := s.store.GetSession(sessionID)
+	if err != nil || !sess.StoppedAt.IsZero() {
+		return
+	}
+	s.events.Publish(sessionID, Event{
+		Type:    EventNotification,
+		Session: sessionID,
+		Data: s.notificationData(sess, map[string]string{
+			"type":    "tool_running",
+			"title":   alertTitle(sess, "tool_running", "Tool still running"),
+			"message": fmt.Sprintf("%s has been running for over %s", tool, after),
+		}),
+	})
+	s.logger.Info("slow tool call", "session_id", sessionID, "tool", tool, "after", after)
+}
@@ -1,4 +1,4 @@
-"use strict";(()=>{var nt=Object.defineProperty;var st=(t,e,n)=>e in t?nt(t,e,{enumerable:!0,configurable:!0,writable:!0,value:n}):t[e]=n;var f=(t,e,n)=>st(t,typeof e!="symbol"?e+"":e,n);var M=class{constructor(e){this.source=null;this.listeners=new Map;this.url=e}connect(){if(!this.source){this.source=new EventSource(this.url);for(let e of this.listeners.keys())this.source.addEventListener(e,n=>{let i=this.listeners.get(e);if(i)for(let s of i)s(n)});window.addEventListener("beforeunload",()=>{this.source?.close()})}}on(e,n){let i=this.listeners.get(e);return i||(i=new Set,this.listeners.set(e,i),this.source?.addEventListener(e,s=>{let r=this.listeners.get(e);if(r)for(let o of r)o(s)})),i.add(n),()=>{i.delete(n)}}};var ve=[],q=null,we=[];function K(t,e,n){let i=[],s=new RegExp("^"+t.replace(/:([^/]+)/g,(r,o)=>(i.push(o),"([^/]+)"))+"$");ve.push({pattern:s,paramNames:i,mount:e,unmount:n})}function ye(t){we.push(t)}function X(t){q&&(q(),q=null);for(let e of ve){let n=t.match(e.pattern);if(n){let i={};e.paramNames.forEach((s,r)=>{i[s]=n[r+1]}),q=e.unmount,e.mount(i);break}}for(let e of we)e(t)}function Y(t){history.pushState(null,"",t),X(t)}function Se(){window.addEventListener("popstate",()=>{X(window.location.pathname)}),document.addEventListener("click",t=>{let e=t.target.closest("a");if(!e)return;let n=e.getAttribute("href");!n||n.startsWith("http")||n.startsWith("//")||(t.preventDefault(),Y(n))}),X(window.location.pathname)}function b(t){let e=document.createElement("div");return e.textContent=t,e.innerHTML}function N(t,e){let n;return()=>{clearTimeout(n),n=setTimeout(t,e)}}function D(t){if(!t)return"just now";let e=Date.now()-new Date(t).getTime();if(e<6e4)return"just now";if(e<36e5){let i=Math.floor(e/6e4);return i===1?"1m ago":i+"m ago"}let n=Math.floor(e/36e5);return n===1?"1h ago":n+"h ago"}var Re="",H=!0;function rt(t){let e=t.replace(/^[\u2800-\u28FF✳]\s*/,"").trim();return["Claude Code","Codex","Antigravity"].includes(e)?"":e}var _e=new Map,Ee=6e4;function ot(t){let e=_e.get(t);return e?Date.now()-e<Ee:!1}function Te(t,e){let n=e&&t.agent_online===!1,i=e&&!n&&!!t.notification_type,s=e?n?"dot-offline":ot(t.session_id)?"dot-active":i?"dot-waiting":"dot-idle":"dot-stopped",r=t.session_id===Re?" selected":"",o=e&&!n,a=o?"a":"div",l="<"+a+' class="sb-card'+r+'"';o&&(l+=' href="/respond/'+b(t.session_id)+'"'),l+=">",l+='<div class="sb-card-header">',l+='<span class="dot '+s+'"></span>',l+='<span class="sb-project">'+b(t.project)+"</span>",t.node_name&&(l+='<span class="sb-node">'+b(t.node_name)+"</span>"),l+="</div>";let c=rt(t.pane_title||"");if(c&&(l+='<div class="sb-pane-title">'+b(c)+"</div>"),e&&!n){let u=t.notify_message||t.plan_summary||t.topic;u&&(l+='<div class="sb-detail'+(i?" sb-detail-notify":"")+'">'+b(u)+"</div>")}else e?l+='<div class="sb-detail sb-detail-offline">Agent offline</div>':l+='<div class="sb-detail">Stopped '+D(t.stopped_at||"")+"</div>";return l+="</"+a+">",l}function y(){fetch("/api/sessions").then(t=>t.json()).then(t=>{let e=document.getElementById("sb-sessions");if(!e)return;let n="",i=(t.active||[]).sort((r,o)=>{let a=r.last_activity_at||r.started_at||"";return(o.last_activity_at||o.started_at||"").localeCompare(a)});i.length>0&&(n+='<div class="sb-section">Active</div>',n+=i.map(r=>Te(r,!0)).join(""));let s=t.recent||[];s.length>0&&(n+='<div class="sb-section sb-section-toggle" id="sb-recent-toggle">'+(H?"\u25B8":"\u25BE")+" Recent ("+s.length+")</div>",H||(n+=s.map(o=>Te(o,!1)).join(""))),i.length===0&&s.length===0&&(n='<div class="sb-empty">No sessions</div>'),e.innerHTML=n}).catch(()=>{})}function $e(t){Re=t,document.querySelectorAll(".sb-card").forEach(e=>{e.classList.remove("selected")}),t&&document.querySelector('a.sb-card[href="/respond/'+t+'"]')?.classList.add("selected")}function Le(t){let e=document.getElementById("sidebar");e.innerHTML='<div class="sb-header"><span class="sb-title">sophon</span></div><div id="notif-pill-slot"></div><div class="sb-scroll" id="sb-sessions"><div class="sb-empty">Loading\u2026</div></div>',y(),document.getElementById("sb-sessions").addEventListener("click",s=>{s.target.id==="sb-recent-toggle"&&(H=!H,y())});let n=N(y,1e3);t.on("notification",()=>y()),t.on("session_start",()=>y()),t.on("session_end",()=>y()),t.on("activity",()=>y()),t.on("response",()=>y());let i=null;t.on("tool_activity",s=>{try{let r=JSON.parse(s.data);r.session_id&&_e.set(r.session_id,Date.now())}catch{}n(),i&&clearTimeout(i),i=setTimeout(y,Ee+1e3)})}function Ae(t,e){document.body.dataset.page="sessions";let n=document.getElementById("app");n.innerHTML='<div class="index-empty"><div class="index-empty-hint">Select a session to view details</div></div>'}function ze(){}function ie(){return{async:!1,breaks:!1,extensions:null,gfm:!0,hooks:null,pedantic:!1,renderer:null,silent:!1,tokenizer:null,walkTokens:null}}var _=ie();function qe(t){_=t}var I={exec:()=>null};function d(t,e=""){let n=typeof t=="string"?t:t.source,i={replace:(s,r)=>{let o=typeof r=="string"?r:r.source;return o=o.replace(x.caret,"$1"),n=n.replace(s,o),i},getRegex:()=>new RegExp(n,e)};return i}var x={codeRemoveIndent:/^(?: {1,4}| {0,3}\t)/gm,outputLinkReplace:/\\([\[\]])/g,indentCodeCompensation:/^(\s+)(?:```)/,beginningSpace:/^\s+/,endingHash:/#$/,startingSpaceChar:/^ /,endingSpaceChar:/ $/,nonSpaceChar:/[^ ]/,newLineCharGlobal:/\n/g,tabCharGlobal:/\t/g,multipleSpaceGlobal:/\s+/g,blankLine:/^[ \t]*$/,doubleBlankLine:/\n[ \t]*\n[ \t]*$/,blockquoteStart:/^ {0,3}>/,blockquoteSetextReplace:/\n {0,3}((?:=+|-+) *)(?=\n|$)/g,blockquoteSetextReplace2:/^ {0,3}>[ \t]?/gm,listReplaceTabs:/^\t+/,listReplaceNesting:/^ {1,4}(?=( {4})*[^ ])/g,listIsTask:/^\[[ xX]\] /,listReplaceTask:/^\[[ xX]\] +/,anyLine:/\n.*\n/,hrefBrackets:/^<(.*)>$/,tableDelimiter:/[:|]/,tableAlignChars:/^\||\| *$/g,tableRowBlankLine:/\n[ \t]*$/,tableAlignRight:/^ *-+: *$/,tableAlignCenter:/^ *:-+: *$/,tableAlignLeft:/^ *:-+ *$/,startATag:/^<a /i,endATag:/^<\/a>/i,startPreScriptTag:/^<(pre|code|kbd|script)(\s|>)/i,endPreScriptTag:/^<\/(pre|code|kbd|script)(\s|>)/i,startAngleBracket:/^</,endAngleBracket:/>$/,pedanticHrefTitle:/^([^'"]*[^\s])\s+(['"])(.*)\2/,unicodeAlphaNumeric:/[\p{L}\p{N}]/u,escapeTest:/[&<>"']/,escapeReplace:/[&<>"']/g,escapeTestNoEncode:/[<>"']|&(?!(#\d{1,7}|#[Xx][a-fA-F0-9]{1,6}|\w+);)/,escapeReplaceNoEncode:/[<>"']|&(?!(#\d{1,7}|#[Xx][a-fA-F0-9]{1,6}|\w+);)/g,unescapeTest:/&(#(?:\d+)|(?:#x[0-9A-Fa-f]+)|(?:\w+));?/ig,caret:/(^|[^\[])\^/g,percentDecode:/%25/g,findPipe:/\|/g,splitPipe:/ \|/,slashPipe:/\\\|/g,carriageReturn:/\r\n|\r/g,spaceLine:/^ +$/gm,notSpaceStart:/^\S*/,endingNewline:/\n$/,listItemRegex:t=>new RegExp(`^( {0,3}${t})((?:[	 ][^\\n]*)?(?:\\n|$))`),nextBulletRegex:t=>new RegExp(`^ {0,${Math.min(3,t-1)}}(?:[*+-]|\\d{1,9}[.)])((?:[ 	][^\\n]*)?(?:\\n|$))`),hrRegex:t=>new RegExp(`^ {0,${Math.min(3,t-1)}}((?:- *){3,}|(?:_ *){3,}|(?:\\* *){3,})(?:\\n+|$)`),fencesBeginRegex:t=>new RegExp(`^ {0,${Math.min(3,t-1)}}(?:\`\`\`|~~~)`),headingBeginRegex:t=>new RegExp(`^ {0,${Math.min(3,t-1)}}#`),htmlBeginRegex:t=>new RegExp(`^ {0,${Math.min(3,t-1)}}<(?:[a-z].*>|!--)`,"i")},ct=/^(?:[ \t]*(?:\n|$))+/,ut=/^((?: {4}| {0,3}\t)[^\n]+(?:\n(?:[ \t]*(?:\n|$))*)?)+/,pt=/^ {0,3}(`{3,}(?=[^`\n]*(?:\n|$))|~{3,})([^\n]*)(?:\n|$)(?:|([\s\S]*?)(?:\n|$))(?: {0,3}\1[~`]* *(?=\n|$)|$)/,P=/^ {0,3}((?:-[\t ]*){3,}|(?:_[ \t]*){3,}|(?:\*[ \t]*){3,})(?:\n+|$)/,ht=/^ {0,3}(#{1,6})(?=\s|$)(.*)(?:\n+|$)/,re=/(?:[*+-]|\d{1,9}[.)])/,Ne=/^(?!bull |blockCode|fences|blockquote|heading|html|table)((?:.|\n(?!\s*?\n|bull |blockCode|fences|blockquote|heading|html|table))+?)\n {0,3}(=+|-+) *(?:\n+|$)/,De=d(Ne).replace(/bull/g,re).replace(/blockCode/g,/(?: {4}| {0,3}\t)/).replace(/fences/g,/ {0,3}(?:`{3,}|~{3,})/).replace(/blockquote/g,/ {0,3}>/).replace(/heading/g,/ {0,3}#{1,6}/).replace(/html/g,/ {0,3}<[^\n>]+>\n/).replace(/\|table/g,"").getRegex(),dt=d(Ne).replace(/bull/g,re).replace(/blockCode/g,/(?: {4}| {0,3}\t)/).replace(/fences/g,/ {0,3}(?:`{3,}|~{3,})/).replace(/blockquote/g,/ {0,3}>/).replace(/heading/g,/ {0,3}#{1,6}/).replace(/html/g,/ {0,3}<[^\n>]+>\n/).replace(/table/g,/ {0,3}\|?(?:[:\- ]*\|)+[\:\- ]*\n/).getRegex(),oe=/^([^\n]+(?:\n(?!hr|heading|lheading|blockquote|fences|list|html|table| +\n)[^\n]+)*)/,ft=/^[^\n]+/,ae=/(?!\s*\])(?:\\.|[^\[\]\\])+/,gt=d(/^ {0,3}\[(label)\]: *(?:\n[ \t]*)?([^<\s][^\s]*|<.*?>)(?:(?: +(?:\n[ \t]*)?| *\n[ \t]*)(title))? *(?:\n+|$)/).replace("label",ae).replace("title",/(?:"(?:\\"?|[^"\\])*"|'[^'\n]*(?:\n[^'\n]+)*\n?'|\([^()]*\))/).getRegex(),kt=d(/^( {0,3}bull)([ \t][^\n]+?)?(?:\n|$)/).replace(/bull/g,re).getRegex(),F="address|article|aside|base|basefont|blockquote|body|caption|center|col|colgroup|dd|details|dialog|dir|div|dl|dt|fieldset|figcaption|figure|footer|form|frame|frameset|h[1-6]|head|header|hr|html|iframe|legend|li|link|main|menu|menuitem|meta|nav|noframes|ol|optgroup|option|p|param|search|section|summary|table|tbody|td|tfoot|th|thead|title|tr|track|ul",le=/<!--(?:-?>|[\s\S]*?(?:-->|$))/,mt=d("^ {0,3}(?:<(script|pre|style|textarea)[\\s>][\\s\\S]*?(?:</\\1>[^\\n]*\\n+|$)|comment[^\\n]*(\\n+|$)|<\\?[\\s\\S]*?(?:\\?>\\n*|$)|<![A-Z][\\s\\S]*?(?:>\\n*|$)|<!\\[CDATA\\[[\\s\\S]*?(?:\\]\\]>\\n*|$)|</?(tag)(?: +|\\n|/?>)[\\s\\S]*?(?:(?:\\n[ 	]*)+\\n|$)|<(?!script|pre|style|textarea)([a-z][\\w-]*)(?:attribute)*? */?>(?=[ \\t]*(?:\\n|$))[\\s\\S]*?(?:(?:\\n[ 	]*)+\\n|$)|</(?!script|pre|style|textarea)[a-z][\\w-]*\\s*>(?=[ \\t]*(?:\\n|$))[\\s\\S]*?(?:(?:\\n[ 	]*)+\\n|$))","i").replace("comment",le).replace("tag",F).replace("attribute",/ +[a-zA-Z:_][\w.:-]*(?: *= *"[^"\n]*"| *= *'[^'\n]*'| *= *[^\s"'=<>`]+)?/).getRegex(),He=d(oe).replace("hr",P).replace("heading"," {0,3}#{1,6}(?:\\s|$)").replace("|lheading","").replace("|table","").replace("blockquote"," {0,3}>").replace("fences"," {0,3}(?:`{3,}(?=[^`\\n]*\\n)|~{3,})[^\\n]*\\n").replace("list"," {0,3}(?:[*+-]|1[.)]) ").replace("html","</?(?:tag)(?: +|\\n|/?>)|<(?:script|pre|style|textarea|!--)").replace("tag",F).getRegex(),bt=d(/^( {0,3}> ?(paragraph|[^\n]*)(?:\n|$))+/).replace("paragraph",He).getRegex(),ce={blockquote:bt,code:ut,def:gt,fences:pt,heading:ht,hr:P,html:mt,lheading:De,list:kt,newline:ct,paragraph:He,table:I,text:ft},Ce=d("^ *([^\\n ].*)\\n {0,3}((?:\\| *)?:?-+:? *(?:\\| *:?-+:? *)*(?:\\| *)?)(?:\\n((?:(?! *\\n|hr|heading|blockquote|code|fences|list|html).*(?:\\n|$))*)\\n*|$)").replace("hr",P).replace("heading"," {0,3}#{1,6}(?:\\s|$)").replace("blockquote"," {0,3}>").replace("code","(?: {4}| {0,3}	)[^\\n]").replace("fences"," {0,3}(?:`{3,}(?=[^`\\n]*\\n)|~{3,})[^\\n]*\\n").replace("list"," {0,3}(?:[*+-]|1[.)]) ").replace("html","</?(?:tag)(?: +|\\n|/?>)|<(?:script|pre|style|textarea|!--)").replace("tag",F).getRegex(),xt={...ce,lheading:dt,table:Ce,paragraph:d(oe).replace("hr",P).replace("heading"," {0,3}#{1,6}(?:\\s|$)").replace("|lheading","").replace("table",Ce).replace("blockquote"," {0,3}>").replace("fences"," {0,3}(?:`{3,}(?=[^`\\n]*\\n)|~{3,})[^\\n]*\\n").replace("list"," {0,3}(?:[*+-]|1[.)]) ").replace("html","</?(?:tag)(?: +|\\n|/?>)|<(?:script|pre|style|textarea|!--)").replace("tag",F).getRegex()},vt={...ce,html:d(`^ *(?:comment *(?:\\n|\\s*$)|<(tag)[\\s\\S]+?</\\1> *(?:\\n{2,}|\\s*$)|<tag(?:"[^"]*"|'[^']*'|\\s[^'"/>\\s]*)*?/?> *(?:\\n{2,}|\\s*$))`).replace("comment",le).replace(/tag/g,"(?!(?:a|em|strong|small|s|cite|q|dfn|abbr|data|time|code|var|samp|kbd|sub|sup|i|b|u|mark|ruby|rt|rp|bdi|bdo|span|br|wbr|ins|del|img)\\b)\\w+(?!:|[^\\w\\s@]*@)\\b").getRegex(),def:/^ *\[([^\]]+)\]: *<?([^\s>]+)>?(?: +(["(][^\n]+[")]))? *(?:\n+|$)/,heading:/^(#{1,6})(.*)(?:\n+|$)/,fences:I,lheading:/^(.+?)\n {0,3}(=+|-+) *(?:\n+|$)/,paragraph:d(oe).replace("hr",P).replace("heading",` *#{1,6} *[^
+"use strict";(()=>{var nt=Object.defineProperty;var st=(t,e,n)=>e in t?nt(t,e,{enumerable:!0,configurable:!0,writable:!0,value:n}):t[e]=n;var f=(t,e,n)=>st(t,typeof e!="symbol"?e+"":e,n);var M=class{constructor(e){this.source=null;this.listeners=new Map;this.url=e}connect(){if(!this.source){this.source=new EventSource(this.url);for(let e of this.listeners.keys())this.source.addEventListener(e,n=>{let i=this.listeners.get(e);if(i)for(let s of i)s(n)});window.addEventListener("beforeunload",()=>{this.source?.close()})}}on(e,n){let i=this.listeners.get(e);return i||(i=new Set,this.listeners.set(e,i),this.source?.addEventListener(e,s=>{let r=this.listeners.get(e);if(r)for(let o of r)o(s)})),i.add(n),()=>{i.delete(n)}}};var ve=[],q=null,we=[];function K(t,e,n){let i=[],s=new RegExp("^"+t.replace(/:([^/]+)/g,(r,o)=>(i.push(o),"([^/]+)"))+"$");ve.push({pattern:s,paramNames:i,mount:e,unmount:n})}function ye(t){we.push(t)}function X(t){q&&(q(),q=null);for(let e of ve){let n=t.match(e.pattern);if(n){let i={};e.paramNames.forEach((s,r)=>{i[s]=n[r+1]}),q=e.unmount,e.mount(i);break}}for(let e of we)e(t)}function Y(t){history.pushState(null,"",t),X(t)}function Se(){window.addEventListener("popstate",()=>{X(window.location.pathname)}),document.addEventListener("click",t=>{let e=t.target.closest("a");if(!e)return;let n=e.getAttribute("href");!n||n.startsWith("http")||n.startsWith("//")||(t.preventDefault(),Y(n))}),X(window.location.pathname)}function b(t){let e=document.createElement("div");return e.textContent=t,e.innerHTML}function N(t,e){let n;return()=>{clearTimeout(n),n=setTimeout(t,e)}}function D(t){if(!t)return"just now";let e=Date.now()-new Date(t).getTime();if(e<6e4)return"just now";if(e<36e5){let i=Math.floor(e/6e4);return i===1?"1m ago":i+"m ago"}let n=Math.floor(e/36e5);return n===1?"1h ago":n+"h ago"}var Re="",H=!0;function rt(t){let e=t.replace(/^[\u2800-\u28FF✳]\s*/,"").trim();return["Claude Code","Codex","Antigravity"].includes(e)?"":e}var _e=new Map,Ee=6e4;function ot(t){if(t.busy&&!t.notification_type)return!0;let e=_e.get(t.session_id);return e?Date.now()-e<Ee:!1}function Te(t,e){let n=e&&t.agent_online===!1,i=e&&!n&&!!t.notification_type,s=e?n?"dot-offline":ot(t)?"dot-active":i?"dot-waiting":"dot-idle":"dot-stopped",r=t.session_id===Re?" selected":"",o=e&&!n,a=o?"a":"div",l="<"+a+' class="sb-card'+r+'"';o&&(l+=' href="/respond/'+b(t.session_id)+'"'),l+=">",l+='<div class="sb-card-header">',l+='<span class="dot '+s+'"></span>',l+='<span class="sb-project">'+b(t.project)+"</span>",t.node_name&&(l+='<span class="sb-node">'+b(t.node_name)+"</span>"),l+="</div>";let c=rt(t.pane_title||"");if(c&&(l+='<div class="sb-pane-title">'+b(c)+"</div>"),e&&!n){let u=t.notify_message||t.plan_summary||t.topic;u&&(l+='<div class="sb-detail'+(i?" sb-detail-notify":"")+'">'+b(u)+"</div>")}else e?l+='<div class="sb-detail sb-detail-offline">Agent offline</div>':l+='<div class="sb-detail">Stopped '+D(t.stopped_at||"")+"</div>";return l+="</"+a+">",l}function y(){fetch("/api/sessions").then(t=>t.json()).then(t=>{let e=document.getElementById("sb-sessions");if(!e)return;let n="",i=(t.active||[]).sort((r,o)=>{let a=r.last_activity_at||r.started_at||"";return(o.last_activity_at||o.started_at||"").localeCompare(a)});i.length>0&&(n+='<div class="sb-section">Active</div>',n+=i.map(r=>Te(r,!0)).join(""));let s=t.recent||[];s.length>0&&(n+='<div class="sb-section sb-section-toggle" id="sb-recent-toggle">'+(H?"\u25B8":"\u25BE")+" Recent ("+s.length+")</div>",H||(n+=s.map(o=>Te(o,!1)).join(""))),i.length===0&&s.length===0&&(n='<div class="sb-empty">No sessions</div>'),e.innerHTML=n}).catch(()=>{})}function $e(t){Re=t,document.querySelectorAll(".sb-card").forEach(e=>{e.classList.remove("selected")}),t&&document.querySelector('a.sb-card[href="/respond/'+t+'"]')?.classList.add("selected")}function Le(t){let e=document.getElementById("sidebar");e.innerHTML='<div class="sb-header"><span class="sb-title">sophon</span></div><div id="notif-pill-slot"></div><div class="sb-scroll" id="sb-sessions"><div class="sb-empty">Loading\u2026</div></div>',y(),document.getElementById("sb-sessions").addEventListener("click",s=>{s.target.id==="sb-recent-toggle"&&(H=!H,y())});let n=N(y,1e3);t.on("notification",()=>y()),t.on("session_start",()=>y()),t.on("session_end",()=>y()),t.on("activity",()=>y()),t.on("response",()=>y()),t.on("busy",()=>y());let i=null;t.on("tool_activity",s=>{try{let r=JSON.parse(s.data);r.session_id&&_e.set(r.session_id,Date.now())}catch{}n(),i&&clearTimeout(i),i=setTimeout(y,Ee+1e3)})}function Ae(t,e){document.body.dataset.page="sessions";let n=document.getElementById("app");n.innerHTML='<div class="index-empty"><div class="index-empty-hint">Select a session to view details</div></div>'}function ze(){}function ie(){return{async:!1,breaks:!1,extensions:null,gfm:!0,hooks:null,pedantic:!1,renderer:null,silent:!1,tokenizer:null,walkTokens:null}}var _=ie();function qe(t){_=t}var I={exec:()=>null};function d(t,e=""){let n=typeof t=="string"?t:t.source,i={replace:(s,r)=>{let o=typeof r=="string"?r:r.source;return o=o.replace(x.caret,"$1"),n=n.replace(s,o),i},getRegex:()=>new RegExp(n,e)};return i}var x={codeRemoveIndent:/^(?: {1,4}| {0,3}\t)/gm,outputLinkReplace:/\\([\[\]])/g,indentCodeCompensation:/^(\s+)(?:```)/,beginningSpace:/^\s+/,endingHash:/#$/,startingSpaceChar:/^ /,endingSpaceChar:/ $/,nonSpaceChar:/[^ ]/,newLineCharGlobal:/\n/g,tabCharGlobal:/\t/g,multipleSpaceGlobal:/\s+/g,blankLine:/^[ \t]*$/,doubleBlankLine:/\n[ \t]*\n[ \t]*$/,blockquoteStart:/^ {0,3}>/,blockquoteSetextReplace:/\n {0,3}((?:=+|-+) *)(?=\n|$)/g,blockquoteSetextReplace2:/^ {0,3}>[ \t]?/gm,listReplaceTabs:/^\t+/,listReplaceNesting:/^ {1,4}(?=( {4})*[^ ])/g,listIsTask:/^\[[ xX]\] /,listReplaceTask:/^\[[ xX]\] +/,anyLine:/\n.*\n/,hrefBrackets:/^<(.*)>$/,tableDelimiter:/[:|]/,tableAlignChars:/^\||\| *$/g,tableRowBlankLine:/\n[ \t]*$/,tableAlignRight:/^ *-+: *$/,tableAlignCenter:/^ *:-+: *$/,tableAlignLeft:/^ *:-+ *$/,startATag:/^<a /i,endATag:/^<\/a>/i,startPreScriptTag:/^<(pre|code|kbd|script)(\s|>)/i,endPreScriptTag:/^<\/(pre|code|kbd|script)(\s|>)/i,startAngleBracket:/^</,endAngleBracket:/>$/,pedanticHrefTitle:/^([^'"]*[^\s])\s+(['"])(.*)\2/,unicodeAlphaNumeric:/[\p{L}\p{N}]/u,escapeTest:/[&<>"']/,escapeReplace:/[&<>"']/g,escapeTestNoEncode:/[<>"']|&(?!(#\d{1,7}|#[Xx][a-fA-F0-9]{1,6}|\w+);)/,escapeReplaceNoEncode:/[<>"']|&(?!(#\d{1,7}|#[Xx][a-fA-F0-9]{1,6}|\w+);)/g,unescapeTest:/&(#(?:\d+)|(?:#x[0-9A-Fa-f]+)|(?:\w+));?/ig,caret:/(^|[^\[])\^/g,percentDecode:/%25/g,findPipe:/\|/g,splitPipe:/ \|/,slashPipe:/\\\|/g,carriageReturn:/\r\n|\r/g,spaceLine:/^ +$/gm,notSpaceStart:/^\S*/,endingNewline:/\n$/,listItemRegex:t=>new RegExp(`^( {0,3}${t})((?:[	 ][^\\n]*)?(?:\\n|$))`),nextBulletRegex:t=>new RegExp(`^ {0,${Math.min(3,t-1)}}(?:[*+-]|\\d{1,9}[.)])((?:[ 	][^\\n]*)?(?:\\n|$))`),hrRegex:t=>new RegExp(`^ {0,${Math.min(3,t-1)}}((?:- *){3,}|(?:_ *){3,}|(?:\\* *){3,})(?:\\n+|$)`),fencesBeginRegex:t=>new RegExp(`^ {0,${Math.min(3,t-1)}}(?:\`\`\`|~~~)`),headingBeginRegex:t=>new RegExp(`^ {0,${Math.min(3,t-1)}}#`),htmlBeginRegex:t=>new RegExp(`^ {0,${Math.min(3,t-1)}}<(?:[a-z].*>|!--)`,"i")},ct=/^(?:[ \t]*(?:\n|$))+/,ut=/^((?: {4}| {0,3}\t)[^\n]+(?:\n(?:[ \t]*(?:\n|$))*)?)+/,pt=/^ {0,3}(`{3,}(?=[^`\n]*(?:\n|$))|~{3,})([^\n]*)(?:\n|$)(?:|([\s\S]*?)(?:\n|$))(?: {0,3}\1[~`]* *(?=\n|$)|$)/,P=/^ {0,3}((?:-[\t ]*){3,}|(?:_[ \t]*){3,}|(?:\*[ \t]*){3,})(?:\n+|$)/,ht=/^ {0,3}(#{1,6})(?=\s|$)(.*)(?:\n+|$)/,re=/(?:[*+-]|\d{1,9}[.)])/,Ne=/^(?!bull |blockCode|fences|blockquote|heading|html|table)((?:.|\n(?!\s*?\n|bull |blockCode|fences|blockquote|heading|html|table))+?)\n {0,3}(=+|-+) *(?:\n+|$)/,De=d(Ne).replace(/bull/g,re).replace(/blockCode/g,/(?: {4}| {0,3}\t)/).replace(/fences/g,/ {0,3}(?:`{3,}|~{3,})/).replace(/blockquote/g,/ {0,3}>/).replace(/heading/g,/ {0,3}#{1,6}/).replace(/html/g,/ {0,3}<[^\n>]+>\n/).replace(/\|table/g,"").getRegex(),dt=d(Ne).replace(/bull/g,re).replace(/blockCode/g,/(?: {4}| {0,3}\t)/).replace(/fences/g,/ {0,3}(?:`{3,}|~{3,})/).replace(/blockquote/g,/ {0,3}>/).replace(/heading/g,/ {0,3}#{1,6}/).replace(/html/g,/ {0,3}<[^\n>]+>\n/).replace(/table/g,/ {0,3}\|?(?:[:\- ]*\|)+[\:\- ]*\n/).getRegex(),oe=/^([^\n]+(?:\n(?!hr|heading|lheading|blockquote|fences|list|html|table| +\n)[^\n]+)*)/,ft=/^[^\n]+/,ae=/(?!\s*\])(?:\\.|[^\[\]\\])+/,gt=d(/^ {0,3}\[(label)\]: *(?:\n[ \t]*)?([^<\s][^\s]*|<.*?>)(?:(?: +(?:\n[ \t]*)?| *\n[ \t]*)(title))? *(?:\n+|$)/).replace("label",ae).replace("title",/(?:"(?:\\"?|[^"\\])*"|'[^'\n]*(?:\n[^'\n]+)*\n?'|\([^()]*\))/).getRegex(),kt=d(/^( {0,3}bull)([ \t][^\n]+?)?(?:\n|$)/).replace(/bull/g,re).getRegex(),F="address|article|aside|base|basefont|blockquote|body|caption|center|col|colgroup|dd|details|dialog|dir|div|dl|dt|fieldset|figcaption|figure|footer|form|frame|frameset|h[1-6]|head|header|hr|html|iframe|legend|li|link|main|menu|menuitem|meta|nav|noframes|ol|optgroup|option|p|param|search|section|summary|table|tbody|td|tfoot|th|thead|title|tr|track|ul",le=/<!--(?:-?>|[\s\S]*?(?:-->|$))/,mt=d("^ {0,3}(?:<(script|pre|style|textarea)[\\s>][\\s\\S]*?(?:</\\1>[^\\n]*\\n+|$)|comment[^\\n]*(\\n+|$)|<\\?[\\s\\S]*?(?:\\?>\\n*|$)|<![A-Z][\\s\\S]*?(?:>\\n*|$)|<!\\[CDATA\\[[\\s\\S]*?(?:\\]\\]>\\n*|$)|</?(tag)(?: +|\\n|/?>)[\\s\\S]*?(?:(?:\\n[ 	]*)+\\n|$)|<(?!script|pre|style|textarea)([a-z][\\w-]*)(?:attribute)*? */?>(?=[ \\t]*(?:\\n|$))[\\s\\S]*?(?:(?:\\n[ 	]*)+\\n|$)|</(?!script|pre|style|textarea)[a-z][\\w-]*\\s*>(?=[ \\t]*(?:\\n|$))[\\s\\S]*?(?:(?:\\n[ 	]*)+\\n|$))","i").replace("comment",le).replace("tag",F).replace("attribute",/ +[a-zA-Z:_][\w.:-]*(?: *= *"[^"\n]*"| *= *'[^'\n]*'| *= *[^\s"'=<>`]+)?/).getRegex(),He=d(oe).replace("hr",P).replace("heading"," {0,3}#{1,6}(?:\\s|$)").replace("|lheading","").replace("|table","").replace("blockquote"," {0,3}>").replace("fences"," {0,3}(?:`{3,}(?=[^`\\n]*\\n)|~{3,})[^\\n]*\\n").replace("list"," {0,3}(?:[*+-]|1[.)]) ").replace("html","</?(?:tag)(?: +|\\n|/?>)|<(?:script|pre|style|textarea|!--)").replace("tag",F).getRegex(),bt=d(/^( {0,3}> ?(paragraph|[^\n]*)(?:\n|$))+/).replace("paragraph",He).getRegex(),ce={blockquote:bt,code:ut,def:gt,fences:pt,heading:ht,hr:P,html:mt,lheading:De,list:kt,newline:ct,paragraph:He,table:I,text:ft},Ce=d("^ *([^\\n ].*)\\n {0,3}((?:\\| *)?:?-+:? *(?:\\| *:?-+:? *)*(?:\\| *)?)(?:\\n((?:(?! *\\n|hr|heading|blockquote|code|fences|list|html).*(?:\\n|$))*)\\n*|$)").replace("hr",P).replace("heading"," {0,3}#{1,6}(?:\\s|$)").replace("blockquote"," {0,3}>").replace("code","(?: {4}| {0,3}	)[^\\n]").replace("fences"," {0,3}(?:`{3,}(?=[^`\\n]*\\n)|~{3,})[^\\n]*\\n").replace("list"," {0,3}(?:[*+-]|1[.)]) ").replace("html","</?(?:tag)(?: +|\\n|/?>)|<(?:script|pre|style|textarea|!--)").replace("tag",F).getRegex(),xt={...ce,lheading:dt,table:Ce,paragraph:d(oe).replace("hr",P).replace("heading"," {0,3}#{1,6}(?:\\s|$)").replace("|lheading","").replace("table",Ce).replace("blockquote"," {0,3}>").replace("fences"," {0,3}(?:`{3,}(?=[^`\\n]*\\n)|~{3,})[^\\n]*\\n").replace("list"," {0,3}(?:[*+-]|1[.)]) ").replace("html","</?(?:tag)(?: +|\\n|/?>)|<(?:script|pre|style|textarea|!--)").replace("tag",F).getRegex()},vt={...ce,html:d(`^ *(?:comment *(?:\\n|\\s*$)|<(tag)[\\s\\S]+?</\\1> *(?:\\n{2,}|\\s*$)|<tag(?:"[^"]*"|'[^']*'|\\s[^'"/>\\s]*)*?/?> *(?:\\n{2,}|\\s*$))`).replace("comment",le).replace(/tag/g,"(?!(?:a|em|strong|small|s|cite|q|dfn|abbr|data|time|code|var|samp|kbd|sub|sup|i|b|u|mark|ruby|rt|rp|bdi|bdo|span|br|wbr|ins|del|img)\\b)\\w+(?!:|[^\\w\\s@]*@)\\b").getRegex(),def:/^ *\[([^\]]+)\]: *<?([^\s>]+)>?(?: +(["(][^\n]+[")]))? *(?:\n+|$)/,heading:/^(#{1,6})(.*)(?:\n+|$)/,fences:I,lheading:/^(.+?)\n {0,3}(=+|-+) *(?:\n+|$)/,paragraph:d(oe).replace("hr",P).replace("heading",` *#{1,6} *[^
 ]`).replace("lheading",De).replace("|table","").replace("blockquote"," {0,3}>").replace("|fences","").replace("|list","").replace("|html","").replace("|tag","").getRegex()},wt=/^\\([!"#$%&'()*+,\-./:;<=>?@\[\]\\^_`{|}~])/,yt=/^(`+)([^`]|[^`][\s\S]*?[^`])\1(?!`)/,Oe=/^( {2,}|\\)\n(?!\s*$)/,St=/^(`+|[^`])(?:(?= {2,}\n)|[\s\S]*?(?:(?=[\\<!\[`*_]|\b_|$)|[^ ](?= {2,}\n)))/,W=/[\p{P}\p{S}]/u,ue=/[\s\p{P}\p{S}]/u,Ge=/[^\s\p{P}\p{S}]/u,Tt=d(/^((?![*_])punctSpace)/,"u").replace(/punctSpace/g,ue).getRegex(),je=/(?!~)[\p{P}\p{S}]/u,Rt=/(?!~)[\s\p{P}\p{S}]/u,_t=/(?:[^\s\p{P}\p{S}]|~)/u,Et=/\[[^[\]]*?\]\((?:\\.|[^\\\(\)]|\((?:\\.|[^\\\(\)])*\))*\)|`[^`]*?`|<[^<>]*?>/g,Ze=/^(?:\*+(?:((?!\*)punct)|[^\s*]))|^_+(?:((?!_)punct)|([^\s_]))/,$t=d(Ze,"u").replace(/punct/g,W).getRegex(),Lt=d(Ze,"u").replace(/punct/g,je).getRegex(),Qe="^[^_*]*?__[^_*]*?\\*[^_*]*?(?=__)|[^*]+(?=[^*])|(?!\\*)punct(\\*+)(?=[\\s]|$)|notPunctSpace(\\*+)(?!\\*)(?=punctSpace|$)|(?!\\*)punctSpace(\\*+)(?=notPunctSpace)|[\\s](\\*+)(?!\\*)(?=punct)|(?!\\*)punct(\\*+)(?!\\*)(?=punct)|notPunctSpace(\\*+)(?=notPunctSpace)",At=d(Qe,"gu").replace(/notPunctSpace/g,Ge).replace(/punctSpace/g,ue).replace(/punct/g,W).getRegex(),zt=d(Qe,"gu").replace(/notPunctSpace/g,_t).replace(/punctSpace/g,Rt).replace(/punct/g,je).getRegex(),Ct=d("^[^_*]*?\\*\\*[^_*]*?_[^_*]*?(?=\\*\\*)|[^_]+(?=[^_])|(?!_)punct(_+)(?=[\\s]|$)|notPunctSpace(_+)(?!_)(?=punctSpace|$)|(?!_)punctSpace(_+)(?=notPunctSpace)|[\\s](_+)(?!_)(?=punct)|(?!_)punct(_+)(?!_)(?=punct)","gu").replace(/notPunctSpace/g,Ge).replace(/punctSpace/g,ue).replace(/punct/g,W).getRegex(),It=d(/\\(punct)/,"gu").replace(/punct/g,W).getRegex(),Pt=d(/^<(scheme:[^\s\x00-\x1f<>]*|email)>/).replace("scheme",/[a-zA-Z][a-zA-Z0-9+.-]{1,31}/).replace("email",/[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+(@)[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)+(?![-_])/).getRegex(),Bt=d(le).replace("(?:-->|$)","-->").getRegex(),Mt=d("^comment|^</[a-zA-Z][\\w:-]*\\s*>|^<[a-zA-Z][\\w-]*(?:attribute)*?\\s*/?>|^<\\?[\\s\\S]*?\\?>|^<![a-zA-Z]+\\s[\\s\\S]*?>|^<!\\[CDATA\\[[\\s\\S]*?\\]\\]>").replace("comment",Bt).replace("attribute",/\s+[a-zA-Z:_][\w.:-]*(?:\s*=\s*"[^"]*"|\s*=\s*'[^']*'|\s*=\s*[^\s"'=<>`]+)?/).getRegex(),j=/(?:\[(?:\\.|[^\[\]\\])*\]|\\.|`[^`]*`|[^\[\]\\`])*?/,qt=d(/^!?\[(label)\]\(\s*(href)(?:(?:[ \t]*(?:\n[ \t]*)?)(title))?\s*\)/).replace("label",j).replace("href",/<(?:\\.|[^\n<>\\])+>|[^ \t\n\x00-\x1f]*/).replace("title",/"(?:\\"?|[^"\\])*"|'(?:\\'?|[^'\\])*'|\((?:\\\)?|[^)\\])*\)/).getRegex(),Fe=d(/^!?\[(label)\]\[(ref)\]/).replace("label",j).replace("ref",ae).getRegex(),We=d(/^!?\[(ref)\](?:\[\])?/).replace("ref",ae).getRegex(),Nt=d("reflink|nolink(?!\\()","g").replace("reflink",Fe).replace("nolink",We).getRegex(),pe={_backpedal:I,anyPunctuation:It,autolink:Pt,blockSkip:Et,br:Oe,code:yt,del:I,emStrongLDelim:$t,emStrongRDelimAst:At,emStrongRDelimUnd:Ct,escape:wt,link:qt,nolink:We,punctuation:Tt,reflink:Fe,reflinkSearch:Nt,tag:Mt,text:St,url:I},Dt={...pe,link:d(/^!?\[(label)\]\((.*?)\)/).replace("label",j).getRegex(),reflink:d(/^!?\[(label)\]\s*\[([^\]]*)\]/).replace("label",j).getRegex()},te={...pe,emStrongRDelimAst:zt,emStrongLDelim:Lt,url:d(/^((?:ftp|https?):\/\/|www\.)(?:[a-zA-Z0-9\-]+\.?)+[^\s<]*|^email/,"i").replace("email",/[A-Za-z0-9._+-]+(@)[a-zA-Z0-9-_]+(?:\.[a-zA-Z0-9-_]*[a-zA-Z0-9])+(?![-_])/).getRegex(),_backpedal:/(?:[^?!.,:;*_'"~()&]+|\([^)]*\)|&(?![a-zA-Z0-9]+;$)|[?!.,:;*_'"~)]+(?!$))+/,del:/^(~~?)(?=[^\s~])((?:\\.|[^\\])*?(?:\\.|[^\s~\\]))\1(?=[^~]|$)/,text:/^([`~]+|[^`~])(?:(?= {2,}\n)|(?=[a-zA-Z0-9.!#$%&'*+\/=?_`{\|}~-]+@)|[\s\S]*?(?:(?=[\\<!\[`*~_]|\b_|https?:\/\/|ftp:\/\/|www\.|$)|[^ ](?= {2,}\n)|[^a-zA-Z0-9.!#$%&'*+\/=?_`{\|}~-](?=[a-zA-Z0-9.!#$%&'*+\/=?_`{\|}~-]+@)))/},Ht={...te,br:d(Oe).replace("{2,}","*").getRegex(),text:d(te.text).replace("\\b_","\\b_| {2,}\\n").replace(/\{2,\}/g,"*").getRegex()},O={normal:ce,gfm:xt,pedantic:vt},z={normal:pe,gfm:te,breaks:Ht,pedantic:Dt},Ot={"&":"&amp;","<":"&lt;",">":"&gt;",'"':"&quot;","'":"&#39;"},Ie=t=>Ot[t];function w(t,e){if(e){if(x.escapeTest.test(t))return t.replace(x.escapeReplace,Ie)}else if(x.escapeTestNoEncode.test(t))return t.replace(x.escapeReplaceNoEncode,Ie);return t}function Pe(t){try{t=encodeURI(t).replace(x.percentDecode,"%")}catch{return null}return t}function Be(t,e){let n=t.replace(x.findPipe,(r,o,a)=>{let l=!1,c=o;for(;--c>=0&&a[c]==="\\";)l=!l;return l?"|":" |"}),i=n.split(x.splitPipe),s=0;if(i[0].trim()||i.shift(),i.length>0&&!i.at(-1)?.trim()&&i.pop(),e)if(i.length>e)i.splice(e);else for(;i.length<e;)i.push("");for(;s<i.length;s++)i[s]=i[s].trim().replace(x.slashPipe,"|");return i}function C(t,e,n){let i=t.length;if(i===0)return"";let s=0;for(;s<i;){let r=t.charAt(i-s-1);if(r===e&&!n)s++;else if(r!==e&&n)s++;else break}return t.slice(0,i-s)}function Gt(t,e){if(t.indexOf(e[1])===-1)return-1;let n=0;for(let i=0;i<t.length;i++)if(t[i]==="\\")i++;else if(t[i]===e[0])n++;else if(t[i]===e[1]&&(n--,n<0))return i;return n>0?-2:-1}function Me(t,e,n,i,s){let r=e.href,o=e.title||null,a=t[1].replace(s.other.outputLinkReplace,"$1");i.state.inLink=!0;let l={type:t[0].charAt(0)==="!"?"image":"link",raw:n,href:r,title:o,text:a,tokens:i.inlineTokens(a)};return i.state.inLink=!1,l}function jt(t,e,n){let i=t.match(n.other.indentCodeCompensation);if(i===null)return e;let s=i[1];return e.split(`
 `).map(r=>{let o=r.match(n.other.beginningSpace);if(o===null)return r;let[a]=o;return a.length>=s.length?r.slice(s.length):r}).join(`
 `)}var Z=class{constructor(t){f(this,"options");f(this,"rules");f(this,"lexer");this.options=t||_}space(t){let e=this.rules.block.newline.exec(t);if(e&&e[0].length>0)return{type:"space",raw:e[0]}}code(t){let e=this.rules.block.code.exec(t);if(e){let n=e[0].replace(this.rules.other.codeRemoveIndent,"");return{type:"code",raw:e[0],codeBlockStyle:"indented",text:this.options.pedantic?n:C(n,`
@@ -53,4 +53,4 @@ ${t}</tr>
 `}tablecell(t){let e=this.parser.parseInline(t.tokens),n=t.header?"th":"td";return(t.align?`<${n} align="${t.align}">`:`<${n}>`)+e+`</${n}>
 `}strong({tokens:t}){return`<strong>${this.parser.parseInline(t)}</strong>`}em({tokens:t}){return`<em>${this.parser.parseInline(t)}</em>`}codespan({text:t}){return`<code>${w(t,!0)}</code>`}br(t){return"<br>"}del({tokens:t}){return`<del>${this.parser.parseInline(t)}</del>`}link({href:t,title:e,tokens:n}){let i=this.parser.parseInline(n),s=Pe(t);if(s===null)return i;t=s;let r='<a href="'+t+'"';return e&&(r+=' title="'+w(e)+'"'),r+=">"+i+"</a>",r}image({href:t,title:e,text:n,tokens:i}){i&&(n=this.parser.parseInline(i,this.parser.textRenderer));let s=Pe(t);if(s===null)return w(n);t=s;let r=`<img src="${t}" alt="${n}"`;return e&&(r+=` title="${w(e)}"`),r+=">",r}text(t){return"tokens"in t&&t.tokens?this.parser.parseInline(t.tokens):"escaped"in t&&t.escaped?t.text:w(t.text)}},he=class{strong({text:t}){return t}em({text:t}){return t}codespan({text:t}){return t}del({text:t}){return t}html({text:t}){return t}text({text:t}){return t}link({text:t}){return""+t}image({text:t}){return""+t}br(){return""}},T=class se{constructor(e){f(this,"options");f(this,"renderer");f(this,"textRenderer");this.options=e||_,this.options.renderer=this.options.renderer||new Q,this.renderer=this.options.renderer,this.renderer.options=this.options,this.renderer.parser=this,this.textRenderer=new he}static parse(e,n){return new se(n).parse(e)}static parseInline(e,n){return new se(n).parseInline(e)}parse(e,n=!0){let i="";for(let s=0;s<e.length;s++){let r=e[s];if(this.options.extensions?.renderers?.[r.type]){let a=r,l=this.options.extensions.renderers[a.type].call({parser:this},a);if(l!==!1||!["space","hr","heading","code","table","blockquote","list","html","paragraph","text"].includes(a.type)){i+=l||"";continue}}let o=r;switch(o.type){case"space":{i+=this.renderer.space(o);continue}case"hr":{i+=this.renderer.hr(o);continue}case"heading":{i+=this.renderer.heading(o);continue}case"code":{i+=this.renderer.code(o);continue}case"table":{i+=this.renderer.table(o);continue}case"blockquote":{i+=this.renderer.blockquote(o);continue}case"list":{i+=this.renderer.list(o);continue}case"html":{i+=this.renderer.html(o);continue}case"paragraph":{i+=this.renderer.paragraph(o);continue}case"text":{let a=o,l=this.renderer.text(a);for(;s+1<e.length&&e[s+1].type==="text";)a=e[++s],l+=`
 `+this.renderer.text(a);n?i+=this.renderer.paragraph({type:"paragraph",raw:l,text:l,tokens:[{type:"text",raw:l,text:l,escaped:!0}]}):i+=l;continue}default:{let a='Token with "'+o.type+'" type was not found.';if(this.options.silent)return console.error(a),"";throw new Error(a)}}}return i}parseInline(e,n=this.renderer){let i="";for(let s=0;s<e.length;s++){let r=e[s];if(this.options.extensions?.renderers?.[r.type]){let a=this.options.extensions.renderers[r.type].call({parser:this},r);if(a!==!1||!["escape","html","link","image","strong","em","codespan","br","del","text"].includes(r.type)){i+=a||"";continue}}let o=r;switch(o.type){case"escape":{i+=n.text(o);break}case"html":{i+=n.html(o);break}case"link":{i+=n.link(o);break}case"image":{i+=n.image(o);break}case"strong":{i+=n.strong(o);break}case"em":{i+=n.em(o);break}case"codespan":{i+=n.codespan(o);break}case"br":{i+=n.br(o);break}case"del":{i+=n.del(o);break}case"text":{i+=n.text(o);break}default:{let a='Token with "'+o.type+'" type was not found.';if(this.options.silent)return console.error(a),"";throw new Error(a)}}}return i}},ee,G=(ee=class{constructor(t){f(this,"options");f(this,"block");this.options=t||_}preprocess(t){return t}postprocess(t){return t}processAllTokens(t){return t}provideLexer(){return this.block?S.lex:S.lexInline}provideParser(){return this.block?T.parse:T.parseInline}},f(ee,"passThroughHooks",new Set(["preprocess","postprocess","processAllTokens"])),ee),Zt=class{constructor(...t){f(this,"defaults",ie());f(this,"options",this.setOptions);f(this,"parse",this.parseMarkdown(!0));f(this,"parseInline",this.parseMarkdown(!1));f(this,"Parser",T);f(this,"Renderer",Q);f(this,"TextRenderer",he);f(this,"Lexer",S);f(this,"Tokenizer",Z);f(this,"Hooks",G);this.use(...t)}walkTokens(t,e){let n=[];for(let i of t)switch(n=n.concat(e.call(this,i)),i.type){case"table":{let s=i;for(let r of s.header)n=n.concat(this.walkTokens(r.tokens,e));for(let r of s.rows)for(let o of r)n=n.concat(this.walkTokens(o.tokens,e));break}case"list":{let s=i;n=n.concat(this.walkTokens(s.items,e));break}default:{let s=i;this.defaults.extensions?.childTokens?.[s.type]?this.defaults.extensions.childTokens[s.type].forEach(r=>{let o=s[r].flat(1/0);n=n.concat(this.walkTokens(o,e))}):s.tokens&&(n=n.concat(this.walkTokens(s.tokens,e)))}}return n}use(...t){let e=this.defaults.extensions||{renderers:{},childTokens:{}};return t.forEach(n=>{let i={...n};if(i.async=this.defaults.async||i.async||!1,n.extensions&&(n.extensions.forEach(s=>{if(!s.name)throw new Error("extension name required");if("renderer"in s){let r=e.renderers[s.name];r?e.renderers[s.name]=function(...o){let a=s.renderer.apply(this,o);return a===!1&&(a=r.apply(this,o)),a}:e.renderers[s.name]=s.renderer}if("tokenizer"in s){if(!s.level||s.level!=="block"&&s.level!=="inline")throw new Error("extension level must be 'block' or 'inline'");let r=e[s.level];r?r.unshift(s.tokenizer):e[s.level]=[s.tokenizer],s.start&&(s.level==="block"?e.startBlock?e.startBlock.push(s.start):e.startBlock=[s.start]:s.level==="inline"&&(e.startInline?e.startInline.push(s.start):e.startInline=[s.start]))}"childTokens"in s&&s.childTokens&&(e.childTokens[s.name]=s.childTokens)}),i.extensions=e),n.renderer){let s=this.defaults.renderer||new Q(this.defaults);for(let r in n.renderer){if(!(r in s))throw new Error(`renderer '${r}' does not exist`);if(["options","parser"].includes(r))continue;let o=r,a=n.renderer[o],l=s[o];s[o]=(...c)=>{let u=a.apply(s,c);return u===!1&&(u=l.apply(s,c)),u||""}}i.renderer=s}if(n.tokenizer){let s=this.defaults.tokenizer||new Z(this.defaults);for(let r in n.tokenizer){if(!(r in s))throw new Error(`tokenizer '${r}' does not exist`);if(["options","rules","lexer"].includes(r))continue;let o=r,a=n.tokenizer[o],l=s[o];s[o]=(...c)=>{let u=a.apply(s,c);return u===!1&&(u=l.apply(s,c)),u}}i.tokenizer=s}if(n.hooks){let s=this.defaults.hooks||new G;for(let r in n.hooks){if(!(r in s))throw new Error(`hook '${r}' does not exist`);if(["options","block"].includes(r))continue;let o=r,a=n.hooks[o],l=s[o];G.passThroughHooks.has(r)?s[o]=c=>{if(this.defaults.async)return Promise.resolve(a.call(s,c)).then(g=>l.call(s,g));let u=a.call(s,c);return l.call(s,u)}:s[o]=(...c)=>{let u=a.apply(s,c);return u===!1&&(u=l.apply(s,c)),u}}i.hooks=s}if(n.walkTokens){let s=this.defaults.walkTokens,r=n.walkTokens;i.walkTokens=function(o){let a=[];return a.push(r.call(this,o)),s&&(a=a.concat(s.call(this,o))),a}}this.defaults={...this.defaults,...i}}),this}setOptions(t){return this.defaults={...this.defaults,...t},this}lexer(t,e){return S.lex(t,e??this.defaults)}parser(t,e){return T.parse(t,e??this.defaults)}parseMarkdown(t){return(n,i)=>{let s={...i},r={...this.defaults,...s},o=this.onError(!!r.silent,!!r.async);if(this.defaults.async===!0&&s.async===!1)return o(new Error("marked(): The async option was set to true by an extension. Remove async: false from the parse options object to return a Promise."));if(typeof n>"u"||n===null)return o(new Error("marked(): input parameter is undefined or null"));if(typeof n!="string")return o(new Error("marked(): input parameter is of type "+Object.prototype.toString.call(n)+", string expected"));r.hooks&&(r.hooks.options=r,r.hooks.block=t);let a=r.hooks?r.hooks.provideLexer():t?S.lex:S.lexInline,l=r.hooks?r.hooks.provideParser():t?T.parse:T.parseInline;if(r.async)return Promise.resolve(r.hooks?r.hooks.preprocess(n):n).then(c=>a(c,r)).then(c=>r.hooks?r.hooks.processAllTokens(c):c).then(c=>r.walkTokens?Promise.all(this.walkTokens(c,r.walkTokens)).then(()=>c):c).then(c=>l(c,r)).then(c=>r.hooks?r.hooks.postprocess(c):c).catch(o);try{r.hooks&&(n=r.hooks.preprocess(n));let c=a(n,r);r.hooks&&(c=r.hooks.processAllTokens(c)),r.walkTokens&&this.walkTokens(c,r.walkTokens);let u=l(c,r);return r.hooks&&(u=r.hooks.postprocess(u)),u}catch(c){return o(c)}}}onError(t,e){return n=>{if(n.message+=`
-Please report this to https://github.com/markedjs/marked.`,t){let i="<p>An error occurred:</p><pre>"+w(n.message+"",!0)+"</pre>";return e?Promise.resolve(i):i}if(e)return Promise.reject(n);throw n}}},R=new Zt;function h(t,e){return R.parse(t,e)}h.options=h.setOptions=function(t){return R.setOptions(t),h.defaults=R.defaults,qe(h.defaults),h};h.getDefaults=ie;h.defaults=_;h.use=function(...t){return R.use(...t),h.defaults=R.defaults,qe(h.defaults),h};h.walkTokens=function(t,e){return R.walkTokens(t,e)};h.parseInline=R.parseInline;h.Parser=T;h.parser=T.parse;h.Renderer=Q;h.TextRenderer=he;h.Lexer=S;h.lexer=S.lex;h.Tokenizer=Z;h.Hooks=G;h.parse=h;var en=h.options,tn=h.setOptions,nn=h.use,sn=h.walkTokens,rn=h.parseInline;var on=T.parse,an=S.lex;var fe="",$=[],L="",E=0,de=!1;function U(t,e){let n=document.getElementById("status");n&&(n.textContent=t,n.className="status "+(e?"ok":"err"),e&&setTimeout(()=>{n.className="status"},3e3))}function ge(t){fetch(fe+"/api/respond/"+L,{method:"POST",headers:{"Content-Type":"application/json"},body:JSON.stringify({text:t})}).then(e=>{e.ok?(U("Sent: "+t,!0),document.querySelector(".context")?.remove(),document.querySelector(".quick-buttons")?.remove()):e.text().then(n=>U("Error: "+n,!1))}).catch(e=>U("Network error: "+e,!1))}function Ue(){let t=document.getElementById("text");if(!t)return;let e=t.value.trim();e&&(ge(e),t.value="")}function Ve(t){return h.parse(t)}function Qt(t){let e="";return(t.questions||[]).forEach(i=>{e+='<div class="ask-question">',i.header&&(e+='<div class="question-header">'+b(i.header)+"</div>"),e+='<div class="question-text">'+b(i.question)+"</div>",(i.options||[]).forEach((s,r)=>{e+='<div class="option">',e+='<div class="option-label">'+(r+1)+". "+b(s.label)+"</div>",s.description&&(e+='<div class="option-desc">'+b(s.description)+"</div>"),e+="</div>"}),e+="</div>"}),e}function Je(t){let e="";return(t.blocks||[]).forEach(n=>{if(n.type==="tool_use"&&n.text==="AskUserQuestion"&&n.input)e+=Qt(n.input);else if(n.type==="tool_use"&&n.text==="ExitPlanMode")n.input?.plan?e+='<div class="plan-content">'+Ve(n.input.plan)+"</div>":e+='<div class="tool-use plan-approval">Plan ready for approval</div>';else if(n.type==="tool_use"){let i=n.summary||n.text;e+='<div class="tool-use">'+b(i)+"</div>"}else e+=Ve(n.text)}),e}function Ft(t){for(let e=t.length-1;e>=0;e--)if(t[e].role==="assistant")return(t[e].blocks||[]).some(n=>n.type==="tool_use"&&n.text==="ExitPlanMode");return!1}function Ke(){if(de)return;de=!0,document.querySelector(".quick-buttons")?.remove();let t=document.querySelector(".respond-footer .input-group");if(!t)return;let e=document.createElement("div");e.className="quick-buttons",e.innerHTML='<button class="btn-plan-clear" data-send="1">Clear ctx & approve</button><button class="btn-plan-approve" data-send="2">Approve</button><button class="btn-plan-manual" data-send="3">Review edits</button>',t.before(e),e.querySelectorAll("[data-send]").forEach(n=>{n.addEventListener("click",()=>ge(n.getAttribute("data-send")))})}function Xe(){fetch(fe+"/api/sessions/"+L+"/transcript").then(t=>t.json()).then(t=>{let e=document.getElementById("conversation");if(!e)return;let n=t.messages||[];if(n.length!==0){if(n.length<E&&(E=0,e.innerHTML=""),E>0&&e.lastElementChild){let i=n[E-1];i&&i.role==="assistant"&&(e.lastElementChild.innerHTML=Je(i))}for(let i=E;i<n.length;i++){let s=n[i],r=s.role==="user"?"user":"assistant",o=document.createElement("div");o.className="msg "+r,o.innerHTML=Je(s),e.appendChild(o)}E=n.length,e.scrollTop=e.scrollHeight,Ft(n)&&Ke()}}).catch(()=>{})}function Ye(t,e){L=t.id,document.body.dataset.page="respond",fetch(fe+"/api/sessions/"+L).then(s=>{if(!s.ok)throw new Error("not found");return s.json()}).then(s=>{let r=document.getElementById("app"),o=s.notification_type==="permission_prompt",a='<div class="respond-view">';a+='<div class="respond-header">',a+='<div class="respond-title">'+b(s.project)+"</div>";let l="Started "+D(s.started_at);s.node_name&&(l+=" \xB7 "+b(s.node_name)),a+='<div class="respond-meta">'+l+"</div>",a+="</div>",a+='<div id="conversation"></div>',a+='<div class="respond-footer">',s.notify_message&&(a+='<div class="context">'+b(s.notify_message)+"</div>"),a+='<div id="status" class="status"></div>',o&&(a+='<div class="quick-buttons">',a+='<button class="btn-allow" data-send="y">Allow</button>',a+='<button class="btn-allow-all" data-send="a">Always</button>',a+='<button class="btn-deny" data-send="n">Deny</button>',a+="</div>"),a+='<div class="input-group">',a+='<input type="text" id="text" placeholder="Type a response...">',a+='<button id="send-btn">Send</button>',a+="</div>",a+="</div>",a+="</div>",r.innerHTML=a,r.querySelectorAll("[data-send]").forEach(p=>{p.addEventListener("click",()=>ge(p.getAttribute("data-send")))}),document.getElementById("send-btn")?.addEventListener("click",Ue);let g=document.getElementById("text");g?.addEventListener("keydown",p=>{p.key==="Enter"&&Ue()}),g?.focus(),s.plan_text&&Ke(),Xe()}).catch(()=>{let s=document.getElementById("app");s.innerHTML='<div class="index-empty"><div class="index-empty-hint">Session not found</div></div>'});let n=N(Xe,500),i=s=>{JSON.parse(s.data).session_id===L&&n()};$.push(e.on("notification",i)),$.push(e.on("activity",i)),$.push(e.on("response",i)),$.push(e.on("tool_activity",i)),$.push(e.on("session_end",s=>{JSON.parse(s.data).session_id===L&&U("Session ended",!0)}))}function et(){for(let t of $)t();$=[],L="",E=0,de=!1}var B=new M("/api/events");function Ut(){if(!("Notification"in window)||Notification.permission==="granted")return;let t=document.getElementById("notif-pill-slot");if(!t)return;let e=document.createElement("div");e.id="notif-pill",e.className="notif-pill",Notification.permission==="default"?(e.textContent="Enable notifications",e.addEventListener("click",async()=>{await Notification.requestPermission()==="granted"?e.remove():(e.textContent="Notifications blocked \u2014 check browser settings",e.classList.add("notif-pill-denied"),e.style.cursor="default")})):(e.textContent="Notifications blocked \u2014 check browser settings",e.classList.add("notif-pill-denied"),e.style.cursor="default"),t.appendChild(e)}function Vt(t,e){if(!("Notification"in window)||Notification.permission!=="granted")return;let n=e.title||"sophon",i=e.message||"",s=new Notification(n,{body:i,tag:"sophon-"+t});s.onclick=()=>{window.focus(),Y("/respond/"+t),s.close()}}B.on("notification",t=>{let e=JSON.parse(t.data),n=e.data||{};Vt(e.session_id,n)});ye(t=>{let e=t.match(/^\/respond\/(.+)$/);$e(e?e[1]:"")});K("/",t=>Ae(t,B),ze);K("/respond/:id",t=>Ye(t,B),et);Le(B);Ut();B.connect();Se();})();
+Please report this to https://github.com/markedjs/marked.`,t){let i="<p>An error occurred:</p><pre>"+w(n.message+"",!0)+"</pre>";return e?Promise.resolve(i):i}if(e)return Promise.reject(n);throw n}}},R=new Zt;function h(t,e){return R.parse(t,e)}h.options=h.setOptions=function(t){return R.setOptions(t),h.defaults=R.defaults,qe(h.defaults),h};h.getDefaults=ie;h.defaults=_;h.use=function(...t){return R.use(...t),h.defaults=R.defaults,qe(h.defaults),h};h.walkTokens=function(t,e){return R.walkTokens(t,e)};h.parseInline=R.parseInline;h.Parser=T;h.parser=T.parse;h.Renderer=Q;h.TextRenderer=he;h.Lexer=S;h.lexer=S.lex;h.Tokenizer=Z;h.Hooks=G;h.parse=h;var en=h.options,tn=h.setOptions,nn=h.use,sn=h.walkTokens,rn=h.parseInline;var on=T.parse,an=S.lex;var fe="",$=[],L="",E=0,de=!1;function U(t,e){let n=document.getElementById("status");n&&(n.textContent=t,n.className="status "+(e?"ok":"err"),e&&setTimeout(()=>{n.className="status"},3e3))}function ge(t){fetch(fe+"/api/respond/"+L,{method:"POST",headers:{"Content-Type":"application/json"},body:JSON.stringify({text:t})}).then(e=>{e.ok?(U("Sent: "+t,!0),document.querySelector(".context")?.remove(),document.querySelector(".quick-buttons")?.remove()):e.json().then(n=>U("Error: "+(n.error?.message||e.statusText),!1),()=>U("Error: "+e.statusText,!1))}).catch(e=>U("Network error: "+e,!1))}function Ue(){let t=document.getElementById("text");if(!t)return;let e=t.value.trim();e&&(ge(e),t.value="")}function Ve(t){return h.parse(t)}function Qt(t){let e="";return(t.questions||[]).forEach(i=>{e+='<div class="ask-question">',i.header&&(e+='<div class="question-header">'+b(i.header)+"</div>"),e+='<div class="question-text">'+b(i.question)+"</div>",(i.options||[]).forEach((s,r)=>{e+='<div class="option">',e+='<div class="option-label">'+(r+1)+". "+b(s.label)+"</div>",s.description&&(e+='<div class="option-desc">'+b(s.description)+"</div>"),e+="</div>"}),e+="</div>"}),e}function Je(t){let e="";return(t.blocks||[]).forEach(n=>{if(n.type==="tool_use"&&n.text==="AskUserQuestion"&&n.input)e+=Qt(n.input);else if(n.type==="tool_use"&&n.text==="ExitPlanMode")n.input?.plan?e+='<div class="plan-content">'+Ve(n.input.plan)+"</div>":e+='<div class="tool-use plan-approval">Plan ready for approval</div>';else if(n.type==="tool_use"){let i=n.summary||n.text,s=n.has_image_result?' <span class="tool-image">[image]</span>':"";e+='<div class="tool-use">'+b(i)+s+"</div>"}else e+=Ve(n.text)}),e}function Ft(t){for(let e=t.length-1;e>=0;e--)if(t[e].role==="assistant")return(t[e].blocks||[]).some(n=>n.type==="tool_use"&&n.text==="ExitPlanMode");return!1}function Ke(){if(de)return;de=!0,document.querySelector(".quick-buttons")?.remove();let t=document.querySelector(".respond-footer .input-group");if(!t)return;let e=document.createElement("div");e.className="quick-buttons",e.innerHTML='<button class="btn-plan-clear" data-send="1">Clear ctx & approve</button><button class="btn-plan-approve" data-send="2">Approve</button><button class="btn-plan-manual" data-send="3">Review edits</button>',t.before(e),e.querySelectorAll("[data-send]").forEach(n=>{n.addEventListener("click",()=>ge(n.getAttribute("data-send")))})}function Xe(){fetch(fe+"/api/sessions/"+L+"/transcript").then(t=>t.json()).then(t=>{let e=document.getElementById("conversation");if(!e)return;let n=t.messages||[];if(n.length!==0){if(n.length<E&&(E=0,e.innerHTML=""),E>0&&e.lastElementChild){let i=n[E-1];i&&i.role==="assistant"&&(e.lastElementChild.innerHTML=Je(i))}for(let i=E;i<n.length;i++){let s=n[i],r=s.role==="user"?"user":"assistant",o=document.createElement("div");o.className="msg "+r,o.innerHTML=Je(s),e.appendChild(o)}E=n.length,e.scrollTop=e.scrollHeight,Ft(n)&&Ke()}}).catch(()=>{})}function Ye(t,e){L=t.id,document.body.dataset.page="respond",fetch(fe+"/api/sessions/"+L).then(s=>{if(!s.ok)throw new Error("not found");return s.json()}).then(s=>{let r=document.getElementById("app"),o=s.notification_type==="permission_prompt",a='<div class="respond-view">';a+='<div class="respond-header">',a+='<div class="respond-title">'+b(s.project)+"</div>";let l="Started "+D(s.started_at);s.node_name&&(l+=" \xB7 "+b(s.node_name)),a+='<div class="respond-meta">'+l+"</div>",a+="</div>",a+='<div id="conversation"></div>',a+='<div class="respond-footer">',s.notify_message&&(a+='<div class="context">'+b(s.notify_message)+"</div>"),a+='<div id="status" class="status"></div>',o&&(a+='<div class="quick-buttons">',a+='<button class="btn-allow" data-send="y">Allow</button>',a+='<button class="btn-allow-all" data-send="a">Always</button>',a+='<button class="btn-deny" data-send="n">Deny</button>',a+="</div>"),a+='<div class="input-group">',a+='<input type="text" id="text" placeholder="Type a response...">',a+='<button id="send-btn">Send</button>',a+="</div>",a+="</div>",a+="</div>",r.innerHTML=a,r.querySelectorAll("[data-send]").forEach(p=>{p.addEventListener("click",()=>ge(p.getAttribute("data-send")))}),document.getElementById("send-btn")?.addEventListener("click",Ue);let g=document.getElementById("text");g?.addEventListener("keydown",p=>{p.key==="Enter"&&Ue()}),g?.focus(),s.plan_text&&Ke(),Xe()}).catch(()=>{let s=document.getElementById("app");s.innerHTML='<div class="index-empty"><div class="index-empty-hint">Session not found</div></div>'});let n=N(Xe,500),i=s=>{JSON.parse(s.data).session_id===L&&n()};$.push(e.on("notification",i)),$.push(e.on("activity",i)),$.push(e.on("response",i)),$.push(e.on("tool_activity",i)),$.push(e.on("session_end",s=>{JSON.parse(s.data).session_id===L&&U("Session ended",!0)}))}function et(){for(let t of $)t();$=[],L="",E=0,de=!1}var B=new M("/api/events");function Ut(){if(!("Notification"in window)||Notification.permission==="granted")return;let t=document.getElementById("notif-pill-slot");if(!t)return;let e=document.createElement("div");e.id="notif-pill",e.className="notif-pill",Notification.permission==="default"?(e.textContent="Enable notifications",e.addEventListener("click",async()=>{await Notification.requestPermission()==="granted"?e.remove():(e.textContent="Notifications blocked \u2014 check browser settings",e.classList.add("notif-pill-denied"),e.style.cursor="default")})):(e.textContent="Notifications blocked \u2014 check browser settings",e.classList.add("notif-pill-denied"),e.style.cursor="default"),t.appendChild(e)}function Vt(t,e){if(!("Notification"in window)||Notification.permission!=="granted")return;let n=e.title||"sophon",i=e.message||"",s=new Notification(n,{body:i,tag:"sophon-"+t});s.onclick=()=>{window.focus(),Y("/respond/"+t),s.close()}}B.on("notification",t=>{let e=JSON.parse(t.data),n=e.data||{};n.muted!=="true"&&n.quiet!=="true"&&Vt(e.session_id,n)});ye(t=>{let e=t.match(/^\/respond\/(.+)$/);$e(e?e[1]:"")});K("/",t=>Ae(t,B),ze);K("/respond/:id",t=>Ye(t,B),et);Le(B);Ut();B.connect();Se();})();
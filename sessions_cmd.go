@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/phinze/sophon/store"
+)
+
+func runSessions(args []string) error {
+	fs := flag.NewFlagSet("sessions", flag.ExitOnError)
+	dataDir := fs.String("data-dir", defaultDataDir(), "directory holding the daemon's SQLite database")
+	recent := fs.Bool("recent", false, "list recently stopped sessions instead of active ones")
+	limit := fs.Int("limit", 20, "maximum number of recent sessions to list")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	dbPath := filepath.Join(*dataDir, "sophon.db")
+	st, err := store.OpenWithOptions(dbPath, store.Options{ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer st.Close()
+
+	return printSessions(os.Stdout, st, *recent, *limit)
+}
+
+// printSessions writes active sessions (or, with recent, the most recently
+// stopped ones) as an aligned table.
+func printSessions(w io.Writer, st *store.Store, recent bool, limit int) error {
+	var sessions []*store.Session
+	var err error
+	if recent {
+		sessions, err = st.ListRecentSessions(limit)
+	} else {
+		sessions, err = st.ListActiveSessions()
+	}
+	if err != nil {
+		return fmt.Errorf("listing sessions: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tPROJECT\tNODE\tSTARTED\tSTOPPED")
+	for _, sess := range sessions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			sess.ID, orDash(sess.Project), orDash(sess.NodeName),
+			formatLocal(sess.StartedAt), formatLocal(sess.StoppedAt))
+	}
+	return tw.Flush()
+}
+
+func formatLocal(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.DateTime)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
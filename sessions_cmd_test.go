@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phinze/sophon/store"
+)
+
+func TestPrintSessions(t *testing.T) {
+	st, err := store.Open(":memory:")
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	defer st.Close()
+
+	now := time.Now()
+	for _, sess := range []*store.Session{
+		{ID: "active-1", Project: "phinze/sophon", NodeName: "laptop", StartedAt: now},
+		{ID: "stopped-1", Project: "phinze/other", StartedAt: now.Add(-time.Hour), StoppedAt: now},
+	} {
+		if err := st.CreateSession(sess); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := printSessions(&buf, st, false, 20); err != nil {
+		t.Fatalf("printSessions: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want header + 1:\n%s", len(lines), buf.String())
+	}
+	if fields := strings.Fields(lines[0]); strings.Join(fields, " ") != "ID PROJECT NODE STARTED STOPPED" {
+		t.Errorf("header = %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "active-1") || !strings.Contains(lines[1], "phinze/sophon") || !strings.Contains(lines[1], "laptop") {
+		t.Errorf("row = %q", lines[1])
+	}
+	if !strings.HasSuffix(lines[1], "-") {
+		t.Errorf("active session should show no stop time: %q", lines[1])
+	}
+
+	buf.Reset()
+	if err := printSessions(&buf, st, true, 20); err != nil {
+		t.Fatalf("printSessions(recent): %v", err)
+	}
+	if !strings.Contains(buf.String(), "stopped-1") || strings.Contains(buf.String(), "active-1") {
+		t.Errorf("recent listing should only include stopped sessions:\n%s", buf.String())
+	}
+}
@@ -2,23 +2,31 @@ package store
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"strings"
 	"time"
 
+	"github.com/phinze/sophon/clock"
+
 	_ "modernc.org/sqlite"
 )
 
-const currentSchemaVersion = 6
-
 // ErrNotFound is returned when a session is not found.
 var ErrNotFound = errors.New("session not found")
 
+// sessionColumns is the column list shared by every session SELECT; it must
+// stay in sync with scanSession.
+const sessionColumns = `id, tmux_pane, cwd, project, node_name, started_at, stopped_at, last_activity_at,
+		notification_type, notify_title, notify_message, notified_at, topic, plan_summary, pane_title, plan_text, transcript_path,
+		pinned, topic_locked, last_reply, muted, busy, window_name, pending_question, error_count, stop_reason, extra_panes`
+
 // Session represents a supported coding-agent session.
 type Session struct {
 	ID             string    `json:"session_id"`
-	TmuxPane       string    `json:"tmux_pane"`
+	TmuxPane       string    `json:"tmux_pane"` // "%N" pane id from TMUX_PANE, never a session:window.pane target
 	Cwd            string    `json:"cwd"`
 	Project        string    `json:"project"`
 	NodeName       string    `json:"node_name"`
@@ -36,6 +44,12 @@ type Session struct {
 	Topic       string `json:"topic,omitempty"`
 	PlanSummary string `json:"plan_summary,omitempty"`
 
+	// TopicLocked marks Topic as user-set; summary extraction leaves it alone.
+	TopicLocked bool `json:"topic_locked,omitempty"`
+
+	// LastReply is a one-line preview of the latest assistant text.
+	LastReply string `json:"last_reply,omitempty"`
+
 	// Task title parsed from the terminal agent's pane title (e.g. "Migrate blog to Miren")
 	PaneTitle string `json:"pane_title,omitempty"`
 
@@ -45,26 +59,126 @@ type Session struct {
 	// Absolute JSONL transcript path as reported by Claude Code hooks, used to
 	// read the transcript without recomputing the cwd slug.
 	TranscriptPath string `json:"transcript_path,omitempty"`
+
+	// Pinned sessions sort ahead of all others on the dashboard.
+	Pinned bool `json:"pinned,omitempty"`
+
+	// Muted sessions still publish events but don't raise alerts.
+	Muted bool `json:"muted,omitempty"`
+
+	// Busy is set when the agent starts a tool and cleared when its turn
+	// ends, so dashboards can show which sessions are working.
+	Busy bool `json:"busy,omitempty"`
+
+	// WindowName is the tmux window holding the session's pane, captured when
+	// the session registers.
+	WindowName string `json:"window_name,omitempty"`
+
+	// PendingQuestion is the JSON list of questions from an unanswered
+	// AskUserQuestion, so clients can offer its choices without fetching the
+	// transcript.
+	PendingQuestion json.RawMessage `json:"pending_question,omitempty"`
+
+	// ErrorCount is how many of the session's tool calls failed, so
+	// dashboards can flag sessions stuck on denials or broken commands.
+	ErrorCount int `json:"error_count,omitempty"`
+
+	// StopReason records why a stopped session ended, one of the
+	// StopReason constants; empty while active or for sessions stopped
+	// before reasons were tracked.
+	StopReason string `json:"stop_reason,omitempty"`
+
+	// ExtraPanes are further "%N" panes the session can be addressed in,
+	// such as a split running its tests, in order after TmuxPane.
+	ExtraPanes []string `json:"extra_panes,omitempty"`
 }
 
+// Reasons a session stopped, stored in Session.StopReason.
+const (
+	StopReasonUserEnd     = "user_end"     // the SessionEnd hook fired
+	StopReasonSuperseded  = "superseded"   // a new session registered on the same pane
+	StopReasonReconciled  = "reconciled"   // the agent no longer saw claude in the pane
+	StopReasonPaneGone    = "pane_gone"    // a response found the pane closed
+	StopReasonIdleTimeout = "idle_timeout" // no activity on a node without an agent
+)
+
 // Store provides SQLite-backed session persistence.
 type Store struct {
-	db *sql.DB
+	db    *sql.DB
+	clock clock.Clock
 }
 
-// Open opens a SQLite database at the given path, runs migrations, and enables WAL mode.
+// DefaultBusyTimeout is how long a statement waits on a locked database
+// before failing with SQLITE_BUSY.
+const DefaultBusyTimeout = 5 * time.Second
+
+// Options tunes how the database is opened.
+type Options struct {
+	// BusyTimeout is how long a statement waits on a lock held by another
+	// connection; 0 means DefaultBusyTimeout.
+	BusyTimeout time.Duration
+
+	// ReadOnly opens an existing database without migrating it, for
+	// inspecting a store that a running daemon owns.
+	ReadOnly bool
+
+	// Clock stamps migrations and computes reap and idle cutoffs; nil means
+	// the wall clock.
+	Clock clock.Clock
+}
+
+// Open opens a SQLite database at the given path with default options.
 func Open(dbPath string) (*Store, error) {
-	db, err := sql.Open("sqlite", dbPath)
+	return OpenWithOptions(dbPath, Options{})
+}
+
+// OpenWithOptions opens a SQLite database at the given path, runs migrations,
+// and enables WAL mode. A read-only open skips both.
+func OpenWithOptions(dbPath string, opts Options) (*Store, error) {
+	if opts.BusyTimeout <= 0 {
+		opts.BusyTimeout = DefaultBusyTimeout
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.Real{}
+	}
+	// busy_timeout and foreign_keys are per-connection settings, so they go in
+	// the DSN where the driver applies them to every pooled connection.
+	pragmas := url.Values{"_pragma": {
+		fmt.Sprintf("busy_timeout(%d)", opts.BusyTimeout.Milliseconds()),
+		"foreign_keys(1)",
+	}}
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
+	}
+	dsn := dbPath + sep + pragmas.Encode()
+	if opts.ReadOnly {
+		dsn = "file:" + dbPath + "?mode=ro&" + pragmas.Encode()
+	}
+
+	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("opening database: %w", err)
 	}
+	if strings.HasPrefix(dbPath, ":memory:") {
+		// Each connection to :memory: gets its own empty database, so the
+		// pool must stay at the one connection that holds the schema.
+		db.SetMaxOpenConns(1)
+	}
+	if opts.ReadOnly {
+		if err := db.Ping(); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("opening database: %w", err)
+		}
+		return &Store{db: db, clock: opts.Clock}, nil
+	}
 
 	if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("enabling WAL mode: %w", err)
 	}
 
-	s := &Store{db: db}
+	s := &Store{db: db, clock: opts.Clock}
 	if err := s.migrate(); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("running migrations: %w", err)
@@ -72,129 +186,170 @@ func Open(dbPath string) (*Store, error) {
 	return s, nil
 }
 
+// Maintain checkpoints the WAL back into the main database file, truncating
+// it, and then vacuums to reclaim pages freed by reaped sessions. SQLite
+// serializes both against other connections, so it is safe to call while the
+// store is in use; it just briefly blocks writers.
+func (s *Store) Maintain() error {
+	if _, err := s.db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		return fmt.Errorf("checkpointing WAL: %w", err)
+	}
+	if _, err := s.db.Exec(`VACUUM`); err != nil {
+		return fmt.Errorf("vacuuming: %w", err)
+	}
+	return nil
+}
+
 // Close closes the database.
 func (s *Store) Close() error {
 	return s.db.Close()
 }
 
+// migrations holds the schema steps in order: applying migrations[i] brings
+// the database to version i+1. Only append; never edit a step that has shipped.
+var migrations = [][]string{
+	{`CREATE TABLE IF NOT EXISTS sessions (
+		id                TEXT PRIMARY KEY,
+		tmux_pane         TEXT NOT NULL DEFAULT '',
+		cwd               TEXT NOT NULL DEFAULT '',
+		project           TEXT NOT NULL DEFAULT '',
+		started_at        TEXT NOT NULL,
+		stopped_at        TEXT,
+		notification_type TEXT NOT NULL DEFAULT '',
+		notify_title      TEXT NOT NULL DEFAULT '',
+		notify_message    TEXT NOT NULL DEFAULT '',
+		notified_at       TEXT
+	)`},
+	{`ALTER TABLE sessions ADD COLUMN last_activity_at TEXT`},
+	{`ALTER TABLE sessions ADD COLUMN node_name TEXT NOT NULL DEFAULT ''`},
+	{
+		`ALTER TABLE sessions ADD COLUMN topic TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE sessions ADD COLUMN plan_summary TEXT NOT NULL DEFAULT ''`,
+	},
+	{`ALTER TABLE sessions ADD COLUMN pane_title TEXT NOT NULL DEFAULT ''`},
+	{
+		`ALTER TABLE sessions ADD COLUMN plan_text TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE sessions ADD COLUMN transcript_path TEXT NOT NULL DEFAULT ''`,
+	},
+	{`ALTER TABLE sessions ADD COLUMN pinned INTEGER NOT NULL DEFAULT 0`},
+	{`ALTER TABLE sessions ADD COLUMN topic_locked INTEGER NOT NULL DEFAULT 0`},
+	{`ALTER TABLE sessions ADD COLUMN last_reply TEXT NOT NULL DEFAULT ''`},
+	{`ALTER TABLE sessions ADD COLUMN muted INTEGER NOT NULL DEFAULT 0`},
+	{`ALTER TABLE sessions ADD COLUMN busy INTEGER NOT NULL DEFAULT 0`},
+	{`ALTER TABLE sessions ADD COLUMN window_name TEXT NOT NULL DEFAULT ''`},
+	{`ALTER TABLE sessions ADD COLUMN pending_question TEXT NOT NULL DEFAULT ''`},
+	{`ALTER TABLE sessions ADD COLUMN error_count INTEGER NOT NULL DEFAULT 0`},
+	{`ALTER TABLE sessions ADD COLUMN stop_reason TEXT NOT NULL DEFAULT ''`},
+	{`ALTER TABLE sessions ADD COLUMN extra_panes TEXT NOT NULL DEFAULT ''`},
+}
+
+// currentSchemaVersion is the newest schema this build knows how to use.
+var currentSchemaVersion = len(migrations)
+
 func (s *Store) migrate() error {
-	// Create schema_version table if it doesn't exist
-	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)`); err != nil {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`); err != nil {
 		return err
 	}
+	if err := s.adoptLegacyVersion(); err != nil {
+		return fmt.Errorf("converting schema_version: %w", err)
+	}
 
 	var version int
-	err := s.db.QueryRow(`SELECT version FROM schema_version LIMIT 1`).Scan(&version)
-	if err == sql.ErrNoRows {
-		version = 0
-	} else if err != nil {
+	if err := s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
 		return err
 	}
+	if version > currentSchemaVersion {
+		return fmt.Errorf("database schema version %d is newer than this build supports (%d)", version, currentSchemaVersion)
+	}
 
-	if version < 1 {
-		if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS sessions (
-			id                TEXT PRIMARY KEY,
-			tmux_pane         TEXT NOT NULL DEFAULT '',
-			cwd               TEXT NOT NULL DEFAULT '',
-			project           TEXT NOT NULL DEFAULT '',
-			started_at        TEXT NOT NULL,
-			stopped_at        TEXT,
-			notification_type TEXT NOT NULL DEFAULT '',
-			notify_title      TEXT NOT NULL DEFAULT '',
-			notify_message    TEXT NOT NULL DEFAULT '',
-			notified_at       TEXT
-		)`); err != nil {
-			return err
+	for v := version + 1; v <= currentSchemaVersion; v++ {
+		if err := s.applyMigration(v); err != nil {
+			return fmt.Errorf("migration %d: %w", v, err)
 		}
-		version = 1
 	}
+	return nil
+}
+
+// applyMigration runs one schema step and records it in the same transaction.
+func (s *Store) applyMigration(version int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	if version < 2 {
-		if _, err := s.db.Exec(`ALTER TABLE sessions ADD COLUMN last_activity_at TEXT`); err != nil {
+	for _, stmt := range migrations[version-1] {
+		if _, err := tx.Exec(stmt); err != nil {
 			// Column may already exist if migration was partially applied
 			if !strings.Contains(err.Error(), "duplicate column") {
 				return err
 			}
 		}
-		version = 2
 	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`,
+		version, formatTime(s.clock.Now())); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
 
-	if version < 3 {
-		if _, err := s.db.Exec(`ALTER TABLE sessions ADD COLUMN node_name TEXT NOT NULL DEFAULT ''`); err != nil {
-			if !strings.Contains(err.Error(), "duplicate column") {
-				return err
-			}
-		}
-		version = 3
-	}
-
-	if version < 4 {
-		for _, col := range []string{
-			`ALTER TABLE sessions ADD COLUMN topic TEXT NOT NULL DEFAULT ''`,
-			`ALTER TABLE sessions ADD COLUMN plan_summary TEXT NOT NULL DEFAULT ''`,
-		} {
-			if _, err := s.db.Exec(col); err != nil {
-				if !strings.Contains(err.Error(), "duplicate column") {
-					return err
-				}
-			}
-		}
-		version = 4
+// adoptLegacyVersion converts the single-row schema_version table used by
+// earlier builds into schema_migrations rows, then drops it. The original
+// apply times weren't recorded, so the conversion time stands in for them.
+func (s *Store) adoptLegacyVersion() error {
+	var tables int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'`).Scan(&tables); err != nil {
+		return err
+	}
+	if tables == 0 {
+		return nil
 	}
 
-	if version < 5 {
-		if _, err := s.db.Exec(`ALTER TABLE sessions ADD COLUMN pane_title TEXT NOT NULL DEFAULT ''`); err != nil {
-			if !strings.Contains(err.Error(), "duplicate column") {
-				return err
-			}
-		}
-		version = 5
-	}
-
-	if version < 6 {
-		for _, col := range []string{
-			`ALTER TABLE sessions ADD COLUMN plan_text TEXT NOT NULL DEFAULT ''`,
-			`ALTER TABLE sessions ADD COLUMN transcript_path TEXT NOT NULL DEFAULT ''`,
-		} {
-			if _, err := s.db.Exec(col); err != nil {
-				if !strings.Contains(err.Error(), "duplicate column") {
-					return err
-				}
-			}
-		}
-		version = 6
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	// Upsert the version
-	if _, err := s.db.Exec(`DELETE FROM schema_version`); err != nil {
+	var legacy int
+	err = tx.QueryRow(`SELECT version FROM schema_version LIMIT 1`).Scan(&legacy)
+	if err != nil && err != sql.ErrNoRows {
 		return err
 	}
-	if _, err := s.db.Exec(`INSERT INTO schema_version (version) VALUES (?)`, version); err != nil {
+	now := formatTime(s.clock.Now())
+	for v := 1; v <= legacy; v++ {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO schema_migrations (version, applied_at) VALUES (?, ?)`, v, now); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`DROP TABLE schema_version`); err != nil {
 		return err
 	}
-	return nil
+	return tx.Commit()
 }
 
 // CreateSession inserts or replaces a session.
 func (s *Store) CreateSession(sess *Session) error {
 	_, err := s.db.Exec(`INSERT OR REPLACE INTO sessions
-		(id, tmux_pane, cwd, project, node_name, started_at, stopped_at, last_activity_at, notification_type, notify_title, notify_message, notified_at, topic, plan_summary, pane_title, plan_text, transcript_path)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		(id, tmux_pane, cwd, project, node_name, started_at, stopped_at, last_activity_at, notification_type, notify_title, notify_message, notified_at, topic, plan_summary, pane_title, plan_text, transcript_path, pinned, topic_locked, last_reply, muted, busy, window_name, pending_question, error_count, stop_reason, extra_panes)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		sess.ID, sess.TmuxPane, sess.Cwd, sess.Project, sess.NodeName,
 		formatTime(sess.StartedAt), formatNullableTime(sess.StoppedAt),
 		formatNullableTime(sess.LastActivityAt),
 		sess.NotificationType, sess.NotifyTitle, sess.NotifyMessage,
 		formatNullableTime(sess.NotifiedAt),
 		sess.Topic, sess.PlanSummary, sess.PaneTitle, sess.PlanText, sess.TranscriptPath,
+		sess.Pinned, sess.TopicLocked, sess.LastReply, sess.Muted, sess.Busy, sess.WindowName, string(sess.PendingQuestion), sess.ErrorCount, sess.StopReason, strings.Join(sess.ExtraPanes, ","),
 	)
 	return err
 }
 
 // GetSession retrieves a session by ID. Returns ErrNotFound if not found.
 func (s *Store) GetSession(id string) (*Session, error) {
-	row := s.db.QueryRow(`SELECT id, tmux_pane, cwd, project, node_name, started_at, stopped_at, last_activity_at,
-		notification_type, notify_title, notify_message, notified_at, topic, plan_summary, pane_title, plan_text, transcript_path
-		FROM sessions WHERE id = ?`, id)
+	row := s.db.QueryRow(`SELECT `+sessionColumns+` FROM sessions WHERE id = ?`, id)
 	sess, err := scanSession(row)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, ErrNotFound
@@ -207,7 +362,8 @@ func (s *Store) UpdateSession(sess *Session) error {
 	result, err := s.db.Exec(`UPDATE sessions SET
 		tmux_pane = ?, cwd = ?, project = ?, node_name = ?, started_at = ?, stopped_at = ?, last_activity_at = ?,
 		notification_type = ?, notify_title = ?, notify_message = ?, notified_at = ?,
-		topic = ?, plan_summary = ?, pane_title = ?, plan_text = ?, transcript_path = ?
+		topic = ?, plan_summary = ?, pane_title = ?, plan_text = ?, transcript_path = ?,
+		pinned = ?, topic_locked = ?, last_reply = ?, muted = ?, busy = ?, window_name = ?, pending_question = ?, error_count = ?, stop_reason = ?, extra_panes = ?
 		WHERE id = ?`,
 		sess.TmuxPane, sess.Cwd, sess.Project, sess.NodeName,
 		formatTime(sess.StartedAt), formatNullableTime(sess.StoppedAt),
@@ -215,6 +371,7 @@ func (s *Store) UpdateSession(sess *Session) error {
 		sess.NotificationType, sess.NotifyTitle, sess.NotifyMessage,
 		formatNullableTime(sess.NotifiedAt),
 		sess.Topic, sess.PlanSummary, sess.PaneTitle, sess.PlanText, sess.TranscriptPath,
+		sess.Pinned, sess.TopicLocked, sess.LastReply, sess.Muted, sess.Busy, sess.WindowName, string(sess.PendingQuestion), sess.ErrorCount, sess.StopReason, strings.Join(sess.ExtraPanes, ","),
 		sess.ID,
 	)
 	if err != nil {
@@ -245,7 +402,7 @@ func (s *Store) UpdatePaneTitles(nodeName string, paneTitles map[string]string)
 // ReapStoppedSessions deletes sessions that have been stopped longer than ttl.
 // Returns the IDs of deleted sessions.
 func (s *Store) ReapStoppedSessions(ttl time.Duration) ([]string, error) {
-	cutoff := time.Now().Add(-ttl)
+	cutoff := s.clock.Now().Add(-ttl)
 	rows, err := s.db.Query(`DELETE FROM sessions WHERE stopped_at IS NOT NULL AND stopped_at < ? RETURNING id`,
 		formatTime(cutoff))
 	if err != nil {
@@ -264,11 +421,47 @@ func (s *Store) ReapStoppedSessions(ttl time.Duration) ([]string, error) {
 	return ids, rows.Err()
 }
 
+// TrimStoppedSessions deletes the oldest stopped sessions, by stopped_at, so
+// that at most max remain. Active sessions are never removed. Returns the IDs
+// of deleted sessions.
+func (s *Store) TrimStoppedSessions(max int) ([]string, error) {
+	rows, err := s.db.Query(`DELETE FROM sessions WHERE id IN (
+		SELECT id FROM sessions WHERE stopped_at IS NOT NULL
+		ORDER BY stopped_at DESC, id DESC LIMIT -1 OFFSET ?
+	) RETURNING id`, max)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
 // ListActiveSessionsByNode returns active sessions for a specific node.
 func (s *Store) ListActiveSessionsByNode(nodeName string) ([]*Session, error) {
-	rows, err := s.db.Query(`SELECT id, tmux_pane, cwd, project, node_name, started_at, stopped_at, last_activity_at,
-		notification_type, notify_title, notify_message, notified_at, topic, plan_summary, pane_title, plan_text, transcript_path
-		FROM sessions WHERE stopped_at IS NULL AND node_name = ? ORDER BY started_at DESC`, nodeName)
+	rows, err := s.db.Query(`SELECT `+sessionColumns+` FROM sessions WHERE stopped_at IS NULL AND node_name = ? ORDER BY started_at DESC`, nodeName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanSessions(rows)
+}
+
+// ListIdleSessions returns active sessions with no activity within idle. A
+// session that never reported activity is measured from its start time.
+func (s *Store) ListIdleSessions(idle time.Duration) ([]*Session, error) {
+	cutoff := s.clock.Now().Add(-idle)
+	rows, err := s.db.Query(`SELECT `+sessionColumns+` FROM sessions
+		WHERE stopped_at IS NULL AND COALESCE(last_activity_at, started_at) < ?
+		ORDER BY started_at DESC`, formatTime(cutoff))
 	if err != nil {
 		return nil, err
 	}
@@ -276,33 +469,41 @@ func (s *Store) ListActiveSessionsByNode(nodeName string) ([]*Session, error) {
 	return scanSessions(rows)
 }
 
-// StopSessions batch-sets stopped_at = now for the given session IDs.
-func (s *Store) StopSessions(ids []string) error {
+// clearLiveState resets pending notification, question, and busy state; a
+// stopped session can't be waiting on anyone or working, so every stop path
+// applies it alongside stopped_at.
+const clearLiveState = `notification_type = '', notify_title = '', notify_message = '', notified_at = NULL, busy = 0, pending_question = ''`
+
+// StopSessions batch-sets stopped_at = now and the stop reason for the given
+// session IDs and clears any pending notification.
+func (s *Store) StopSessions(ids []string, reason string) error {
 	if len(ids) == 0 {
 		return nil
 	}
 	placeholders := make([]string, len(ids))
-	args := make([]any, len(ids)+1)
-	args[0] = formatTime(time.Now())
+	args := make([]any, len(ids)+2)
+	args[0] = formatTime(s.clock.Now())
+	args[1] = reason
 	for i, id := range ids {
 		placeholders[i] = "?"
-		args[i+1] = id
+		args[i+2] = id
 	}
-	query := fmt.Sprintf(`UPDATE sessions SET stopped_at = ? WHERE id IN (%s)`, strings.Join(placeholders, ","))
+	query := fmt.Sprintf(`UPDATE sessions SET stopped_at = ?, stop_reason = ?, %s WHERE id IN (%s)`,
+		clearLiveState, strings.Join(placeholders, ","))
 	_, err := s.db.Exec(query, args...)
 	return err
 }
 
-// StopSessionsByPane stops active sessions on the same node+pane, excluding excludeID.
-// Returns the IDs of stopped sessions.
+// StopSessionsByPane stops active sessions on the same node+pane, excluding
+// excludeID, as superseded. Returns the IDs of stopped sessions.
 func (s *Store) StopSessionsByPane(nodeName, pane, excludeID string) ([]string, error) {
 	if pane == "" {
 		return nil, nil
 	}
-	now := formatTime(time.Now())
-	rows, err := s.db.Query(`UPDATE sessions SET stopped_at = ?
+	now := formatTime(s.clock.Now())
+	rows, err := s.db.Query(`UPDATE sessions SET stopped_at = ?, stop_reason = ?, `+clearLiveState+`
 		WHERE stopped_at IS NULL AND node_name = ? AND tmux_pane = ? AND id != ?
-		RETURNING id`, now, nodeName, pane, excludeID)
+		RETURNING id`, now, StopReasonSuperseded, nodeName, pane, excludeID)
 	if err != nil {
 		return nil, err
 	}
@@ -319,11 +520,48 @@ func (s *Store) StopSessionsByPane(nodeName, pane, excludeID string) ([]string,
 	return ids, rows.Err()
 }
 
-// ListActiveSessions returns sessions that haven't been stopped, newest first.
+// ActiveOrder selects the ordering of active sessions.
+type ActiveOrder string
+
+const (
+	// OrderStarted sorts by start time, newest first.
+	OrderStarted ActiveOrder = "started"
+	// OrderActivity sorts by last activity, most recently active first.
+	OrderActivity ActiveOrder = "activity"
+)
+
+// ListActiveSessions returns sessions that haven't been stopped, pinned
+// sessions first and newest first within each group.
 func (s *Store) ListActiveSessions() ([]*Session, error) {
-	rows, err := s.db.Query(`SELECT id, tmux_pane, cwd, project, node_name, started_at, stopped_at, last_activity_at,
-		notification_type, notify_title, notify_message, notified_at, topic, plan_summary, pane_title, plan_text, transcript_path
-		FROM sessions WHERE stopped_at IS NULL ORDER BY started_at DESC`)
+	return s.ListActiveSessionsOrdered(OrderStarted)
+}
+
+// ListActiveSessionsOrdered is ListActiveSessions with a selectable ordering.
+// Pinned sessions always sort first.
+func (s *Store) ListActiveSessionsOrdered(order ActiveOrder) ([]*Session, error) {
+	var orderBy string
+	switch order {
+	case OrderStarted:
+		orderBy = "started_at DESC"
+	case OrderActivity:
+		orderBy = "COALESCE(last_activity_at, started_at) DESC"
+	default:
+		return nil, fmt.Errorf("unknown session order %q", order)
+	}
+	rows, err := s.db.Query(`SELECT ` + sessionColumns + ` FROM sessions WHERE stopped_at IS NULL ORDER BY pinned DESC, ` + orderBy)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanSessions(rows)
+}
+
+// ListAttentionSessions returns active sessions with a pending notification,
+// longest-waiting first.
+func (s *Store) ListAttentionSessions() ([]*Session, error) {
+	rows, err := s.db.Query(`SELECT ` + sessionColumns + ` FROM sessions
+		WHERE stopped_at IS NULL AND notification_type != '' AND notified_at IS NOT NULL
+		ORDER BY notified_at ASC`)
 	if err != nil {
 		return nil, err
 	}
@@ -333,9 +571,7 @@ func (s *Store) ListActiveSessions() ([]*Session, error) {
 
 // ListRecentSessions returns stopped sessions ordered by stopped_at DESC, limited to n.
 func (s *Store) ListRecentSessions(limit int) ([]*Session, error) {
-	rows, err := s.db.Query(`SELECT id, tmux_pane, cwd, project, node_name, started_at, stopped_at, last_activity_at,
-		notification_type, notify_title, notify_message, notified_at, topic, plan_summary, pane_title, plan_text, transcript_path
-		FROM sessions WHERE stopped_at IS NOT NULL ORDER BY stopped_at DESC LIMIT ?`, limit)
+	rows, err := s.db.Query(`SELECT `+sessionColumns+` FROM sessions WHERE stopped_at IS NOT NULL ORDER BY stopped_at DESC LIMIT ?`, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -343,6 +579,40 @@ func (s *Store) ListRecentSessions(limit int) ([]*Session, error) {
 	return scanSessions(rows)
 }
 
+// SessionCounts summarizes the sessions table without loading rows.
+type SessionCounts struct {
+	Active  int            `json:"active"`
+	Stopped int            `json:"stopped"`
+	Total   int            `json:"total"`
+	ByNode  map[string]int `json:"by_node"` // active sessions per node
+}
+
+// Counts returns aggregate session counts.
+func (s *Store) Counts() (*SessionCounts, error) {
+	c := &SessionCounts{ByNode: make(map[string]int)}
+	err := s.db.QueryRow(`SELECT
+		COALESCE(SUM(stopped_at IS NULL), 0), COALESCE(SUM(stopped_at IS NOT NULL), 0), COUNT(*)
+		FROM sessions`).Scan(&c.Active, &c.Stopped, &c.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`SELECT node_name, COUNT(*) FROM sessions WHERE stopped_at IS NULL GROUP BY node_name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var node string
+		var n int
+		if err := rows.Scan(&node, &n); err != nil {
+			return nil, err
+		}
+		c.ByNode[node] = n
+	}
+	return c, rows.Err()
+}
+
 // ProjectFromCwd extracts last two path components as project name.
 func ProjectFromCwd(cwd string) string {
 	trimmed := strings.TrimRight(cwd, "/")
@@ -368,6 +638,7 @@ func scanSession(s scanner) (*Session, error) {
 	var sess Session
 	var startedAt string
 	var stoppedAt, lastActivityAt, notifiedAt sql.NullString
+	var pendingQuestion, extraPanes string
 
 	err := s.Scan(
 		&sess.ID, &sess.TmuxPane, &sess.Cwd, &sess.Project, &sess.NodeName,
@@ -375,10 +646,17 @@ func scanSession(s scanner) (*Session, error) {
 		&sess.NotificationType, &sess.NotifyTitle, &sess.NotifyMessage,
 		&notifiedAt,
 		&sess.Topic, &sess.PlanSummary, &sess.PaneTitle, &sess.PlanText, &sess.TranscriptPath,
+		&sess.Pinned, &sess.TopicLocked, &sess.LastReply, &sess.Muted, &sess.Busy, &sess.WindowName, &pendingQuestion, &sess.ErrorCount, &sess.StopReason, &extraPanes,
 	)
 	if err != nil {
 		return nil, err
 	}
+	if pendingQuestion != "" {
+		sess.PendingQuestion = json.RawMessage(pendingQuestion)
+	}
+	if extraPanes != "" {
+		sess.ExtraPanes = strings.Split(extraPanes, ",")
+	}
 
 	sess.StartedAt, err = parseTime(startedAt)
 	if err != nil {
@@ -1,8 +1,16 @@
 package store
 
 import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/phinze/sophon/clock"
 )
 
 func openTestStore(t *testing.T) *Store {
@@ -126,6 +134,130 @@ func TestUpdateSession(t *testing.T) {
 	}
 }
 
+func TestLastReplyPersists(t *testing.T) {
+	s := openTestStore(t)
+
+	sess := &Session{ID: "s1", StartedAt: time.Now(), LastReply: "Done — all tests pass."}
+	if err := s.CreateSession(sess); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	got, err := s.GetSession("s1")
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if got.LastReply != sess.LastReply {
+		t.Errorf("LastReply = %q, want %q", got.LastReply, sess.LastReply)
+	}
+
+	got.LastReply = "Updated"
+	if err := s.UpdateSession(got); err != nil {
+		t.Fatalf("UpdateSession: %v", err)
+	}
+	got, _ = s.GetSession("s1")
+	if got.LastReply != "Updated" {
+		t.Errorf("LastReply after update = %q", got.LastReply)
+	}
+}
+
+func TestMutedPersists(t *testing.T) {
+	s := openTestStore(t)
+
+	if err := s.CreateSession(&Session{ID: "s1", StartedAt: time.Now(), Muted: true}); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	got, err := s.GetSession("s1")
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if !got.Muted {
+		t.Error("Muted should round-trip through CreateSession")
+	}
+
+	got.Muted = false
+	if err := s.UpdateSession(got); err != nil {
+		t.Fatalf("UpdateSession: %v", err)
+	}
+	got, _ = s.GetSession("s1")
+	if got.Muted {
+		t.Error("Muted should be cleared by UpdateSession")
+	}
+}
+
+func TestStopSessionsClearsBusy(t *testing.T) {
+	s := openTestStore(t)
+	s.CreateSession(&Session{ID: "s1", StartedAt: time.Now(), Busy: true})
+
+	if got, _ := s.GetSession("s1"); !got.Busy {
+		t.Fatal("Busy should round-trip through CreateSession")
+	}
+	if err := s.StopSessions([]string{"s1"}, StopReasonReconciled); err != nil {
+		t.Fatalf("StopSessions: %v", err)
+	}
+	if got, _ := s.GetSession("s1"); got.Busy {
+		t.Error("stopping a session should clear Busy")
+	}
+}
+
+func TestPendingQuestionRoundTrip(t *testing.T) {
+	s := openTestStore(t)
+	s.CreateSession(&Session{ID: "s1", StartedAt: time.Now()})
+
+	got, _ := s.GetSession("s1")
+	if got.PendingQuestion != nil {
+		t.Fatalf("PendingQuestion = %s, want nil for a new session", got.PendingQuestion)
+	}
+
+	q := `[{"question":"Which approach?","options":[{"label":"A"},{"label":"B"}]}]`
+	got.PendingQuestion = json.RawMessage(q)
+	if err := s.UpdateSession(got); err != nil {
+		t.Fatalf("UpdateSession: %v", err)
+	}
+	if got, _ := s.GetSession("s1"); string(got.PendingQuestion) != q {
+		t.Errorf("PendingQuestion = %s, want %s", got.PendingQuestion, q)
+	}
+
+	if err := s.StopSessions([]string{"s1"}, StopReasonReconciled); err != nil {
+		t.Fatalf("StopSessions: %v", err)
+	}
+	if got, _ := s.GetSession("s1"); got.PendingQuestion != nil {
+		t.Errorf("PendingQuestion = %s, stopping a session should clear it", got.PendingQuestion)
+	}
+}
+
+func TestErrorCountRoundTrip(t *testing.T) {
+	s := openTestStore(t)
+	s.CreateSession(&Session{ID: "s1", StartedAt: time.Now(), ErrorCount: 2})
+
+	got, _ := s.GetSession("s1")
+	if got.ErrorCount != 2 {
+		t.Fatalf("ErrorCount = %d, want 2", got.ErrorCount)
+	}
+	got.ErrorCount = 5
+	if err := s.UpdateSession(got); err != nil {
+		t.Fatalf("UpdateSession: %v", err)
+	}
+	if got, _ := s.GetSession("s1"); got.ErrorCount != 5 {
+		t.Errorf("ErrorCount = %d, want 5", got.ErrorCount)
+	}
+}
+
+func TestExtraPanesRoundTrip(t *testing.T) {
+	s := openTestStore(t)
+	s.CreateSession(&Session{ID: "s1", TmuxPane: "%1", StartedAt: time.Now(), ExtraPanes: []string{"%3", "%2"}})
+
+	got, _ := s.GetSession("s1")
+	if !slices.Equal(got.ExtraPanes, []string{"%3", "%2"}) {
+		t.Fatalf("ExtraPanes = %q, want [%%3 %%2] in order", got.ExtraPanes)
+	}
+	got.ExtraPanes = nil
+	if err := s.UpdateSession(got); err != nil {
+		t.Fatalf("UpdateSession: %v", err)
+	}
+	if got, _ := s.GetSession("s1"); got.ExtraPanes != nil {
+		t.Errorf("ExtraPanes = %q, want none", got.ExtraPanes)
+	}
+}
+
 func TestUpdateSessionNotFound(t *testing.T) {
 	s := openTestStore(t)
 
@@ -171,6 +303,88 @@ func TestListActiveSessions(t *testing.T) {
 	}
 }
 
+func TestListActiveSessionsPinnedFirst(t *testing.T) {
+	s := openTestStore(t)
+
+	now := time.Now().Truncate(time.Second)
+	for i, id := range []string{"old-pinned", "mid", "new"} {
+		sess := &Session{
+			ID:        id,
+			StartedAt: now.Add(time.Duration(i) * time.Minute),
+			Pinned:    id == "old-pinned",
+		}
+		if err := s.CreateSession(sess); err != nil {
+			t.Fatalf("CreateSession(%s): %v", id, err)
+		}
+	}
+
+	active, err := s.ListActiveSessions()
+	if err != nil {
+		t.Fatalf("ListActiveSessions: %v", err)
+	}
+	var got []string
+	for _, sess := range active {
+		got = append(got, sess.ID)
+	}
+	want := []string{"old-pinned", "new", "mid"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+	if !active[0].Pinned {
+		t.Error("Pinned should round-trip through the store")
+	}
+
+	// Unpinning drops it back into recency order.
+	active[0].Pinned = false
+	if err := s.UpdateSession(active[0]); err != nil {
+		t.Fatalf("UpdateSession: %v", err)
+	}
+	active, _ = s.ListActiveSessions()
+	if active[0].ID != "new" || active[2].ID != "old-pinned" {
+		t.Errorf("after unpin: [%s, %s, %s]", active[0].ID, active[1].ID, active[2].ID)
+	}
+}
+
+func TestListActiveSessionsOrdered(t *testing.T) {
+	s := openTestStore(t)
+	now := time.Now().Truncate(time.Second)
+
+	// "first" started earliest but was active most recently; "quiet" has no
+	// recorded activity and falls back to its start time.
+	for _, sess := range []*Session{
+		{ID: "first", StartedAt: now, LastActivityAt: now.Add(10 * time.Minute)},
+		{ID: "second", StartedAt: now.Add(1 * time.Minute), LastActivityAt: now.Add(2 * time.Minute)},
+		{ID: "quiet", StartedAt: now.Add(5 * time.Minute)},
+	} {
+		if err := s.CreateSession(sess); err != nil {
+			t.Fatalf("CreateSession(%s): %v", sess.ID, err)
+		}
+	}
+
+	ids := func(order ActiveOrder) string {
+		t.Helper()
+		sessions, err := s.ListActiveSessionsOrdered(order)
+		if err != nil {
+			t.Fatalf("ListActiveSessionsOrdered(%s): %v", order, err)
+		}
+		var out []string
+		for _, sess := range sessions {
+			out = append(out, sess.ID)
+		}
+		return strings.Join(out, ",")
+	}
+
+	if got := ids(OrderStarted); got != "quiet,second,first" {
+		t.Errorf("started order = %s", got)
+	}
+	if got := ids(OrderActivity); got != "first,quiet,second" {
+		t.Errorf("activity order = %s", got)
+	}
+	if _, err := s.ListActiveSessionsOrdered("bogus"); err == nil {
+		t.Error("expected error for unknown order")
+	}
+}
+
 func TestListRecentSessions(t *testing.T) {
 	s := openTestStore(t)
 
@@ -254,6 +468,53 @@ func TestReapStoppedSessions(t *testing.T) {
 	}
 }
 
+func TestCounts(t *testing.T) {
+	s := openTestStore(t)
+	now := time.Now().Truncate(time.Second)
+
+	sessions := []struct {
+		id, node string
+		stopped  bool
+	}{
+		{"a", "node1", false},
+		{"b", "node1", false},
+		{"c", "node2", false},
+		{"d", "node1", true},
+		{"e", "node3", true},
+	}
+	for _, tc := range sessions {
+		sess := &Session{ID: tc.id, NodeName: tc.node, StartedAt: now}
+		if tc.stopped {
+			sess.StoppedAt = now
+		}
+		if err := s.CreateSession(sess); err != nil {
+			t.Fatalf("CreateSession(%s): %v", tc.id, err)
+		}
+	}
+
+	c, err := s.Counts()
+	if err != nil {
+		t.Fatalf("Counts: %v", err)
+	}
+	if c.Active != 3 || c.Stopped != 2 || c.Total != 5 {
+		t.Errorf("counts = %d active, %d stopped, %d total; want 3, 2, 5", c.Active, c.Stopped, c.Total)
+	}
+	if len(c.ByNode) != 2 || c.ByNode["node1"] != 2 || c.ByNode["node2"] != 1 {
+		t.Errorf("ByNode = %v, want map[node1:2 node2:1]", c.ByNode)
+	}
+}
+
+func TestCountsEmpty(t *testing.T) {
+	s := openTestStore(t)
+	c, err := s.Counts()
+	if err != nil {
+		t.Fatalf("Counts: %v", err)
+	}
+	if c.Active != 0 || c.Stopped != 0 || c.Total != 0 || len(c.ByNode) != 0 {
+		t.Errorf("empty store counts = %+v", c)
+	}
+}
+
 func TestProjectFromCwd(t *testing.T) {
 	tests := []struct {
 		cwd  string
@@ -332,7 +593,7 @@ func TestStopSessions(t *testing.T) {
 		}
 	}
 
-	if err := s.StopSessions([]string{"a", "c"}); err != nil {
+	if err := s.StopSessions([]string{"a", "c"}, StopReasonIdleTimeout); err != nil {
 		t.Fatalf("StopSessions: %v", err)
 	}
 
@@ -349,10 +610,13 @@ func TestStopSessions(t *testing.T) {
 		if !tc.stopped && !sess.StoppedAt.IsZero() {
 			t.Errorf("session %s should not be stopped", tc.id)
 		}
+		if want := map[bool]string{true: StopReasonIdleTimeout}[tc.stopped]; sess.StopReason != want {
+			t.Errorf("session %s StopReason = %q, want %q", tc.id, sess.StopReason, want)
+		}
 	}
 
 	// Empty list is a no-op
-	if err := s.StopSessions(nil); err != nil {
+	if err := s.StopSessions(nil, StopReasonReconciled); err != nil {
 		t.Fatalf("StopSessions(nil): %v", err)
 	}
 }
@@ -398,6 +662,9 @@ func TestStopSessionsByPane(t *testing.T) {
 		if !tc.stopped && !sess.StoppedAt.IsZero() {
 			t.Errorf("session %s should not be stopped", tc.id)
 		}
+		if want := map[bool]string{true: StopReasonSuperseded}[tc.stopped]; sess.StopReason != want {
+			t.Errorf("session %s StopReason = %q, want %q", tc.id, sess.StopReason, want)
+		}
 	}
 
 	// Empty pane is a no-op
@@ -424,3 +691,312 @@ func TestMigrationIdempotent(t *testing.T) {
 		t.Fatalf("CreateSession after re-migrate: %v", err)
 	}
 }
+
+func TestMigrateFresh(t *testing.T) {
+	s := openTestStore(t)
+
+	rows, err := s.db.Query(`SELECT version, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		t.Fatalf("query schema_migrations: %v", err)
+	}
+	defer rows.Close()
+	var versions []int
+	for rows.Next() {
+		var v int
+		var appliedAt string
+		if err := rows.Scan(&v, &appliedAt); err != nil {
+			t.Fatal(err)
+		}
+		if appliedAt == "" {
+			t.Errorf("version %d has no applied_at", v)
+		}
+		versions = append(versions, v)
+	}
+	if len(versions) != currentSchemaVersion {
+		t.Fatalf("recorded %d migrations, want %d", len(versions), currentSchemaVersion)
+	}
+	for i, v := range versions {
+		if v != i+1 {
+			t.Errorf("versions = %v, want 1..%d", versions, currentSchemaVersion)
+			break
+		}
+	}
+}
+
+func TestMigrateFromLegacyVersionTable(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "sophon.db")
+
+	// Build a version-3 database the way earlier builds did.
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE schema_version (version INTEGER NOT NULL)`,
+		`INSERT INTO schema_version (version) VALUES (3)`,
+		migrations[0][0], migrations[1][0], migrations[2][0],
+		`INSERT INTO sessions (id, started_at, node_name) VALUES ('old', '2026-01-01T00:00:00Z', 'n1')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	db.Close()
+
+	s, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	sess, err := s.GetSession("old")
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if sess.NodeName != "n1" {
+		t.Errorf("NodeName = %q, want n1", sess.NodeName)
+	}
+
+	var maxVersion, legacyTables int
+	s.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&maxVersion)
+	s.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'schema_version'`).Scan(&legacyTables)
+	if maxVersion != currentSchemaVersion {
+		t.Errorf("version = %d, want %d", maxVersion, currentSchemaVersion)
+	}
+	if legacyTables != 0 {
+		t.Error("schema_version table should be dropped after conversion")
+	}
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "sophon.db")
+	s, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, err := s.db.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`,
+		currentSchemaVersion+1, formatTime(time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, err = Open(dbPath)
+	if err == nil {
+		s.Close()
+		t.Fatal("Open should refuse a database newer than the binary")
+	}
+	if !strings.Contains(err.Error(), "newer than this build supports") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "sophon.db")
+	s, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := s.CreateSession(&Session{ID: "s1", StartedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	ro, err := OpenWithOptions(dbPath, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("OpenWithOptions(ReadOnly): %v", err)
+	}
+	defer ro.Close()
+
+	if _, err := ro.GetSession("s1"); err != nil {
+		t.Errorf("GetSession: %v", err)
+	}
+	if err := ro.CreateSession(&Session{ID: "s2", StartedAt: time.Now()}); err == nil {
+		t.Error("CreateSession should fail on a read-only store")
+	}
+
+	if _, err := OpenWithOptions(filepath.Join(t.TempDir(), "missing.db"), Options{ReadOnly: true}); err == nil {
+		t.Error("read-only open of a missing database should fail")
+	}
+}
+
+func TestListAttentionSessions(t *testing.T) {
+	s := openTestStore(t)
+
+	now := time.Now().Truncate(time.Second)
+	for _, sess := range []*Session{
+		{ID: "quiet", StartedAt: now},
+		{ID: "recent-prompt", StartedAt: now, NotificationType: "permission_prompt", NotifiedAt: now},
+		{ID: "old-prompt", StartedAt: now, NotificationType: "idle_prompt", NotifiedAt: now.Add(-time.Hour)},
+		{ID: "stopped", StartedAt: now, StoppedAt: now, NotificationType: "idle_prompt", NotifiedAt: now},
+	} {
+		if err := s.CreateSession(sess); err != nil {
+			t.Fatalf("CreateSession(%s): %v", sess.ID, err)
+		}
+	}
+
+	got, err := s.ListAttentionSessions()
+	if err != nil {
+		t.Fatalf("ListAttentionSessions: %v", err)
+	}
+	if len(got) != 2 || got[0].ID != "old-prompt" || got[1].ID != "recent-prompt" {
+		ids := make([]string, len(got))
+		for i, sess := range got {
+			ids[i] = sess.ID
+		}
+		t.Errorf("ids = %v, want [old-prompt recent-prompt]", ids)
+	}
+}
+
+func TestListIdleSessions(t *testing.T) {
+	s := openTestStore(t)
+	now := time.Now().Truncate(time.Second)
+
+	sessions := []*Session{
+		{ID: "fresh", StartedAt: now.Add(-3 * time.Hour), LastActivityAt: now.Add(-time.Minute)},
+		{ID: "quiet", StartedAt: now.Add(-3 * time.Hour), LastActivityAt: now.Add(-2 * time.Hour)},
+		{ID: "never-active", StartedAt: now.Add(-2 * time.Hour)},
+		{ID: "stopped", StartedAt: now.Add(-3 * time.Hour), LastActivityAt: now.Add(-2 * time.Hour), StoppedAt: now},
+	}
+	for _, sess := range sessions {
+		if err := s.CreateSession(sess); err != nil {
+			t.Fatalf("CreateSession(%s): %v", sess.ID, err)
+		}
+	}
+
+	idle, err := s.ListIdleSessions(time.Hour)
+	if err != nil {
+		t.Fatalf("ListIdleSessions: %v", err)
+	}
+	got := make(map[string]bool)
+	for _, sess := range idle {
+		got[sess.ID] = true
+	}
+	if len(got) != 2 || !got["quiet"] || !got["never-active"] {
+		t.Errorf("idle sessions = %v, want quiet and never-active", got)
+	}
+}
+
+func TestTrimStoppedSessions(t *testing.T) {
+	s := openTestStore(t)
+	now := time.Now().Truncate(time.Second)
+
+	// Five stopped sessions, s0 the oldest, plus one long-running active one.
+	for i := range 5 {
+		s.CreateSession(&Session{
+			ID:        fmt.Sprintf("s%d", i),
+			StartedAt: now.Add(-48 * time.Hour),
+			StoppedAt: now.Add(time.Duration(i-5) * time.Hour),
+		})
+	}
+	s.CreateSession(&Session{ID: "active", StartedAt: now.Add(-72 * time.Hour)})
+
+	trimmed, err := s.TrimStoppedSessions(3)
+	if err != nil {
+		t.Fatalf("TrimStoppedSessions: %v", err)
+	}
+	slices.Sort(trimmed)
+	if !slices.Equal(trimmed, []string{"s0", "s1"}) {
+		t.Errorf("trimmed = %v, want [s0 s1]", trimmed)
+	}
+	for _, id := range []string{"s2", "s3", "s4", "active"} {
+		if _, err := s.GetSession(id); err != nil {
+			t.Errorf("GetSession(%s) after trim: %v", id, err)
+		}
+	}
+
+	if trimmed, err := s.TrimStoppedSessions(3); err != nil || len(trimmed) != 0 {
+		t.Errorf("second trim = %v, %v; want nothing under the cap", trimmed, err)
+	}
+}
+
+func TestReapUsesClock(t *testing.T) {
+	fake := clock.NewFake(time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+	s, err := OpenWithOptions(":memory:", Options{Clock: fake})
+	if err != nil {
+		t.Fatalf("OpenWithOptions: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	if err := s.CreateSession(&Session{ID: "s1", StartedAt: fake.Now()}); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if err := s.StopSessions([]string{"s1"}, StopReasonReconciled); err != nil {
+		t.Fatalf("StopSessions: %v", err)
+	}
+	if got, _ := s.GetSession("s1"); !got.StoppedAt.Equal(fake.Now()) {
+		t.Errorf("StoppedAt = %v, want %v", got.StoppedAt, fake.Now())
+	}
+
+	fake.Advance(23 * time.Hour)
+	if reaped, _ := s.ReapStoppedSessions(24 * time.Hour); len(reaped) != 0 {
+		t.Errorf("reaped %v before the TTL elapsed", reaped)
+	}
+	fake.Advance(2 * time.Hour)
+	if reaped, _ := s.ReapStoppedSessions(24 * time.Hour); len(reaped) != 1 {
+		t.Errorf("reaped %v, want [s1] after the TTL", reaped)
+	}
+}
+
+func TestMaintain(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(filepath.Join(dir, "sophon.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	now := time.Now()
+	for i := range 50 {
+		sess := &Session{ID: fmt.Sprintf("s%d", i), StartedAt: now, StoppedAt: now.Add(-48 * time.Hour)}
+		if err := s.CreateSession(sess); err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+	}
+	if _, err := s.ReapStoppedSessions(time.Hour); err != nil {
+		t.Fatalf("ReapStoppedSessions: %v", err)
+	}
+	if err := s.CreateSession(&Session{ID: "keep", StartedAt: now}); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	if err := s.Maintain(); err != nil {
+		t.Fatalf("Maintain: %v", err)
+	}
+	if _, err := s.GetSession("keep"); err != nil {
+		t.Errorf("GetSession after Maintain: %v", err)
+	}
+}
+
+func TestOpenPragmas(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want int
+	}{
+		{"default", Options{}, 5000},
+		{"custom", Options{BusyTimeout: 2500 * time.Millisecond}, 2500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := OpenWithOptions(filepath.Join(t.TempDir(), "sophon.db"), tt.opts)
+			if err != nil {
+				t.Fatalf("OpenWithOptions: %v", err)
+			}
+			t.Cleanup(func() { s.Close() })
+
+			var busy, fk int
+			if err := s.db.QueryRow(`PRAGMA busy_timeout`).Scan(&busy); err != nil {
+				t.Fatalf("PRAGMA busy_timeout: %v", err)
+			}
+			if err := s.db.QueryRow(`PRAGMA foreign_keys`).Scan(&fk); err != nil {
+				t.Fatalf("PRAGMA foreign_keys: %v", err)
+			}
+			if busy != tt.want {
+				t.Errorf("busy_timeout = %d, want %d", busy, tt.want)
+			}
+			if fk != 1 {
+				t.Errorf("foreign_keys = %d, want 1", fk)
+			}
+		})
+	}
+}
@@ -1,22 +1,84 @@
 package tmux
 
 import (
+	"errors"
 	"fmt"
 	"os/exec"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// Panes are addressed either by tmux's unique pane id ("%5") or by a
+// session:window.pane target ("work:1.0"). Sophon stores the id, taken from
+// TMUX_PANE: it survives windows being renumbered or moved, and it is what
+// ListAgentPanes reports, so reconciliation can match sessions to panes.
+// Named targets are accepted wherever a pane is taken, for manual use.
+
+// ErrInvalidPane is returned for pane ids that are neither of tmux's "%N"
+// form nor a session:window.pane target.
+var ErrInvalidPane = errors.New("invalid tmux pane id")
+
+// NormalizePane trims surrounding whitespace from a pane id, as found in
+// TMUX_PANE or a request, and checks that it is a "%N" pane id or a
+// session:window.pane target. A bare number would be read by tmux as a window
+// index, so it is rejected rather than targeting the wrong pane.
+func NormalizePane(pane string) (string, error) {
+	pane = strings.TrimSpace(pane)
+	if pane == "" {
+		return "", fmt.Errorf("%w: no pane given", ErrInvalidPane)
+	}
+	if IsPaneID(pane) {
+		if !isDigits(pane[1:]) {
+			return "", fmt.Errorf("%w %q: want %%N, such as %%5", ErrInvalidPane, pane)
+		}
+		return pane, nil
+	}
+	session, rest, _ := strings.Cut(pane, ":")
+	dot := strings.LastIndexByte(rest, '.')
+	if strings.TrimPrefix(session, "=") == "" || dot < 1 || !isDigits(rest[dot+1:]) {
+		return "", fmt.Errorf("%w %q: want %%N or session:window.pane", ErrInvalidPane, pane)
+	}
+	return pane, nil
+}
+
+// IsPaneID reports whether pane is a "%N" pane id rather than a
+// session:window.pane target.
+func IsPaneID(pane string) bool {
+	return strings.HasPrefix(pane, "%")
+}
+
+func isDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+// target returns pane as a tmux -t argument. Session names in named targets
+// are marked for exact matching with "=", since tmux otherwise falls back to
+// prefix and pattern matches and could pick a different session. Arguments
+// are passed to tmux directly, not through a shell, so no other quoting is
+// needed.
+func target(pane string) string {
+	if IsPaneID(pane) || strings.HasPrefix(pane, "=") {
+		return pane
+	}
+	return "=" + pane
+}
+
+// displayArgs builds a display-message invocation printing format for pane.
+func displayArgs(pane, format string) []string {
+	return []string{"display-message", "-t", target(pane), "-p", format}
+}
+
 // PaneFocused checks whether a tmux pane is currently visible and active.
 // Returns true only if the pane is the active pane in the active window of an
 // attached session — i.e., the user is looking at it right now.
-// Returns false on any error (pane gone, tmux not running, etc.).
+// Returns false on any error (invalid id, pane gone, tmux not running, etc.).
 func PaneFocused(pane string) bool {
-	if pane == "" {
+	pane, err := NormalizePane(pane)
+	if err != nil {
 		return false
 	}
-	cmd := exec.Command("tmux", "display-message", "-t", pane, "-p",
-		"#{pane_active} #{window_active} #{session_attached}")
+	cmd := exec.Command("tmux", displayArgs(pane, "#{pane_active} #{window_active} #{session_attached}")...)
 	output, err := cmd.Output()
 	if err != nil {
 		return false
@@ -28,6 +90,15 @@ func PaneFocused(pane string) bool {
 	return fields[0] == "1" && fields[1] == "1" && fields[2] != "0"
 }
 
+// PaneExists reports whether a tmux pane is still present.
+func PaneExists(pane string) bool {
+	pane, err := NormalizePane(pane)
+	if err != nil {
+		return false
+	}
+	return exec.Command("tmux", displayArgs(pane, "#{pane_id}")...).Run() == nil
+}
+
 // process holds parsed process info from ps output.
 type process struct {
 	pid  int
@@ -172,24 +243,159 @@ func parsePaneTitles(output string) map[string]string {
 	return titles
 }
 
-// SendKeys sends text to a tmux pane followed by Enter.
-func SendKeys(pane, text string) error {
-	if pane == "" {
-		return fmt.Errorf("no tmux pane specified for session")
+// Pane holds orientation metadata for a tmux pane.
+type Pane struct {
+	Title      string `json:"pane_title"`
+	WindowName string `json:"window_name"`
+}
+
+// PaneInfo returns the title and window name of a tmux pane.
+func PaneInfo(pane string) (Pane, error) {
+	pane, err := NormalizePane(pane)
+	if err != nil {
+		return Pane{}, fmt.Errorf("tmux pane-info: %w", err)
 	}
+	out, err := exec.Command("tmux", displayArgs(pane, "#{pane_title}\t#{window_name}")...).Output()
+	if err != nil {
+		return Pane{}, fmt.Errorf("tmux display-message: %w", err)
+	}
+	return parsePaneInfo(string(out)), nil
+}
 
-	// Send the text literally (-l prevents interpreting key names in the text)
-	cmd := exec.Command("tmux", "send-keys", "-t", pane, "-l", text)
-	output, err := cmd.CombinedOutput()
+// parsePaneInfo parses a tab-separated "title\twindow_name" line.
+func parsePaneInfo(output string) Pane {
+	title, window, _ := strings.Cut(strings.TrimRight(output, "\n"), "\t")
+	return Pane{Title: title, WindowName: window}
+}
+
+// Sender types text into tmux panes. The zero value sends Enter right after
+// the text, as the package-level SendKeys and Paste do.
+type Sender struct {
+	// EnterDelay pauses between the text and the Enter key press, for slow
+	// terminals where the agent's TUI drops keys that arrive together.
+	EnterDelay time.Duration
+
+	// Injectable for testing; nil means time.Sleep and runCommand.
+	sleep func(time.Duration)
+	run   func(command) error
+}
+
+// SendKeys sends text to a tmux pane. When enter is set the text is submitted
+// with an Enter key press; otherwise it is left staged in the pane's input.
+func SendKeys(pane, text string, enter bool) error {
+	return Sender{}.SendKeys(pane, text, enter)
+}
+
+// Paste writes text into a tmux buffer and pastes it into a pane, optionally
+// followed by Enter. Unlike send-keys the text travels over stdin, so it isn't
+// subject to tmux's argument-length limits.
+func Paste(pane, text string, enter bool) error {
+	return Sender{}.Paste(pane, text, enter)
+}
+
+// SendKeys is like the package-level SendKeys, with s's Enter delay.
+func (s Sender) SendKeys(pane, text string, enter bool) error {
+	pane, err := NormalizePane(pane)
+	if err != nil {
+		return err
+	}
+	return s.runCommands(sendKeysCommands(pane, text, enter))
+}
+
+// Paste is like the package-level Paste, with s's Enter delay.
+func (s Sender) Paste(pane, text string, enter bool) error {
+	pane, err := NormalizePane(pane)
 	if err != nil {
-		return fmt.Errorf("sending text: %w: %s", err, string(output))
+		return err
+	}
+	return s.runCommands(pasteCommands(pane, text, enter))
+}
+
+// pasteThreshold is the text length above which SendKeys switches to Paste.
+const pasteThreshold = 1024
+
+// command is a single tmux invocation. desc names the step in errors.
+type command struct {
+	desc  string
+	args  []string
+	stdin string
+	enter bool // the Enter key press, which waits out Sender.EnterDelay
+}
+
+// sendKeysCommands is the testable core of SendKeys. Short single-line text is
+// sent with send-keys -l (literal, so key names in the text aren't
+// interpreted). Multi-line text goes through a paste buffer instead: typed
+// newlines would reach the agent's input as Enter presses and submit a partial
+// response, while a bracketed paste (-p) lands as one block. Long text is
+// pasted too, since send-keys is slow and bounded by argument length.
+//
+// The text is an argument to tmux itself, so it is guarded against tmux's own
+// parsing: "--" ends option parsing for text starting with "-", and
+// literalArg escapes a trailing semicolon. No shell is involved, so nothing
+// else in the text is special.
+func sendKeysCommands(pane, text string, enter bool) []command {
+	if strings.Contains(text, "\n") || len(text) > pasteThreshold {
+		return pasteCommands(pane, text, enter)
+	}
+	cmds := []command{{desc: "sending text", args: []string{"send-keys", "-t", target(pane), "-l", "--", literalArg(text)}}}
+	return withEnter(cmds, pane, enter)
+}
+
+// literalArg escapes s for use as a tmux command-line argument. tmux treats
+// an argument ending in ";" as a command separator and drops the semicolon,
+// unless it is written as "\;", which tmux turns back into a plain ";".
+// Semicolons and backslashes elsewhere are passed through unchanged.
+func literalArg(s string) string {
+	if strings.HasSuffix(s, ";") {
+		return s[:len(s)-1] + `\;`
+	}
+	return s
+}
+
+// pasteCommands is the testable core of Paste.
+func pasteCommands(pane, text string, enter bool) []command {
+	buffer := "sophon-" + pane
+	cmds := []command{
+		{desc: "loading paste buffer", args: []string{"load-buffer", "-b", buffer, "-"}, stdin: text},
+		{desc: "pasting text", args: []string{"paste-buffer", "-d", "-p", "-b", buffer, "-t", target(pane)}},
+	}
+	return withEnter(cmds, pane, enter)
+}
+
+func withEnter(cmds []command, pane string, enter bool) []command {
+	if !enter {
+		return cmds
+	}
+	return append(cmds, command{desc: "sending Enter", args: []string{"send-keys", "-t", target(pane), "Enter"}, enter: true})
+}
+
+func (s Sender) runCommands(cmds []command) error {
+	sleep, run := s.sleep, s.run
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+	if run == nil {
+		run = runCommand
+	}
+	for _, c := range cmds {
+		if c.enter && s.EnterDelay > 0 {
+			sleep(s.EnterDelay)
+		}
+		if err := run(c); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	// Then send Enter as a key press
-	cmd = exec.Command("tmux", "send-keys", "-t", pane, "Enter")
-	output, err = cmd.CombinedOutput()
+func runCommand(c command) error {
+	cmd := exec.Command("tmux", c.args...)
+	if c.stdin != "" {
+		cmd.Stdin = strings.NewReader(c.stdin)
+	}
+	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("sending Enter: %w: %s", err, string(output))
+		return fmt.Errorf("%s: %w: %s", c.desc, err, string(output))
 	}
 	return nil
 }
@@ -1,6 +1,12 @@
 package tmux
 
-import "testing"
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestParseProcesses(t *testing.T) {
 	input := `    1     0 systemd
@@ -134,3 +140,227 @@ func TestParsePaneTitlesEmpty(t *testing.T) {
 		t.Errorf("expected empty, got %v", titles)
 	}
 }
+
+func TestParsePaneInfo(t *testing.T) {
+	got := parsePaneInfo("✳ Port session titles\tsophon\n")
+	if got.Title != "✳ Port session titles" || got.WindowName != "sophon" {
+		t.Errorf("got %+v", got)
+	}
+	if got := parsePaneInfo("\tzsh\n"); got.Title != "" || got.WindowName != "zsh" {
+		t.Errorf("empty title: got %+v", got)
+	}
+}
+
+func TestSendKeysCommandsSingleLine(t *testing.T) {
+	cmds := sendKeysCommands("%5", "yes, go ahead", true)
+	want := [][]string{
+		{"send-keys", "-t", "%5", "-l", "--", "yes, go ahead"},
+		{"send-keys", "-t", "%5", "Enter"},
+	}
+	assertCommands(t, cmds, want)
+	for _, c := range cmds {
+		if c.stdin != "" {
+			t.Errorf("single-line path should not use stdin, got %q", c.stdin)
+		}
+	}
+}
+
+func TestSendKeysCommandsMultiLine(t *testing.T) {
+	text := "first line\nsecond line"
+	cmds := sendKeysCommands("%5", text, true)
+	want := [][]string{
+		{"load-buffer", "-b", "sophon-%5", "-"},
+		{"paste-buffer", "-d", "-p", "-b", "sophon-%5", "-t", "%5"},
+		{"send-keys", "-t", "%5", "Enter"},
+	}
+	assertCommands(t, cmds, want)
+	if cmds[0].stdin != text {
+		t.Errorf("load-buffer stdin = %q, want %q", cmds[0].stdin, text)
+	}
+}
+
+func TestSendKeysCommandsLongText(t *testing.T) {
+	text := strings.Repeat("x", pasteThreshold+1)
+	cmds := sendKeysCommands("%5", text, true)
+	if cmds[0].args[0] != "load-buffer" {
+		t.Fatalf("long text should use the paste path, got %q", cmds[0].args)
+	}
+	if cmds[0].stdin != text {
+		t.Errorf("load-buffer stdin has %d bytes, want %d", len(cmds[0].stdin), len(text))
+	}
+
+	short := strings.Repeat("x", pasteThreshold)
+	if got := sendKeysCommands("%5", short, true)[0].args[0]; got != "send-keys" {
+		t.Errorf("text at the threshold should use send-keys, got %q", got)
+	}
+}
+
+func TestPasteCommands(t *testing.T) {
+	cmds := pasteCommands("%7", "hello", true)
+	want := [][]string{
+		{"load-buffer", "-b", "sophon-%7", "-"},
+		{"paste-buffer", "-d", "-p", "-b", "sophon-%7", "-t", "%7"},
+		{"send-keys", "-t", "%7", "Enter"},
+	}
+	assertCommands(t, cmds, want)
+	if cmds[0].stdin != "hello" {
+		t.Errorf("load-buffer stdin = %q, want %q", cmds[0].stdin, "hello")
+	}
+	for _, c := range cmds[1:] {
+		if c.stdin != "" {
+			t.Errorf("%s should not read stdin", c.args[0])
+		}
+	}
+}
+
+func TestSendKeysCommandsNoEnter(t *testing.T) {
+	assertCommands(t, sendKeysCommands("%5", "draft", false), [][]string{
+		{"send-keys", "-t", "%5", "-l", "--", "draft"},
+	})
+	assertCommands(t, sendKeysCommands("%5", "line one\nline two", false), [][]string{
+		{"load-buffer", "-b", "sophon-%5", "-"},
+		{"paste-buffer", "-d", "-p", "-b", "sophon-%5", "-t", "%5"},
+	})
+}
+
+func assertCommands(t *testing.T, got []command, want [][]string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d commands, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if strings.Join(got[i].args, " ") != strings.Join(want[i], " ") {
+			t.Errorf("command %d = %q, want %q", i, got[i].args, want[i])
+		}
+	}
+}
+
+func TestNormalizePane(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+		ok       bool
+	}{
+		{"%5", "%5", true},
+		{" %12\n", "%12", true},
+		{"5", "", false},
+		{"", "", false},
+		{"%", "", false},
+		{"%5x", "", false},
+		{"main:1.0", "main:1.0", true},
+		{"=main:editor.2", "=main:editor.2", true},
+		{"main:1", "", false},
+		{":1.0", "", false},
+		{"main:.0", "", false},
+		{"main:1.x", "", false},
+	} {
+		got, err := NormalizePane(tc.in)
+		if tc.ok && (err != nil || got != tc.want) {
+			t.Errorf("NormalizePane(%q) = %q, %v; want %q", tc.in, got, err, tc.want)
+		}
+		if !tc.ok && !errors.Is(err, ErrInvalidPane) {
+			t.Errorf("NormalizePane(%q) error = %v, want ErrInvalidPane", tc.in, err)
+		}
+	}
+}
+
+func TestSendKeysRejectsInvalidPane(t *testing.T) {
+	// Validation happens before tmux runs, so these need no tmux server.
+	for _, pane := range []string{"5", ""} {
+		if err := SendKeys(pane, "yes", true); !errors.Is(err, ErrInvalidPane) {
+			t.Errorf("SendKeys(%q) error = %v, want ErrInvalidPane", pane, err)
+		}
+		if PaneFocused(pane) {
+			t.Errorf("PaneFocused(%q) = true, want false", pane)
+		}
+	}
+}
+
+func TestCommandsForNamedTarget(t *testing.T) {
+	// Named targets get "=" so tmux matches the session name exactly; pane
+	// ids are unique and pass through untouched.
+	assertCommands(t, sendKeysCommands("work:1.0", "yes", true), [][]string{
+		{"send-keys", "-t", "=work:1.0", "-l", "--", "yes"},
+		{"send-keys", "-t", "=work:1.0", "Enter"},
+	})
+	assertCommands(t, pasteCommands("my project:2.1", "a\nb", false), [][]string{
+		{"load-buffer", "-b", "sophon-my project:2.1", "-"},
+		{"paste-buffer", "-d", "-p", "-b", "sophon-my project:2.1", "-t", "=my project:2.1"},
+	})
+	assertCommands(t, sendKeysCommands("%5", "yes", false), [][]string{
+		{"send-keys", "-t", "%5", "-l", "--", "yes"},
+	})
+
+	for pane, want := range map[string]string{
+		"%5":        "%5",
+		"work:1.0":  "=work:1.0",
+		"=work:1.0": "=work:1.0",
+	} {
+		args := displayArgs(pane, "#{pane_id}")
+		if got := args[2]; got != want {
+			t.Errorf("displayArgs(%q) target = %q, want %q", pane, got, want)
+		}
+	}
+}
+
+func TestSenderEnterDelay(t *testing.T) {
+	var steps []string
+	record := func(delay time.Duration) Sender {
+		return Sender{
+			EnterDelay: delay,
+			sleep:      func(d time.Duration) { steps = append(steps, "sleep "+d.String()) },
+			run: func(c command) error {
+				steps = append(steps, c.args[len(c.args)-1])
+				return nil
+			},
+		}
+	}
+
+	if err := record(50*time.Millisecond).SendKeys("%5", "yes", true); err != nil {
+		t.Fatalf("SendKeys: %v", err)
+	}
+	if got, want := strings.Join(steps, ", "), "yes, sleep 50ms, Enter"; got != want {
+		t.Errorf("steps = %q, want %q", got, want)
+	}
+
+	// No delay by default, and none when Enter isn't sent.
+	steps = nil
+	record(0).SendKeys("%5", "yes", true)
+	record(50*time.Millisecond).SendKeys("%5", "draft", false)
+	if got, want := strings.Join(steps, ", "), "yes, Enter, draft"; got != want {
+		t.Errorf("steps = %q, want %q", got, want)
+	}
+}
+
+func TestSendKeysCommandsKeepTextLiteral(t *testing.T) {
+	for text, wantArg := range map[string]string{
+		"echo hi; rm -rf /": "echo hi; rm -rf /",
+		"echo hi;":          `echo hi\;`,
+		`ends in \;`:        `ends in \\;`, // tmux reads back "ends in \;"
+		";":                 `\;`,
+		`C:\path\to\file`:   `C:\path\to\file`,
+		`trailing \`:        `trailing \`,
+		"-n is not a flag":  "-n is not a flag",
+		"Enter":             "Enter",
+		"$(whoami) `id`":    "$(whoami) `id`",
+		"héllo wörld ✓ 日本語": "héllo wörld ✓ 日本語",
+	} {
+		cmds := sendKeysCommands("%5", text, false)
+		if len(cmds) != 1 {
+			t.Fatalf("%q: got %d commands, want 1", text, len(cmds))
+		}
+		args := cmds[0].args
+		if !slices.Equal(args[:5], []string{"send-keys", "-t", "%5", "-l", "--"}) || len(args) != 6 {
+			t.Errorf("%q: args = %q, want literal send-keys ending in --", text, args)
+			continue
+		}
+		if args[5] != wantArg {
+			t.Errorf("%q: text arg = %q, want %q", text, args[5], wantArg)
+		}
+	}
+
+	// Multi-line text travels over stdin, where nothing needs escaping.
+	text := "echo hi;\nrm -rf /;"
+	if cmds := sendKeysCommands("%5", text, false); cmds[0].stdin != text {
+		t.Errorf("paste stdin = %q, want %q", cmds[0].stdin, text)
+	}
+}
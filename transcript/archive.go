@@ -0,0 +1,232 @@
+package transcript
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ArchiveExt is appended to a transcript's path when Archive compresses it.
+// Read, FindToolCall, and Stat fall back to the archived file when the
+// plain one is gone, so archived sessions stay viewable. A resumed session
+// can have both; its transcript is the archive followed by the plain file.
+const ArchiveExt = ".gz"
+
+// Stat is os.Stat for a transcript path, falling back to its archive. When
+// both exist the plain file is reported, since it holds the latest writes.
+func Stat(path string) (os.FileInfo, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if archived, aerr := os.Stat(path + ArchiveExt); aerr == nil {
+			return archived, nil
+		}
+	}
+	return info, err
+}
+
+// open opens the transcript at path, decompressing its archive as it reads.
+// With both an archive and a plain file, the archive's lines come first.
+func open(path string) (io.ReadCloser, error) {
+	plain, err := os.Open(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	archive, aerr := os.Open(path + ArchiveExt)
+	if aerr != nil {
+		if plain == nil {
+			return nil, err
+		}
+		return plain, nil
+	}
+
+	zr, zerr := gzip.NewReader(archive)
+	if zerr != nil {
+		archive.Close()
+		if plain != nil {
+			plain.Close()
+		}
+		return nil, zerr
+	}
+	gz := &gzipFile{Reader: zr, f: archive}
+	if plain == nil {
+		return gz, nil
+	}
+	return &resumedFile{Reader: io.MultiReader(gz, plain), archive: gz, plain: plain}, nil
+}
+
+// resumedFile reads an archived transcript and then the plain file a
+// resumed session went on writing.
+type resumedFile struct {
+	io.Reader
+	archive io.Closer
+	plain   *os.File
+}
+
+func (r *resumedFile) Close() error {
+	err := r.archive.Close()
+	if cerr := r.plain.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// MaxRawSize caps the size of a transcript OpenRaw will serve, counted in
+// uncompressed bytes for archived transcripts.
+const MaxRawSize = 256 << 20
+
+// ErrTooLarge is returned by OpenRaw for transcripts over MaxRawSize.
+var ErrTooLarge = errors.New("transcript too large")
+
+// ErrNotTranscript is returned by OpenRaw for paths that aren't JSONL
+// transcripts inside the Claude directory.
+var ErrNotTranscript = errors.New("not a transcript in the Claude directory")
+
+// OpenRaw opens the transcript at path, or its archive, for streaming the
+// original JSONL unparsed. Unlike Read, it hands back the file's bytes, so
+// path must name a .jsonl (or .jsonl.gz) file that resolves inside
+// claudeDir.
+func OpenRaw(claudeDir, path string) (io.ReadCloser, error) {
+	path = strings.TrimSuffix(path, ArchiveExt)
+	if !strings.HasSuffix(path, ".jsonl") {
+		return nil, ErrNotTranscript
+	}
+	for _, p := range []string{path, path + ArchiveExt} {
+		if _, err := os.Lstat(p); err != nil {
+			continue
+		}
+		if _, ok := ResolveIn(claudeDir, p); !ok {
+			return nil, ErrNotTranscript
+		}
+	}
+
+	info, err := Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	// An archive's on-disk size understates what it inflates to, so this
+	// only turns away the obvious cases; the limit below catches the rest.
+	if info.Size() > MaxRawSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrTooLarge, info.Size())
+	}
+	rc, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	return newRawReader(rc, MaxRawSize), nil
+}
+
+// rawReader reads at most limit bytes, failing with ErrTooLarge rather than
+// silently truncating a longer transcript.
+type rawReader struct {
+	r     io.Reader
+	c     io.Closer
+	n     int64
+	limit int64
+}
+
+func newRawReader(rc io.ReadCloser, limit int64) *rawReader {
+	return &rawReader{r: io.LimitReader(rc, limit+1), c: rc, limit: limit}
+}
+
+func (r *rawReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	if r.n > r.limit {
+		return n - int(r.n-r.limit), fmt.Errorf("%w: over %d bytes", ErrTooLarge, r.limit)
+	}
+	return n, err
+}
+
+func (r *rawReader) Close() error { return r.c.Close() }
+
+// gzipFile closes both the decompressor and the file beneath it.
+type gzipFile struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (g *gzipFile) Close() error {
+	err := g.Reader.Close()
+	if cerr := g.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// ErrModified is returned by Archive when the transcript was written while
+// being compressed. The original is left in place to be archived later.
+var ErrModified = errors.New("transcript modified during archiving")
+
+// Archive gzips the transcript at path to path+ArchiveExt and removes the
+// original. An existing archive, from before a session was resumed, is kept
+// at the front of the new one. The archive keeps the original's
+// modification time, so age checks and ETags stay stable. A partial archive
+// is never left behind.
+func Archive(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst := path + ArchiveExt
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".archive-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	// Gzip members concatenate, so the earlier archive is copied as is.
+	if prev, err := os.Open(dst); err == nil {
+		_, err = io.Copy(tmp, prev)
+		prev.Close()
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		tmp.Close()
+		return err
+	}
+
+	zw := gzip.NewWriter(tmp)
+	if _, err := io.Copy(zw, src); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp.Name(), info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+	// Lines appended while compressing would be lost with the original.
+	now, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if now.Size() != info.Size() || !now.ModTime().Equal(info.ModTime()) {
+		return ErrModified
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,133 @@
+package transcript
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// RenderMarkdown renders a transcript as a readable Markdown document.
+// Consecutive messages from the same role are grouped under one heading, text
+// blocks are emitted verbatim (so fenced code survives), tool calls become a
+// bullet list of their summaries, and a plan presented via ExitPlanMode is
+// included as a quoted block.
+func RenderMarkdown(t *Transcript) string {
+	var b strings.Builder
+	b.WriteString("# Transcript\n")
+
+	lastRole := ""
+	inTools := false
+	for _, msg := range t.Messages {
+		if msg.Role != lastRole {
+			b.WriteString("\n## " + roleLabel(msg.Role))
+			if !msg.Timestamp.IsZero() {
+				b.WriteString(" · " + msg.Timestamp.UTC().Format(time.DateTime) + " UTC")
+			}
+			b.WriteString("\n")
+			lastRole = msg.Role
+			inTools = false
+		}
+		for _, blk := range msg.Blocks {
+			switch blk.Type {
+			case "text":
+				b.WriteString("\n" + blk.Text + "\n")
+				inTools = false
+			case "tool_use":
+				if !inTools {
+					b.WriteString("\n")
+					inTools = true
+				}
+				b.WriteString("- `" + toolLabel(blk) + "`\n")
+				if plan := planText(blk); plan != "" {
+					b.WriteString("\n" + quote(plan) + "\n")
+					inTools = false
+				}
+			}
+		}
+	}
+	return b.String()
+}
+
+// RenderText renders a transcript as compact plain text for pasting into an
+// issue or chat. Each role change starts a paragraph prefixed with the role,
+// and tool calls appear as indented "- summary" lines. Thinking and tool
+// results never reach the parsed transcript, so they are absent here too.
+func RenderText(t *Transcript) string {
+	var b strings.Builder
+	lastRole := ""
+	for _, msg := range t.Messages {
+		for _, blk := range msg.Blocks {
+			if msg.Role != lastRole {
+				if lastRole != "" {
+					b.WriteString("\n")
+				}
+				b.WriteString(roleLabel(msg.Role) + ":")
+				lastRole = msg.Role
+				if blk.Type == "text" {
+					b.WriteString(" " + blk.Text + "\n")
+					continue
+				}
+				b.WriteString("\n")
+			}
+			switch blk.Type {
+			case "text":
+				b.WriteString(blk.Text + "\n")
+			case "tool_use":
+				label := blk.Summary
+				if label == "" {
+					label = blk.Text
+				}
+				b.WriteString("  - " + label + "\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+func roleLabel(role string) string {
+	switch role {
+	case "user":
+		return "User"
+	case "assistant":
+		return "Assistant"
+	default:
+		return role
+	}
+}
+
+// toolLabel returns a tool block's summary, falling back to the tool name
+// when the summary is empty. Backticks become quotes so the label can't break
+// out of the surrounding code span.
+func toolLabel(blk Block) string {
+	label := blk.Summary
+	if label == "" {
+		label = blk.Text
+	}
+	return strings.ReplaceAll(label, "`", "'")
+}
+
+// planText returns the plan markdown carried by an ExitPlanMode block.
+func planText(blk Block) string {
+	if blk.Text != "ExitPlanMode" || len(blk.Input) == 0 {
+		return ""
+	}
+	var parsed struct {
+		Plan string `json:"plan"`
+	}
+	if err := json.Unmarshal(blk.Input, &parsed); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(parsed.Plan)
+}
+
+func quote(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = ">"
+		} else {
+			lines[i] = "> " + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
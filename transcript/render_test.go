@@ -0,0 +1,89 @@
+package transcript
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	ts := time.Date(2026, 1, 1, 9, 30, 0, 0, time.UTC)
+	tr := &Transcript{Messages: []Message{
+		{Role: "user", Timestamp: ts, Blocks: []Block{{Type: "text", Text: "Fix the bug"}}},
+		{Role: "assistant", Timestamp: ts.Add(time.Second), Blocks: []Block{{Type: "text", Text: "Looking now."}}},
+		{Role: "assistant", Blocks: []Block{
+			{Type: "tool_use", Text: "Read", Summary: "Read src/main.go"},
+			{Type: "tool_use", Text: "Bash", Summary: "Bash: go test ./..."},
+		}},
+		{Role: "assistant", Blocks: []Block{{Type: "text", Text: "Fixed:\n\n```go\nreturn nil\n```"}}},
+	}}
+
+	want := "# Transcript\n" +
+		"\n## User · 2026-01-01 09:30:00 UTC\n" +
+		"\nFix the bug\n" +
+		"\n## Assistant · 2026-01-01 09:30:01 UTC\n" +
+		"\nLooking now.\n" +
+		"\n- `Read src/main.go`\n" +
+		"- `Bash: go test ./...`\n" +
+		"\nFixed:\n\n```go\nreturn nil\n```\n"
+	if got := RenderMarkdown(tr); got != want {
+		t.Errorf("RenderMarkdown mismatch\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRenderMarkdownPlan(t *testing.T) {
+	tr := &Transcript{Messages: []Message{
+		{Role: "assistant", Blocks: []Block{{
+			Type:    "tool_use",
+			Text:    "ExitPlanMode",
+			Summary: "ExitPlanMode",
+			Input:   json.RawMessage(`{"plan":"# Plan\n\nStep 1"}`),
+		}}},
+	}}
+	got := RenderMarkdown(tr)
+	if !strings.Contains(got, "> # Plan\n>\n> Step 1\n") {
+		t.Errorf("plan not quoted in output:\n%s", got)
+	}
+}
+
+func TestRenderMarkdownEmpty(t *testing.T) {
+	if got := RenderMarkdown(&Transcript{}); got != "# Transcript\n" {
+		t.Errorf("empty render = %q", got)
+	}
+}
+
+func TestRenderText(t *testing.T) {
+	jsonl := `{"type":"user","timestamp":"2026-01-01T00:00:00.000Z","message":{"role":"user","content":"Fix the bug"}}
+{"type":"assistant","timestamp":"2026-01-01T00:00:01.000Z","message":{"role":"assistant","content":[{"type":"thinking","thinking":"secret reasoning"},{"type":"text","text":"Looking now."}]}}
+{"type":"assistant","timestamp":"2026-01-01T00:00:02.000Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"go test ./..."}}]}}
+{"type":"user","timestamp":"2026-01-01T00:00:03.000Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"FAIL noisy output"}]}}
+{"type":"assistant","timestamp":"2026-01-01T00:00:04.000Z","message":{"role":"assistant","content":[{"type":"text","text":"Fixed it."}]}}
+{"type":"user","timestamp":"2026-01-01T00:00:05.000Z","message":{"role":"user","content":"Thanks"}}
+`
+	got := RenderText(readFromString(t, jsonl))
+	want := "User: Fix the bug\n" +
+		"\n" +
+		"Assistant: Looking now.\n" +
+		"  - Bash: go test ./...\n" +
+		"Fixed it.\n" +
+		"\n" +
+		"User: Thanks\n"
+	if got != want {
+		t.Errorf("RenderText mismatch\ngot:\n%s\nwant:\n%s", got, want)
+	}
+	for _, noise := range []string{"secret reasoning", "noisy output"} {
+		if strings.Contains(got, noise) {
+			t.Errorf("output should not contain %q", noise)
+		}
+	}
+}
+
+func TestRenderTextLeadingToolUse(t *testing.T) {
+	tr := &Transcript{Messages: []Message{
+		{Role: "assistant", Blocks: []Block{{Type: "tool_use", Text: "Read", Summary: "Read a.go"}}},
+	}}
+	if got := RenderText(tr); got != "Assistant:\n  - Read a.go\n" {
+		t.Errorf("RenderText = %q", got)
+	}
+}
@@ -2,10 +2,15 @@ package transcript
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"io"
+	"io/fs"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -16,11 +21,128 @@ type Block struct {
 	Text    string          `json:"text"`
 	Summary string          `json:"summary,omitempty"` // concise tool description
 	Input   json.RawMessage `json:"input,omitempty"`   // tool_use input (preserved for select tools)
+	// Truncated marks a text block shortened by Compact.
+	Truncated bool `json:"truncated,omitempty"`
+	// HasImageResult marks a tool_use whose result included an image, which
+	// the transcript doesn't carry, so the UI can show a placeholder.
+	HasImageResult bool `json:"has_image_result,omitempty"`
+	// IsError marks a tool_use whose result was a <tool_use_error>.
+	IsError bool `json:"is_error,omitempty"`
+	// HasResult marks a tool_use whose result is in the transcript, so the
+	// call has finished.
+	HasResult bool `json:"has_result,omitempty"`
+	// Questions is the validated form of an AskUserQuestion input, so
+	// clients needn't re-parse Input. It survives Compact.
+	Questions []Question `json:"questions,omitempty"`
+	// ToolUseID identifies a tool_use block, so clients can fetch its full
+	// input and result on demand (see FindToolCall).
+	ToolUseID string `json:"tool_use_id,omitempty"`
 
-	toolUseID string          // for linking to tool_result during post-processing
 	toolInput json.RawMessage // for summary generation
 }
 
+// Question is one prompt from an AskUserQuestion tool call.
+type Question struct {
+	Header      string           `json:"header,omitempty"`
+	Question    string           `json:"question"`
+	Options     []QuestionOption `json:"options"`
+	MultiSelect bool             `json:"multi_select"`
+}
+
+// QuestionOption is one selectable answer to a Question.
+type QuestionOption struct {
+	Label       string `json:"label"`
+	Description string `json:"description,omitempty"`
+}
+
+// Answer formats the keystrokes that pick the options at the given 0-based
+// indices in Claude Code's question prompt, which selects options by their
+// 1-based number. A single-select question takes exactly one index; a
+// multi-select question toggles each chosen option in ascending order.
+func (q Question) Answer(indices []int) (string, error) {
+	if len(indices) == 0 {
+		return "", fmt.Errorf("no option selected")
+	}
+	if !q.MultiSelect && len(indices) > 1 {
+		return "", fmt.Errorf("question allows only one option, got %d", len(indices))
+	}
+	picked := make(map[int]bool, len(indices))
+	for _, i := range indices {
+		if i < 0 || i >= len(q.Options) {
+			return "", fmt.Errorf("option %d out of range (question has %d)", i, len(q.Options))
+		}
+		if picked[i] {
+			return "", fmt.Errorf("option %d selected twice", i)
+		}
+		picked[i] = true
+	}
+	var b strings.Builder
+	for i := range q.Options {
+		if picked[i] {
+			b.WriteString(strconv.Itoa(i + 1))
+		}
+	}
+	return b.String(), nil
+}
+
+// PendingQuestions returns the questions of an unanswered AskUserQuestion
+// call that ends the transcript's last assistant turn, or nil if the agent
+// isn't waiting on one.
+func (t *Transcript) PendingQuestions() []Question {
+	for i := len(t.Messages) - 1; i >= 0; i-- {
+		msg := t.Messages[i]
+		if msg.Role != "assistant" {
+			continue
+		}
+		for j := len(msg.Blocks) - 1; j >= 0; j-- {
+			if blk := msg.Blocks[j]; blk.Type == "tool_use" {
+				if blk.HasResult {
+					return nil
+				}
+				return blk.Questions
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// parseQuestions normalizes AskUserQuestion input, dropping questions with
+// no text and options with no label. It returns nil when nothing usable
+// remains, leaving clients to fall back to the raw Input.
+func parseQuestions(input json.RawMessage) []Question {
+	var raw struct {
+		Questions []struct {
+			Header      string           `json:"header"`
+			Question    string           `json:"question"`
+			Options     []QuestionOption `json:"options"`
+			MultiSelect bool             `json:"multiSelect"`
+		} `json:"questions"`
+	}
+	if err := json.Unmarshal(input, &raw); err != nil {
+		return nil
+	}
+	var questions []Question
+	for _, q := range raw.Questions {
+		if strings.TrimSpace(q.Question) == "" {
+			continue
+		}
+		options := []QuestionOption{}
+		for _, opt := range q.Options {
+			if strings.TrimSpace(opt.Label) != "" {
+				options = append(options, opt)
+			}
+		}
+		questions = append(questions, Question{
+			Header:      q.Header,
+			Question:    q.Question,
+			Options:     options,
+			MultiSelect: q.MultiSelect,
+		})
+	}
+	return questions
+}
+
 // Message is a single user or assistant turn.
 type Message struct {
 	Role      string    `json:"role"` // "user" or "assistant"
@@ -30,15 +152,94 @@ type Message struct {
 
 // Transcript is a parsed conversation.
 type Transcript struct {
+	// Version is the JSON format version, FormatVersion for transcripts
+	// produced by this package. It changes only on breaking format changes.
+	Version  int       `json:"version"`
 	Messages []Message `json:"messages"`
+
+	// Warnings describes records that were skipped while reading.
+	Warnings []string `json:"warnings,omitempty"`
+
+	// Debug reports where the transcript was read from. Readers fill it in
+	// on request, to diagnose transcripts that come back empty.
+	Debug *Debug `json:"debug,omitempty"`
+}
+
+// FormatVersion is the current transcript JSON format version.
+const FormatVersion = 1
+
+// Empty returns a transcript with no messages.
+func Empty() *Transcript {
+	return &Transcript{Version: FormatVersion}
+}
+
+// Debug describes the file a transcript was read from.
+type Debug struct {
+	Path   string `json:"path"`
+	Exists bool   `json:"exists"`
 }
 
 // TranscriptPath returns the expected JSONL path for a given session.
 // Claude Code stores transcripts at ~/.claude/projects/{cwd-slug}/{session-id}.jsonl
 // where cwd-slug replaces all "/" with "-".
 func TranscriptPath(claudeDir, cwd, sessionID string) string {
-	slug := cwdToSlug(cwd)
-	return claudeDir + "/projects/" + slug + "/" + sessionID + ".jsonl"
+	return ExpandPathTemplate(DefaultPathTemplate, claudeDir, cwd, sessionID)
+}
+
+// DefaultPathTemplate is the transcript layout Claude Code currently uses.
+// Templates may reference {claudeDir}, {slug}, and {sessionID}.
+const DefaultPathTemplate = "{claudeDir}/projects/{slug}/{sessionID}.jsonl"
+
+// ExpandPathTemplate fills in a transcript path template for a session. An
+// empty template means DefaultPathTemplate.
+func ExpandPathTemplate(tmpl, claudeDir, cwd, sessionID string) string {
+	if tmpl == "" {
+		tmpl = DefaultPathTemplate
+	}
+	return strings.NewReplacer(
+		"{claudeDir}", claudeDir,
+		"{slug}", cwdToSlug(cwd),
+		"{sessionID}", sessionID,
+	).Replace(tmpl)
+}
+
+// ValidatePathTemplate reports whether tmpl can locate a session's
+// transcript. Without {sessionID} every session would resolve to one file.
+func ValidatePathTemplate(tmpl string) error {
+	if !strings.Contains(tmpl, "{sessionID}") {
+		return fmt.Errorf("transcript path template %q must contain {sessionID}", tmpl)
+	}
+	return nil
+}
+
+// ResolveIn resolves symlinks in path and reports whether the result lies
+// inside dir, itself resolved. A missing file is judged by its resolved
+// directory, so transcripts not yet written, or archived, still qualify.
+func ResolveIn(dir, path string) (string, bool) {
+	if dir == "" || !filepath.IsAbs(path) {
+		return "", false
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	if r, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = r
+	}
+	path = filepath.Clean(path)
+	resolved, err := filepath.EvalSymlinks(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		parent, perr := filepath.EvalSymlinks(filepath.Dir(path))
+		if perr != nil {
+			return "", false
+		}
+		resolved = filepath.Join(parent, filepath.Base(path))
+	} else if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(dir, resolved)
+	inside := err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+	return resolved, inside
 }
 
 func cwdToSlug(cwd string) string {
@@ -47,48 +248,176 @@ func cwdToSlug(cwd string) string {
 	return slug
 }
 
+// DefaultMaxLineSize is the largest JSONL record Read will parse.
+const DefaultMaxLineSize = 10 * 1024 * 1024
+
+// ReadOptions tunes ReadWithOptions.
+type ReadOptions struct {
+	// MaxLineSize caps a single record in bytes. Longer lines are skipped and
+	// noted in Transcript.Warnings; 0 means DefaultMaxLineSize.
+	MaxLineSize int
+}
+
 // Read parses Claude Code, Codex, or Antigravity JSONL into one display model.
 // The formats have distinct top-level record types, so detection is per-line
 // and requires no provider flag or filename convention.
 func Read(path string) (*Transcript, error) {
-	f, err := os.Open(path)
+	return ReadWithOptions(path, ReadOptions{})
+}
+
+// ReadWithOptions is Read with a configurable line size limit.
+func ReadWithOptions(path string, opts ReadOptions) (*Transcript, error) {
+	if opts.MaxLineSize <= 0 {
+		opts.MaxLineSize = DefaultMaxLineSize
+	}
+	f, err := open(path)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
 
 	var messages []Message
-	toolResults := map[string]string{}
-	scanner := bufio.NewScanner(f)
-	scanner.Buffer(make([]byte, 0, 1024*1024), 10*1024*1024) // up to 10MB lines
-
-	for scanner.Scan() {
-		line := scanner.Bytes()
-		collectToolResults(line, toolResults)
-		msg, ok := parseLine(line)
-		if ok {
+	var warnings []string
+	toolResults := map[string]toolResult{}
+	err = readLines(f, opts.MaxLineSize, func(lineNum int, line []byte) {
+		if line == nil {
+			warnings = append(warnings, fmt.Sprintf("line %d: skipped, exceeds %d bytes", lineNum, opts.MaxLineSize))
+			return
+		}
+		if msg, ok := parseLine(line, toolResults); ok {
 			messages = append(messages, msg)
 		}
-	}
-	if err := scanner.Err(); err != nil {
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	attachSummaries(messages, toolResults)
-	return &Transcript{Messages: messages}, nil
+	return &Transcript{Version: FormatVersion, Messages: messages, Warnings: warnings}, nil
+}
+
+// readLines calls fn for each newline-terminated line of r. Unlike
+// bufio.Scanner, an oversized line doesn't abort the read: it is discarded
+// without being buffered and fn receives a nil line for it.
+func readLines(r io.Reader, maxLine int, fn func(lineNum int, line []byte)) error {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	var buf []byte
+	tooLong := false
+	lineNum := 0
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if !tooLong {
+			buf = append(buf, chunk...)
+			// +2 leaves room for a trailing \r\n that isn't part of the record
+			tooLong = len(buf) > maxLine+2
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if len(buf) > 0 || tooLong {
+			lineNum++
+			// Drop the terminator, including the \r of CRLF-written transcripts.
+			line := bytes.TrimRight(buf, "\r\n")
+			if tooLong || len(line) > maxLine {
+				fn(lineNum, nil)
+			} else {
+				fn(lineNum, line)
+			}
+		}
+		buf = buf[:0]
+		tooLong = false
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ErrToolCallNotFound is returned by FindToolCall when no tool_use in the
+// transcript has the requested ID.
+var ErrToolCallNotFound = errors.New("tool call not found")
+
+// ToolCall is one tool invocation with its full input and result, which the
+// display model otherwise drops.
+type ToolCall struct {
+	ID             string          `json:"id"`
+	Name           string          `json:"name"`
+	Input          json.RawMessage `json:"input,omitempty"`
+	Result         string          `json:"result"`
+	IsError        bool            `json:"is_error,omitempty"`
+	HasImageResult bool            `json:"has_image_result,omitempty"`
+}
+
+// FindToolCall re-reads the transcript at path and returns the tool call
+// whose tool_use ID is id. Result is empty if the call hasn't returned yet.
+func FindToolCall(path, id string) (*ToolCall, error) {
+	f, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var call *ToolCall
+	toolResults := map[string]toolResult{}
+	err = readLines(f, DefaultMaxLineSize, func(_ int, line []byte) {
+		if line == nil {
+			return
+		}
+		msg, ok := parseLine(line, toolResults)
+		if !ok || call != nil {
+			return
+		}
+		for _, blk := range msg.Blocks {
+			if blk.Type == "tool_use" && blk.ToolUseID == id {
+				call = &ToolCall{ID: id, Name: blk.Text, Input: blk.toolInput}
+				return
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if call == nil {
+		return nil, ErrToolCallNotFound
+	}
+	if result, ok := toolResults[id]; ok {
+		call.Result = result.text
+		call.IsError = strings.Contains(result.text, "<tool_use_error>")
+		call.HasImageResult = result.hasImage
+	}
+	return call, nil
 }
 
 // SessionSummary holds extracted summary fields for a session.
 type SessionSummary struct {
 	Topic       string `json:"topic"`
 	PlanSummary string `json:"plan_summary"`
+	LastReply   string `json:"last_reply"`
+
+	MessageCount int `json:"message_count"`
+	ToolUseCount int `json:"tool_use_count"`
+	ErrorCount   int `json:"error_count"`
+
+	// PendingQuestions are the questions the agent is waiting on, if its
+	// last turn ended in AskUserQuestion.
+	PendingQuestions []Question `json:"pending_questions,omitempty"`
 }
 
 // ExtractSummary extracts a topic and plan summary from a transcript.
 // Topic is the first user message's first text block, truncated to 120 chars.
 // PlanSummary is the first non-empty line from the most recent plan's Write content.
+// LastReply is the latest assistant text, untruncated.
 func ExtractSummary(t *Transcript) SessionSummary {
-	var s SessionSummary
+	s := SessionSummary{
+		LastReply:    LastAssistantText(t),
+		MessageCount: t.MessageCount(),
+		ToolUseCount: t.ToolUseCount(),
+		ErrorCount:   CountToolErrors(t),
+
+		PendingQuestions: t.PendingQuestions(),
+	}
 
 	// Topic: first user message text
 	for _, msg := range t.Messages {
@@ -97,7 +426,7 @@ func ExtractSummary(t *Transcript) SessionSummary {
 		}
 		for _, blk := range msg.Blocks {
 			if blk.Type == "text" && blk.Text != "" {
-				s.Topic = truncate(blk.Text, 120)
+				s.Topic = truncateWords(blk.Text, 120)
 				break
 			}
 		}
@@ -147,7 +476,7 @@ func planFirstLine(input json.RawMessage) string {
 		line = strings.TrimLeft(line, "# ")
 		line = strings.TrimSpace(line)
 		if line != "" {
-			return truncate(line, 120)
+			return truncateWords(line, 120)
 		}
 	}
 	return ""
@@ -169,20 +498,111 @@ func LastAssistantText(t *Transcript) string {
 	return ""
 }
 
-// jsonlEntry is the raw structure of a JSONL line.
+// MessageCount returns the number of displayable messages.
+func (t *Transcript) MessageCount() int {
+	return len(t.Messages)
+}
+
+// ToolUseCount returns the total number of tool calls across all messages.
+func (t *Transcript) ToolUseCount() int {
+	n := 0
+	for _, msg := range t.Messages {
+		for _, blk := range msg.Blocks {
+			if blk.Type == "tool_use" {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// CountToolErrors returns the number of tool calls whose result was an error.
+func CountToolErrors(t *Transcript) int {
+	n := 0
+	for _, msg := range t.Messages {
+		for _, blk := range msg.Blocks {
+			if blk.IsError {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// CountByTool returns the number of calls per tool name.
+func (t *Transcript) CountByTool() map[string]int {
+	counts := make(map[string]int)
+	for _, msg := range t.Messages {
+		for _, blk := range msg.Blocks {
+			if blk.Type == "tool_use" {
+				counts[blk.Text]++
+			}
+		}
+	}
+	return counts
+}
+
+// Compact returns a lighter copy of the transcript for slow clients: tool_use
+// inputs are dropped and text blocks longer than maxTextLen runes are cut and
+// marked Truncated. The receiver is left unchanged.
+func (t *Transcript) Compact(maxTextLen int) *Transcript {
+	out := &Transcript{
+		Version:  FormatVersion,
+		Messages: make([]Message, len(t.Messages)),
+		Warnings: t.Warnings,
+		Debug:    t.Debug,
+	}
+	for i, msg := range t.Messages {
+		blocks := make([]Block, len(msg.Blocks))
+		for j, blk := range msg.Blocks {
+			blk.Input = nil
+			if blk.Type == "text" && maxTextLen > 0 {
+				if runes := []rune(blk.Text); len(runes) > maxTextLen {
+					blk.Text = string(runes[:maxTextLen])
+					blk.Truncated = true
+				}
+			}
+			blocks[j] = blk
+		}
+		msg.Blocks = blocks
+		out.Messages[i] = msg
+	}
+	return out
+}
+
+// jsonlEntry is the decoded form of a Claude Code JSONL line, message and
+// content included, so a record is decoded in a single json.Unmarshal.
 type jsonlEntry struct {
 	Type      string          `json:"type"`
 	Timestamp string          `json:"timestamp"`
-	Message   json.RawMessage `json:"message"`
+	Message   messageEnvelope `json:"message"`
 	IsMeta    bool            `json:"isMeta"`
 }
 
 // messageEnvelope is the message field inside a JSONL entry.
 type messageEnvelope struct {
-	Role              string          `json:"role"`
-	Content           json.RawMessage `json:"content"`
-	Model             string          `json:"model"`
-	IsApiErrorMessage bool            `json:"isApiErrorMessage"`
+	Role              string         `json:"role"`
+	Content           messageContent `json:"content"`
+	Model             string         `json:"model"`
+	IsApiErrorMessage bool           `json:"isApiErrorMessage"`
+}
+
+// messageContent is a message's content: either a plain string (text set)
+// or an array of blocks.
+type messageContent struct {
+	text   *string
+	blocks []contentBlock
+}
+
+func (c *messageContent) UnmarshalJSON(data []byte) error {
+	switch data[0] {
+	case '"':
+		c.text = new(string)
+		return json.Unmarshal(data, c.text)
+	case '[':
+		return json.Unmarshal(data, &c.blocks)
+	}
+	return nil
 }
 
 // contentBlock is a single block in the content array.
@@ -199,25 +619,36 @@ type contentBlock struct {
 // toolsWithDisplayableInput lists tool names whose Input should be preserved for display.
 // ExitPlanMode carries the full plan markdown in its input ("plan" key); that is the
 // canonical source for both the live approval view and the archived plan summary.
+// Bash keeps its full command so the UI can expand the truncated summary.
 var toolsWithDisplayableInput = map[string]bool{
 	"AskUserQuestion": true,
+	"Bash":            true,
 	"ExitPlanMode":    true,
 }
 
-func parseLine(line []byte) (Message, bool) {
+// parseLine decodes one JSONL record into a display message. Tool results
+// in the record, including those in isMeta entries, are added to results on
+// the way, so each line is decoded only once.
+func parseLine(line []byte, results map[string]toolResult) (Message, bool) {
 	var entry jsonlEntry
 	if err := json.Unmarshal(line, &entry); err != nil {
-		return Message{}, false
+		// A malformed message spoils a Claude record, but other formats
+		// decode the line their own way and only need the type.
+		var typeErr *json.UnmarshalTypeError
+		if !errors.As(err, &typeErr) || entry.Type == "user" || entry.Type == "assistant" {
+			return Message{}, false
+		}
 	}
 
+	// Only user entries carry tool results; they handle isMeta themselves.
+	if entry.Type == "user" {
+		return parseUserEntry(entry, results)
+	}
 	if entry.IsMeta {
 		return Message{}, false
 	}
 
-	// Only process "user" and "assistant" type entries
 	switch entry.Type {
-	case "user":
-		return parseUserEntry(entry)
 	case "assistant":
 		return parseAssistantEntry(entry)
 	case "response_item":
@@ -270,7 +701,7 @@ func parseCodexEntry(line []byte, timestamp string) (Message, bool) {
 		return Message{Role: "assistant", Timestamp: ts, Blocks: []Block{{
 			Type:      "tool_use",
 			Text:      entry.Payload.Name,
-			toolUseID: entry.Payload.CallID,
+			ToolUseID: entry.Payload.CallID,
 			toolInput: input,
 		}}}, true
 	default:
@@ -354,11 +785,10 @@ func stripCodexContext(text string) string {
 	return trimmed
 }
 
-func parseUserEntry(entry jsonlEntry) (Message, bool) {
-	var env messageEnvelope
-	if err := json.Unmarshal(entry.Message, &env); err != nil {
-		return Message{}, false
-	}
+// parseUserEntry records the entry's tool results in results and returns
+// its displayable text, if any. isMeta entries contribute results only.
+func parseUserEntry(entry jsonlEntry, results map[string]toolResult) (Message, bool) {
+	env := entry.Message
 	if env.Role != "user" {
 		return Message{}, false
 	}
@@ -366,10 +796,11 @@ func parseUserEntry(entry jsonlEntry) (Message, bool) {
 	ts, _ := time.Parse(time.RFC3339Nano, entry.Timestamp)
 
 	// Content can be a string or an array
-	// Try string first
-	var strContent string
-	if err := json.Unmarshal(env.Content, &strContent); err == nil {
-		strContent = stripSystemReminders(strContent)
+	if env.Content.text != nil {
+		if entry.IsMeta {
+			return Message{}, false
+		}
+		strContent := stripSystemReminders(*env.Content.text)
 		if strContent == "" {
 			return Message{}, false
 		}
@@ -380,16 +811,10 @@ func parseUserEntry(entry jsonlEntry) (Message, bool) {
 		}, true
 	}
 
-	// Try array of content blocks
-	var blocks []contentBlock
-	if err := json.Unmarshal(env.Content, &blocks); err != nil {
-		return Message{}, false
-	}
-
 	// Check if this is only tool_result blocks (automatic feedback, skip)
 	hasNonToolResult := false
 	var displayBlocks []Block
-	for _, b := range blocks {
+	for _, b := range env.Content.blocks {
 		switch b.Type {
 		case "text":
 			text := stripSystemReminders(b.Text)
@@ -398,13 +823,19 @@ func parseUserEntry(entry jsonlEntry) (Message, bool) {
 				displayBlocks = append(displayBlocks, Block{Type: "text", Text: text})
 			}
 		case "tool_result":
-			// skip — automatic feedback
+			// Automatic feedback: not displayed, but summaries need it.
+			if b.ToolUseID != "" && results != nil {
+				results[b.ToolUseID] = toolResult{
+					text:     extractResultText(b.Content),
+					hasImage: resultHasImage(b.Content),
+				}
+			}
 		default:
 			// skip unknown
 		}
 	}
 
-	if !hasNonToolResult || len(displayBlocks) == 0 {
+	if entry.IsMeta || !hasNonToolResult || len(displayBlocks) == 0 {
 		return Message{}, false
 	}
 
@@ -416,10 +847,7 @@ func parseUserEntry(entry jsonlEntry) (Message, bool) {
 }
 
 func parseAssistantEntry(entry jsonlEntry) (Message, bool) {
-	var env messageEnvelope
-	if err := json.Unmarshal(entry.Message, &env); err != nil {
-		return Message{}, false
-	}
+	env := entry.Message
 	if env.Role != "assistant" {
 		return Message{}, false
 	}
@@ -429,10 +857,7 @@ func parseAssistantEntry(entry jsonlEntry) (Message, bool) {
 
 	ts, _ := time.Parse(time.RFC3339Nano, entry.Timestamp)
 
-	var blocks []contentBlock
-	if err := json.Unmarshal(env.Content, &blocks); err != nil {
-		return Message{}, false
-	}
+	blocks := env.Content.blocks
 
 	var displayBlocks []Block
 	for _, b := range blocks {
@@ -446,12 +871,15 @@ func parseAssistantEntry(entry jsonlEntry) (Message, bool) {
 			blk := Block{
 				Type:      "tool_use",
 				Text:      b.Name,
-				toolUseID: b.ID,
+				ToolUseID: b.ID,
 				toolInput: b.Input,
 			}
 			if toolsWithDisplayableInput[b.Name] && len(b.Input) > 0 {
 				blk.Input = b.Input
 			}
+			if b.Name == "AskUserQuestion" {
+				blk.Questions = parseQuestions(b.Input)
+			}
 			displayBlocks = append(displayBlocks, blk)
 		case "thinking":
 			// skip
@@ -477,37 +905,10 @@ func stripSystemReminders(s string) string {
 	return strings.TrimSpace(systemReminderRe.ReplaceAllString(s, ""))
 }
 
-// collectToolResults extracts tool_result text from a JSONL line (including isMeta entries)
-// and adds them to the results map keyed by tool_use_id.
-func collectToolResults(line []byte, results map[string]string) {
-	var entry jsonlEntry
-	if err := json.Unmarshal(line, &entry); err != nil {
-		return
-	}
-
-	// Only user entries contain tool_result blocks
-	if entry.Type != "user" {
-		return
-	}
-
-	var env messageEnvelope
-	if err := json.Unmarshal(entry.Message, &env); err != nil {
-		return
-	}
-	if env.Role != "user" {
-		return
-	}
-
-	var blocks []contentBlock
-	if err := json.Unmarshal(env.Content, &blocks); err != nil {
-		return
-	}
-
-	for _, b := range blocks {
-		if b.Type == "tool_result" && b.ToolUseID != "" {
-			results[b.ToolUseID] = extractResultText(b.Content)
-		}
-	}
+// toolResult is what summaries need from a tool_result block.
+type toolResult struct {
+	text     string
+	hasImage bool
 }
 
 // extractResultText pulls text from a tool_result content field.
@@ -535,8 +936,24 @@ func extractResultText(content any) string {
 	return ""
 }
 
-// attachSummaries generates summary strings for tool_use blocks.
-func attachSummaries(messages []Message, toolResults map[string]string) {
+// resultHasImage reports whether a tool_result's array content includes an
+// {type:"image"} block.
+func resultHasImage(content any) bool {
+	arr, ok := content.([]any)
+	if !ok {
+		return false
+	}
+	for _, item := range arr {
+		if m, ok := item.(map[string]any); ok && m["type"] == "image" {
+			return true
+		}
+	}
+	return false
+}
+
+// attachSummaries generates summary strings for tool_use blocks and flags
+// those that have results, and whose results carried errors or images.
+func attachSummaries(messages []Message, toolResults map[string]toolResult) {
 	for i := range messages {
 		for j := range messages[i].Blocks {
 			blk := &messages[i].Blocks[j]
@@ -545,16 +962,32 @@ func attachSummaries(messages []Message, toolResults map[string]string) {
 			}
 			summary := summarizeTool(blk.Text, blk.toolInput)
 			// Check for error in result
-			if result, ok := toolResults[blk.toolUseID]; ok {
-				if strings.Contains(result, "<tool_use_error>") {
+			if result, ok := toolResults[blk.ToolUseID]; ok {
+				blk.HasResult = true
+				if strings.Contains(result.text, "<tool_use_error>") {
 					summary += " (error)"
+					blk.IsError = true
 				}
+				blk.HasImageResult = result.hasImage
 			}
 			blk.Summary = summary
 		}
 	}
 }
 
+// lineRange formats a Read tool's offset/limit as a ":start-end" suffix, starting at line 1 without an offset.
+func lineRange(offset, limit int) string {
+	switch {
+	case offset <= 0 && limit <= 0:
+		return ""
+	case limit <= 0:
+		return fmt.Sprintf(":%d-", offset)
+	case offset <= 0:
+		offset = 1
+	}
+	return fmt.Sprintf(":%d-%d", offset, offset+limit-1)
+}
+
 // summarizeTool generates a concise summary for a tool_use block based on name and input.
 func summarizeTool(name string, input json.RawMessage) string {
 	var fields map[string]json.RawMessage
@@ -571,15 +1004,24 @@ func summarizeTool(name string, input json.RawMessage) string {
 		json.Unmarshal(raw, &s) //nolint: errcheck
 		return s
 	}
+	getInt := func(key string) int {
+		var n int
+		json.Unmarshal(fields[key], &n) //nolint: errcheck
+		return n
+	}
 
 	switch name {
 	case "Read":
 		if p := getString("file_path"); p != "" {
-			return "Read " + shortenPath(p)
+			return "Read " + shortenPath(p) + lineRange(getInt("offset"), getInt("limit"))
 		}
 	case "Bash":
+		// Claude's own description ("Run tests") reads better than the raw command.
+		if desc := getString("description"); desc != "" {
+			return "Bash: " + truncateWords(desc, 50)
+		}
 		if cmd := getString("command"); cmd != "" {
-			return "Bash: " + truncate(cmd, 50)
+			return "Bash: " + truncateWords(cmd, 50)
 		}
 	case "Edit":
 		if p := getString("file_path"); p != "" {
@@ -599,11 +1041,11 @@ func summarizeTool(name string, input json.RawMessage) string {
 		}
 	case "Task":
 		if desc := getString("description"); desc != "" {
-			return "Task: " + truncate(desc, 50)
+			return "Task: " + truncateWords(desc, 50)
 		}
 	case "WebSearch":
 		if q := getString("query"); q != "" {
-			return fmt.Sprintf("WebSearch \u00ab%s\u00bb", truncate(q, 40))
+			return fmt.Sprintf("WebSearch \u00ab%s\u00bb", truncateWords(q, 40))
 		}
 	case "WebFetch":
 		if u := getString("url"); u != "" {
@@ -612,7 +1054,7 @@ func summarizeTool(name string, input json.RawMessage) string {
 	case "exec", "exec_command", "run_command":
 		for _, key := range []string{"cmd", "command", "CommandLine"} {
 			if cmd := getString(key); cmd != "" {
-				return "Run: " + truncate(cmd, 50)
+				return "Run: " + truncateWords(cmd, 50)
 			}
 		}
 	case "view_file":
@@ -630,7 +1072,7 @@ func summarizeTool(name string, input json.RawMessage) string {
 	case "search_web":
 		for _, key := range []string{"query", "Query"} {
 			if q := getString(key); q != "" {
-				return fmt.Sprintf("Search «%s»", truncate(q, 40))
+				return fmt.Sprintf("Search «%s»", truncateWords(q, 40))
 			}
 		}
 	}
@@ -641,11 +1083,15 @@ func summarizeTool(name string, input json.RawMessage) string {
 		if len(parts) == 3 {
 			toolName := parts[2]
 			// Try to find a recognizable input field
-			for _, key := range []string{"query", "id", "name", "title", "issueId", "team"} {
-				if v := getString(key); v != "" {
-					return toolName + ": " + truncate(v, 40)
+			for _, key := range mcpSummaryKeys {
+				if v := scalarString(fields[key]); v != "" {
+					return toolName + ": " + truncateWords(v, 40)
 				}
 			}
+			// Otherwise the first scalar argument is usually the subject.
+			if v := firstScalar(input); v != "" {
+				return toolName + ": " + truncateWords(v, 40)
+			}
 			return toolName
 		}
 	}
@@ -653,6 +1099,51 @@ func summarizeTool(name string, input json.RawMessage) string {
 	return name
 }
 
+// mcpSummaryKeys are MCP input fields likely to identify what a call acts on,
+// in order of preference.
+var mcpSummaryKeys = []string{
+	"query", "id", "name", "title", "issueId", "team",
+	"url", "path", "repo", "number",
+}
+
+// scalarString renders a JSON string, number, or bool as text. Objects,
+// arrays, null, and empty strings yield "".
+func scalarString(raw json.RawMessage) string {
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return ""
+	}
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64, bool:
+		return strings.TrimSpace(string(raw))
+	}
+	return ""
+}
+
+// firstScalar returns the first non-empty scalar value of a JSON object, in
+// document order.
+func firstScalar(input json.RawMessage) string {
+	dec := json.NewDecoder(bytes.NewReader(input))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return ""
+	}
+	for dec.More() {
+		if _, err := dec.Token(); err != nil { // key
+			return ""
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return ""
+		}
+		if v := scalarString(raw); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
 // shortenPath returns the last 2-3 components of a path, capped at 40 chars.
 func shortenPath(p string) string {
 	parts := strings.Split(p, "/")
@@ -670,3 +1161,22 @@ func truncate(s string, max int) string {
 	}
 	return s[:max] + "..."
 }
+
+// wordBoundaryWindow is how far truncateWords will back up to find a space.
+const wordBoundaryWindow = 15
+
+// truncateWords is truncate for prose and commands: when the cut would land
+// mid-word it backs up to the last space within wordBoundaryWindow bytes.
+// Without a nearby space it cuts at max like truncate.
+func truncateWords(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	cut := s[:max]
+	if s[max] != ' ' {
+		if i := strings.LastIndexByte(cut, ' '); i > 0 && max-i <= wordBoundaryWindow {
+			cut = cut[:i]
+		}
+	}
+	return strings.TrimRight(cut, " ") + "..."
+}
@@ -2,8 +2,12 @@ package transcript
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -34,6 +38,36 @@ func TestTranscriptPath(t *testing.T) {
 	}
 }
 
+func TestExpandPathTemplate(t *testing.T) {
+	tests := []struct {
+		name string
+		tmpl string
+		want string
+	}{
+		{"default", "", "/home/user/.claude/projects/-home-user-proj/abc-123.jsonl"},
+		{"explicit default", DefaultPathTemplate, "/home/user/.claude/projects/-home-user-proj/abc-123.jsonl"},
+		{"custom", "/mnt/transcripts/{slug}--{sessionID}.jsonl", "/mnt/transcripts/-home-user-proj--abc-123.jsonl"},
+		{"flat", "{claudeDir}/sessions/{sessionID}.jsonl", "/home/user/.claude/sessions/abc-123.jsonl"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ExpandPathTemplate(tt.tmpl, "/home/user/.claude", "/home/user/proj", "abc-123")
+			if got != tt.want {
+				t.Errorf("ExpandPathTemplate(%q) = %q, want %q", tt.tmpl, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePathTemplate(t *testing.T) {
+	if err := ValidatePathTemplate(DefaultPathTemplate); err != nil {
+		t.Errorf("default template rejected: %v", err)
+	}
+	if err := ValidatePathTemplate("{claudeDir}/projects/{slug}/latest.jsonl"); err == nil {
+		t.Error("template without {sessionID} should be rejected")
+	}
+}
+
 func TestReadFileNotFound(t *testing.T) {
 	_, err := Read("/nonexistent/file.jsonl")
 	if err == nil {
@@ -41,6 +75,132 @@ func TestReadFileNotFound(t *testing.T) {
 	}
 }
 
+func TestReadArchivedTranscript(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s1.jsonl")
+	content := `{"type":"assistant","timestamp":"2026-01-01T00:00:01.000Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"ls"}}]}}
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := os.Stat(path)
+	if err := Archive(path); err != nil {
+		t.Fatalf("Archive: %v", err)
+	}
+
+	info, err := Stat(path)
+	if err != nil || !info.ModTime().Equal(before.ModTime()) {
+		t.Errorf("Stat = %v, %v; want the archive with the original mtime", info, err)
+	}
+	tr, err := Read(path)
+	if err != nil || len(tr.Messages) != 1 {
+		t.Fatalf("Read archived: %v (%d messages)", err, len(tr.Messages))
+	}
+	if call, err := FindToolCall(path, "t1"); err != nil || call.Name != "Bash" {
+		t.Errorf("FindToolCall archived = %+v, %v", call, err)
+	}
+}
+
+func TestReadResumedArchivedTranscript(t *testing.T) {
+	user := func(text string) string {
+		return `{"type":"user","timestamp":"2026-01-01T00:00:00.000Z","message":{"role":"user","content":"` + text + `"}}` + "\n"
+	}
+	path := filepath.Join(t.TempDir(), "s1.jsonl")
+	os.WriteFile(path, []byte(user("first")), 0o644)
+	if err := Archive(path); err != nil {
+		t.Fatalf("Archive: %v", err)
+	}
+	// The session resumes, writing a fresh plain file beside the archive.
+	os.WriteFile(path, []byte(user("second")), 0o644)
+
+	texts := func() []string {
+		t.Helper()
+		tr, err := Read(path)
+		if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+		var got []string
+		for _, m := range tr.Messages {
+			got = append(got, m.Blocks[0].Text)
+		}
+		return got
+	}
+	if got := texts(); !reflect.DeepEqual(got, []string{"first", "second"}) {
+		t.Errorf("resumed transcript = %q, want archive then plain file", got)
+	}
+
+	// Archiving again keeps the earlier archive's lines.
+	if err := Archive(path); err != nil {
+		t.Fatalf("re-Archive: %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("plain file still present after re-archiving: %v", err)
+	}
+	if got := texts(); !reflect.DeepEqual(got, []string{"first", "second"}) {
+		t.Errorf("re-archived transcript = %q, want both sessions' lines", got)
+	}
+}
+
+func TestRawReaderFailsPastLimit(t *testing.T) {
+	r := newRawReader(io.NopCloser(strings.NewReader("0123456789abc")), 10)
+	got, err := io.ReadAll(r)
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("err = %v, want ErrTooLarge", err)
+	}
+	if string(got) != "0123456789" {
+		t.Errorf("read %q before failing, want the first 10 bytes", got)
+	}
+
+	r = newRawReader(io.NopCloser(strings.NewReader("0123456789")), 10)
+	if got, err := io.ReadAll(r); err != nil || string(got) != "0123456789" {
+		t.Errorf("at the limit: %q, %v; want all bytes and no error", got, err)
+	}
+}
+
+func TestReadSkipsOversizedLine(t *testing.T) {
+	user := func(text string) string {
+		return `{"type":"user","timestamp":"2026-01-01T00:00:00.000Z","message":{"role":"user","content":"` + text + `"}}` + "\n"
+	}
+	// The middle line is larger than the reader's internal buffer as well as
+	// the limit, so it must be discarded across several reads.
+	content := user("before") + user(strings.Repeat("x", 200*1024)) + user(strings.Repeat("y", 80*1024)) + user("after")
+	path := filepath.Join(t.TempDir(), "big.jsonl")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tr, err := ReadWithOptions(path, ReadOptions{MaxLineSize: 100 * 1024})
+	if err != nil {
+		t.Fatalf("ReadWithOptions: %v", err)
+	}
+	if len(tr.Messages) != 3 {
+		t.Fatalf("got %d messages, want 3", len(tr.Messages))
+	}
+	if tr.Messages[0].Blocks[0].Text != "before" || tr.Messages[2].Blocks[0].Text != "after" {
+		t.Errorf("unexpected messages around the skipped line: %q, %q",
+			tr.Messages[0].Blocks[0].Text, tr.Messages[2].Blocks[0].Text)
+	}
+	if len(tr.Messages[1].Blocks[0].Text) != 80*1024 {
+		t.Errorf("line under the limit should parse, got %d bytes", len(tr.Messages[1].Blocks[0].Text))
+	}
+	if len(tr.Warnings) != 1 || !strings.HasPrefix(tr.Warnings[0], "line 2:") {
+		t.Errorf("warnings = %v, want one for line 2", tr.Warnings)
+	}
+}
+
+func TestReadCRLF(t *testing.T) {
+	lf := `{"type":"user","timestamp":"2026-01-01T00:00:00.000Z","message":{"role":"user","content":"Fix the bug"}}
+{"type":"assistant","timestamp":"2026-01-01T00:00:01.000Z","message":{"role":"assistant","content":[{"type":"text","text":"Looking now."},{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"go test ./..."}}]}}
+{"type":"user","timestamp":"2026-01-01T00:00:02.000Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"ok"}]}}
+`
+	crlf := strings.ReplaceAll(lf, "\n", "\r\n")
+
+	want, _ := json.Marshal(readFromString(t, lf))
+	got, _ := json.Marshal(readFromString(t, crlf))
+	if string(got) != string(want) {
+		t.Errorf("CRLF transcript parsed differently\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
 func TestReadUserStringContent(t *testing.T) {
 	jsonl := `{"type":"user","timestamp":"2026-01-01T00:00:00.000Z","message":{"role":"user","content":"Hello there"}}` + "\n"
 
@@ -165,6 +325,47 @@ func TestLastAssistantTextToolUseOnly(t *testing.T) {
 	}
 }
 
+func TestTranscriptCounts(t *testing.T) {
+	tr := &Transcript{Messages: []Message{
+		{Role: "user", Blocks: []Block{{Type: "text", Text: "Fix the tests"}}},
+		{Role: "assistant", Blocks: []Block{
+			{Type: "text", Text: "Reading first."},
+			{Type: "tool_use", Text: "Read"},
+			{Type: "tool_use", Text: "Read"},
+		}},
+		{Role: "assistant", Blocks: []Block{{Type: "tool_use", Text: "Bash"}}},
+		{Role: "assistant", Blocks: []Block{
+			{Type: "tool_use", Text: "Edit"},
+			{Type: "tool_use", Text: "Bash"},
+			{Type: "text", Text: "Done."},
+		}},
+	}}
+
+	if got := tr.MessageCount(); got != 4 {
+		t.Errorf("MessageCount = %d, want 4", got)
+	}
+	if got := tr.ToolUseCount(); got != 5 {
+		t.Errorf("ToolUseCount = %d, want 5", got)
+	}
+	want := map[string]int{"Read": 2, "Bash": 2, "Edit": 1}
+	got := tr.CountByTool()
+	if len(got) != len(want) {
+		t.Errorf("CountByTool = %v, want %v", got, want)
+	}
+	for name, n := range want {
+		if got[name] != n {
+			t.Errorf("CountByTool[%s] = %d, want %d", name, got[name], n)
+		}
+	}
+}
+
+func TestTranscriptCountsEmpty(t *testing.T) {
+	tr := &Transcript{}
+	if tr.MessageCount() != 0 || tr.ToolUseCount() != 0 || len(tr.CountByTool()) != 0 {
+		t.Error("empty transcript should have zero counts")
+	}
+}
+
 func TestReadMixedConversation(t *testing.T) {
 	lines := `{"type":"file-history-snapshot","snapshot":{}}
 {"type":"progress","data":{"type":"hook_progress"}}
@@ -289,6 +490,100 @@ func TestReadAskUserQuestionPreservesInput(t *testing.T) {
 	if !ok || len(questions) != 1 {
 		t.Errorf("expected 1 question, got %v", input["questions"])
 	}
+
+	want := []Question{{
+		Header:   "Approach",
+		Question: "Which approach?",
+		Options: []QuestionOption{
+			{Label: "Option A", Description: "First option"},
+			{Label: "Option B", Description: "Second option"},
+		},
+	}}
+	if got := m.Blocks[1].Questions; !reflect.DeepEqual(got, want) {
+		t.Errorf("Questions = %+v, want %+v", got, want)
+	}
+	if got := tr.Compact(0).Messages[0].Blocks[1].Questions; !reflect.DeepEqual(got, want) {
+		t.Errorf("compacted Questions = %+v, want them kept", got)
+	}
+}
+
+func TestQuestionAnswer(t *testing.T) {
+	single := Question{Question: "Which?", Options: []QuestionOption{{Label: "A"}, {Label: "B"}, {Label: "C"}}}
+	multi := single
+	multi.MultiSelect = true
+
+	tests := []struct {
+		q       Question
+		indices []int
+		want    string
+		wantErr bool
+	}{
+		{single, []int{0}, "1", false},
+		{single, []int{2}, "3", false},
+		{single, []int{0, 1}, "", true},
+		{single, nil, "", true},
+		{single, []int{3}, "", true},
+		{single, []int{-1}, "", true},
+		{multi, []int{2, 0}, "13", false},
+		{multi, []int{1}, "2", false},
+		{multi, []int{1, 1}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := tt.q.Answer(tt.indices)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Answer(multi=%v, %v) = %q, %v; want %q, err=%v", tt.q.MultiSelect, tt.indices, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPendingQuestions(t *testing.T) {
+	ask := Block{Type: "tool_use", Text: "AskUserQuestion", Questions: []Question{{Question: "Which?"}}}
+	tr := &Transcript{Messages: []Message{
+		{Role: "user", Blocks: []Block{{Type: "text", Text: "help"}}},
+		{Role: "assistant", Blocks: []Block{{Type: "text", Text: "One question."}, ask}},
+	}}
+	if got := tr.PendingQuestions(); len(got) != 1 {
+		t.Errorf("PendingQuestions = %+v, want the trailing question", got)
+	}
+
+	// Once answered, the question's tool_result follows even before the
+	// agent says anything more.
+	tr.Messages[1].Blocks[1].HasResult = true
+	if got := tr.PendingQuestions(); got != nil {
+		t.Errorf("PendingQuestions = %+v, want nil once the question has a result", got)
+	}
+	tr.Messages[1].Blocks[1].HasResult = false
+
+	tr.Messages = append(tr.Messages, Message{Role: "assistant", Blocks: []Block{
+		{Type: "tool_use", Text: "Read"}, {Type: "text", Text: "Done."},
+	}})
+	if got := tr.PendingQuestions(); got != nil {
+		t.Errorf("PendingQuestions = %+v, want nil once the agent moved on", got)
+	}
+}
+
+func TestParseQuestions(t *testing.T) {
+	input := `{"questions":[
+		{"question":"Which files?","options":[{"label":"a.go"},{"label":""},{"label":"b.go"}],"multiSelect":true},
+		{"question":"  ","options":[{"label":"x"}]},
+		{"question":"Free text?"}
+	]}`
+	got := parseQuestions(json.RawMessage(input))
+	if len(got) != 2 {
+		t.Fatalf("got %d questions, want 2: %+v", len(got), got)
+	}
+	if !got[0].MultiSelect || len(got[0].Options) != 2 || got[0].Options[1].Label != "b.go" {
+		t.Errorf("question 0 = %+v, want multi-select with two labelled options", got[0])
+	}
+	if got[1].MultiSelect || got[1].Options == nil || len(got[1].Options) != 0 {
+		t.Errorf("question 1 = %+v, want single-select with an empty option list", got[1])
+	}
+
+	for _, bad := range []string{`not json`, `{"questions":"nope"}`, `{}`} {
+		if got := parseQuestions(json.RawMessage(bad)); got != nil {
+			t.Errorf("parseQuestions(%s) = %+v, want nil", bad, got)
+		}
+	}
 }
 
 func TestReadRegularToolUseOmitsInput(t *testing.T) {
@@ -303,6 +598,78 @@ func TestReadRegularToolUseOmitsInput(t *testing.T) {
 	}
 }
 
+func TestReadBashPreservesFullCommand(t *testing.T) {
+	cmd := "go test -count=1 -run 'TestReadBash|TestReadExitPlanMode' ./transcript/... ./server/..."
+	input, _ := json.Marshal(map[string]string{"command": cmd})
+	jsonl := `{"type":"assistant","timestamp":"2026-01-01T00:00:01.000Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"Bash","input":` + string(input) + `}]}}` + "\n"
+
+	tr := readFromString(t, jsonl)
+	if len(tr.Messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(tr.Messages))
+	}
+	blk := tr.Messages[0].Blocks[0]
+	if len(blk.Summary) >= len("Bash: "+cmd) || !strings.HasSuffix(blk.Summary, "...") {
+		t.Errorf("Summary = %q, want the command truncated", blk.Summary)
+	}
+	var got struct {
+		Command string `json:"command"`
+	}
+	if err := json.Unmarshal(blk.Input, &got); err != nil {
+		t.Fatalf("Input = %s: %v", blk.Input, err)
+	}
+	if got.Command != cmd {
+		t.Errorf("Input command = %q, want the full command", got.Command)
+	}
+}
+
+func TestFindToolCall(t *testing.T) {
+	jsonl := `{"type":"user","timestamp":"2026-01-01T00:00:00.000Z","message":{"role":"user","content":"Fix the bug"}}
+{"type":"assistant","timestamp":"2026-01-01T00:00:01.000Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"Read","input":{"file_path":"/src/main.go"}},{"type":"tool_use","id":"t2","name":"Bash","input":{"command":"go test ./..."}}]}}
+{"type":"user","timestamp":"2026-01-01T00:00:02.000Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"package main"},{"type":"tool_result","tool_use_id":"t2","content":[{"type":"text","text":"<tool_use_error>exit 1</tool_use_error>"}]}]}}
+{"type":"assistant","timestamp":"2026-01-01T00:00:03.000Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"t3","name":"Edit","input":{"file_path":"/src/main.go"}}]}}
+`
+	path := filepath.Join(t.TempDir(), "test.jsonl")
+	if err := os.WriteFile(path, []byte(jsonl), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	call, err := FindToolCall(path, "t1")
+	if err != nil {
+		t.Fatalf("FindToolCall(t1): %v", err)
+	}
+	if call.Name != "Read" || string(call.Input) != `{"file_path":"/src/main.go"}` || call.Result != "package main" || call.IsError {
+		t.Errorf("t1 = %+v", call)
+	}
+
+	call, err = FindToolCall(path, "t2")
+	if err != nil {
+		t.Fatalf("FindToolCall(t2): %v", err)
+	}
+	if call.Name != "Bash" || !call.IsError {
+		t.Errorf("t2 = %+v, want a failed Bash call", call)
+	}
+
+	// Still running: found, but without a result.
+	call, err = FindToolCall(path, "t3")
+	if err != nil || call.Result != "" {
+		t.Errorf("FindToolCall(t3) = %+v, %v; want no result yet", call, err)
+	}
+
+	if _, err := FindToolCall(path, "missing"); !errors.Is(err, ErrToolCallNotFound) {
+		t.Errorf("FindToolCall(missing) error = %v, want ErrToolCallNotFound", err)
+	}
+}
+
+func TestReadBlocksCarryToolUseID(t *testing.T) {
+	tr := readFromString(t, `{"type":"assistant","timestamp":"2026-01-01T00:00:01.000Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"toolu_01","name":"Read","input":{"file_path":"/foo"}}]}}`+"\n")
+	if got := tr.Messages[0].Blocks[0].ToolUseID; got != "toolu_01" {
+		t.Errorf("ToolUseID = %q, want toolu_01", got)
+	}
+	if got := tr.Compact(100).Messages[0].Blocks[0].ToolUseID; got != "toolu_01" {
+		t.Errorf("compact ToolUseID = %q, want it kept", got)
+	}
+}
+
 func TestReadExitPlanModePreservesPlanInput(t *testing.T) {
 	// The plan markdown lives in ExitPlanMode's own input ("plan" key). The
 	// sibling Write block (the plan file) is incidental and its input is dropped.
@@ -456,6 +823,214 @@ func TestReadMixedConversationWithNoise(t *testing.T) {
 	}
 }
 
+// mixedConversationJSONL exercises every record kind Read handles: noise
+// that is filtered out, tool results linked from isMeta and regular user
+// entries, errors and images in results, ExitPlanMode and AskUserQuestion
+// inputs, non-JSON and unknown lines, and Codex records.
+const mixedConversationJSONL = `{"type":"user","timestamp":"2026-01-01T00:00:00.000Z","isMeta":true,"message":{"role":"user","content":"<local-command-caveat>caveat</local-command-caveat>"}}
+{"type":"user","timestamp":"2026-01-01T00:00:01.000Z","message":{"role":"user","content":"Fix the bug\n<system-reminder>ctx</system-reminder>"}}
+{"type":"assistant","timestamp":"2026-01-01T00:00:02.000Z","message":{"role":"assistant","model":"<synthetic>","isApiErrorMessage":true,"content":[{"type":"text","text":"error msg"}]}}
+{"type":"assistant","timestamp":"2026-01-01T00:00:03.000Z","message":{"role":"assistant","content":[{"type":"thinking","thinking":"hmm"},{"type":"text","text":"Sure, let me look.\n<system-reminder>injected</system-reminder>"},{"type":"tool_use","id":"t1","name":"Read","input":{"file_path":"/home/user/src/main.go","offset":10,"limit":5}},{"type":"tool_use","id":"t2","name":"Bash","input":{"command":"go test ./...","description":"Run tests"}}]}}
+{"type":"user","timestamp":"2026-01-01T00:00:04.000Z","isMeta":true,"message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"package main"}]}}
+{"type":"user","timestamp":"2026-01-01T00:00:05.000Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t2","content":[{"type":"text","text":"<tool_use_error>exit 1</tool_use_error>"}]}]}}
+{"type":"assistant","timestamp":"2026-01-01T00:00:06.000Z","message":{"role":"assistant","content":[{"type":"text","text":"<system-reminder>only noise</system-reminder>"}]}}
+{"type":"assistant","timestamp":"2026-01-01T00:00:07.000Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"t3","name":"Write","input":{"file_path":"/tmp/plan.md","content":"## Plan"}}]}}
+{"type":"user","timestamp":"2026-01-01T00:00:08.000Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t3","content":"ok"},{"type":"text","text":"Also take a screenshot"}]}}
+{"type":"assistant","timestamp":"2026-01-01T00:00:09.000Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"t4","name":"ExitPlanMode","input":{"plan":"## Plan\n\nDo the thing."}}]}}
+{"type":"user","timestamp":"2026-01-01T00:00:10.000Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t4","content":[{"type":"text","text":"approved"},{"type":"image","source":{"type":"base64","data":"AAAA"}}]}]}}
+{"type":"assistant","timestamp":"2026-01-01T00:00:11.000Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"t5","name":"AskUserQuestion","input":{"questions":[{"question":"Which approach?","header":"Approach","multiSelect":false,"options":[{"label":"A","description":"first"},{"label":"B"}]}]}}]}}
+{"type":"summary","summary":"ignored","leafUuid":"x"}
+not json at all
+{"timestamp":"2026-01-01T00:00:12.000Z","type":"response_item","payload":{"type":"function_call","name":"shell","call_id":"c1","arguments":"{}","input":"{\"command\":[\"ls\"]}"}}
+{"timestamp":"2026-01-01T00:00:12.500Z","type":"response_item","message":"not an object","payload":{"type":"message","role":"assistant","content":[{"type":"output_text","text":"Listed the files."}]}}
+{"type":"assistant","timestamp":"2026-01-01T00:00:12.700Z","message":{"role":"assistant","content":"plain string content is not a display message"}}
+{"type":"assistant","timestamp":"2026-01-01T00:00:13.000Z","message":{"role":"assistant","content":[{"type":"text","text":"Done!"}]}}
+`
+
+// TestReadMixedConversationGolden pins Read's output on
+// mixedConversationJSONL. The expected JSON was produced by the former
+// two-pass reader, which decoded each line separately for tool results and
+// for messages, so the single-pass decode must match it exactly.
+func TestReadMixedConversationGolden(t *testing.T) {
+	const want = `{
+  "version": 1,
+  "messages": [
+    {
+      "role": "user",
+      "timestamp": "2026-01-01T00:00:01Z",
+      "blocks": [
+        {
+          "type": "text",
+          "text": "Fix the bug"
+        }
+      ]
+    },
+    {
+      "role": "assistant",
+      "timestamp": "2026-01-01T00:00:03Z",
+      "blocks": [
+        {
+          "type": "text",
+          "text": "Sure, let me look."
+        },
+        {
+          "type": "tool_use",
+          "text": "Read",
+          "summary": "Read user/src/main.go:10-14",
+          "has_result": true,
+          "tool_use_id": "t1"
+        },
+        {
+          "type": "tool_use",
+          "text": "Bash",
+          "summary": "Bash: Run tests (error)",
+          "input": {
+            "command": "go test ./...",
+            "description": "Run tests"
+          },
+          "is_error": true,
+          "has_result": true,
+          "tool_use_id": "t2"
+        }
+      ]
+    },
+    {
+      "role": "assistant",
+      "timestamp": "2026-01-01T00:00:07Z",
+      "blocks": [
+        {
+          "type": "tool_use",
+          "text": "Write",
+          "summary": "Write /tmp/plan.md",
+          "has_result": true,
+          "tool_use_id": "t3"
+        }
+      ]
+    },
+    {
+      "role": "user",
+      "timestamp": "2026-01-01T00:00:08Z",
+      "blocks": [
+        {
+          "type": "text",
+          "text": "Also take a screenshot"
+        }
+      ]
+    },
+    {
+      "role": "assistant",
+      "timestamp": "2026-01-01T00:00:09Z",
+      "blocks": [
+        {
+          "type": "tool_use",
+          "text": "ExitPlanMode",
+          "summary": "ExitPlanMode",
+          "input": {
+            "plan": "## Plan\n\nDo the thing."
+          },
+          "has_image_result": true,
+          "has_result": true,
+          "tool_use_id": "t4"
+        }
+      ]
+    },
+    {
+      "role": "assistant",
+      "timestamp": "2026-01-01T00:00:11Z",
+      "blocks": [
+        {
+          "type": "tool_use",
+          "text": "AskUserQuestion",
+          "summary": "AskUserQuestion",
+          "input": {
+            "questions": [
+              {
+                "question": "Which approach?",
+                "header": "Approach",
+                "multiSelect": false,
+                "options": [
+                  {
+                    "label": "A",
+                    "description": "first"
+                  },
+                  {
+                    "label": "B"
+                  }
+                ]
+              }
+            ]
+          },
+          "questions": [
+            {
+              "header": "Approach",
+              "question": "Which approach?",
+              "options": [
+                {
+                  "label": "A",
+                  "description": "first"
+                },
+                {
+                  "label": "B"
+                }
+              ],
+              "multi_select": false
+            }
+          ],
+          "tool_use_id": "t5"
+        }
+      ]
+    },
+    {
+      "role": "assistant",
+      "timestamp": "2026-01-01T00:00:12Z",
+      "blocks": [
+        {
+          "type": "tool_use",
+          "text": "shell",
+          "summary": "shell",
+          "tool_use_id": "c1"
+        }
+      ]
+    },
+    {
+      "role": "assistant",
+      "timestamp": "2026-01-01T00:00:12.5Z",
+      "blocks": [
+        {
+          "type": "text",
+          "text": "Listed the files."
+        }
+      ]
+    },
+    {
+      "role": "assistant",
+      "timestamp": "2026-01-01T00:00:13Z",
+      "blocks": [
+        {
+          "type": "text",
+          "text": "Done!"
+        }
+      ]
+    }
+  ]
+}`
+
+	got, err := json.Marshal(readFromString(t, mixedConversationJSONL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotV, wantV any
+	if err := json.Unmarshal(got, &gotV); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantV); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotV, wantV) {
+		t.Errorf("Read output changed\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
 // readFromString writes content to a temp file and reads it as a transcript.
 func readFromString(t *testing.T, content string) *Transcript {
 	t.Helper()
@@ -487,6 +1062,26 @@ func TestToolSummaryRead(t *testing.T) {
 	}
 }
 
+func TestToolSummaryReadRange(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"offset and limit", `{"file_path":"/home/user/src/main.go","offset":100,"limit":51}`, "Read user/src/main.go:100-150"},
+		{"offset only", `{"file_path":"/home/user/src/main.go","offset":100}`, "Read user/src/main.go:100-"},
+		{"limit only", `{"file_path":"/home/user/src/main.go","limit":20}`, "Read user/src/main.go:1-20"},
+		{"no range", `{"file_path":"/home/user/src/main.go"}`, "Read user/src/main.go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := summarizeTool("Read", json.RawMessage(tt.input)); got != tt.want {
+				t.Errorf("summary = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestToolSummaryBash(t *testing.T) {
 	jsonl := `{"type":"assistant","timestamp":"2026-01-01T00:00:01.000Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"go test ./..."}}]}}
 {"type":"user","timestamp":"2026-01-01T00:00:02.000Z","isMeta":true,"message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"PASS"}]}}
@@ -498,6 +1093,16 @@ func TestToolSummaryBash(t *testing.T) {
 	}
 }
 
+func TestToolSummaryBashDescription(t *testing.T) {
+	jsonl := `{"type":"assistant","timestamp":"2026-01-01T00:00:01.000Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"go test -count=1 ./...","description":"Run tests"}}]}}
+`
+	tr := readFromString(t, jsonl)
+	blk := tr.Messages[0].Blocks[0]
+	if blk.Summary != "Bash: Run tests" {
+		t.Errorf("summary = %q, want %q", blk.Summary, "Bash: Run tests")
+	}
+}
+
 func TestToolSummaryEdit(t *testing.T) {
 	jsonl := `{"type":"assistant","timestamp":"2026-01-01T00:00:01.000Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"Edit","input":{"file_path":"/home/user/src/transcript.go","old_string":"foo","new_string":"bar"}}]}}
 `
@@ -529,6 +1134,27 @@ func TestToolSummaryMCP(t *testing.T) {
 	}
 }
 
+func TestToolSummaryMCPExtraKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		tool  string
+		input string
+		want  string
+	}{
+		{"url", "mcp__fetch__fetch", `{"url":"https://example.com/docs","max_length":5000}`, "fetch: https://example.com/docs"},
+		{"number", "mcp__github__get_pull_request", `{"owner":"","number":42}`, "get_pull_request: 42"},
+		{"unusual key", "mcp__notes__lookup", `{"options":{"deep":true},"slug":"weekly-sync","limit":3}`, "lookup: weekly-sync"},
+		{"no scalars", "mcp__notes__list", `{"filter":{"tag":"x"}}`, "list"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := summarizeTool(tt.tool, json.RawMessage(tt.input)); got != tt.want {
+				t.Errorf("summary = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestToolSummaryLongCommand(t *testing.T) {
 	longCmd := "go test -v -count=1 -run TestSomethingVeryLongNameHere ./pkg/something/deeply/nested/..."
 	input, _ := json.Marshal(map[string]string{"command": longCmd})
@@ -604,6 +1230,40 @@ func TestToolSummaryLinkedFromIsMeta(t *testing.T) {
 	}
 }
 
+func TestToolResultWithImage(t *testing.T) {
+	jsonl := `{"type":"assistant","timestamp":"2026-01-01T00:00:01.000Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"Read","input":{"file_path":"/tmp/shot.png"}},{"type":"tool_use","id":"t2","name":"Bash","input":{"command":"ls"}}]}}
+{"type":"user","timestamp":"2026-01-01T00:00:02.000Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":[{"type":"image","source":{"type":"base64","media_type":"image/png","data":"iVBORw0KGgo="}}]}]}}
+{"type":"user","timestamp":"2026-01-01T00:00:03.000Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t2","content":[{"type":"text","text":"shot.png"}]}]}}
+`
+	tr := readFromString(t, jsonl)
+	blocks := tr.Messages[0].Blocks
+	if !blocks[0].HasImageResult {
+		t.Error("Read with an image result should set HasImageResult")
+	}
+	if blocks[1].HasImageResult {
+		t.Error("Bash with a text result should not set HasImageResult")
+	}
+	if !tr.Compact(100).Messages[0].Blocks[0].HasImageResult {
+		t.Error("Compact should keep HasImageResult")
+	}
+}
+
+func TestTranscriptVersion(t *testing.T) {
+	tr := readFromString(t, `{"type":"user","timestamp":"2026-01-01T00:00:00.000Z","message":{"role":"user","content":"Hi"}}
+`)
+	data, _ := json.Marshal(tr)
+	var got struct {
+		Version *int `json:"version"`
+	}
+	json.Unmarshal(data, &got)
+	if got.Version == nil || *got.Version != FormatVersion {
+		t.Errorf("version = %v in %s, want %d", got.Version, data, FormatVersion)
+	}
+	if Empty().Version != FormatVersion {
+		t.Error("Empty should carry the format version")
+	}
+}
+
 func TestShortenPath(t *testing.T) {
 	tests := []struct {
 		input string
@@ -630,6 +1290,28 @@ func TestTruncate(t *testing.T) {
 	}
 }
 
+func TestTruncateWords(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		max  int
+		want string
+	}{
+		{"fits", "go test ./...", 50, "go test ./..."},
+		{"backs up to a space", "go test -v -count=1 -run TestSomethingVeryLong ./pkg", 36, "go test -v -count=1 -run..."},
+		{"cut lands on a space", "fix the bug in the parser", 11, "fix the bug..."},
+		{"no space nearby", "go test -run TestAnExtremelyLongTestNameWithNoSpaces", 40, "go test -run TestAnExtremelyLongTestName..."},
+		{"no space at all", strings.Repeat("x", 30), 10, "xxxxxxxxxx..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateWords(tt.s, tt.max); got != tt.want {
+				t.Errorf("truncateWords(%q, %d) = %q, want %q", tt.s, tt.max, got, tt.want)
+			}
+		})
+	}
+}
+
 // --- ExtractSummary tests ---
 
 func TestExtractSummaryEmpty(t *testing.T) {
@@ -654,6 +1336,55 @@ func TestExtractSummaryTopicOnly(t *testing.T) {
 	if s.PlanSummary != "" {
 		t.Errorf("PlanSummary = %q, want empty", s.PlanSummary)
 	}
+	if s.LastReply != "I'll look into it." {
+		t.Errorf("LastReply = %q, want %q", s.LastReply, "I'll look into it.")
+	}
+}
+
+func TestExtractSummaryCounts(t *testing.T) {
+	tr := &Transcript{Messages: []Message{
+		{Role: "user", Blocks: []Block{{Type: "text", Text: "Fix it"}}},
+		{Role: "assistant", Blocks: []Block{{Type: "tool_use", Text: "Read"}, {Type: "tool_use", Text: "Edit"}}},
+		{Role: "assistant", Blocks: []Block{{Type: "text", Text: "Done."}}},
+	}}
+	s := ExtractSummary(tr)
+	if s.MessageCount != 3 || s.ToolUseCount != 2 {
+		t.Errorf("counts = %d messages, %d tool uses; want 3, 2", s.MessageCount, s.ToolUseCount)
+	}
+}
+
+func TestCountToolErrors(t *testing.T) {
+	jsonl := `{"type":"assistant","timestamp":"2026-01-01T00:00:01.000Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"ls"}},{"type":"tool_use","id":"t2","name":"Read","input":{"file_path":"/a"}},{"type":"tool_use","id":"t3","name":"Edit","input":{"file_path":"/b"}}]}}
+{"type":"user","timestamp":"2026-01-01T00:00:02.000Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"<tool_use_error>permission denied</tool_use_error>"},{"type":"tool_result","tool_use_id":"t2","content":"package main"},{"type":"tool_result","tool_use_id":"t3","content":[{"type":"text","text":"<tool_use_error>no match</tool_use_error>"}]}]}}
+`
+	tr := readFromString(t, jsonl)
+	if got := CountToolErrors(tr); got != 2 {
+		t.Errorf("CountToolErrors = %d, want 2", got)
+	}
+	if s := ExtractSummary(tr); s.ErrorCount != 2 {
+		t.Errorf("ErrorCount = %d, want 2", s.ErrorCount)
+	}
+	if got := CountToolErrors(&Transcript{}); got != 0 {
+		t.Errorf("CountToolErrors(empty) = %d, want 0", got)
+	}
+}
+
+func TestExtractSummaryPendingQuestions(t *testing.T) {
+	tr := &Transcript{Messages: []Message{
+		{Role: "user", Blocks: []Block{{Type: "text", Text: "Fix it"}}},
+		{Role: "assistant", Blocks: []Block{{Type: "tool_use", Text: "AskUserQuestion", Questions: []Question{
+			{Question: "Which approach?", Options: []QuestionOption{{Label: "A"}, {Label: "B"}}},
+		}}}},
+	}}
+	s := ExtractSummary(tr)
+	if len(s.PendingQuestions) != 1 || s.PendingQuestions[0].Question != "Which approach?" {
+		t.Errorf("PendingQuestions = %+v, want the open AskUserQuestion", s.PendingQuestions)
+	}
+
+	tr.Messages = append(tr.Messages, Message{Role: "assistant", Blocks: []Block{{Type: "text", Text: "Going with A."}}})
+	if s := ExtractSummary(tr); s.PendingQuestions != nil {
+		t.Errorf("PendingQuestions = %+v, want none once the agent moves on", s.PendingQuestions)
+	}
 }
 
 func TestExtractSummaryTopicTruncation(t *testing.T) {
@@ -737,3 +1468,63 @@ func TestToolSummaryArrayContent(t *testing.T) {
 		t.Errorf("expected error suffix for array content, got summary = %q", blk.Summary)
 	}
 }
+
+func TestCompact(t *testing.T) {
+	tr := &Transcript{Messages: []Message{
+		{Role: "user", Blocks: []Block{{Type: "text", Text: "short"}}},
+		{Role: "assistant", Blocks: []Block{
+			{Type: "text", Text: "héllo wörld, this is long"},
+			{Type: "tool_use", Text: "ExitPlanMode", Summary: "ExitPlanMode", Input: json.RawMessage(`{"plan":"big"}`)},
+		}},
+	}}
+
+	c := tr.Compact(5)
+	if got := c.Messages[0].Blocks[0]; got.Text != "short" || got.Truncated {
+		t.Errorf("short text changed: %+v", got)
+	}
+	if got := c.Messages[1].Blocks[0]; got.Text != "héllo" || !got.Truncated {
+		t.Errorf("long text = %q truncated=%v, want %q true", got.Text, got.Truncated, "héllo")
+	}
+	if got := c.Messages[1].Blocks[1]; got.Input != nil || got.Summary != "ExitPlanMode" {
+		t.Errorf("tool input not stripped: %+v", got)
+	}
+
+	// The original is untouched.
+	if tr.Messages[1].Blocks[0].Truncated || tr.Messages[1].Blocks[1].Input == nil {
+		t.Error("Compact modified its receiver")
+	}
+}
+
+// writeLargeTranscript writes a synthetic Claude Code transcript of turns
+// user/assistant/tool_result exchanges and returns its path.
+func writeLargeTranscript(tb testing.TB, turns int) string {
+	tb.Helper()
+	var b strings.Builder
+	output, _ := json.Marshal(strings.Repeat("ok  \tgithub.com/example/pkg\t0.012s\n", 60))
+	for i := range turns {
+		fmt.Fprintf(&b, `{"type":"user","timestamp":"2026-01-01T00:00:00.000Z","message":{"role":"user","content":"Step %d: fix the failing test in pkg/%d"}}`+"\n", i, i)
+		fmt.Fprintf(&b, `{"type":"assistant","timestamp":"2026-01-01T00:00:01.000Z","message":{"role":"assistant","model":"claude","content":[{"type":"thinking","thinking":"Let me look."},{"type":"text","text":"Running the tests for pkg/%d first."},{"type":"tool_use","id":"t%d","name":"Bash","input":{"command":"go test ./pkg/%d/...","description":"Run tests"}}]}}`+"\n", i, i, i)
+		fmt.Fprintf(&b, `{"type":"user","timestamp":"2026-01-01T00:00:02.000Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t%d","content":%s}]}}`+"\n", i, output)
+	}
+	path := filepath.Join(tb.TempDir(), "large.jsonl")
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+func BenchmarkRead(b *testing.B) {
+	path := writeLargeTranscript(b, 2000)
+	info, err := os.Stat(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(info.Size())
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := Read(path); err != nil {
+			b.Fatal(err)
+		}
+	}
+}